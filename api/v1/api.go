@@ -30,6 +30,7 @@ type Routes struct {
 	Tag        chi.Router // 'api/v1/tags/{tag_id:[A-Za-z0-9]+}'
 	Promotions chi.Router // 'api/v1/promotions'
 	Promotion  chi.Router // 'api/v1/promotions/{promo_code:[A-Za-z0-9]+}'
+	Webhooks   chi.Router // 'api/v1/webhooks'
 }
 
 // Init inits the API
@@ -60,6 +61,7 @@ func Init(a *app.App, r *chi.Mux) {
 	api.Routes.Tag = api.Routes.Tags.Route("/{tag_id:[A-Za-z0-9]+}", nil)
 	api.Routes.Promotions = api.Routes.API.Route("/promotions", nil)
 	api.Routes.Promotion = api.Routes.Promotions.Route("/{promo_code:[A-Za-z0-9_]+}", nil)
+	api.Routes.Webhooks = api.Routes.API.Route("/webhooks", nil)
 
 	InitUser(api)
 	InitProducts(api)
@@ -68,4 +70,5 @@ func Init(a *app.App, r *chi.Mux) {
 	InitBrands(api)
 	InitTags(api)
 	InitPromotions(api)
+	InitWebhooks(api)
 }
@@ -29,6 +29,7 @@ func InitBrands(a *API) {
 	a.Routes.Brands.Get("/", a.getBrands)
 	a.Routes.Brands.Delete("/bulk", a.deleteBrands)
 	a.Routes.Brand.Get("/", a.getBrand)
+	a.Routes.Brand.Get("/products", a.getBrandProducts)
 	a.Routes.Brand.Patch("/", a.AdminSessionRequired(a.patchBrand))
 	a.Routes.Brand.Delete("/", a.AdminSessionRequired(a.deleteBrand))
 }
@@ -75,6 +76,34 @@ func (a *API) getBrand(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, http.StatusOK, b)
 }
 
+func (a *API) getBrandProducts(w http.ResponseWriter, r *http.Request) {
+	bid, e := strconv.ParseInt(chi.URLParam(r, "brand_id"), 10, 64)
+	if e != nil {
+		respondError(w, model.NewAppErr("getBrandProducts", model.ErrInternal, locale.GetUserLocalizer("en"), msgURLParamErr, http.StatusInternalServerError, nil))
+		return
+	}
+	sort, err := model.ParseProductSort(r.URL.Query().Get("sort"))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	pages := pagination.NewFromRequest(r)
+	products, err := a.app.GetBrandProducts(bid, r.URL.Query(), sort, pages.Limit(), pages.Offset())
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	totalCount := -1
+	if len(products) > 0 {
+		totalCount = products[0].TotalCount
+	}
+	pages.SetData(products, totalCount)
+
+	respondJSON(w, http.StatusOK, pages)
+}
+
 func (a *API) getBrands(w http.ResponseWriter, r *http.Request) {
 	pages := pagination.NewFromRequest(r)
 	brands, err := a.app.GetBrands(pages.Limit(), pages.Offset())
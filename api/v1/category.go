@@ -20,6 +20,7 @@ var (
 	msgCategoryDeleteerr        = &i18n.Message{ID: "api.category.delete_category.app_error", Other: "could not delete category"}
 	msgCategoryMultipartErr     = &i18n.Message{ID: "api.category.create_category.multipart.app_error", Other: "could not decode category multipart data"}
 	msgCategoryURLParamErr      = &i18n.Message{ID: "api.category.url.params.app_error", Other: "could not parse URL params"}
+	msgCategoryMoveFromJSONErr  = &i18n.Message{ID: "api.category.move_category.app_error", Other: "could not decode category move data"}
 	msgCategoryPatchFromJSONErr = &i18n.Message{ID: "api.category.patch_product.app_error", Other: "could not decode product patch data"}
 )
 
@@ -28,8 +29,14 @@ func InitCategories(a *API) {
 	a.Routes.Categories.Post("/", a.AdminSessionRequired(a.createCategory))
 	a.Routes.Categories.Get("/", a.getCategories)
 	a.Routes.Categories.Get("/featured", a.getFeaturedCategories)
+	a.Routes.Categories.Get("/tree", a.getCategoryTree)
 	a.Routes.Categories.Delete("/bulk", a.deleteCategories)
 	a.Routes.Category.Get("/", a.getCategory)
+	a.Routes.Category.Get("/filters", a.getCategoryFilters)
+	a.Routes.Category.Get("/products", a.getCategoryProducts)
+	a.Routes.Category.Get("/tree", a.getCategorySubtree)
+	a.Routes.Category.Get("/breadcrumbs", a.getCategoryBreadcrumbs)
+	a.Routes.Category.Put("/parent", a.AdminSessionRequired(a.moveCategory))
 	a.Routes.Category.Patch("/", a.AdminSessionRequired(a.patchCategory))
 	a.Routes.Category.Delete("/", a.AdminSessionRequired(a.deleteCategory))
 }
@@ -76,6 +83,105 @@ func (a *API) getCategory(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, http.StatusOK, c)
 }
 
+func (a *API) getCategoryFilters(w http.ResponseWriter, r *http.Request) {
+	cid, e := strconv.ParseInt(chi.URLParam(r, "category_id"), 10, 64)
+	if e != nil {
+		respondError(w, model.NewAppErr("getCategoryFilters", model.ErrInternal, locale.GetUserLocalizer("en"), msgURLParamErr, http.StatusInternalServerError, nil))
+		return
+	}
+	filters, err := a.app.GetCategoryFilters(cid)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, filters)
+}
+
+func (a *API) getCategoryTree(w http.ResponseWriter, r *http.Request) {
+	tree, err := a.app.GetCategoryTree()
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, tree)
+}
+
+func (a *API) getCategorySubtree(w http.ResponseWriter, r *http.Request) {
+	cid, e := strconv.ParseInt(chi.URLParam(r, "category_id"), 10, 64)
+	if e != nil {
+		respondError(w, model.NewAppErr("getCategorySubtree", model.ErrInternal, locale.GetUserLocalizer("en"), msgURLParamErr, http.StatusInternalServerError, nil))
+		return
+	}
+	tree, err := a.app.GetCategorySubtree(cid)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, tree)
+}
+
+func (a *API) getCategoryBreadcrumbs(w http.ResponseWriter, r *http.Request) {
+	cid, e := strconv.ParseInt(chi.URLParam(r, "category_id"), 10, 64)
+	if e != nil {
+		respondError(w, model.NewAppErr("getCategoryBreadcrumbs", model.ErrInternal, locale.GetUserLocalizer("en"), msgURLParamErr, http.StatusInternalServerError, nil))
+		return
+	}
+	breadcrumbs, err := a.app.GetCategoryBreadcrumbs(cid)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, breadcrumbs)
+}
+
+func (a *API) moveCategory(w http.ResponseWriter, r *http.Request) {
+	cid, e := strconv.ParseInt(chi.URLParam(r, "category_id"), 10, 64)
+	if e != nil {
+		respondError(w, model.NewAppErr("moveCategory", model.ErrInternal, locale.GetUserLocalizer("en"), msgURLParamErr, http.StatusInternalServerError, nil))
+		return
+	}
+	move, e := model.CategoryMoveFromJSON(r.Body)
+	if e != nil || move == nil {
+		respondError(w, model.NewAppErr("moveCategory", model.ErrInternal, locale.GetUserLocalizer("en"), msgCategoryMoveFromJSONErr, http.StatusInternalServerError, nil))
+		return
+	}
+
+	c, err := a.app.MoveCategory(cid, move)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, c)
+}
+
+func (a *API) getCategoryProducts(w http.ResponseWriter, r *http.Request) {
+	cid, e := strconv.ParseInt(chi.URLParam(r, "category_id"), 10, 64)
+	if e != nil {
+		respondError(w, model.NewAppErr("getCategoryProducts", model.ErrInternal, locale.GetUserLocalizer("en"), msgURLParamErr, http.StatusInternalServerError, nil))
+		return
+	}
+	sort, err := model.ParseProductSort(r.URL.Query().Get("sort"))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	pages := pagination.NewFromRequest(r)
+	products, err := a.app.GetCategoryProducts(cid, r.URL.Query(), sort, pages.Limit(), pages.Offset())
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	totalCount := -1
+	if len(products) > 0 {
+		totalCount = products[0].TotalCount
+	}
+	pages.SetData(products, totalCount)
+
+	respondJSON(w, http.StatusOK, pages)
+}
+
 func (a *API) getCategories(w http.ResponseWriter, r *http.Request) {
 	pages := pagination.NewFromRequest(r)
 	categories, err := a.app.GetCategories(pages.Limit(), pages.Offset())
@@ -0,0 +1,122 @@
+package apiv1
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/dankobgd/ecommerce-shop/app"
+	"github.com/dankobgd/ecommerce-shop/model"
+	"github.com/dankobgd/ecommerce-shop/utils/locale"
+	"github.com/dankobgd/ecommerce-shop/zlog"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+var (
+	msgIdempotencyKeyLength = &i18n.Message{ID: "api.idempotent.key_length.app_error", Other: "idempotency key is too long"}
+	msgIdempotencyBody      = &i18n.Message{ID: "api.idempotent.body.app_error", Other: "could not read the request body"}
+)
+
+const (
+	headerIdempotencyKey      = "Idempotency-Key"
+	headerIdempotentReplayed  = "Idempotent-Replayed"
+	idempotencyMaxRequestBody = 1 << 20
+)
+
+// responseRecorder captures the response so it can be stored for the replays
+type responseRecorder struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (rr *responseRecorder) WriteHeader(code int) {
+	rr.statusCode = code
+	rr.ResponseWriter.WriteHeader(code)
+}
+
+func (rr *responseRecorder) Write(b []byte) (int, error) {
+	if rr.statusCode == 0 {
+		rr.statusCode = http.StatusOK
+	}
+	rr.body.Write(b)
+	return rr.ResponseWriter.Write(b)
+}
+
+// Idempotent honours the Idempotency-Key header, the completed response is replayed for the retried request
+// the keys are scoped per user, so it has to be used inside of SessionRequired or AdminSessionRequired
+func (a *API) Idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(headerIdempotencyKey)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > model.IdempotencyKeyMaxLength {
+			respondError(w, model.NewAppErr("Idempotent", model.ErrInvalid, locale.GetUserLocalizer("en"), msgIdempotencyKeyLength, http.StatusBadRequest, nil))
+			return
+		}
+
+		body, e := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, idempotencyMaxRequestBody))
+		if e != nil {
+			respondError(w, model.NewAppErr("Idempotent", model.ErrInvalid, locale.GetUserLocalizer("en"), msgIdempotencyBody, http.StatusBadRequest, nil))
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		uid := a.app.GetUserIDFromContext(r.Context())
+		scopedKey := fmt.Sprintf("%d:%s", uid, key)
+		fingerprint := model.RequestFingerprint(r.Method, r.URL.Path, body)
+
+		rec, err := a.app.BeginIdempotentRequest(scopedKey, fingerprint)
+		if err != nil {
+			respondError(w, err)
+			return
+		}
+		if rec != nil {
+			w.Header().Set("Content-Type", rec.ContentType)
+			w.Header().Set(headerIdempotentReplayed, "true")
+			w.WriteHeader(rec.StatusCode)
+			w.Write(rec.Body)
+			return
+		}
+
+		scope := model.NewIdempotencyScope(scopedKey)
+		r = r.WithContext(context.WithValue(r.Context(), app.IdempotencyScopeCtxKey, scope))
+
+		handled := false
+		defer func() {
+			// the server errors and panics release the key so the request can be retried,
+			// unless the payment provider was called, then the key stays locked until it expires
+			if handled {
+				return
+			}
+			if scope.PaymentAttempted() {
+				if err := a.app.HoldIdempotencyKey(scopedKey, fingerprint); err != nil {
+					a.app.Log().Error(err.Error(), zlog.Err(err))
+				}
+				return
+			}
+			if err := a.app.ReleaseIdempotencyKey(scopedKey); err != nil {
+				a.app.Log().Error(err.Error(), zlog.Err(err))
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+
+		if recorder.statusCode == 0 || (recorder.statusCode >= http.StatusInternalServerError && !scope.PaymentAttempted()) {
+			return
+		}
+
+		// if the response can't be stored the key stays locked until it expires, retrying could repeat the side effects
+		handled = true
+		rec = model.NewIdempotencyRecord(fingerprint)
+		rec.Complete(recorder.statusCode, recorder.Header().Get("Content-Type"), recorder.body.Bytes())
+		if err := a.app.CompleteIdempotentRequest(scopedKey, rec); err != nil {
+			a.app.Log().Error(err.Error(), zlog.Err(err))
+		}
+	})
+}
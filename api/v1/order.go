@@ -15,17 +15,26 @@ import (
 )
 
 var (
-	msgOrderItemsDataFromJSON = &i18n.Message{ID: "api.order.create_order.json.app_error", Other: "could not parse order item json data"}
+	msgOrderItemsDataFromJSON    = &i18n.Message{ID: "api.order.create_order.json.app_error", Other: "could not parse order item json data"}
+	msgOrderStatusChangeFromJSON = &i18n.Message{ID: "api.order.change_order_status.json.app_error", Other: "could not parse order status json data"}
+	msgQuoteRequestFromJSON      = &i18n.Message{ID: "api.order.quote_order.json.app_error", Other: "could not parse quote json data"}
+	msgRefundRequestFromJSON     = &i18n.Message{ID: "api.order.refund_order.json.app_error", Other: "could not parse refund json data"}
 )
 
 // InitOrder inits the order routes
 func InitOrder(a *API) {
-	a.Routes.Orders.Post("/", a.SessionRequired(a.createOrder))
+	a.Routes.Orders.Post("/", a.SessionRequired(a.Idempotent(a.createOrder)))
 	a.Routes.Orders.Get("/", a.SessionRequired(a.getOrders))
+	a.Routes.Orders.Post("/quote", a.SessionRequired(a.quoteOrder))
 
 	a.Routes.Order.Get("/", a.SessionRequired(a.getOrder))
 	a.Routes.Order.Get("/details", a.SessionRequired(a.getOrderDetails))
 	a.Routes.Order.Get("/details/pdf", a.SessionRequired(a.getOrderDetailsPDF))
+	a.Routes.Order.Post("/confirm", a.SessionRequired(a.confirmOrder))
+	a.Routes.Order.Post("/status", a.AdminSessionRequired(a.changeOrderStatus))
+	a.Routes.Order.Get("/status/history", a.AdminSessionRequired(a.getOrderStatusHistory))
+	a.Routes.Order.Post("/refunds", a.AdminSessionRequired(a.Idempotent(a.refundOrder)))
+	a.Routes.Order.Get("/refunds", a.AdminSessionRequired(a.getOrderRefunds))
 }
 
 func (a *API) createOrder(w http.ResponseWriter, r *http.Request) {
@@ -36,13 +45,59 @@ func (a *API) createOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	order, err := a.app.CreateOrder(uid, orderData)
+	result, err := a.app.CreateOrder(uid, orderData, a.app.GetIdempotencyScopeFromContext(r.Context()))
 	if err != nil {
 		respondError(w, err)
 		return
 	}
 
-	respondJSON(w, http.StatusCreated, order)
+	// the client has to authenticate the payment and then confirm the order
+	if result.RequiresAction {
+		respondJSON(w, http.StatusAccepted, result)
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, result.Order)
+}
+
+func (a *API) quoteOrder(w http.ResponseWriter, r *http.Request) {
+	uid := a.app.GetUserIDFromContext(r.Context())
+	quoteData, e := model.QuoteRequestDataFromJSON(r.Body)
+	if e != nil || quoteData == nil {
+		respondError(w, model.NewAppErr("quoteOrder", model.ErrInternal, locale.GetUserLocalizer("en"), msgQuoteRequestFromJSON, http.StatusInternalServerError, nil))
+		return
+	}
+	crossSells, _ := strconv.ParseBool(r.URL.Query().Get("cross_sells"))
+
+	quote, err := a.app.QuoteCart(uid, quoteData, crossSells)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, quote)
+}
+
+func (a *API) confirmOrder(w http.ResponseWriter, r *http.Request) {
+	uid := a.app.GetUserIDFromContext(r.Context())
+	oid, e := strconv.ParseInt(chi.URLParam(r, "order_id"), 10, 64)
+	if e != nil {
+		respondError(w, model.NewAppErr("confirmOrder", model.ErrInternal, locale.GetUserLocalizer("en"), msgURLParamErr, http.StatusInternalServerError, nil))
+		return
+	}
+
+	result, err := a.app.ConfirmOrder(uid, oid)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	if result.RequiresAction {
+		respondJSON(w, http.StatusAccepted, result)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, result.Order)
 }
 
 func (a *API) getOrders(w http.ResponseWriter, r *http.Request) {
@@ -127,3 +182,77 @@ func (a *API) getOrderDetailsPDF(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
 	io.Copy(w, bytes.NewReader(pdf.Bytes()))
 }
+
+func (a *API) changeOrderStatus(w http.ResponseWriter, r *http.Request) {
+	uid := a.app.GetUserIDFromContext(r.Context())
+	oid, e := strconv.ParseInt(chi.URLParam(r, "order_id"), 10, 64)
+	if e != nil {
+		respondError(w, model.NewAppErr("changeOrderStatus", model.ErrInternal, locale.GetUserLocalizer("en"), msgURLParamErr, http.StatusInternalServerError, nil))
+		return
+	}
+
+	sc, e := model.OrderStatusChangeFromJSON(r.Body)
+	if e != nil || sc == nil {
+		respondError(w, model.NewAppErr("changeOrderStatus", model.ErrBadRequest, locale.GetUserLocalizer("en"), msgOrderStatusChangeFromJSON, http.StatusBadRequest, nil))
+		return
+	}
+
+	order, err := a.app.ChangeOrderStatus(oid, sc, uid)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, order)
+}
+
+func (a *API) getOrderStatusHistory(w http.ResponseWriter, r *http.Request) {
+	oid, e := strconv.ParseInt(chi.URLParam(r, "order_id"), 10, 64)
+	if e != nil {
+		respondError(w, model.NewAppErr("getOrderStatusHistory", model.ErrInternal, locale.GetUserLocalizer("en"), msgURLParamErr, http.StatusInternalServerError, nil))
+		return
+	}
+
+	history, err := a.app.GetOrderStatusHistory(oid)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, history)
+}
+
+func (a *API) refundOrder(w http.ResponseWriter, r *http.Request) {
+	uid := a.app.GetUserIDFromContext(r.Context())
+	oid, e := strconv.ParseInt(chi.URLParam(r, "order_id"), 10, 64)
+	if e != nil {
+		respondError(w, model.NewAppErr("refundOrder", model.ErrInternal, locale.GetUserLocalizer("en"), msgURLParamErr, http.StatusInternalServerError, nil))
+		return
+	}
+
+	rr, e := model.RefundRequestFromJSON(r.Body)
+	if e != nil {
+		respondError(w, model.NewAppErr("refundOrder", model.ErrInternal, locale.GetUserLocalizer("en"), msgRefundRequestFromJSON, http.StatusInternalServerError, nil))
+		return
+	}
+
+	refund, err := a.app.RefundOrder(oid, rr, uid, a.app.GetIdempotencyScopeFromContext(r.Context()))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusCreated, refund)
+}
+
+func (a *API) getOrderRefunds(w http.ResponseWriter, r *http.Request) {
+	oid, e := strconv.ParseInt(chi.URLParam(r, "order_id"), 10, 64)
+	if e != nil {
+		respondError(w, model.NewAppErr("getOrderRefunds", model.ErrInternal, locale.GetUserLocalizer("en"), msgURLParamErr, http.StatusInternalServerError, nil))
+		return
+	}
+
+	refunds, err := a.app.GetOrderRefunds(oid)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, refunds)
+}
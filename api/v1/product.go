@@ -22,6 +22,7 @@ var (
 	msgDiscountFromJSON       = &i18n.Message{ID: "api.product.create_product_discount.app_error", Other: "could not parse discount pricing from json"}
 	msgReviewFromJSON         = &i18n.Message{ID: "api.product.create_product_review.app_error", Other: "could not parse product review from json"}
 	msgReviewURLParamErr      = &i18n.Message{ID: "api.product.create_product_review.app_error", Other: "invalid product review url param"}
+	msgStockAdjustFromJSON    = &i18n.Message{ID: "api.product.adjust_product_stock.app_error", Other: "could not decode stock adjustment data"}
 	msgReviewPatchFromJSONErr = &i18n.Message{ID: "api.product.patch_product_review.app_error", Other: "could not decode product review patch data"}
 )
 
@@ -33,6 +34,7 @@ func InitProducts(a *API) {
 	a.Routes.Products.Get("/sold", a.getMostSoldProducts)
 	a.Routes.Products.Get("/deals", a.getBestDealsProducts)
 	a.Routes.Products.Get("/search", a.searchProducts)
+	a.Routes.Products.Get("/search/suggest", a.suggestSearch)
 	a.Routes.Products.Delete("/bulk", a.deleteProducts)
 
 	a.Routes.Product.Get("/", a.getProduct)
@@ -51,6 +53,16 @@ func InitProducts(a *API) {
 	a.Routes.Product.Delete("/tags/{tag_id:[A-Za-z0-9]+}", a.AdminSessionRequired(a.deleteProductTag))
 	a.Routes.Product.Delete("/tags/bulk", a.AdminSessionRequired(a.deleteProductTags))
 
+	// product relations
+	a.Routes.Product.Get("/relations", a.getProductRelations)
+	a.Routes.Product.Put("/relations/{relation_type:[a-z_]+}", a.AdminSessionRequired(a.replaceProductRelations))
+	a.Routes.Product.Get("/recommendations", a.getProductRecommendations)
+
+	// product bundle
+	a.Routes.Product.Get("/bundle", a.getProductBundle)
+	a.Routes.Product.Put("/bundle", a.AdminSessionRequired(a.saveProductBundle))
+	a.Routes.Product.Delete("/bundle", a.AdminSessionRequired(a.deleteProductBundle))
+
 	// product images
 	a.Routes.Product.Post("/images/bulk", a.AdminSessionRequired(a.createProductImages))
 	a.Routes.Product.Post("/images", a.AdminSessionRequired(a.createProductImage))
@@ -66,6 +78,21 @@ func InitProducts(a *API) {
 	a.Routes.Product.Patch("/reviews/{review_id:[A-Za-z0-9]+}", a.SessionRequired(a.patchProductReview))
 	a.Routes.Product.Delete("/reviews/{review_id:[A-Za-z0-9]+}", a.SessionRequired(a.deleteProductReview))
 	a.Routes.Product.Delete("/reviews/bulk", a.AdminSessionRequired(a.deleteProductReviews))
+
+	// product variants
+	a.Routes.Product.Get("/options", a.getProductOptions)
+	a.Routes.Product.Put("/options", a.AdminSessionRequired(a.replaceProductOptions))
+	a.Routes.Product.Post("/variants", a.AdminSessionRequired(a.createProductVariant))
+	a.Routes.Product.Get("/variants", a.getProductVariants)
+	a.Routes.Product.Get("/variants/{variant_id:[A-Za-z0-9]+}", a.getProductVariant)
+	a.Routes.Product.Patch("/variants/{variant_id:[A-Za-z0-9]+}", a.AdminSessionRequired(a.patchProductVariant))
+	a.Routes.Product.Delete("/variants/{variant_id:[A-Za-z0-9]+}", a.AdminSessionRequired(a.deleteProductVariant))
+	a.Routes.Product.Post("/variants/{variant_id:[A-Za-z0-9]+}/images", a.AdminSessionRequired(a.createProductVariantImage))
+
+	// product stock
+	a.Routes.Product.Get("/stock", a.AdminSessionRequired(a.getProductStock))
+	a.Routes.Product.Post("/stock", a.AdminSessionRequired(a.adjustProductStock))
+	a.Routes.Product.Get("/stock/movements", a.AdminSessionRequired(a.getProductStockMovements))
 }
 
 func (a *API) createProduct(w http.ResponseWriter, r *http.Request) {
@@ -180,10 +207,28 @@ func (a *API) getProduct(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, http.StatusOK, p)
 }
 
+// productListing is the paginated product listing with its facets
+type productListing struct {
+	*pagination.Pagination
+	Facets *model.ProductFacets `json:"facets,omitempty"`
+	Fuzzy  bool                 `json:"fuzzy,omitempty"`
+}
+
+// wantsFacets returns true if the listing request asks for the facets with the facets query param
+func wantsFacets(r *http.Request) bool {
+	facets, _ := strconv.ParseBool(r.URL.Query().Get("facets"))
+	return facets
+}
+
 func (a *API) getProducts(w http.ResponseWriter, r *http.Request) {
 	filters := r.URL.Query()
+	sort, err := model.ParseProductSort(filters.Get("sort"))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
 	pages := pagination.NewFromRequest(r)
-	products, err := a.app.GetProducts(filters, pages.Limit(), pages.Offset())
+	products, err := a.app.GetProducts(filters, sort, pages.Limit(), pages.Offset())
 	if err != nil {
 		respondError(w, err)
 		return
@@ -195,7 +240,17 @@ func (a *API) getProducts(w http.ResponseWriter, r *http.Request) {
 	}
 	pages.SetData(products, totalCount)
 
-	respondJSON(w, http.StatusOK, pages)
+	if !wantsFacets(r) {
+		respondJSON(w, http.StatusOK, pages)
+		return
+	}
+
+	facets, err := a.app.GetProductFacets(filters, nil)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, &productListing{Pagination: pages, Facets: facets})
 }
 
 func (a *API) getProductLatestPricing(w http.ResponseWriter, r *http.Request) {
@@ -205,7 +260,17 @@ func (a *API) getProductLatestPricing(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	pp, err := a.app.GetProductLatestPricing(pid)
+	var variantID *int64
+	if v := r.URL.Query().Get("variant_id"); v != "" {
+		vid, e := strconv.ParseInt(v, 10, 64)
+		if e != nil {
+			respondError(w, model.NewAppErr("getProductLatestPricing", model.ErrInternal, locale.GetUserLocalizer("en"), msgURLParamErr, http.StatusInternalServerError, nil))
+			return
+		}
+		variantID = &vid
+	}
+
+	pp, err := a.app.GetProductLatestPricing(pid, variantID)
 	if err != nil {
 		respondError(w, err)
 		return
@@ -616,8 +681,13 @@ func (a *API) deleteProductImages(w http.ResponseWriter, r *http.Request) {
 }
 
 func (a *API) getFeaturedProducts(w http.ResponseWriter, r *http.Request) {
+	sort, err := model.ParseProductSort(r.URL.Query().Get("sort"))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
 	pages := pagination.NewFromRequest(r)
-	featured, err := a.app.GetFeaturedProducts(pages.Limit(), pages.Offset())
+	featured, err := a.app.GetFeaturedProducts(sort, pages.Limit(), pages.Offset())
 	if err != nil {
 		respondError(w, err)
 		return
@@ -667,15 +737,48 @@ func (a *API) getBestDealsProducts(w http.ResponseWriter, r *http.Request) {
 }
 
 func (a *API) searchProducts(w http.ResponseWriter, r *http.Request) {
-	query := r.URL.Query().Get("q")
+	search, err := model.ParseSearchQuery(r.URL.Query().Get("q"))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	sort, err := model.ParseProductSort(r.URL.Query().Get("sort"))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
 
-	searchResults, err := a.app.SearchProducts(query)
+	pages := pagination.NewFromRequest(r)
+	searchResults, err := a.app.SearchProducts(search, r.URL.Query(), sort, pages.Limit(), pages.Offset())
 	if err != nil {
 		respondError(w, err)
 		return
 	}
 
-	respondJSON(w, http.StatusOK, searchResults)
+	totalCount := -1
+	if len(searchResults) > 0 {
+		totalCount = searchResults[0].TotalCount
+	}
+	pages.SetData(searchResults, totalCount)
+
+	listing := &productListing{Pagination: pages, Fuzzy: search.Fuzzy}
+	if wantsFacets(r) {
+		if listing.Facets, err = a.app.GetProductFacets(r.URL.Query(), search); err != nil {
+			respondError(w, err)
+			return
+		}
+	}
+	respondJSON(w, http.StatusOK, listing)
+}
+
+func (a *API) suggestSearch(w http.ResponseWriter, r *http.Request) {
+	suggestions, err := a.app.SuggestSearch(r.URL.Query().Get("q"))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, suggestions)
 }
 
 func (a *API) deleteProducts(w http.ResponseWriter, r *http.Request) {
@@ -688,3 +791,65 @@ func (a *API) deleteProducts(w http.ResponseWriter, r *http.Request) {
 
 	respondOK(w)
 }
+
+func (a *API) getProductStock(w http.ResponseWriter, r *http.Request) {
+	pid, e := strconv.ParseInt(chi.URLParam(r, "product_id"), 10, 64)
+	if e != nil {
+		respondError(w, model.NewAppErr("getProductStock", model.ErrInternal, locale.GetUserLocalizer("en"), msgURLParamErr, http.StatusInternalServerError, nil))
+		return
+	}
+
+	stock, err := a.app.GetProductStock(pid)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, stock)
+}
+
+func (a *API) adjustProductStock(w http.ResponseWriter, r *http.Request) {
+	uid := a.app.GetUserIDFromContext(r.Context())
+	pid, e := strconv.ParseInt(chi.URLParam(r, "product_id"), 10, 64)
+	if e != nil {
+		respondError(w, model.NewAppErr("adjustProductStock", model.ErrInternal, locale.GetUserLocalizer("en"), msgURLParamErr, http.StatusInternalServerError, nil))
+		return
+	}
+
+	sa, e := model.StockAdjustmentFromJSON(r.Body)
+	if e != nil {
+		respondError(w, model.NewAppErr("adjustProductStock", model.ErrInternal, locale.GetUserLocalizer("en"), msgStockAdjustFromJSON, http.StatusInternalServerError, nil))
+		return
+	}
+
+	stock, err := a.app.AdjustProductStock(pid, sa, uid)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, stock)
+}
+
+func (a *API) getProductStockMovements(w http.ResponseWriter, r *http.Request) {
+	pid, e := strconv.ParseInt(chi.URLParam(r, "product_id"), 10, 64)
+	if e != nil {
+		respondError(w, model.NewAppErr("getProductStockMovements", model.ErrInternal, locale.GetUserLocalizer("en"), msgURLParamErr, http.StatusInternalServerError, nil))
+		return
+	}
+
+	pages := pagination.NewFromRequest(r)
+	movements, err := a.app.GetProductStockMovements(pid, pages.Limit(), pages.Offset())
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	totalCount := -1
+	if len(movements) > 0 {
+		totalCount = movements[0].TotalCount
+	}
+	pages.SetData(movements, totalCount)
+
+	respondJSON(w, http.StatusOK, pages)
+}
@@ -0,0 +1,66 @@
+package apiv1
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/dankobgd/ecommerce-shop/model"
+	"github.com/dankobgd/ecommerce-shop/utils/locale"
+	"github.com/go-chi/chi"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+var msgBundleFromJSON = &i18n.Message{ID: "api.product_bundle.save_product_bundle.app_error", Other: "could not decode product bundle data"}
+
+func (a *API) getProductBundle(w http.ResponseWriter, r *http.Request) {
+	pid, e := strconv.ParseInt(chi.URLParam(r, "product_id"), 10, 64)
+	if e != nil {
+		respondError(w, model.NewAppErr("getProductBundle", model.ErrInternal, locale.GetUserLocalizer("en"), msgURLParamErr, http.StatusInternalServerError, nil))
+		return
+	}
+
+	bundle, err := a.app.GetProductBundle(pid)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, bundle)
+}
+
+func (a *API) saveProductBundle(w http.ResponseWriter, r *http.Request) {
+	pid, e := strconv.ParseInt(chi.URLParam(r, "product_id"), 10, 64)
+	if e != nil {
+		respondError(w, model.NewAppErr("saveProductBundle", model.ErrInternal, locale.GetUserLocalizer("en"), msgURLParamErr, http.StatusInternalServerError, nil))
+		return
+	}
+
+	b, e := model.ProductBundleFromJSON(r.Body)
+	if e != nil || b == nil {
+		respondError(w, model.NewAppErr("saveProductBundle", model.ErrInternal, locale.GetUserLocalizer("en"), msgBundleFromJSON, http.StatusInternalServerError, nil))
+		return
+	}
+
+	bundle, err := a.app.SaveProductBundle(pid, b)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, bundle)
+}
+
+func (a *API) deleteProductBundle(w http.ResponseWriter, r *http.Request) {
+	pid, e := strconv.ParseInt(chi.URLParam(r, "product_id"), 10, 64)
+	if e != nil {
+		respondError(w, model.NewAppErr("deleteProductBundle", model.ErrInternal, locale.GetUserLocalizer("en"), msgURLParamErr, http.StatusInternalServerError, nil))
+		return
+	}
+
+	if err := a.app.DeleteProductBundle(pid); err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respondOK(w)
+}
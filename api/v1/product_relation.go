@@ -0,0 +1,75 @@
+package apiv1
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/dankobgd/ecommerce-shop/model"
+	"github.com/dankobgd/ecommerce-shop/utils/locale"
+	"github.com/go-chi/chi"
+)
+
+func (a *API) getProductRelations(w http.ResponseWriter, r *http.Request) {
+	pid, e := strconv.ParseInt(chi.URLParam(r, "product_id"), 10, 64)
+	if e != nil {
+		respondError(w, model.NewAppErr("getProductRelations", model.ErrInternal, locale.GetUserLocalizer("en"), msgURLParamErr, http.StatusInternalServerError, nil))
+		return
+	}
+
+	var typ model.ProductRelationType
+	if value := r.URL.Query().Get("type"); value != "" {
+		t, err := model.ParseProductRelationType(value)
+		if err != nil {
+			respondError(w, err)
+			return
+		}
+		typ = t
+	}
+
+	relations, err := a.app.GetProductRelations(pid, typ)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, relations)
+}
+
+func (a *API) replaceProductRelations(w http.ResponseWriter, r *http.Request) {
+	pid, e := strconv.ParseInt(chi.URLParam(r, "product_id"), 10, 64)
+	if e != nil {
+		respondError(w, model.NewAppErr("replaceProductRelations", model.ErrInternal, locale.GetUserLocalizer("en"), msgURLParamErr, http.StatusInternalServerError, nil))
+		return
+	}
+	typ, err := model.ParseProductRelationType(chi.URLParam(r, "relation_type"))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	relatedIDs := model.IntSliceFromJSON(r.Body)
+
+	relations, err := a.app.ReplaceProductRelations(pid, typ, relatedIDs)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, relations)
+}
+
+func (a *API) getProductRecommendations(w http.ResponseWriter, r *http.Request) {
+	pid, e := strconv.ParseInt(chi.URLParam(r, "product_id"), 10, 64)
+	if e != nil {
+		respondError(w, model.NewAppErr("getProductRecommendations", model.ErrInternal, locale.GetUserLocalizer("en"), msgURLParamErr, http.StatusInternalServerError, nil))
+		return
+	}
+
+	products, err := a.app.GetProductRecommendations(pid)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, products)
+}
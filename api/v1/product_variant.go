@@ -0,0 +1,194 @@
+package apiv1
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/dankobgd/ecommerce-shop/model"
+	"github.com/dankobgd/ecommerce-shop/utils/locale"
+	"github.com/go-chi/chi"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+var (
+	msgVariantFromJSON      = &i18n.Message{ID: "api.product_variant.create_product_variant.app_error", Other: "could not decode product variant data"}
+	msgVariantPatchFromJSON = &i18n.Message{ID: "api.product_variant.patch_product_variant.app_error", Other: "could not decode product variant patch data"}
+	msgOptionsFromJSON      = &i18n.Message{ID: "api.product_variant.replace_product_options.app_error", Other: "could not decode product options data"}
+)
+
+func (a *API) getProductOptions(w http.ResponseWriter, r *http.Request) {
+	pid, e := strconv.ParseInt(chi.URLParam(r, "product_id"), 10, 64)
+	if e != nil {
+		respondError(w, model.NewAppErr("getProductOptions", model.ErrInternal, locale.GetUserLocalizer("en"), msgURLParamErr, http.StatusInternalServerError, nil))
+		return
+	}
+
+	opts, err := a.app.GetProductOptions(pid)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, opts)
+}
+
+func (a *API) replaceProductOptions(w http.ResponseWriter, r *http.Request) {
+	pid, e := strconv.ParseInt(chi.URLParam(r, "product_id"), 10, 64)
+	if e != nil {
+		respondError(w, model.NewAppErr("replaceProductOptions", model.ErrInternal, locale.GetUserLocalizer("en"), msgURLParamErr, http.StatusInternalServerError, nil))
+		return
+	}
+
+	opts, e := model.ProductOptionsFromJSON(r.Body)
+	if e != nil {
+		respondError(w, model.NewAppErr("replaceProductOptions", model.ErrInternal, locale.GetUserLocalizer("en"), msgOptionsFromJSON, http.StatusInternalServerError, nil))
+		return
+	}
+
+	saved, err := a.app.ReplaceProductOptions(pid, opts)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, saved)
+}
+
+func (a *API) createProductVariant(w http.ResponseWriter, r *http.Request) {
+	pid, e := strconv.ParseInt(chi.URLParam(r, "product_id"), 10, 64)
+	if e != nil {
+		respondError(w, model.NewAppErr("createProductVariant", model.ErrInternal, locale.GetUserLocalizer("en"), msgURLParamErr, http.StatusInternalServerError, nil))
+		return
+	}
+
+	v, e := model.ProductVariantFromJSON(r.Body)
+	if e != nil {
+		respondError(w, model.NewAppErr("createProductVariant", model.ErrInternal, locale.GetUserLocalizer("en"), msgVariantFromJSON, http.StatusInternalServerError, nil))
+		return
+	}
+
+	variant, err := a.app.CreateProductVariant(pid, v)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, variant)
+}
+
+func (a *API) getProductVariants(w http.ResponseWriter, r *http.Request) {
+	pid, e := strconv.ParseInt(chi.URLParam(r, "product_id"), 10, 64)
+	if e != nil {
+		respondError(w, model.NewAppErr("getProductVariants", model.ErrInternal, locale.GetUserLocalizer("en"), msgURLParamErr, http.StatusInternalServerError, nil))
+		return
+	}
+
+	variants, err := a.app.GetProductVariants(pid)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, variants)
+}
+
+func (a *API) getProductVariant(w http.ResponseWriter, r *http.Request) {
+	pid, e := strconv.ParseInt(chi.URLParam(r, "product_id"), 10, 64)
+	if e != nil {
+		respondError(w, model.NewAppErr("getProductVariant", model.ErrInternal, locale.GetUserLocalizer("en"), msgURLParamErr, http.StatusInternalServerError, nil))
+		return
+	}
+	vid, e := strconv.ParseInt(chi.URLParam(r, "variant_id"), 10, 64)
+	if e != nil {
+		respondError(w, model.NewAppErr("getProductVariant", model.ErrInternal, locale.GetUserLocalizer("en"), msgURLParamErr, http.StatusInternalServerError, nil))
+		return
+	}
+
+	variant, err := a.app.GetProductVariant(pid, vid)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, variant)
+}
+
+func (a *API) patchProductVariant(w http.ResponseWriter, r *http.Request) {
+	pid, e := strconv.ParseInt(chi.URLParam(r, "product_id"), 10, 64)
+	if e != nil {
+		respondError(w, model.NewAppErr("patchProductVariant", model.ErrInternal, locale.GetUserLocalizer("en"), msgURLParamErr, http.StatusInternalServerError, nil))
+		return
+	}
+	vid, e := strconv.ParseInt(chi.URLParam(r, "variant_id"), 10, 64)
+	if e != nil {
+		respondError(w, model.NewAppErr("patchProductVariant", model.ErrInternal, locale.GetUserLocalizer("en"), msgURLParamErr, http.StatusInternalServerError, nil))
+		return
+	}
+
+	patch, e := model.ProductVariantPatchFromJSON(r.Body)
+	if e != nil {
+		respondError(w, model.NewAppErr("patchProductVariant", model.ErrInternal, locale.GetUserLocalizer("en"), msgVariantPatchFromJSON, http.StatusInternalServerError, nil))
+		return
+	}
+
+	variant, err := a.app.PatchProductVariant(pid, vid, patch)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, variant)
+}
+
+func (a *API) deleteProductVariant(w http.ResponseWriter, r *http.Request) {
+	pid, e := strconv.ParseInt(chi.URLParam(r, "product_id"), 10, 64)
+	if e != nil {
+		respondError(w, model.NewAppErr("deleteProductVariant", model.ErrInternal, locale.GetUserLocalizer("en"), msgURLParamErr, http.StatusInternalServerError, nil))
+		return
+	}
+	vid, e := strconv.ParseInt(chi.URLParam(r, "variant_id"), 10, 64)
+	if e != nil {
+		respondError(w, model.NewAppErr("deleteProductVariant", model.ErrInternal, locale.GetUserLocalizer("en"), msgURLParamErr, http.StatusInternalServerError, nil))
+		return
+	}
+
+	if err := a.app.DeleteProductVariant(pid, vid); err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respondOK(w)
+}
+
+func (a *API) createProductVariantImage(w http.ResponseWriter, r *http.Request) {
+	pid, e := strconv.ParseInt(chi.URLParam(r, "product_id"), 10, 64)
+	if e != nil {
+		respondError(w, model.NewAppErr("createProductVariantImage", model.ErrInternal, locale.GetUserLocalizer("en"), msgURLParamErr, http.StatusInternalServerError, nil))
+		return
+	}
+	vid, e := strconv.ParseInt(chi.URLParam(r, "variant_id"), 10, 64)
+	if e != nil {
+		respondError(w, model.NewAppErr("createProductVariantImage", model.ErrInternal, locale.GetUserLocalizer("en"), msgURLParamErr, http.StatusInternalServerError, nil))
+		return
+	}
+
+	if err := r.ParseMultipartForm(model.FileUploadSizeLimit); err != nil {
+		respondError(w, model.NewAppErr("createProductVariantImage", model.ErrInternal, locale.GetUserLocalizer("en"), msgProductAvatarMultipart, http.StatusInternalServerError, nil))
+		return
+	}
+
+	files := r.MultipartForm.File["image"]
+	if len(files) == 0 {
+		respondError(w, model.NewAppErr("createProductVariantImage", model.ErrInternal, locale.GetUserLocalizer("en"), msgProductFileErr, http.StatusInternalServerError, nil))
+		return
+	}
+
+	img := &model.ProductImage{VariantID: &vid}
+	productImage, err := a.app.CreateProductImage(pid, img, files[0])
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, productImage)
+}
@@ -1,6 +1,9 @@
 package apiv1
 
 import (
+	"bytes"
+	"fmt"
+	"io"
 	"net/http"
 
 	"github.com/dankobgd/ecommerce-shop/model"
@@ -18,11 +21,12 @@ var (
 	msgPromotionDeleteerr        = &i18n.Message{ID: "api.promotion.delete_promotion.app_error", Other: "could not delete promotion"}
 	msgPromotionURLParamErr      = &i18n.Message{ID: "api.promotion.url.params.app_error", Other: "could not parse URL params"}
 	msgPromotionPatchFromJSONErr = &i18n.Message{ID: "api.promotion.patch_product.app_error", Other: "could not decode promotion patch data"}
+	msgPromotionCodeBatchErr     = &i18n.Message{ID: "api.promotion.generate_promotion_codes.app_error", Other: "could not decode promotion code batch data"}
 )
 
 // InitPromotions inits the promotion routes
 func InitPromotions(a *API) {
-	a.Routes.Promotions.Post("/", a.AdminSessionRequired(a.createPromotion))
+	a.Routes.Promotions.Post("/", a.AdminSessionRequired(a.Idempotent(a.createPromotion)))
 	a.Routes.Promotions.Get("/", a.SessionRequired(a.getPromotions))
 	a.Routes.Promotions.Delete("/bulk", a.AdminSessionRequired(a.deletePromotions))
 
@@ -32,6 +36,9 @@ func InitPromotions(a *API) {
 	a.Routes.Promotion.Get("/valid", a.SessionRequired(a.getPromotionIsValid))
 	a.Routes.Promotion.Get("/used", a.SessionRequired(a.getPromotionIsUsed))
 	a.Routes.Promotion.Get("/status", a.SessionRequired(a.getPromotionStatus))
+	a.Routes.Promotion.Post("/codes", a.AdminSessionRequired(a.Idempotent(a.generatePromotionCodes)))
+	a.Routes.Promotion.Get("/codes", a.AdminSessionRequired(a.getPromotionCodes))
+	a.Routes.Promotion.Get("/codes/export", a.AdminSessionRequired(a.exportPromotionCodes))
 }
 
 func (a *API) createPromotion(w http.ResponseWriter, r *http.Request) {
@@ -146,3 +153,51 @@ func (a *API) deletePromotions(w http.ResponseWriter, r *http.Request) {
 
 	respondOK(w)
 }
+
+func (a *API) generatePromotionCodes(w http.ResponseWriter, r *http.Request) {
+	code := chi.URLParam(r, "promo_code")
+	b, e := model.PromotionCodeBatchFromJSON(r.Body)
+	if e != nil || b == nil {
+		respondError(w, model.NewAppErr("generatePromotionCodes", model.ErrInternal, locale.GetUserLocalizer("en"), msgPromotionCodeBatchErr, http.StatusInternalServerError, nil))
+		return
+	}
+
+	codes, err := a.app.GeneratePromotionCodes(code, b)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusCreated, codes)
+}
+
+func (a *API) getPromotionCodes(w http.ResponseWriter, r *http.Request) {
+	code := chi.URLParam(r, "promo_code")
+	pages := pagination.NewFromRequest(r)
+	codes, err := a.app.GetPromotionCodes(code, pages.Limit(), pages.Offset())
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	totalCount := -1
+	if len(codes) > 0 {
+		totalCount = codes[0].TotalCount
+	}
+	pages.SetData(codes, totalCount)
+
+	respondJSON(w, http.StatusOK, pages)
+}
+
+func (a *API) exportPromotionCodes(w http.ResponseWriter, r *http.Request) {
+	code := chi.URLParam(r, "promo_code")
+
+	var buf bytes.Buffer
+	if err := a.app.ExportPromotionCodes(code, &buf); err != nil {
+		respondError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", code+"-codes.csv"))
+	io.Copy(w, &buf)
+}
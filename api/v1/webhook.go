@@ -0,0 +1,45 @@
+package apiv1
+
+import (
+	"io/ioutil"
+	"net/http"
+
+	"github.com/dankobgd/ecommerce-shop/model"
+	"github.com/dankobgd/ecommerce-shop/payment"
+	"github.com/dankobgd/ecommerce-shop/utils/locale"
+	"github.com/go-chi/chi"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+// maxWebhookBodyBytes limits the webhook payload size
+const maxWebhookBodyBytes = int64(65536)
+
+var (
+	msgWebhookReadBody = &i18n.Message{ID: "api.webhook.payment_webhook.body.app_error", Other: "could not read webhook body"}
+)
+
+// InitWebhooks inits the webhook routes, the route is not mounted when the provider runs without the webhook secret
+func InitWebhooks(a *API) {
+	if !payment.WebhookEnabled(a.app.PaymentProvider()) {
+		a.app.Log().Warn("payment webhook secret is not set, the payment webhook route is not mounted")
+		return
+	}
+	a.Routes.Webhooks.Post("/payments/{provider:[a-z]+}", a.paymentWebhook)
+}
+
+func (a *API) paymentWebhook(w http.ResponseWriter, r *http.Request) {
+	provider := chi.URLParam(r, "provider")
+
+	payload, e := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBodyBytes))
+	if e != nil {
+		respondError(w, model.NewAppErr("paymentWebhook", model.ErrBadRequest, locale.GetUserLocalizer("en"), msgWebhookReadBody, http.StatusRequestEntityTooLarge, nil))
+		return
+	}
+
+	if err := a.app.HandlePaymentWebhook(provider, payload, r.Header); err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respondOK(w)
+}
@@ -19,7 +19,8 @@ type contextKey string
 
 // context keys
 const (
-	AccessDataCtxKey contextKey = "access_data"
+	AccessDataCtxKey       contextKey = "access_data"
+	IdempotencyScopeCtxKey contextKey = "idempotency_scope"
 )
 
 var (
@@ -23,6 +23,11 @@ func (a *App) CreateCategory(c *model.Category, fh *multipart.FileHeader) (*mode
 	if err := c.Validate(fh); err != nil {
 		return nil, err
 	}
+	if c.ParentID != nil {
+		if _, err := a.Srv().Store.Category().Get(*c.ParentID); err != nil {
+			return nil, err
+		}
+	}
 
 	thumbnail, err := fh.Open()
 	if err != nil {
@@ -103,6 +108,43 @@ func (a *App) GetCategory(cid int64) (*model.Category, *model.AppErr) {
 	return a.Srv().Store.Category().Get(cid)
 }
 
+// GetCategoryFilters gets the filterable attributes of the category schema, the most important first
+func (a *App) GetCategoryFilters(cid int64) (model.CategorySchema, *model.AppErr) {
+	c, err := a.Srv().Store.Category().Get(cid)
+	if err != nil {
+		return nil, err
+	}
+	return c.Schema().Filters(), nil
+}
+
+// GetCategoryTree gets the whole category tree, the roots and the siblings are ordered by the name
+func (a *App) GetCategoryTree() ([]*model.Category, *model.AppErr) {
+	categories, err := a.Srv().Store.Category().GetTree()
+	if err != nil {
+		return nil, err
+	}
+	return model.BuildCategoryTree(categories), nil
+}
+
+// GetCategorySubtree gets the category with its descendants
+func (a *App) GetCategorySubtree(cid int64) (*model.Category, *model.AppErr) {
+	categories, err := a.Srv().Store.Category().GetSubtree(cid)
+	if err != nil {
+		return nil, err
+	}
+	return model.BuildCategoryTree(categories)[0], nil
+}
+
+// GetCategoryBreadcrumbs gets the path of the category from the root to the category itself
+func (a *App) GetCategoryBreadcrumbs(cid int64) ([]*model.Category, *model.AppErr) {
+	return a.Srv().Store.Category().GetAncestors(cid)
+}
+
+// MoveCategory moves the category with its subtree under the new parent
+func (a *App) MoveCategory(cid int64, move *model.CategoryMove) (*model.Category, *model.AppErr) {
+	return a.Srv().Store.Category().Move(cid, move.ParentID)
+}
+
 // GetCategories gets all categories from the db
 func (a *App) GetCategories(limit, offset int) ([]*model.Category, *model.AppErr) {
 	return a.Srv().Store.Category().GetAll(limit, offset)
@@ -0,0 +1,75 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/dankobgd/ecommerce-shop/model"
+	"github.com/dankobgd/ecommerce-shop/utils/locale"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+var (
+	msgIdempotencyKeyInProgress = &i18n.Message{ID: "app.idempotency.begin_idempotent_request.in_progress.app_error", Other: "request with the same idempotency key is already being processed"}
+	msgIdempotencyKeyReused     = &i18n.Message{ID: "app.idempotency.begin_idempotent_request.reused.app_error", Other: "idempotency key has already been used with a different request"}
+)
+
+// IdempotencyKeyTTL is how long the idempotent responses are kept for the replays
+const IdempotencyKeyTTL = 24 * time.Hour
+
+// IdempotencyProcessingTTL is how long the key is locked while its request is processed,
+// the request that crashed or timed out doesn't block the retries for longer than that
+const IdempotencyProcessingTTL = time.Minute
+
+// BeginIdempotentRequest locks the idempotency key for the request
+// it returns the stored record if the same request has already completed, nil if the request should be processed
+func (a *App) BeginIdempotentRequest(key, fingerprint string) (*model.IdempotencyRecord, *model.AppErr) {
+	locked, err := a.Srv().Store.Idempotency().Lock(key, model.NewIdempotencyRecord(fingerprint), IdempotencyProcessingTTL)
+	if err != nil {
+		return nil, err
+	}
+	if locked {
+		return nil, nil
+	}
+
+	rec, err := a.Srv().Store.Idempotency().Get(key)
+	if err != nil {
+		return nil, err
+	}
+	// the key expired or was released in the meantime
+	if rec == nil {
+		return a.BeginIdempotentRequest(key, fingerprint)
+	}
+
+	if rec.Fingerprint != fingerprint {
+		return nil, model.NewAppErr("BeginIdempotentRequest", model.ErrInvalid, locale.GetUserLocalizer("en"), msgIdempotencyKeyReused, http.StatusUnprocessableEntity, nil)
+	}
+	if !rec.IsCompleted() {
+		return nil, model.NewAppErr("BeginIdempotentRequest", model.ErrConflict, locale.GetUserLocalizer("en"), msgIdempotencyKeyInProgress, http.StatusConflict, nil)
+	}
+
+	return rec, nil
+}
+
+// CompleteIdempotentRequest stores the response for the replays
+func (a *App) CompleteIdempotentRequest(key string, rec *model.IdempotencyRecord) *model.AppErr {
+	return a.Srv().Store.Idempotency().Save(key, rec, IdempotencyKeyTTL)
+}
+
+// GetIdempotencyScopeFromContext gets the idempotency scope from ctx, nil if the request has no key
+func (a *App) GetIdempotencyScopeFromContext(ctx context.Context) *model.IdempotencyScope {
+	scope, _ := ctx.Value(IdempotencyScopeCtxKey).(*model.IdempotencyScope)
+	return scope
+}
+
+// HoldIdempotencyKey keeps the key of the unfinished request locked for the whole key ttl,
+// used when the request paid but its response couldn't be stored, so the retry can't pay again
+func (a *App) HoldIdempotencyKey(key, fingerprint string) *model.AppErr {
+	return a.Srv().Store.Idempotency().Save(key, model.NewIdempotencyRecord(fingerprint), IdempotencyKeyTTL)
+}
+
+// ReleaseIdempotencyKey deletes the key so the request can be retried
+func (a *App) ReleaseIdempotencyKey(key string) *model.AppErr {
+	return a.Srv().Store.Idempotency().Delete(key)
+}
@@ -0,0 +1,191 @@
+package app
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/dankobgd/ecommerce-shop/model"
+	"github.com/dankobgd/ecommerce-shop/store"
+	"github.com/dankobgd/ecommerce-shop/utils/locale"
+	"github.com/dankobgd/ecommerce-shop/zlog"
+)
+
+// expiredReservationsBatch is the max number of the expired reservations released in one job run
+const expiredReservationsBatch = 100
+
+// paymentInFlightMaxAge is how long the order with the payment in flight keeps its stock before it is cancelled
+const paymentInFlightMaxAge = 24 * time.Hour
+
+// AdjustProductStock changes the product variant stock manually and records it in the ledger
+// without the variant the default product variant is adjusted
+func (a *App) AdjustProductStock(productID int64, sa *model.StockAdjustment, userID int64) (*model.ProductStock, *model.AppErr) {
+	if err := sa.Validate(); err != nil {
+		return nil, err
+	}
+
+	tx, err := a.Srv().Store.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var v *model.ProductVariant
+	if sa.VariantID != nil {
+		v, err = tx.ProductVariant().Get(productID, *sa.VariantID)
+	} else {
+		v, err = tx.ProductVariant().GetDefault(productID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ps, err := tx.Inventory().Adjust(v.ID, sa.Quantity)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &model.StockMovement{ProductID: productID, VariantID: model.NewInt64(v.ID), Quantity: sa.Quantity, Reason: sa.Reason, CreatedBy: &userID}
+	if sa.Note != "" {
+		m.Note = model.NewString(sa.Note)
+	}
+	m.PreSave()
+	if _, err := tx.Inventory().SaveMovement(m); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return ps, nil
+}
+
+// GetProductStock gets the current stock of every product variant
+func (a *App) GetProductStock(productID int64) ([]*model.ProductStock, *model.AppErr) {
+	stock, err := a.Srv().Store.Inventory().GetProductStock(productID)
+	if err != nil {
+		return nil, err
+	}
+	// every product has the default variant, so no stock means no product
+	if len(stock) == 0 {
+		return nil, model.NewAppErr("GetProductStock", model.ErrNotFound, locale.GetUserLocalizer("en"), msgProductNotFound, http.StatusNotFound, nil)
+	}
+	return stock, nil
+}
+
+// GetProductStockMovements gets the stock ledger of the product
+func (a *App) GetProductStockMovements(productID int64, limit, offset int) ([]*model.StockMovement, *model.AppErr) {
+	return a.Srv().Store.Inventory().GetMovements(productID, limit, offset)
+}
+
+// recordInitialStock records the stock the variant was created with
+func (a *App) recordInitialStock(st store.Store, v *model.ProductVariant, quantity int) *model.AppErr {
+	if quantity == 0 {
+		return nil
+	}
+	m := &model.StockMovement{ProductID: v.ProductID, VariantID: model.NewInt64(v.ID), Quantity: quantity, Reason: model.StockMovementInitial}
+	m.PreSave()
+	_, err := st.Inventory().SaveMovement(m)
+	return err
+}
+
+// reserveOrderStock decrements the stock of the order lines, it fails with the validation error if any line is out of stock
+// the variants are locked in the id order, so the concurrent orders of the same variants can't deadlock
+func (a *App) reserveOrderStock(st store.Store, o *model.Order, details []*model.OrderDetail) *model.AppErr {
+	sorted := make([]*model.OrderDetail, len(details))
+	copy(sorted, details)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].VariantID < sorted[j].VariantID })
+
+	for _, d := range sorted {
+		if _, err := st.Inventory().Adjust(d.VariantID, -d.Quantity); err != nil {
+			if err.StatusCode != http.StatusConflict {
+				return err
+			}
+			available := 0
+			if ps, sErr := st.Inventory().GetStock(d.VariantID); sErr == nil {
+				available = ps.StockQuantity
+			}
+			return model.NewOutOfStockError(d.VariantID, d.Quantity, available)
+		}
+
+		m := &model.StockMovement{ProductID: d.ProductID, VariantID: model.NewInt64(d.VariantID), OrderID: &o.ID, Quantity: -d.Quantity, Reason: model.StockMovementSale}
+		m.PreSave()
+		if _, err := st.Inventory().SaveMovement(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// releaseOrderStock returns the stock of the order lines that were never paid
+func (a *App) releaseOrderStock(st store.Store, o *model.Order) *model.AppErr {
+	details, err := st.OrderDetail().GetAll(o.ID)
+	if err != nil {
+		return err
+	}
+	// the same lock order as when the stock was reserved
+	sort.SliceStable(details, func(i, j int) bool { return details[i].OrderDetail.VariantID < details[j].OrderDetail.VariantID })
+
+	for _, d := range details {
+		if _, err := st.Inventory().Adjust(d.OrderDetail.VariantID, d.OrderDetail.Quantity); err != nil {
+			// the variant was deleted in the meantime, there is no stock to return
+			if err.StatusCode == http.StatusConflict {
+				continue
+			}
+			return err
+		}
+
+		m := &model.StockMovement{ProductID: d.OrderDetail.ProductID, VariantID: model.NewInt64(d.OrderDetail.VariantID), OrderID: &o.ID, Quantity: d.OrderDetail.Quantity, Reason: model.StockMovementRelease}
+		m.PreSave()
+		if _, err := st.Inventory().SaveMovement(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// holdStockReservation keeps the order stock reserved while the payment is in flight
+func (a *App) holdStockReservation(o *model.Order) *model.AppErr {
+	ttl := time.Duration(a.Cfg().InventorySettings.ReservationTTLMinutes) * time.Minute
+	return a.Srv().Store.StockReservation().Reserve(o.ID, time.Now().Add(ttl))
+}
+
+// dropStockReservation removes the reservation once the order is paid or cancelled
+func (a *App) dropStockReservation(orderID int64) {
+	if _, err := a.Srv().Store.StockReservation().Release(orderID); err != nil {
+		a.Log().Error("could not drop the stock reservation", zlog.Int64("order_id", orderID), zlog.Err(err))
+	}
+}
+
+// releaseExpiredStockReservations cancels the orders that were not paid before their stock reservation expired
+func (a *App) releaseExpiredStockReservations() {
+	ids, err := a.Srv().Store.StockReservation().GetExpired(time.Now(), expiredReservationsBatch)
+	if err != nil {
+		a.Log().Error(err.Error(), zlog.Err(err))
+		return
+	}
+
+	// the reservation is dropped only once the order is settled, so a failed run is retried on the next one
+	// and the concurrent runs are safe since only one of them can move the order out of pending
+	for _, id := range ids {
+		o, err := a.GetOrder(id)
+		if err != nil {
+			a.Log().Error("could not get the order of the expired stock reservation", zlog.Int64("order_id", id), zlog.Err(err))
+			continue
+		}
+		if o.Status != model.OrderStatusPendingPayment.String() {
+			a.dropStockReservation(id)
+			continue
+		}
+
+		// the customer is authenticating the payment or the provider is processing it, the webhook settles the order
+		if o.PaymentIntentID != "" && time.Since(o.CreatedAt) < paymentInFlightMaxAge {
+			if err := a.holdStockReservation(o); err != nil {
+				a.Log().Error("could not extend the stock reservation", zlog.Int64("order_id", id), zlog.Err(err))
+			}
+			continue
+		}
+
+		a.cancelPendingOrder(o, "stock reservation expired")
+	}
+}
@@ -0,0 +1,49 @@
+package app
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dankobgd/ecommerce-shop/zlog"
+)
+
+// job is the periodic background task
+type job struct {
+	name     string
+	interval time.Duration
+	run      func()
+}
+
+func (a *App) jobs() []*job {
+	return []*job{
+		{name: "release_expired_stock_reservations", interval: time.Minute, run: a.releaseExpiredStockReservations},
+		{name: "refresh_search_index", interval: 30 * time.Second, run: a.refreshSearchIndex},
+		{name: "recompute_recommendations", interval: 6 * time.Hour, run: a.recomputeRecommendations},
+	}
+}
+
+// StartJobs runs the background jobs periodically for the lifetime of the process
+func (a *App) StartJobs() {
+	for _, j := range a.jobs() {
+		go a.runJob(j)
+	}
+}
+
+func (a *App) runJob(j *job) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		a.runJobOnce(j)
+	}
+}
+
+func (a *App) runJobOnce(j *job) {
+	defer func() {
+		if r := recover(); r != nil {
+			a.Log().Error("background job panicked", zlog.String("job", j.name), zlog.String("panic", fmt.Sprint(r)))
+		}
+	}()
+
+	j.run()
+}
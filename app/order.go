@@ -4,93 +4,60 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"math"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/dankobgd/ecommerce-shop/model"
+	"github.com/dankobgd/ecommerce-shop/payment"
+	"github.com/dankobgd/ecommerce-shop/store"
 	"github.com/dankobgd/ecommerce-shop/utils/locale"
 	"github.com/dankobgd/ecommerce-shop/zlog"
 	"github.com/jung-kurt/gofpdf"
 	"github.com/nicksnyder/go-i18n/v2/i18n"
-	"github.com/stripe/stripe-go"
 )
 
 var (
 	msgGetAddressGeocodeResult = &i18n.Message{ID: "app.order.get_address_geocode_result.app_error", Other: "could not get geocoding result on given address"}
 	msgCreatePDF               = &i18n.Message{ID: "app.order.details_pdf.app_error", Other: "could not create order details pdf"}
+	msgChargeCard              = &i18n.Message{ID: "app.order.create_order.app_error", Other: "could not charge the card"}
+	msgOrderNotFound           = &i18n.Message{ID: "app.order.confirm_order.not_found.app_error", Other: "order not found"}
+	msgOrderNotAwaitingConfirm = &i18n.Message{ID: "app.order.confirm_order.status.app_error", Other: "order is not awaiting the payment confirmation"}
+	msgChargeNotCompleted      = &i18n.Message{ID: "app.order.create_order.charge_not_completed.app_error", Other: "the card charge was not completed"}
+	msgPromoCodeNotApplied     = &i18n.Message{ID: "app.order.create_order.promo_code.app_error", Other: "promo code can't be applied to the order"}
 )
 
 // CreateOrder creates the new order
-func (a *App) CreateOrder(userID int64, data *model.OrderRequestData) (*model.Order, *model.AppErr) {
+func (a *App) CreateOrder(userID int64, data *model.OrderRequestData, scope *model.IdempotencyScope) (*model.CheckoutResult, *model.AppErr) {
 	// validate order request data
 	if err := data.Validate(); err != nil {
 		return nil, err
 	}
 
-	ids := make([]int64, 0)
-	for _, x := range data.Items {
-		ids = append(ids, x.ProductID)
-	}
-
-	// get products from given data product ids
-	products, err := a.GetProductsbyIDS(ids)
+	// price the ordered variants the same way the quote does
+	quote, orderDetails, err := a.priceCart(userID, data.Items, data.PromoCode)
 	if err != nil {
 		return nil, err
 	}
 
+	// the entered promo code must be applied, the customer doesn't expect to pay the full price
+	if data.PromoCode != nil && *data.PromoCode != "" && quote.PromoCode == nil {
+		details := map[string]interface{}{"promo_code": *data.PromoCode}
+		if r := quote.Rejection(*data.PromoCode); r != nil {
+			details["reason"] = r.Reason
+			details["reason_id"] = r.ReasonID
+		}
+		return nil, model.NewAppErr("CreateOrder", model.ErrInvalid, locale.GetUserLocalizer("en"), msgPromoCodeNotApplied, http.StatusBadRequest, details)
+	}
+
 	// get authed user
 	user, err := a.GetUserByID(userID)
 	if err != nil {
 		return nil, err
 	}
 
-	// calc subtotal price (price before discount, possible taxes etc...)
-	subtotal := 0
-	for i, p := range products {
-		subtotal += p.Price * data.Items[i].Quantity
-	}
-
-	// calc total price (after possible discount)
-	total := 0
-	if data.PromoCode == nil {
-		total = subtotal
-	}
-
-	var promoCodeName *string
-	var promoCodeType *string
-	var promoCodeAmount *int
-
-	if data.PromoCode != nil && *data.PromoCode != "" {
-		if err := a.GetPromotionStatus(*data.PromoCode, userID); err != nil {
-			return nil, err
-		}
-
-		promo, err := a.GetPromotion(*data.PromoCode)
-		if err != nil {
-			return nil, err
-		}
-
-		promoCodeName = &promo.PromoCode
-		promoCodeType = &promo.Type
-		promoCodeAmount = &promo.Amount
-
-		if promo.Type == "percentage" {
-			t := float64(subtotal) - float64(promo.Amount)/100*float64(subtotal)
-			total = int(math.Round(t*100) / 100)
-		}
-
-		if promo.Type == "fixed" {
-			t := (subtotal - promo.Amount)
-			if t < 0 {
-				t = 0
-			}
-			total = t
-		}
-	}
-
 	billAddrInfo := &model.Address{}
 
 	if data.UseExistingBillingAddress != nil && *data.UseExistingBillingAddress == true {
@@ -105,21 +72,17 @@ func (a *App) CreateOrder(userID int64, data *model.OrderRequestData) (*model.Or
 		billAddrInfo = data.BillingAddress
 	}
 
-	// im too lazy to handle 100% discount (free item) case, and stripe lowest is 0.5$
-	// otherwise i would need to create invoice which cant work without invoice items etc bla bla...
-	if total == 0 {
-		total = 50
-	}
-
 	o := &model.Order{
 		UserID:          userID,
-		Subtotal:        subtotal,
-		Total:           total,
-		Status:          model.OrderStatusSuccess.String(),
+		Subtotal:        quote.Subtotal,
+		Shipping:        quote.Shipping,
+		Discount:        quote.Discount,
+		Total:           quote.Total,
+		Status:          model.OrderStatusPendingPayment.String(),
 		PaymentMethodID: data.PaymentMethodID,
-		PromoCode:       promoCodeName,
-		PromoCodeType:   promoCodeType,
-		PromoCodeAmount: promoCodeAmount,
+		PromoCode:       quote.PromoCode,
+		PromoCodeType:   quote.PromoCodeType,
+		PromoCodeAmount: quote.PromoCodeAmount,
 	}
 
 	o.BillingAddressLine1 = billAddrInfo.Line1
@@ -180,67 +143,318 @@ func (a *App) CreateOrder(userID int64, data *model.OrderRequestData) (*model.Or
 		o.ShippingAddressLongitude = &sLon
 	}
 
-	pi, cErr := a.PaymentProvider().Charge(data.PaymentMethodID, o, user, uint64(o.Total), "usd")
-	if cErr != nil {
-		if stripeErr, ok := cErr.(*stripe.Error); ok {
-			if cardErr, ok := stripeErr.Err.(*stripe.CardError); ok {
-				dc := ""
-				if (string(cardErr.DeclineCode)) != "" {
-					dc = "Decline code: " + string(cardErr.DeclineCode)
-				}
-
-				return nil, model.NewAppErr("CreateOrder", model.ErrInternal, locale.GetUserLocalizer("en"), &i18n.Message{ID: "app.order.create_order.app_error", Other: fmt.Sprintf("%s\n%s", stripeErr.Msg, dc)}, http.StatusInternalServerError, nil)
+	// persist the pending order before charging, so the charge is never left without the order
+	order, err := a.savePendingOrder(o, orderDetails, quote)
+	if err != nil {
+		return nil, err
+	}
+	// without the reservation nothing would cancel the order if the payment never settles
+	if err := a.holdStockReservation(order); err != nil {
+		a.cancelPendingOrder(order, "stock reservation failed")
+		return nil, err
+	}
+
+	// the fully discounted order has nothing to charge, so it is finalized right away
+	charge := &payment.ChargeResult{Status: payment.ChargeStatusSucceeded}
+	if order.Total > 0 {
+		scope.AttemptPayment()
+		var cErr error
+		charge, cErr = a.PaymentProvider().Charge(data.PaymentMethodID, order, user, uint64(order.Total), "usd", scope.ProviderKey("charge"))
+		if cErr != nil {
+			// only the declined card surely wasn't charged, on the other errors the charge may have gone through
+			// so the order stays pending until the webhook reports the payment or the stock reservation expires
+			if pErr, ok := cErr.(*payment.Error); ok && pErr.IsCardError() {
+				a.cancelPendingOrder(order, "payment failed")
+			} else {
+				a.Log().Warn("charge result unknown, order left pending", zlog.Int64("order_id", order.ID), zlog.Err(cErr))
 			}
-			return nil, model.NewAppErr("CreateOrder", model.ErrInternal, locale.GetUserLocalizer("en"), &i18n.Message{ID: "app.order.create_order.app_error", Other: stripeErr.Msg}, http.StatusInternalServerError, nil)
+			return nil, chargeErr(cErr)
+		}
+	}
 
+	switch {
+	case charge.Succeeded():
+		if err := a.finalizeOrder(order, charge); err != nil {
+			if charge.ChargeID == "" {
+				a.cancelPendingOrder(order, "order could not be finalized")
+			} else {
+				a.refundUnfinalizedOrder(order, charge)
+			}
+			return nil, err
+		}
+	case charge.RequiresAction(), charge.Status == payment.ChargeStatusProcessing:
+		// the order stays pending until the customer authenticates the payment and confirms the order,
+		// or until the provider webhook reports the payment result
+		if err := a.Srv().Store.Order().UpdatePayment(order.ID, charge.ChargeID, charge.ReceiptURL); err != nil {
+			a.cancelPendingOrder(order, "payment could not be tracked")
+			return nil, err
 		}
-		return nil, model.NewAppErr("CreateOrder", model.ErrInternal, locale.GetUserLocalizer("en"), &i18n.Message{ID: "app.order.create_order.app_error", Other: "could not charge the card"}, http.StatusInternalServerError, nil)
+		order.PaymentIntentID = charge.ChargeID
+	default:
+		a.cancelPendingOrder(order, "payment was not completed")
+		return nil, model.NewAppErr("CreateOrder", model.ErrInternal, locale.GetUserLocalizer("en"), msgChargeNotCompleted, http.StatusInternalServerError, map[string]interface{}{"status": charge.Status})
 	}
 
-	o.PaymentIntentID = pi.ID
-	o.ReceiptURL = pi.Charges.Data[0].ReceiptURL
+	defer func() {
+		if (data.UseExistingBillingAddress == nil || data.UseExistingBillingAddress != nil && *data.UseExistingBillingAddress == false) && (data.SaveAddress != nil && *data.SaveAddress == true) {
+			if _, err := a.CreateUserAddress(data.BillingAddress, userID); err != nil {
+				a.Log().Error(err.Error(), zlog.Err(err))
+			}
+		}
+	}()
+
+	return &model.CheckoutResult{
+		Order:              order,
+		RequiresAction:     charge.RequiresAction(),
+		ClientSecret:       charge.ClientSecret,
+		Discounts:          quote.Discounts,
+		RejectedPromotions: quote.Rejected,
+	}, nil
+}
+
+// getOrderItemVariants gets the variant of every cart item, in the items order
+// the item gets nil when its variant doesn't exist
+func (a *App) getOrderItemVariants(items []*model.CartItem) ([]*model.ProductVariant, *model.AppErr) {
+	vids := make([]int64, 0)
+	pids := make([]int64, 0)
+	for _, item := range items {
+		if item.VariantID != nil {
+			vids = append(vids, *item.VariantID)
+		} else {
+			pids = append(pids, item.ProductID)
+		}
+	}
 
-	// save actual order
-	order, err := a.Srv().Store.Order().Save(o)
+	byID, err := a.Srv().Store.ProductVariant().ListByIDS(vids)
 	if err != nil {
 		return nil, err
 	}
+	defaults, err := a.Srv().Store.ProductVariant().ListDefaultsByProductIDS(pids)
+	if err != nil {
+		return nil, err
+	}
+
+	variantsByID := make(map[int64]*model.ProductVariant, len(byID))
+	for _, v := range byID {
+		variantsByID[v.ID] = v
+	}
+	defaultsByProduct := make(map[int64]*model.ProductVariant, len(defaults))
+	for _, v := range defaults {
+		defaultsByProduct[v.ProductID] = v
+	}
 
-	orderDetails := make([]*model.OrderDetail, 0)
-	for i, p := range products {
-		detail := &model.OrderDetail{
-			OrderID:      order.ID,
-			ProductID:    p.ID,
-			Quantity:     data.Items[i].Quantity,
-			HistoryPrice: p.Price,
-			HistorySKU:   p.SKU,
+	variants := make([]*model.ProductVariant, len(items))
+	for i, item := range items {
+		if item.VariantID != nil {
+			variants[i] = variantsByID[*item.VariantID]
+		} else {
+			variants[i] = defaultsByProduct[item.ProductID]
 		}
-		orderDetails = append(orderDetails, detail)
 	}
+	return variants, nil
+}
 
-	if err := a.InsertOrderDetails(orderDetails); err != nil {
+// ConfirmOrder completes the checkout after the customer authenticated the payment
+func (a *App) ConfirmOrder(userID, orderID int64) (*model.CheckoutResult, *model.AppErr) {
+	o, err := a.GetOrder(orderID)
+	if err != nil {
 		return nil, err
 	}
+	if o.UserID != userID {
+		return nil, model.NewAppErr("ConfirmOrder", model.ErrNotFound, locale.GetUserLocalizer("en"), msgOrderNotFound, http.StatusNotFound, nil)
+	}
 
-	if data.PromoCode != nil && *data.PromoCode != "" {
-		// insert promo detail to mark the promo_code as used by the specific user
-		pd := &model.PromotionDetail{UserID: userID, PromoCode: *data.PromoCode}
-		if _, err := a.CreatePromotionDetail(pd); err != nil {
-			return nil, err
+	status, _ := o.StatusValue()
+	if status == model.OrderStatusPaid {
+		return &model.CheckoutResult{Order: o}, nil
+	}
+	if status != model.OrderStatusPendingPayment || o.PaymentIntentID == "" {
+		return nil, model.NewAppErr("ConfirmOrder", model.ErrConflict, locale.GetUserLocalizer("en"), msgOrderNotAwaitingConfirm, http.StatusConflict, map[string]interface{}{"status": o.Status})
+	}
+
+	charge, cErr := a.PaymentProvider().Confirm(o.PaymentIntentID)
+	if cErr != nil {
+		if pErr, ok := cErr.(*payment.Error); ok && pErr.IsCardError() {
+			a.cancelPendingOrder(o, "payment failed")
 		}
+		return nil, chargeErr(cErr)
 	}
 
-	defer func() {
-		if (data.UseExistingBillingAddress == nil || data.UseExistingBillingAddress != nil && *data.UseExistingBillingAddress == false) && (data.SaveAddress != nil && *data.SaveAddress == true) {
-			if _, err := a.CreateUserAddress(data.BillingAddress, userID); err != nil {
-				a.Log().Error(err.Error(), zlog.Err(err))
+	switch {
+	case charge.Succeeded():
+		if err := a.finalizeOrder(o, charge); err != nil {
+			// the webhook may have finalized the order in the meantime
+			if current, gErr := a.GetOrder(orderID); gErr == nil && current.Status == model.OrderStatusPaid.String() {
+				return &model.CheckoutResult{Order: current}, nil
 			}
+			a.refundUnfinalizedOrder(o, charge)
+			return nil, err
 		}
-	}()
+	case charge.RequiresAction(), charge.Status == payment.ChargeStatusProcessing:
+	default:
+		a.cancelPendingOrder(o, "payment was not completed")
+		return nil, model.NewAppErr("ConfirmOrder", model.ErrInternal, locale.GetUserLocalizer("en"), msgChargeNotCompleted, http.StatusInternalServerError, map[string]interface{}{"status": charge.Status})
+	}
+
+	return &model.CheckoutResult{Order: o, RequiresAction: charge.RequiresAction(), ClientSecret: charge.ClientSecret}, nil
+}
+
+// savePendingOrder atomically saves the order awaiting payment, with its lines, the quote discounts, the stock and the promotions usage
+// the generated code of the quote is redeemed by the order
+func (a *App) savePendingOrder(o *model.Order, details []*model.OrderDetail, quote *model.Quote) (*model.Order, *model.AppErr) {
+	tx, err := a.Srv().Store.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	order, err := tx.Order().Save(o)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, d := range details {
+		d.OrderID = order.ID
+	}
+	if err := tx.OrderDetail().BulkInsert(details); err != nil {
+		return nil, err
+	}
+	if err := a.reserveOrderStock(tx, order, details); err != nil {
+		return nil, err
+	}
+
+	for _, d := range quote.Discounts {
+		d.OrderID = order.ID
+	}
+	if err := tx.OrderDiscount().BulkInsert(quote.Discounts); err != nil {
+		return nil, err
+	}
+
+	// promo details claim the applied promotions for the specific user before the charge, the db enforces the usage limits
+	// the claims are released when the payment fails and the order is cancelled
+	for _, d := range quote.Discounts {
+		if d.PromoCode == nil {
+			continue
+		}
+		pd := &model.PromotionDetail{UserID: order.UserID, PromoCode: *d.PromoCode, OrderID: &order.ID}
+		if _, err := tx.Promotion().ClaimDetail(pd); err != nil {
+			return nil, err
+		}
+	}
+	if quote.GeneratedCode != nil {
+		if err := tx.PromotionCode().Redeem(*quote.GeneratedCode, order.UserID, order.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := a.recordInitialOrderStatus(tx, order); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
 
 	return order, nil
 }
 
+// finalizeOrder atomically stores the payment info and marks the order as paid
+func (a *App) finalizeOrder(o *model.Order, charge *payment.ChargeResult) *model.AppErr {
+	tx, err := a.Srv().Store.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := tx.Order().UpdatePayment(o.ID, charge.ChargeID, charge.ReceiptURL); err != nil {
+		return err
+	}
+	if _, err := a.transitionOrderStatus(tx, o, model.OrderStatusPaid, "", nil); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	a.dropStockReservation(o.ID)
+
+	o.PaymentIntentID = charge.ChargeID
+	o.ReceiptURL = charge.ReceiptURL
+	return nil
+}
+
+// cancelPendingOrder cancels the order that was not paid and releases the stock and the promotions it redeemed
+func (a *App) cancelPendingOrder(o *model.Order, reason string) *model.AppErr {
+	tx, err := a.Srv().Store.Begin()
+	if err != nil {
+		a.Log().Error(err.Error(), zlog.Err(err))
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := a.transitionOrderStatus(tx, o, model.OrderStatusCancelled, reason, nil); err != nil {
+		a.Log().Error(err.Error(), zlog.Err(err))
+		return err
+	}
+	if err := a.releaseOrderStock(tx, o); err != nil {
+		a.Log().Error(err.Error(), zlog.Err(err))
+		return err
+	}
+	if err := a.releaseOrderPromotions(tx, o); err != nil {
+		a.Log().Error(err.Error(), zlog.Err(err))
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		a.Log().Error(err.Error(), zlog.Err(err))
+		return err
+	}
+	a.dropStockReservation(o.ID)
+	return nil
+}
+
+// releaseOrderPromotions releases the promotion redemptions and the generated codes claimed by the order
+func (a *App) releaseOrderPromotions(st store.Store, o *model.Order) *model.AppErr {
+	if err := st.Promotion().DeleteOrderDetails(o.ID); err != nil {
+		return err
+	}
+	return st.PromotionCode().ReleaseOrder(o.ID)
+}
+
+// refundUnfinalizedOrder gives the money back when the order could not be finalized after the charge
+func (a *App) refundUnfinalizedOrder(o *model.Order, charge *payment.ChargeResult) {
+	refundID, err := a.PaymentProvider().Refund(charge.ChargeID, uint64(o.Total), "usd")
+	if err != nil {
+		a.Log().Error("could not refund the charge of the unfinalized order", zlog.Int64("order_id", o.ID), zlog.String("charge_id", charge.ChargeID), zlog.Err(err))
+		return
+	}
+
+	a.Log().Info("refunded the charge of the unfinalized order", zlog.Int64("order_id", o.ID), zlog.String("charge_id", charge.ChargeID), zlog.String("refund_id", refundID))
+	a.cancelPendingOrder(o, "order could not be finalized, payment refunded")
+}
+
+// chargeErr converts the payment provider error to the app error
+func chargeErr(err error) *model.AppErr {
+	pErr, ok := err.(*payment.Error)
+	if !ok {
+		return model.NewAppErr("CreateOrder", model.ErrInternal, locale.GetUserLocalizer("en"), msgChargeCard, http.StatusInternalServerError, nil)
+	}
+
+	details := map[string]interface{}{"provider": pErr.Provider, "code": pErr.Code}
+	msg := pErr.Message
+	if pErr.DeclineCode != "" {
+		details["decline_code"] = pErr.DeclineCode
+		msg = fmt.Sprintf("%s\nDecline code: %s", pErr.Message, pErr.DeclineCode)
+	}
+
+	statusCode := http.StatusInternalServerError
+	if pErr.IsCardError() {
+		statusCode = http.StatusPaymentRequired
+	}
+
+	return model.NewAppErr("CreateOrder", model.ErrInternal, locale.GetUserLocalizer("en"), &i18n.Message{ID: msgChargeCard.ID, Other: msg}, statusCode, details)
+}
+
 // GetOrder gets the order by id
 func (a *App) GetOrder(id int64) (*model.Order, *model.AppErr) {
 	return a.Srv().Store.Order().Get(id)
@@ -305,6 +519,11 @@ const (
 
 // GenerateOrderDetailsPDF creates the pdf
 func (a *App) GenerateOrderDetailsPDF(o *model.Order, details []*model.OrderInfo, user *model.User) (bytes.Buffer, *model.AppErr) {
+	discounts, err := a.Srv().Store.OrderDiscount().GetAll(o.ID)
+	if err != nil {
+		return bytes.Buffer{}, err
+	}
+
 	pdf := gofpdf.New(gofpdf.OrientationPortrait, gofpdf.UnitPoint, gofpdf.PageSizeLetter, "")
 	w, h := pdf.GetPageSize()
 	pdf.AddPage()
@@ -416,20 +635,29 @@ func (a *App) GenerateOrderDetailsPDF(o *model.Order, details []*model.OrderInfo
 	// Line Items - real data
 	y = y + lineHt
 
+	// the component lines of the bundle are listed under the bundle line, which has the bundle price
+	bundles := make(map[int64]bool)
 	for _, dtl := range details {
-		x, y = lineItem(pdf, x, y, dtl)
+		if dtl.BundleID == nil {
+			x, y = lineItem(pdf, x, y, dtl.Product.Name, toUSD(dtl.HistoryPrice), dtl.Quantity, toUSD(dtl.HistoryPrice*dtl.Quantity))
+			continue
+		}
+		if bundles[*dtl.BundleID] {
+			continue
+		}
+		bundles[*dtl.BundleID] = true
+		x, y = bundleLineItem(pdf, x, y, dtl, details)
 	}
 
 	// Subtotal etc
 	x, y = w/1.75, y+lineHt*2.25
 	x, y = trailerLine(pdf, x, y, "Subtotal", toUSD(o.Subtotal))
 
-	if o.PromoCode != nil && *o.PromoCode != "" {
-		promoStr := fmt.Sprintf("-%v", toUSD(*o.PromoCodeAmount))
-		if *o.PromoCodeType == "percentage" {
-			promoStr = fmt.Sprintf("-%v%%", *o.PromoCodeAmount)
-		}
-		x, y = trailerLine(pdf, x, y, "Promo Code", promoStr)
+	if o.Shipping > 0 {
+		x, y = trailerLine(pdf, x, y, "Shipping", toUSD(o.Shipping))
+	}
+	for _, d := range discounts {
+		x, y = trailerLine(pdf, x, y, discountLabel(d), fmt.Sprintf("-%v", toUSD(d.Total())))
 	}
 
 	pdf.SetDrawColor(180, 180, 180)
@@ -462,6 +690,17 @@ func trailerLine(pdf *gofpdf.Fpdf, x, y float64, label string, formattedAmount s
 	return origX, y
 }
 
+// discountLabel names the applied discount on the invoice by its promo code, or by its description or kind
+func discountLabel(d *model.OrderDiscount) string {
+	switch {
+	case d.PromoCode != nil && *d.PromoCode != "":
+		return *d.PromoCode
+	case d.Description != "":
+		return d.Description
+	}
+	return strings.ReplaceAll(d.Type, "_", " ")
+}
+
 func toUSD(cents int) string {
 	centsStr := fmt.Sprintf("%d", cents%100)
 	if len(centsStr) < 2 {
@@ -470,7 +709,7 @@ func toUSD(cents int) string {
 	return fmt.Sprintf("$%d.%s", cents/100, centsStr)
 }
 
-func lineItem(pdf *gofpdf.Fpdf, x, y float64, item *model.OrderInfo) (float64, float64) {
+func lineItem(pdf *gofpdf.Fpdf, x, y float64, name string, price string, quantity int, total string) (float64, float64) {
 	origX := x
 	w, _ := pdf.GetPageSize()
 	pdf.SetFont("times", "", 14)
@@ -479,18 +718,18 @@ func lineItem(pdf *gofpdf.Fpdf, x, y float64, item *model.OrderInfo) (float64, f
 	pdf.MoveTo(x, y)
 	x, y = xIndent-2.0, y+lineHt*.75
 	pdf.MoveTo(x, y)
-	pdf.MultiCell(w/2.65+1.5, lineHt, item.Product.Name, gofpdf.BorderNone, gofpdf.AlignLeft, false)
-	tmp := pdf.SplitLines([]byte(item.Product.Name), w/2.65+1.5)
+	pdf.MultiCell(w/2.65+1.5, lineHt, name, gofpdf.BorderNone, gofpdf.AlignLeft, false)
+	tmp := pdf.SplitLines([]byte(name), w/2.65+1.5)
 	maxY := y + float64(len(tmp)-1)*lineHt
 	x = x + w/2.65 + 1.5
 	pdf.MoveTo(x, y)
-	pdf.CellFormat(100.0, lineHt, toUSD(item.HistoryPrice), gofpdf.BorderNone, gofpdf.LineBreakNone, gofpdf.AlignRight, false, 0, "")
+	pdf.CellFormat(100.0, lineHt, price, gofpdf.BorderNone, gofpdf.LineBreakNone, gofpdf.AlignRight, false, 0, "")
 	x = x + 100.0
 	pdf.MoveTo(x, y)
-	pdf.CellFormat(80.0, lineHt, fmt.Sprintf("%d", item.Quantity), gofpdf.BorderNone, gofpdf.LineBreakNone, gofpdf.AlignRight, false, 0, "")
+	pdf.CellFormat(80.0, lineHt, fmt.Sprintf("%d", quantity), gofpdf.BorderNone, gofpdf.LineBreakNone, gofpdf.AlignRight, false, 0, "")
 	x = w - xIndent - 2.0 - 119.5
 	pdf.MoveTo(x, y)
-	pdf.CellFormat(119.5, lineHt, toUSD(item.HistoryPrice*item.Quantity), gofpdf.BorderNone, gofpdf.LineBreakNone, gofpdf.AlignRight, false, 0, "")
+	pdf.CellFormat(119.5, lineHt, total, gofpdf.BorderNone, gofpdf.LineBreakNone, gofpdf.AlignRight, false, 0, "")
 	if maxY > y {
 		y = maxY
 	}
@@ -500,6 +739,22 @@ func lineItem(pdf *gofpdf.Fpdf, x, y float64, item *model.OrderInfo) (float64, f
 	return origX, y
 }
 
+// bundleLineItem writes the bundle line with the bundle price, followed by its component lines without the price
+func bundleLineItem(pdf *gofpdf.Fpdf, x, y float64, item *model.OrderInfo, details []*model.OrderInfo) (float64, float64) {
+	name := "Bundle"
+	if item.BundleName != nil {
+		name = *item.BundleName
+	}
+	x, y = lineItem(pdf, x, y, name, toUSD(*item.BundlePrice), *item.BundleQuantity, toUSD(*item.BundlePrice**item.BundleQuantity))
+
+	for _, dtl := range details {
+		if dtl.BundleID != nil && *dtl.BundleID == *item.BundleID {
+			x, y = lineItem(pdf, x, y, "    - "+dtl.Product.Name, "", dtl.Quantity, "")
+		}
+	}
+	return x, y
+}
+
 func summaryBlock(pdf *gofpdf.Fpdf, x, y float64, title string, data ...string) (float64, float64) {
 	pdf.SetFont("times", "", 14)
 	pdf.SetTextColor(180, 180, 180)
@@ -0,0 +1,246 @@
+package app
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/dankobgd/ecommerce-shop/model"
+	"github.com/dankobgd/ecommerce-shop/store"
+	"github.com/dankobgd/ecommerce-shop/utils/locale"
+	"github.com/dankobgd/ecommerce-shop/zlog"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+var (
+	msgInvalidOrderStatus           = &i18n.Message{ID: "app.order.transition_order_status.invalid_status.app_error", Other: "order has an invalid status"}
+	msgRefundStatusRequiresRefund   = &i18n.Message{ID: "app.order.change_order_status.refund_status.app_error", Other: "refund statuses can only be set by refunding the order"}
+	msgInvalidOrderStatusTransition = &i18n.Message{ID: "app.order.transition_order_status.invalid_transition.app_error", Other: "order can not be moved to the requested status"}
+	msgFulfillmentStatusTransition  = &i18n.Message{ID: "app.order.change_order_status.fulfillment.app_error", Other: "order status can only be moved along the fulfillment (paid, processing, shipped, delivered) or cancelled"}
+)
+
+// orderStatusTransitions contains the allowed order lifecycle moves
+var orderStatusTransitions = map[model.OrderStatus][]model.OrderStatus{
+	model.OrderStatusPendingPayment:    {model.OrderStatusPaid, model.OrderStatusCancelled},
+	model.OrderStatusPaid:              {model.OrderStatusProcessing, model.OrderStatusCancelled, model.OrderStatusRefunded, model.OrderStatusPartiallyRefunded},
+	model.OrderStatusProcessing:        {model.OrderStatusShipped, model.OrderStatusCancelled, model.OrderStatusRefunded, model.OrderStatusPartiallyRefunded},
+	model.OrderStatusShipped:           {model.OrderStatusDelivered, model.OrderStatusRefunded, model.OrderStatusPartiallyRefunded},
+	model.OrderStatusDelivered:         {model.OrderStatusRefunded, model.OrderStatusPartiallyRefunded},
+	model.OrderStatusPartiallyRefunded: {model.OrderStatusProcessing, model.OrderStatusShipped, model.OrderStatusDelivered, model.OrderStatusPartiallyRefunded, model.OrderStatusRefunded},
+	model.OrderStatusCancelled:         {},
+	model.OrderStatusRefunded:          {},
+}
+
+// orderFulfillmentTransitions contains the moves the admin can make by hand, the payment and refund statuses are set only by their flows
+var orderFulfillmentTransitions = map[model.OrderStatus][]model.OrderStatus{
+	model.OrderStatusPaid:              {model.OrderStatusProcessing},
+	model.OrderStatusProcessing:        {model.OrderStatusShipped},
+	model.OrderStatusShipped:           {model.OrderStatusDelivered},
+	model.OrderStatusPartiallyRefunded: {model.OrderStatusProcessing, model.OrderStatusShipped, model.OrderStatusDelivered},
+}
+
+// CanTransitionOrderStatus checks if the order can be moved from one status to the other
+func CanTransitionOrderStatus(from, to model.OrderStatus) bool {
+	for _, s := range orderStatusTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// CanFulfillOrderStatus checks if the admin can move the order from one status to the other
+func CanFulfillOrderStatus(from, to model.OrderStatus) bool {
+	for _, s := range orderFulfillmentTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// TransitionOrderStatus moves the order to the given status if the lifecycle allows it
+// changedBy is nil when the change was not made by the user (payment events etc...)
+func (a *App) TransitionOrderStatus(orderID int64, to model.OrderStatus, note string, changedBy *int64) (*model.Order, *model.AppErr) {
+	o, err := a.GetOrder(orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.transitionOrderStatus(a.Srv().Store, o, to, note, changedBy)
+}
+
+// transitionOrderStatus moves the order using the given store, so it can be part of the unit of work
+func (a *App) transitionOrderStatus(st store.Store, o *model.Order, to model.OrderStatus, note string, changedBy *int64) (*model.Order, *model.AppErr) {
+	from, ok := o.StatusValue()
+	if !ok {
+		return nil, model.NewAppErr("TransitionOrderStatus", model.ErrInternal, locale.GetUserLocalizer("en"), msgInvalidOrderStatus, http.StatusInternalServerError, nil)
+	}
+	if !CanTransitionOrderStatus(from, to) {
+		details := map[string]interface{}{"from": from.String(), "to": to.String()}
+		return nil, model.NewAppErr("TransitionOrderStatus", model.ErrConflict, locale.GetUserLocalizer("en"), msgInvalidOrderStatusTransition, http.StatusConflict, details)
+	}
+
+	h := &model.OrderStatusHistory{
+		OrderID:    o.ID,
+		FromStatus: model.NewString(from.String()),
+		ToStatus:   to.String(),
+		ChangedBy:  changedBy,
+	}
+	if note != "" {
+		h.Note = model.NewString(note)
+	}
+	h.PreSave()
+
+	var shippedAt *time.Time
+	if to == model.OrderStatusShipped && o.ShippedAt == nil {
+		shippedAt = &h.CreatedAt
+	}
+
+	if err := st.Order().UpdateStatus(h, shippedAt); err != nil {
+		return nil, err
+	}
+
+	o.Status = to.String()
+	if shippedAt != nil {
+		o.ShippedAt = shippedAt
+	}
+	return o, nil
+}
+
+// ChangeOrderStatus handles the admin status change request
+// the admin moves the order along the fulfillment or cancels it, the payment and refund statuses are set only by their flows
+func (a *App) ChangeOrderStatus(orderID int64, sc *model.OrderStatusChange, changedBy int64) (*model.Order, *model.AppErr) {
+	if err := sc.Validate(); err != nil {
+		return nil, err
+	}
+
+	to, _ := model.OrderStatusFromString(sc.Status)
+	if to == model.OrderStatusRefunded || to == model.OrderStatusPartiallyRefunded {
+		return nil, model.NewAppErr("ChangeOrderStatus", model.ErrInvalid, locale.GetUserLocalizer("en"), msgRefundStatusRequiresRefund, http.StatusBadRequest, nil)
+	}
+
+	if to == model.OrderStatusCancelled {
+		return a.cancelOrder(orderID, sc.Note, changedBy)
+	}
+
+	o, err := a.GetOrder(orderID)
+	if err != nil {
+		return nil, err
+	}
+	if from, _ := o.StatusValue(); !CanFulfillOrderStatus(from, to) {
+		details := map[string]interface{}{"from": o.Status, "to": to.String()}
+		return nil, model.NewAppErr("ChangeOrderStatus", model.ErrConflict, locale.GetUserLocalizer("en"), msgFulfillmentStatusTransition, http.StatusConflict, details)
+	}
+
+	return a.transitionOrderStatus(a.Srv().Store, o, to, sc.Note, &changedBy)
+}
+
+// cancelOrder cancels the order that was not shipped yet, returns its lines to the stock and releases its promotions
+// the paid order gets the remaining paid amount refunded, so the cancelled order never keeps the money
+func (a *App) cancelOrder(orderID int64, note string, changedBy int64) (*model.Order, *model.AppErr) {
+	tx, err := a.Srv().Store.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	o, err := tx.Order().GetForUpdate(orderID)
+	if err != nil {
+		return nil, err
+	}
+	from, _ := o.StatusValue()
+	if !CanTransitionOrderStatus(from, model.OrderStatusCancelled) {
+		details := map[string]interface{}{"from": o.Status, "to": model.OrderStatusCancelled.String()}
+		return nil, model.NewAppErr("cancelOrder", model.ErrConflict, locale.GetUserLocalizer("en"), msgInvalidOrderStatusTransition, http.StatusConflict, details)
+	}
+
+	var r *model.OrderRefund
+	if from != model.OrderStatusPendingPayment {
+		if r, err = a.refundCancelledOrder(tx, o, note, changedBy); err != nil {
+			return nil, err
+		}
+	}
+
+	// the money has already been returned when there is the refund, so the failures bellow have to be logged for the manual fix
+	if _, err := a.transitionOrderStatus(tx, o, model.OrderStatusCancelled, note, &changedBy); err != nil {
+		a.logUnsavedRefund(o, r, err)
+		return nil, err
+	}
+	if err := a.releaseOrderStock(tx, o); err != nil {
+		a.logUnsavedRefund(o, r, err)
+		return nil, err
+	}
+	if err := a.releaseOrderPromotions(tx, o); err != nil {
+		a.logUnsavedRefund(o, r, err)
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		a.logUnsavedRefund(o, r, err)
+		return nil, err
+	}
+	a.dropStockReservation(o.ID)
+
+	return o, nil
+}
+
+// refundCancelledOrder refunds the remaining paid amount of the order that is being cancelled and records the refund
+func (a *App) refundCancelledOrder(st store.Store, o *model.Order, note string, changedBy int64) (*model.OrderRefund, *model.AppErr) {
+	refunded, err := st.Refund().GetRefundedAmount(o.ID)
+	if err != nil {
+		return nil, err
+	}
+	remaining := o.Total - refunded
+	if remaining <= 0 || o.PaymentIntentID == "" {
+		return nil, nil
+	}
+
+	refundID, pErr := a.PaymentProvider().Refund(o.PaymentIntentID, uint64(remaining), "usd")
+	if pErr != nil {
+		a.Log().Error(pErr.Error(), zlog.Int64("order_id", o.ID), zlog.Err(pErr))
+		return nil, model.NewAppErr("cancelOrder", model.ErrInternal, locale.GetUserLocalizer("en"), msgRefundPayment, http.StatusInternalServerError, map[string]interface{}{"reason": pErr.Error()})
+	}
+
+	r := &model.OrderRefund{
+		OrderID:          o.ID,
+		ProviderRefundID: refundID,
+		Amount:           remaining,
+		CreatedBy:        &changedBy,
+		Items:            make([]*model.OrderRefundItem, 0),
+	}
+	if note != "" {
+		r.Reason = model.NewString(note)
+	}
+	r.PreSave()
+
+	if _, err := st.Refund().Save(r); err != nil {
+		a.logUnsavedRefund(o, r, err)
+		return nil, err
+	}
+	return r, nil
+}
+
+// logUnsavedRefund logs the refund that was made in the provider but could not be saved with the order
+func (a *App) logUnsavedRefund(o *model.Order, r *model.OrderRefund, err *model.AppErr) {
+	if r == nil {
+		return
+	}
+	a.Log().Error("could not save the refund of the cancelled order", zlog.Int64("order_id", o.ID), zlog.String("refund_id", r.ProviderRefundID), zlog.Err(err))
+}
+
+// GetOrderStatusHistory gets the order status changes
+func (a *App) GetOrderStatusHistory(orderID int64) ([]*model.OrderStatusHistory, *model.AppErr) {
+	return a.Srv().Store.Order().GetStatusHistory(orderID)
+}
+
+// recordInitialOrderStatus records the status the order was created with
+func (a *App) recordInitialOrderStatus(st store.Store, o *model.Order) *model.AppErr {
+	h := &model.OrderStatusHistory{
+		OrderID:  o.ID,
+		ToStatus: o.Status,
+	}
+	h.PreSave()
+
+	_, err := st.Order().InsertStatusHistory(h)
+	return err
+}
@@ -0,0 +1,77 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/dankobgd/ecommerce-shop/model"
+)
+
+func TestCanTransitionOrderStatus(t *testing.T) {
+	tests := []struct {
+		from model.OrderStatus
+		to   model.OrderStatus
+		want bool
+	}{
+		{model.OrderStatusPendingPayment, model.OrderStatusPaid, true},
+		{model.OrderStatusPendingPayment, model.OrderStatusCancelled, true},
+		{model.OrderStatusPendingPayment, model.OrderStatusShipped, false},
+		{model.OrderStatusPendingPayment, model.OrderStatusRefunded, false},
+		{model.OrderStatusPaid, model.OrderStatusProcessing, true},
+		{model.OrderStatusPaid, model.OrderStatusPartiallyRefunded, true},
+		{model.OrderStatusPaid, model.OrderStatusDelivered, false},
+		{model.OrderStatusPaid, model.OrderStatusPendingPayment, false},
+		{model.OrderStatusProcessing, model.OrderStatusShipped, true},
+		{model.OrderStatusShipped, model.OrderStatusDelivered, true},
+		{model.OrderStatusShipped, model.OrderStatusCancelled, false},
+		{model.OrderStatusDelivered, model.OrderStatusRefunded, true},
+		{model.OrderStatusDelivered, model.OrderStatusShipped, false},
+		{model.OrderStatusPartiallyRefunded, model.OrderStatusPartiallyRefunded, true},
+		{model.OrderStatusPartiallyRefunded, model.OrderStatusRefunded, true},
+		{model.OrderStatusPartiallyRefunded, model.OrderStatusCancelled, false},
+		{model.OrderStatusCancelled, model.OrderStatusPaid, false},
+		{model.OrderStatusCancelled, model.OrderStatusCancelled, false},
+		{model.OrderStatusRefunded, model.OrderStatusPartiallyRefunded, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.from.String()+" to "+tt.to.String(), func(t *testing.T) {
+			if got := CanTransitionOrderStatus(tt.from, tt.to); got != tt.want {
+				t.Errorf("CanTransitionOrderStatus(%s, %s) = %v, want %v", tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCanFulfillOrderStatus(t *testing.T) {
+	tests := []struct {
+		from model.OrderStatus
+		to   model.OrderStatus
+		want bool
+	}{
+		{model.OrderStatusPaid, model.OrderStatusProcessing, true},
+		{model.OrderStatusProcessing, model.OrderStatusShipped, true},
+		{model.OrderStatusShipped, model.OrderStatusDelivered, true},
+		{model.OrderStatusPartiallyRefunded, model.OrderStatusShipped, true},
+		{model.OrderStatusPendingPayment, model.OrderStatusPaid, false},
+		{model.OrderStatusPaid, model.OrderStatusRefunded, false},
+		{model.OrderStatusPaid, model.OrderStatusShipped, false},
+		{model.OrderStatusDelivered, model.OrderStatusPartiallyRefunded, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.from.String()+" to "+tt.to.String(), func(t *testing.T) {
+			if got := CanFulfillOrderStatus(tt.from, tt.to); got != tt.want {
+				t.Errorf("CanFulfillOrderStatus(%s, %s) = %v, want %v", tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+
+	// every move the admin can make has to be allowed by the lifecycle
+	for from, tos := range orderFulfillmentTransitions {
+		for _, to := range tos {
+			if !CanTransitionOrderStatus(from, to) {
+				t.Errorf("fulfillment move %s to %s is not in the order lifecycle", from, to)
+			}
+		}
+	}
+}
@@ -5,12 +5,14 @@ import (
 	"io/ioutil"
 	"mime/multipart"
 	"net/http"
+	"net/url"
 	"strconv"
 	"time"
 
 	"github.com/dankobgd/ecommerce-shop/model"
 	"github.com/dankobgd/ecommerce-shop/utils/locale"
 	"github.com/dankobgd/ecommerce-shop/zlog"
+	"github.com/jmoiron/sqlx/types"
 	"github.com/nicksnyder/go-i18n/v2/i18n"
 )
 
@@ -20,6 +22,7 @@ var (
 	msgErrPropsJSONFile    = &i18n.Message{ID: "app.product.get_product_properties.app_error", Other: "error parsing properties json file"}
 	msgProductImageFileErr = &i18n.Message{ID: "app.product.create_product_image.formfile.app_error", Other: "error parsing product image"}
 	msgProductImagesErr    = &i18n.Message{ID: "app.product.create_product_images.formfile.app_error", Other: "No images provided"}
+	msgProductNotFound     = &i18n.Message{ID: "app.product.get_product.not_found.app_error", Other: "product not found"}
 )
 
 // CreateProduct creates the new product in the system
@@ -28,6 +31,9 @@ func (a *App) CreateProduct(p *model.Product, thumbnailFH *multipart.FileHeader,
 	if err := p.Validate(thumbnailFH); err != nil {
 		return nil, err
 	}
+	if err := a.validateProductProperties(p.CategoryID, p.Properties); err != nil {
+		return nil, err
+	}
 
 	thumbnail, err := thumbnailFH.Open()
 	if err != nil {
@@ -49,6 +55,10 @@ func (a *App) CreateProduct(p *model.Product, thumbnailFH *multipart.FileHeader,
 		a.Log().Error(pErr.Error(), zlog.Err(pErr))
 		return nil, pErr
 	}
+	if err := a.createDefaultVariant(product); err != nil {
+		a.Log().Error(err.Error(), zlog.Err(err))
+		return nil, err
+	}
 
 	// handle optional create product tags
 	if len(tagids) > 0 {
@@ -119,6 +129,20 @@ func (a *App) PatchProduct(pid int64, patch *model.ProductPatch, fh *multipart.F
 		return nil, err
 	}
 
+	// the properties are validated only when they or the category change, so the products saved before the schema change can still be patched
+	if patch.CategoryID != nil || patch.Properties != nil {
+		categoryID, props := old.CategoryID, old.Properties
+		if patch.CategoryID != nil {
+			categoryID = *patch.CategoryID
+		}
+		if patch.Properties != nil {
+			props = patch.Properties
+		}
+		if err := a.validateProductProperties(categoryID, props); err != nil {
+			return nil, err
+		}
+	}
+
 	oldPublicID := old.ImagePublicID
 
 	if fh != nil {
@@ -180,19 +204,108 @@ func (a *App) DeleteProduct(pid int64) *model.AppErr {
 	return nil
 }
 
-// GetProduct gets the product by the id
+// GetProduct gets the product by the id, with its options and variants
 func (a *App) GetProduct(pid int64) (*model.Product, *model.AppErr) {
-	return a.Srv().Store.Product().Get(pid)
+	p, err := a.Srv().Store.Product().Get(pid)
+	if err != nil {
+		return nil, err
+	}
+
+	opts, err := a.GetProductOptions(pid)
+	if err != nil {
+		return nil, err
+	}
+	variants, err := a.GetProductVariants(pid)
+	if err != nil {
+		return nil, err
+	}
+
+	p.Options = opts
+	p.Variants = variants
+	if err := a.attachProductBundle(p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// GetProducts gets all products that match the listing query filters
+// the property filters are whitelisted against the schemas of the filtered categories
+func (a *App) GetProducts(query url.Values, sort model.ProductSort, limit, offset int) ([]*model.Product, *model.AppErr) {
+	filter, _, err := a.parseProductFilter(query)
+	if err != nil {
+		return nil, err
+	}
+	return a.Srv().Store.Product().GetAll(filter, sort, limit, offset)
+}
+
+// GetProductFacets counts the facets of the products that match the listing query filters
+// the search narrows the facets down to the full text search results, the nil search counts the whole listing
+func (a *App) GetProductFacets(query url.Values, search *model.SearchQuery) (*model.ProductFacets, *model.AppErr) {
+	filter, categories, err := a.parseProductFilter(query)
+	if err != nil {
+		return nil, err
+	}
+	if search != nil {
+		filter = model.FilterAnd{&model.FilterSearch{Query: search}, filter}
+	}
+	return a.Srv().Store.Product().GetFacets(filter, categories)
+}
+
+// parseProductFilter parses the listing query filters, it also returns the filtered categories
+func (a *App) parseProductFilter(query url.Values) (model.FilterNode, []*model.Category, *model.AppErr) {
+	categories, err := a.Srv().Store.Category().ListBySlugs(model.CategorySlugs(query))
+	if err != nil {
+		return nil, nil, err
+	}
+	filter, err := model.ParseProductFilter(query, categories)
+	if err != nil {
+		return nil, nil, err
+	}
+	return filter, categories, nil
+}
+
+// GetCategoryProducts gets the products of the category and its descendants that match the listing query filters
+func (a *App) GetCategoryProducts(cid int64, query url.Values, sort model.ProductSort, limit, offset int) ([]*model.Product, *model.AppErr) {
+	c, err := a.Srv().Store.Category().Get(cid)
+	if err != nil {
+		return nil, err
+	}
+	query = cloneQuery(query)
+	query["category"] = []string{c.Slug}
+	return a.GetProducts(query, sort, limit, offset)
+}
+
+// GetBrandProducts gets the products of the brand that match the listing query filters
+func (a *App) GetBrandProducts(bid int64, query url.Values, sort model.ProductSort, limit, offset int) ([]*model.Product, *model.AppErr) {
+	b, err := a.Srv().Store.Brand().Get(bid)
+	if err != nil {
+		return nil, err
+	}
+	query = cloneQuery(query)
+	query["brand"] = []string{b.Slug}
+	return a.GetProducts(query, sort, limit, offset)
 }
 
-// GetProducts gets all products from the db
-func (a *App) GetProducts(filters map[string][]string, limit, offset int) ([]*model.Product, *model.AppErr) {
-	return a.Srv().Store.Product().GetAll(filters, limit, offset)
+func cloneQuery(query url.Values) url.Values {
+	clone := make(url.Values, len(query))
+	for k, v := range query {
+		clone[k] = v
+	}
+	return clone
+}
+
+// validateProductProperties validates the product properties against the attribute schema of its category
+func (a *App) validateProductProperties(categoryID int64, props *types.JSONText) *model.AppErr {
+	c, err := a.Srv().Store.Category().Get(categoryID)
+	if err != nil {
+		return err
+	}
+	return c.Schema().ValidateProperties(props)
 }
 
 // GetFeaturedProducts returns featured products
-func (a *App) GetFeaturedProducts(limit, offset int) ([]*model.Product, *model.AppErr) {
-	return a.Srv().Store.Product().GetFeatured(limit, offset)
+func (a *App) GetFeaturedProducts(sort model.ProductSort, limit, offset int) ([]*model.Product, *model.AppErr) {
+	return a.Srv().Store.Product().GetFeatured(sort, limit, offset)
 }
 
 // GetMostSoldProducts returns most sold products
@@ -205,9 +318,9 @@ func (a *App) GetBestDealsProducts(limit, offset int) ([]*model.Product, *model.
 	return a.Srv().Store.Product().GetBestDeals(limit, offset)
 }
 
-// GetProductLatestPricing creates the discount
-func (a *App) GetProductLatestPricing(pid int64) (*model.ProductPricing, *model.AppErr) {
-	return a.Srv().Store.Product().GetLatestPricing(pid)
+// GetProductLatestPricing gets the latest pricing of the product, or of its variant when the variant id is given
+func (a *App) GetProductLatestPricing(pid int64, variantID *int64) (*model.ProductPricing, *model.AppErr) {
+	return a.Srv().Store.Product().GetLatestPricing(pid, variantID)
 }
 
 // DeleteProducts creates the discount
@@ -216,19 +329,22 @@ func (a *App) DeleteProducts(ids []int) *model.AppErr {
 }
 
 // AddProductPricing adds the new pricing (updates the prev val and creates 2 new entries)
+// with the variant id the pricing is added to the variant, the variant without its own pricing starts from the product price
 func (a *App) AddProductPricing(pricing *model.ProductPricing) (*model.ProductPricing, *model.AppErr) {
-	old, err := a.GetProductLatestPricing(pricing.ProductID)
+	old, err := a.latestPricingToEnd(pricing)
 	if err != nil {
 		return nil, err
 	}
 
 	t := pricing.SaleStarts.Add(time.Millisecond - 1)
 
-	patch := &model.ProductPricingPatch{SaleEnds: &t}
-	old.Patch(patch)
+	if old.PriceID != 0 {
+		patch := &model.ProductPricingPatch{SaleEnds: &t}
+		old.Patch(patch)
 
-	if _, err := a.UpdateProductPricing(old); err != nil {
-		return nil, err
+		if _, err := a.UpdateProductPricing(old); err != nil {
+			return nil, err
+		}
 	}
 
 	pricing.OriginalPrice = old.Price
@@ -240,6 +356,7 @@ func (a *App) AddProductPricing(pricing *model.ProductPricing) (*model.ProductPr
 
 	pricingAfter := &model.ProductPricing{
 		ProductID:     pricing.ProductID,
+		VariantID:     pricing.VariantID,
 		Price:         old.Price,
 		OriginalPrice: discount.Price,
 		SaleStarts:    pricing.SaleEnds.Add(time.Millisecond),
@@ -252,6 +369,28 @@ func (a *App) AddProductPricing(pricing *model.ProductPricing) (*model.ProductPr
 	return pricing, nil
 }
 
+// latestPricingToEnd gets the pricing the new pricing replaces
+// for the variant that is sold for the product price it is not stored, so it has no price id
+func (a *App) latestPricingToEnd(pricing *model.ProductPricing) (*model.ProductPricing, *model.AppErr) {
+	if pricing.VariantID == nil {
+		return a.GetProductLatestPricing(pricing.ProductID, nil)
+	}
+
+	v, err := a.Srv().Store.ProductVariant().Get(pricing.ProductID, *pricing.VariantID)
+	if err != nil {
+		return nil, err
+	}
+
+	old, err := a.GetProductLatestPricing(pricing.ProductID, pricing.VariantID)
+	if err != nil && err.StatusCode != http.StatusNotFound {
+		return nil, err
+	}
+	if old == nil {
+		old = &model.ProductPricing{ProductID: v.ProductID, VariantID: &v.ID, Price: v.Price, OriginalPrice: v.OriginalPrice}
+	}
+	return old, nil
+}
+
 // InsertProductPricing creates the discount
 func (a *App) InsertProductPricing(pricing *model.ProductPricing) (*model.ProductPricing, *model.AppErr) {
 	if err := pricing.Validate(); err != nil {
@@ -361,6 +500,11 @@ func (a *App) CreateProductImage(pid int64, img *model.ProductImage, fh *multipa
 	if err := img.Validate(fh); err != nil {
 		return nil, err
 	}
+	if img.VariantID != nil {
+		if _, err := a.Srv().Store.ProductVariant().Get(pid, *img.VariantID); err != nil {
+			return nil, err
+		}
+	}
 
 	thumbnail, err := fh.Open()
 	if err != nil {
@@ -521,7 +665,56 @@ func (a *App) DeleteProductImages(pid int64, ids []int) *model.AppErr {
 	return a.Srv().Store.ProductImage().BulkDelete(pid, ids)
 }
 
-// SearchProducts performs the full text search on products
-func (a *App) SearchProducts(query string) ([]*model.Product, *model.AppErr) {
-	return a.Srv().Store.Product().Search(query)
+// search suggestion settings
+const (
+	searchSuggestionsLimit = 10
+	searchSuggestionsTTL   = 10 * time.Minute
+)
+
+// SearchProducts performs the full text search on products that match the listing query filters
+// when the full text search finds nothing, the search falls back to the fuzzy match and the search query is marked fuzzy
+func (a *App) SearchProducts(search *model.SearchQuery, query url.Values, sort model.ProductSort, limit, offset int) ([]*model.Product, *model.AppErr) {
+	filter, _, err := a.parseProductFilter(query)
+	if err != nil {
+		return nil, err
+	}
+
+	products, err := a.Srv().Store.Product().Search(search, filter, sort, limit, offset)
+	if err != nil || len(products) > 0 || search.Fuzzy {
+		return products, err
+	}
+	// the page past the end is empty too, the fallback is only for the searches without any hits
+	if offset > 0 {
+		first, err := a.Srv().Store.Product().Search(search, filter, sort, 1, 0)
+		if err != nil || len(first) > 0 {
+			return products, err
+		}
+	}
+
+	search.Fuzzy = true
+	return a.Srv().Store.Product().Search(search, filter, sort, limit, offset)
+}
+
+// SuggestSearch returns the search completions of the input, the suggestions are cached per normalized input
+func (a *App) SuggestSearch(input string) ([]*model.SearchSuggestion, *model.AppErr) {
+	input = model.NormalizeSuggestionInput(input)
+	if input == "" {
+		return make([]*model.SearchSuggestion, 0), nil
+	}
+
+	cached, err := a.Srv().Store.SearchSuggestion().Get(input)
+	if err != nil {
+		a.Log().Error(err.Error(), zlog.Err(err))
+	} else if cached != nil {
+		return cached, nil
+	}
+
+	suggestions, err := a.Srv().Store.Product().Suggest(input, searchSuggestionsLimit)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.Srv().Store.SearchSuggestion().Save(input, suggestions, searchSuggestionsTTL); err != nil {
+		a.Log().Error(err.Error(), zlog.Err(err))
+	}
+	return suggestions, nil
 }
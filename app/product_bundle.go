@@ -0,0 +1,135 @@
+package app
+
+import (
+	"net/http"
+
+	"github.com/dankobgd/ecommerce-shop/model"
+)
+
+// GetProductBundle gets the bundle of the product with its components, prices and availability
+func (a *App) GetProductBundle(pid int64) (*model.BundleInfo, *model.AppErr) {
+	b, err := a.Srv().Store.ProductBundle().Get(pid)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.loadBundleVariants(b); err != nil {
+		return nil, err
+	}
+	return b.Info(), nil
+}
+
+// SaveProductBundle creates or replaces the bundle of the product
+// the components can't be other bundles, and the product can't become the bundle while it's the component of another bundle
+func (a *App) SaveProductBundle(pid int64, b *model.ProductBundle) (*model.BundleInfo, *model.AppErr) {
+	b.ProductID = pid
+	if err := b.Validate(); err != nil {
+		return nil, err
+	}
+	b.PreSave()
+
+	if _, err := a.Srv().Store.Product().Get(pid); err != nil {
+		return nil, err
+	}
+	if err := a.loadBundleVariants(b); err != nil {
+		return nil, err
+	}
+
+	pids := make([]int64, 0, len(b.Items))
+	for _, item := range b.Items {
+		if item.Variant != nil {
+			pids = append(pids, item.Variant.ProductID)
+		}
+	}
+	nested, err := a.Srv().Store.ProductBundle().ListByProductIDS(pids)
+	if err != nil {
+		return nil, err
+	}
+	bundles := make(map[int64]bool, len(nested))
+	for _, n := range nested {
+		bundles[n.ProductID] = true
+	}
+	isComponent, err := a.Srv().Store.ProductBundle().IsComponent(pid)
+	if err != nil {
+		return nil, err
+	}
+	if err := b.ValidateComponents(bundles, isComponent); err != nil {
+		return nil, err
+	}
+
+	saved, err := a.Srv().Store.ProductBundle().Save(b)
+	if err != nil {
+		return nil, err
+	}
+	return saved.Info(), nil
+}
+
+// DeleteProductBundle turns the bundle back into the regular product
+func (a *App) DeleteProductBundle(pid int64) *model.AppErr {
+	return a.Srv().Store.ProductBundle().Delete(pid)
+}
+
+// loadBundleVariants attaches the current component variants to the bundles, with their prices and stock
+func (a *App) loadBundleVariants(bundles ...*model.ProductBundle) *model.AppErr {
+	vids := make([]int64, 0)
+	for _, b := range bundles {
+		for _, item := range b.Items {
+			vids = append(vids, item.VariantID)
+		}
+	}
+
+	variants, err := a.Srv().Store.ProductVariant().ListByIDS(vids)
+	if err != nil {
+		return err
+	}
+	byID := make(map[int64]*model.ProductVariant, len(variants))
+	for _, v := range variants {
+		byID[v.ID] = v
+	}
+
+	for _, b := range bundles {
+		for _, item := range b.Items {
+			item.Variant = byID[item.VariantID]
+		}
+	}
+	return nil
+}
+
+// getCartBundles gets the bundles among the cart variant products, by the bundle product id
+func (a *App) getCartBundles(variants []*model.ProductVariant) (map[int64]*model.ProductBundle, *model.AppErr) {
+	pids := make([]int64, 0, len(variants))
+	for _, v := range variants {
+		if v != nil {
+			pids = append(pids, v.ProductID)
+		}
+	}
+
+	bundles, err := a.Srv().Store.ProductBundle().ListByProductIDS(pids)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.loadBundleVariants(bundles...); err != nil {
+		return nil, err
+	}
+
+	byProduct := make(map[int64]*model.ProductBundle, len(bundles))
+	for _, b := range bundles {
+		byProduct[b.ProductID] = b
+	}
+	return byProduct, nil
+}
+
+// attachProductBundle attaches the bundle to the bundle product, its stock is the number of the bundles the components can make
+func (a *App) attachProductBundle(p *model.Product) *model.AppErr {
+	info, err := a.GetProductBundle(p.ID)
+	if err != nil {
+		if err.StatusCode == http.StatusNotFound {
+			return nil
+		}
+		return err
+	}
+
+	p.Bundle = info
+	p.StockQuantity = info.StockQuantity
+	p.InStock = info.StockQuantity > 0
+	return nil
+}
@@ -0,0 +1,67 @@
+package app
+
+import (
+	"github.com/dankobgd/ecommerce-shop/model"
+	"github.com/dankobgd/ecommerce-shop/zlog"
+)
+
+// recommendation settings
+const (
+	productRecommendationsLimit = 12
+	recommendationHistoryDays   = 365
+	recommendationMinSupport    = 2
+	recommendationTopN          = 24
+)
+
+// GetProductRecommendations gets the products the customers also bought with the product, the best first
+// when there are not enough co-purchases the rest are the best sellers of the product category
+func (a *App) GetProductRecommendations(pid int64) ([]*model.Product, *model.AppErr) {
+	p, err := a.Srv().Store.Product().Get(pid)
+	if err != nil {
+		return nil, err
+	}
+
+	// more ids than needed are taken, since the products that are not on sale are left out
+	ids, err := a.Srv().Store.ProductRecommendation().GetRecommended(pid, recommendationTopN)
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) < recommendationTopN {
+		exclude := append([]int64{pid}, ids...)
+		bestSellers, err := a.Srv().Store.ProductRecommendation().GetCategoryBestSellers(p.CategoryID, exclude, recommendationTopN-len(ids))
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, bestSellers...)
+	}
+	if len(ids) == 0 {
+		return make([]*model.Product, 0), nil
+	}
+
+	byID, err := a.getProductsByID(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	products := make([]*model.Product, 0, productRecommendationsLimit)
+	for _, id := range ids {
+		if len(products) == productRecommendationsLimit {
+			break
+		}
+		if rec, ok := byID[id]; ok {
+			products = append(products, rec)
+		}
+	}
+	return products, nil
+}
+
+// recomputeRecommendations recomputes the co-purchase recommendations of all products from the order history
+func (a *App) recomputeRecommendations() {
+	params := model.RecommendationParams{Days: recommendationHistoryDays, MinSupport: recommendationMinSupport, TopN: recommendationTopN}
+	n, err := a.Srv().Store.ProductRecommendation().Recompute(params)
+	if err != nil {
+		a.Log().Error(err.Error(), zlog.Err(err))
+		return
+	}
+	a.Log().Info("recomputed product recommendations", zlog.Int("pairs", n))
+}
@@ -0,0 +1,84 @@
+package app
+
+import (
+	"github.com/dankobgd/ecommerce-shop/model"
+)
+
+// GetProductRelations gets the relations of the product with their related products, the empty type gets all types
+// the relations whose related product is not currently on sale are left out
+func (a *App) GetProductRelations(pid int64, typ model.ProductRelationType) ([]*model.ProductRelation, *model.AppErr) {
+	relations, err := a.Srv().Store.ProductRelation().GetAll(pid, typ)
+	if err != nil {
+		return nil, err
+	}
+	if len(relations) == 0 {
+		return relations, nil
+	}
+
+	ids := make([]int64, 0, len(relations))
+	for _, r := range relations {
+		ids = append(ids, r.RelatedID)
+	}
+	byID, err := a.getProductsByID(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	listed := make([]*model.ProductRelation, 0, len(relations))
+	for _, r := range relations {
+		if p, ok := byID[r.RelatedID]; ok {
+			r.Product = p
+			listed = append(listed, r)
+		}
+	}
+	return listed, nil
+}
+
+// ReplaceProductRelations replaces the related products of the relation type, in the given order
+func (a *App) ReplaceProductRelations(pid int64, typ model.ProductRelationType, relatedIDs []int) ([]*model.ProductRelation, *model.AppErr) {
+	if err := model.ValidateRelatedIDs(pid, relatedIDs); err != nil {
+		return nil, err
+	}
+	if _, err := a.Srv().Store.Product().Get(pid); err != nil {
+		return nil, err
+	}
+	return a.Srv().Store.ProductRelation().Replace(pid, typ, relatedIDs)
+}
+
+// GetCartCrossSells gets the cross sell and accessory products of the cart products, the cart products are not suggested
+func (a *App) GetCartCrossSells(pids []int64, limit int) ([]*model.Product, *model.AppErr) {
+	ids, err := a.Srv().Store.ProductRelation().GetCartSuggestions(pids, model.CartSuggestionTypes, limit)
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return make([]*model.Product, 0), nil
+	}
+
+	byID, err := a.getProductsByID(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	products := make([]*model.Product, 0, len(ids))
+	for _, id := range ids {
+		if p, ok := byID[id]; ok {
+			products = append(products, p)
+		}
+	}
+	return products, nil
+}
+
+// getProductsByID gets the currently listed products of the ids, by their id
+func (a *App) getProductsByID(ids []int64) (map[int64]*model.Product, *model.AppErr) {
+	products, err := a.Srv().Store.Product().ListByIDS(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[int64]*model.Product, len(products))
+	for _, p := range products {
+		byID[p.ID] = p
+	}
+	return byID, nil
+}
@@ -0,0 +1,187 @@
+package app
+
+import (
+	"net/http"
+
+	"github.com/dankobgd/ecommerce-shop/model"
+	"github.com/dankobgd/ecommerce-shop/utils/locale"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+var (
+	msgDeleteDefaultVariant = &i18n.Message{ID: "app.product_variant.delete_product_variant.default.app_error", Other: "the default product variant can not be deleted"}
+	msgProductOptionsInUse  = &i18n.Message{ID: "app.product_variant.replace_product_options.in_use.app_error", Other: "product variant options don't match the new product options"}
+)
+
+// createDefaultVariant creates the default variant of the new product, it takes over the product sku, price and stock
+func (a *App) createDefaultVariant(p *model.Product) *model.AppErr {
+	tx, err := a.Srv().Store.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := tx.ProductVariant().CreateDefaults([]int64{p.ID}); err != nil {
+		return err
+	}
+	v, err := tx.ProductVariant().GetDefault(p.ID)
+	if err != nil {
+		return err
+	}
+	if err := a.recordInitialStock(tx, v, v.StockQuantity); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// CreateProductVariant creates the new product variant with its initial stock
+func (a *App) CreateProductVariant(pid int64, v *model.ProductVariant) (*model.ProductVariant, *model.AppErr) {
+	tx, err := a.Srv().Store.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Product().Get(pid); err != nil {
+		return nil, err
+	}
+	opts, err := tx.ProductVariant().GetOptions(pid)
+	if err != nil {
+		return nil, err
+	}
+
+	v.ProductID = pid
+	v.IsDefault = false
+	v.PreSave()
+	if err := v.Validate(opts); err != nil {
+		return nil, err
+	}
+
+	saved, err := tx.ProductVariant().Save(v)
+	if err != nil {
+		return nil, err
+	}
+	if v.StockQuantity > 0 {
+		if _, err := tx.Inventory().Adjust(saved.ID, v.StockQuantity); err != nil {
+			return nil, err
+		}
+		if err := a.recordInitialStock(tx, saved, v.StockQuantity); err != nil {
+			return nil, err
+		}
+		saved.StockQuantity = v.StockQuantity
+		saved.InStock = true
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	saved.Images = make([]*model.ProductImage, 0)
+	return saved, nil
+}
+
+// GetProductVariant gets the product variant with its images
+func (a *App) GetProductVariant(pid, vid int64) (*model.ProductVariant, *model.AppErr) {
+	v, err := a.Srv().Store.ProductVariant().Get(pid, vid)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.attachVariantImages(pid, []*model.ProductVariant{v}); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// GetProductVariants gets all product variants with their images, the default one first
+func (a *App) GetProductVariants(pid int64) ([]*model.ProductVariant, *model.AppErr) {
+	variants, err := a.Srv().Store.ProductVariant().GetAll(pid)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.attachVariantImages(pid, variants); err != nil {
+		return nil, err
+	}
+	return variants, nil
+}
+
+// PatchProductVariant patches the product variant sku and options
+func (a *App) PatchProductVariant(pid, vid int64, patch *model.ProductVariantPatch) (*model.ProductVariant, *model.AppErr) {
+	old, err := a.Srv().Store.ProductVariant().Get(pid, vid)
+	if err != nil {
+		return nil, err
+	}
+	opts, err := a.Srv().Store.ProductVariant().GetOptions(pid)
+	if err != nil {
+		return nil, err
+	}
+
+	old.Patch(patch)
+	old.PreUpdate()
+	if err := old.Validate(opts); err != nil {
+		return nil, err
+	}
+
+	if _, err := a.Srv().Store.ProductVariant().Update(old); err != nil {
+		return nil, err
+	}
+	return a.GetProductVariant(pid, vid)
+}
+
+// DeleteProductVariant deletes the product variant, the default variant can't be deleted
+func (a *App) DeleteProductVariant(pid, vid int64) *model.AppErr {
+	v, err := a.Srv().Store.ProductVariant().Get(pid, vid)
+	if err != nil {
+		return err
+	}
+	if v.IsDefault {
+		return model.NewAppErr("DeleteProductVariant", model.ErrConflict, locale.GetUserLocalizer("en"), msgDeleteDefaultVariant, http.StatusConflict, nil)
+	}
+	return a.Srv().Store.ProductVariant().Delete(pid, vid)
+}
+
+// GetProductOptions gets the product option axes
+func (a *App) GetProductOptions(pid int64) ([]*model.ProductOption, *model.AppErr) {
+	return a.Srv().Store.ProductVariant().GetOptions(pid)
+}
+
+// ReplaceProductOptions replaces the product option axes, the existing variants must match the new axes
+func (a *App) ReplaceProductOptions(pid int64, opts []*model.ProductOption) ([]*model.ProductOption, *model.AppErr) {
+	if err := model.ValidateProductOptions(opts); err != nil {
+		return nil, err
+	}
+
+	variants, err := a.Srv().Store.ProductVariant().GetAll(pid)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range variants {
+		if !v.MatchesOptions(opts) {
+			return nil, model.NewAppErr("ReplaceProductOptions", model.ErrConflict, locale.GetUserLocalizer("en"), msgProductOptionsInUse, http.StatusConflict, map[string]interface{}{"variant_id": v.ID})
+		}
+	}
+
+	return a.Srv().Store.ProductVariant().ReplaceOptions(pid, opts)
+}
+
+// attachVariantImages sets the images of every variant
+func (a *App) attachVariantImages(pid int64, variants []*model.ProductVariant) *model.AppErr {
+	images, err := a.Srv().Store.ProductImage().GetAll(pid)
+	if err != nil {
+		return err
+	}
+
+	byVariant := make(map[int64][]*model.ProductImage)
+	for _, img := range images {
+		if img.VariantID != nil {
+			byVariant[*img.VariantID] = append(byVariant[*img.VariantID], img)
+		}
+	}
+	for _, v := range variants {
+		v.Images = byVariant[v.ID]
+		if v.Images == nil {
+			v.Images = make([]*model.ProductImage, 0)
+		}
+	}
+	return nil
+}
@@ -1,6 +1,8 @@
 package app
 
 import (
+	"net/http"
+
 	"github.com/dankobgd/ecommerce-shop/model"
 	"github.com/dankobgd/ecommerce-shop/zlog"
 	"github.com/nicksnyder/go-i18n/v2/i18n"
@@ -35,6 +37,9 @@ func (a *App) PatchPromotion(code string, patch *model.PromotionPatch) (*model.P
 
 	old.Patch(patch)
 	old.PreUpdate()
+	if err := old.Validate(); err != nil {
+		return nil, err
+	}
 	up, err := a.Srv().Store.Promotion().Update(code, old)
 	if err != nil {
 		return nil, err
@@ -69,7 +74,15 @@ func (a *App) IsUsedPromotion(code string, uid int64) *model.AppErr {
 }
 
 // GetPromotionStatus checks if the promotion is active and not already used by user
+// the generated code is checked by its campaign, the same way as when it is entered at the checkout
+// it's only the hint for the customer, the redemption is claimed atomically when the order is placed
 func (a *App) GetPromotionStatus(code string, userID int64) *model.AppErr {
+	if _, err := a.Srv().Store.Promotion().Get(code); err != nil {
+		if err.StatusCode != http.StatusNotFound {
+			return err
+		}
+		return a.promotionEngine(userID).CheckGeneratedCode(code)
+	}
 	if err := a.IsValidPromotion(code); err != nil {
 		return err
 	}
@@ -79,9 +92,9 @@ func (a *App) GetPromotionStatus(code string, userID int64) *model.AppErr {
 	return nil
 }
 
-// CreatePromotionDetail creates the new promotion detail
+// CreatePromotionDetail claims the new promotion detail, it fails when the promotion or the user reached the usage limit
 func (a *App) CreatePromotionDetail(pd *model.PromotionDetail) (*model.PromotionDetail, *model.AppErr) {
-	pdetail, pErr := a.Srv().Store.Promotion().InsertDetail(pd)
+	pdetail, pErr := a.Srv().Store.Promotion().ClaimDetail(pd)
 	if pErr != nil {
 		a.Log().Error(pErr.Error(), zlog.Err(pErr))
 		return nil, pErr
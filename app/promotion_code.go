@@ -0,0 +1,95 @@
+package app
+
+import (
+	"io"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/dankobgd/ecommerce-shop/model"
+	"github.com/dankobgd/ecommerce-shop/utils/locale"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+var (
+	msgCampaignAutomatic    = &i18n.Message{ID: "app.promotion_code.generate.automatic.app_error", Other: "automatic promotion can't have the generated codes"}
+	msgCodePatternExhausted = &i18n.Message{ID: "app.promotion_code.generate.exhausted.app_error", Other: "could not generate enough unique codes, use a longer pattern"}
+	msgExportPromotionCodes = &i18n.Message{ID: "app.promotion_code.export.app_error", Other: "could not export promotion codes"}
+)
+
+// promotionCodeAttempts is the number of the tries to replace the generated codes that are already taken
+const promotionCodeAttempts = 10
+
+// GeneratePromotionCodes generates the unique single use codes of the campaign, the promotion becomes the campaign
+// the codes never match the existing codes nor the promo codes, so every code resolves to one campaign
+func (a *App) GeneratePromotionCodes(promoCode string, b *model.PromotionCodeBatch) ([]*model.PromotionCode, *model.AppErr) {
+	b.PreSave()
+	if err := b.Validate(); err != nil {
+		return nil, err
+	}
+
+	promo, err := a.Srv().Store.Promotion().Get(promoCode)
+	if err != nil {
+		return nil, err
+	}
+	if promo.Automatic {
+		return nil, model.NewAppErr("GeneratePromotionCodes", model.ErrInvalid, locale.GetUserLocalizer("en"), msgCampaignAutomatic, http.StatusBadRequest, nil)
+	}
+
+	unique := make(map[string]bool, b.Count)
+	for attempt := 0; attempt < promotionCodeAttempts && len(unique) < b.Count; attempt++ {
+		candidates := make([]string, 0, b.Count-len(unique))
+		for len(candidates) < b.Count-len(unique) {
+			candidates = append(candidates, b.Generate())
+		}
+
+		taken, err := a.Srv().Store.PromotionCode().ListTaken(candidates)
+		if err != nil {
+			return nil, err
+		}
+		isTaken := make(map[string]bool, len(taken))
+		for _, c := range taken {
+			isTaken[c] = true
+		}
+		for _, c := range candidates {
+			if !isTaken[c] && len(unique) < b.Count {
+				unique[c] = true
+			}
+		}
+	}
+	if len(unique) < b.Count {
+		return nil, model.NewAppErr("GeneratePromotionCodes", model.ErrInvalid, locale.GetUserLocalizer("en"), msgCodePatternExhausted, http.StatusBadRequest, map[string]interface{}{"generated": len(unique)})
+	}
+
+	now := time.Now()
+	codes := make([]*model.PromotionCode, 0, len(unique))
+	for c := range unique {
+		codes = append(codes, &model.PromotionCode{Code: c, PromoCode: promo.PromoCode, CreatedAt: now})
+	}
+	sort.Slice(codes, func(i, j int) bool { return codes[i].Code < codes[j].Code })
+	if err := a.Srv().Store.PromotionCode().BulkInsert(promo.PromoCode, codes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// GetPromotionCodes gets the generated codes of the campaign with their redemptions
+func (a *App) GetPromotionCodes(promoCode string, limit, offset int) ([]*model.PromotionCode, *model.AppErr) {
+	return a.Srv().Store.PromotionCode().GetAll(promoCode, limit, offset)
+}
+
+// ExportPromotionCodes writes all generated codes of the campaign with their redemptions as the csv
+func (a *App) ExportPromotionCodes(promoCode string, w io.Writer) *model.AppErr {
+	if _, err := a.Srv().Store.Promotion().Get(promoCode); err != nil {
+		return err
+	}
+
+	codes, err := a.Srv().Store.PromotionCode().ListByPromotion(promoCode)
+	if err != nil {
+		return err
+	}
+	if err := model.WritePromotionCodesCSV(w, codes); err != nil {
+		return model.NewAppErr("ExportPromotionCodes", model.ErrInternal, locale.GetUserLocalizer("en"), msgExportPromotionCodes, http.StatusInternalServerError, nil)
+	}
+	return nil
+}
@@ -0,0 +1,249 @@
+package app
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/dankobgd/ecommerce-shop/model"
+	"github.com/dankobgd/ecommerce-shop/utils/locale"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+var (
+	msgPromoInactive      = &i18n.Message{ID: "app.promotion_engine.inactive.app_error", Other: "promo code is invalid or is no longer active"}
+	msgPromoUsageLimit    = &i18n.Message{ID: "app.promotion_engine.usage_limit.app_error", Other: "promo code has reached its usage limit"}
+	msgPromoUserLimit     = &i18n.Message{ID: "app.promotion_engine.usage_limit_per_user.app_error", Other: "you have already used this promo code"}
+	msgPromoMinOrder      = &i18n.Message{ID: "app.promotion_engine.min_order_amount.app_error", Other: "order subtotal is below the promo code minimum"}
+	msgPromoNoTargetItems = &i18n.Message{ID: "app.promotion_engine.targets.app_error", Other: "promo code doesn't apply to any item in the cart"}
+	msgPromoNoDiscount    = &i18n.Message{ID: "app.promotion_engine.no_discount.app_error", Other: "cart doesn't meet the promo code conditions"}
+	msgPromoNotStackable  = &i18n.Message{ID: "app.promotion_engine.not_stackable.app_error", Other: "promotion can't be combined with the other applied promotions"}
+	msgPromoCodeRedeemed  = &i18n.Message{ID: "app.promotion_engine.code_redeemed.app_error", Other: "promo code was already redeemed"}
+)
+
+// promotionEngine evaluates the promotion rules against the priced cart
+// every rule rejects the promotion with the error, the accepted promotion gives the discount allocated to the cart lines it applies to
+type promotionEngine struct {
+	app    *App
+	userID int64
+	now    time.Time
+}
+
+// promotionEngine returns the engine that evaluates the promotions for the user
+func (a *App) promotionEngine(userID int64) *promotionEngine {
+	return &promotionEngine{app: a, userID: userID, now: time.Now()}
+}
+
+// Apply evaluates the active automatic promotions and the promotion of the entered promo code, and applies their discounts to the quote
+// the entered code is the promo code or the generated code of the campaign
+// the promotions are evaluated from the highest priority, the exclusive promotion is applied only alone
+// every promotion that is not applied is added to the quote rejections with the reason
+func (e *promotionEngine) Apply(code *string, quote *model.Quote) *model.AppErr {
+	candidates, err := e.app.Srv().Store.Promotion().GetActiveAutomatic()
+	if err != nil {
+		return err
+	}
+
+	entered, enteredPromo := "", ""
+	if code != nil {
+		entered = *code
+	}
+	if entered != "" && !containsPromotion(candidates, entered) {
+		promo, generated, err := e.resolveCode(entered)
+		if err != nil {
+			if err.StatusCode != http.StatusBadRequest {
+				return err
+			}
+			quote.Rejected = append(quote.Rejected, promotionRejection(entered, false, err))
+		} else {
+			candidates = append(candidates, promo)
+			enteredPromo = promo.PromoCode
+			if generated != nil {
+				quote.GeneratedCode = &generated.Code
+			}
+		}
+	} else if entered != "" {
+		enteredPromo = entered
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].Priority != candidates[j].Priority {
+			return candidates[i].Priority > candidates[j].Priority
+		}
+		return candidates[i].PromoCode < candidates[j].PromoCode
+	})
+
+	exclusiveApplied := false
+	for _, promo := range candidates {
+		isEntered := enteredPromo != "" && promo.PromoCode == enteredPromo
+		key, automatic := promo.PromoCode, promo.Automatic && !isEntered
+		if isEntered {
+			key = entered
+		}
+		if len(quote.Discounts) > 0 && (promo.Exclusive || exclusiveApplied) {
+			quote.Rejected = append(quote.Rejected, promotionRejection(key, automatic, promotionRejected(msgPromoNotStackable, nil)))
+			continue
+		}
+
+		discount, err := e.Evaluate(promo, quote)
+		if err != nil {
+			if err.StatusCode != http.StatusBadRequest {
+				return err
+			}
+			quote.Rejected = append(quote.Rejected, promotionRejection(key, automatic, err))
+			continue
+		}
+
+		if isEntered {
+			quote.PromoCode = &promo.PromoCode
+			quote.PromoCodeType = &promo.Type
+			quote.PromoCodeAmount = &promo.Amount
+		}
+		exclusiveApplied = exclusiveApplied || promo.Exclusive
+		quote.AddDiscount(discount)
+	}
+	if quote.PromoCode == nil {
+		quote.GeneratedCode = nil
+	}
+	return nil
+}
+
+// resolveCode gets the promotion of the entered code, the generated code gives its campaign together with the code
+// the campaign itself can't be entered, only its generated codes that weren't redeemed yet
+func (e *promotionEngine) resolveCode(code string) (*model.Promotion, *model.PromotionCode, *model.AppErr) {
+	promo, err := e.app.Srv().Store.Promotion().Get(code)
+	if err == nil {
+		if promo.Campaign {
+			return nil, nil, promotionRejected(msgPromoInactive, nil)
+		}
+		return promo, nil, nil
+	}
+	if err.StatusCode != http.StatusNotFound {
+		return nil, nil, err
+	}
+
+	generated, err := e.app.Srv().Store.PromotionCode().Get(code)
+	if err != nil {
+		if err.StatusCode == http.StatusNotFound {
+			return nil, nil, promotionRejected(msgPromoInactive, nil)
+		}
+		return nil, nil, err
+	}
+	if generated.IsRedeemed() {
+		return nil, nil, promotionRejected(msgPromoCodeRedeemed, nil)
+	}
+
+	promo, err = e.app.Srv().Store.Promotion().Get(generated.PromoCode)
+	if err != nil {
+		return nil, nil, err
+	}
+	return promo, generated, nil
+}
+
+// CheckGeneratedCode checks that the generated code wasn't redeemed and that its campaign is active and under its usage limits
+func (e *promotionEngine) CheckGeneratedCode(code string) *model.AppErr {
+	promo, _, err := e.resolveCode(code)
+	if err != nil {
+		return err
+	}
+	if !promo.IsActive(e.now) {
+		return promotionRejected(msgPromoInactive, nil)
+	}
+	return e.checkUsage(promo)
+}
+
+// Evaluate checks the promotion conditions and limits, and returns its discount for the quote
+func (e *promotionEngine) Evaluate(promo *model.Promotion, quote *model.Quote) (*model.OrderDiscount, *model.AppErr) {
+	if !promo.IsActive(e.now) {
+		return nil, promotionRejected(msgPromoInactive, nil)
+	}
+	if err := e.checkUsage(promo); err != nil {
+		return nil, err
+	}
+	if quote.Subtotal < promo.MinOrderAmount {
+		return nil, promotionRejected(msgPromoMinOrder, map[string]interface{}{"min_order_amount": promo.MinOrderAmount})
+	}
+
+	targeted, err := e.targetedItems(promo, quote)
+	if err != nil {
+		return nil, err
+	}
+	hasTargeted := false
+	for _, t := range targeted {
+		hasTargeted = hasTargeted || t
+	}
+	if !hasTargeted {
+		return nil, promotionRejected(msgPromoNoTargetItems, nil)
+	}
+
+	discount := promo.ApplyTo(quote.Items, targeted, quote.ShippingDue())
+	if discount.Total() == 0 && promo.Type != model.PromotionTypeFreeShipping {
+		return nil, promotionRejected(msgPromoNoDiscount, nil)
+	}
+	return discount, nil
+}
+
+// checkUsage rejects the promotion that was redeemed as many times as its total or per user limit allows
+func (e *promotionEngine) checkUsage(promo *model.Promotion) *model.AppErr {
+	if promo.UsageLimit == nil && promo.UsageLimitPerUser == nil {
+		return nil
+	}
+
+	usage, err := e.app.Srv().Store.Promotion().GetUsage(promo.PromoCode, e.userID)
+	if err != nil {
+		return err
+	}
+	if promo.UsageLimit != nil && usage.Total >= *promo.UsageLimit {
+		return promotionRejected(msgPromoUsageLimit, nil)
+	}
+	if promo.UsageLimitPerUser != nil && usage.ByUser >= *promo.UsageLimitPerUser {
+		return promotionRejected(msgPromoUserLimit, nil)
+	}
+	return nil
+}
+
+// targetedItems marks the cart items the promotion applies to, all of them when it has no targets
+func (e *promotionEngine) targetedItems(promo *model.Promotion, quote *model.Quote) ([]bool, *model.AppErr) {
+	targeted := make([]bool, len(quote.Items))
+	if len(promo.Targets) == 0 {
+		for i := range targeted {
+			targeted[i] = true
+		}
+		return targeted, nil
+	}
+
+	pids := make([]int64, 0, len(quote.Items))
+	for _, item := range quote.Items {
+		pids = append(pids, item.ProductID)
+	}
+	ids, err := e.app.Srv().Store.Promotion().GetTargetedProductIDS(promo.PromoCode, pids)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		byID[id] = true
+	}
+	for i, item := range quote.Items {
+		targeted[i] = byID[item.ProductID]
+	}
+	return targeted, nil
+}
+
+// containsPromotion returns true if the promotion of the promo code is among the promotions
+func containsPromotion(promotions []*model.Promotion, code string) bool {
+	for _, p := range promotions {
+		if p.PromoCode == code {
+			return true
+		}
+	}
+	return false
+}
+
+func promotionRejection(code string, automatic bool, err *model.AppErr) *model.PromotionRejection {
+	return &model.PromotionRejection{PromoCode: code, Automatic: automatic, Reason: err.Message, ReasonID: err.ID}
+}
+
+func promotionRejected(msg *i18n.Message, details interface{}) *model.AppErr {
+	return model.NewAppErr("promotionEngine", model.ErrInvalid, locale.GetUserLocalizer("en"), msg, http.StatusBadRequest, details)
+}
@@ -0,0 +1,113 @@
+package app
+
+import (
+	"github.com/dankobgd/ecommerce-shop/model"
+)
+
+// cartCrossSellsLimit is the max number of the cross sell products suggested for the cart
+const cartCrossSellsLimit = 8
+
+// QuoteCart prices the cart without placing the order, the cross sells are included when they are asked for
+func (a *App) QuoteCart(userID int64, data *model.QuoteRequestData, crossSells bool) (*model.Quote, *model.AppErr) {
+	if err := data.Validate(); err != nil {
+		return nil, err
+	}
+
+	quote, _, err := a.priceCart(userID, data.Items, data.PromoCode)
+	if err != nil {
+		return nil, err
+	}
+
+	if crossSells {
+		if quote.CrossSells, err = a.GetCartCrossSells(data.ProductIDs(), cartCrossSellsLimit); err != nil {
+			return nil, err
+		}
+	}
+	return quote, nil
+}
+
+// priceCart prices the cart items and applies the promo code of the user
+// it returns the order lines of the items, the items without the variant get the default product variant
+// the bundle is priced by its pricing and its stock is derived from the components, it's expanded into the component lines
+func (a *App) priceCart(userID int64, items []*model.CartItem, promoCode *string) (*model.Quote, []*model.OrderDetail, *model.AppErr) {
+	variants, err := a.getOrderItemVariants(items)
+	if err != nil {
+		return nil, nil, err
+	}
+	bundles, err := a.getCartBundles(variants)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i, v := range variants {
+		if v == nil {
+			continue
+		}
+		if b, ok := bundles[v.ProductID]; ok {
+			bv := *v
+			bv.Price = b.Price()
+			bv.StockQuantity = b.Stock()
+			variants[i] = &bv
+		}
+	}
+	if err := model.ValidateCartItems(items, variants); err != nil {
+		return nil, nil, err
+	}
+
+	// calc subtotal price (price before discount, possible taxes etc...)
+	quote := &model.Quote{Items: make([]*model.QuoteItem, 0, len(items))}
+	lines := make([][]*model.OrderDetail, 0, len(items))
+	for i, v := range variants {
+		line := &model.QuoteItem{ProductID: v.ProductID, VariantID: v.ID, SKU: v.SKU, Quantity: items[i].Quantity, Price: v.Price}
+		line.Total = line.Price * line.Quantity
+		quote.Subtotal += line.Total
+		quote.Items = append(quote.Items, line)
+
+		b, ok := bundles[v.ProductID]
+		if !ok {
+			lines = append(lines, []*model.OrderDetail{{
+				ProductID:      v.ProductID,
+				VariantID:      v.ID,
+				Quantity:       line.Quantity,
+				HistoryPrice:   v.Price,
+				HistorySKU:     v.SKU,
+				HistoryOptions: v.Options,
+			}})
+			continue
+		}
+
+		details := b.OrderDetails(line.Quantity)
+		for _, d := range details {
+			line.Components = append(line.Components, &model.QuoteItem{ProductID: d.ProductID, VariantID: d.VariantID, SKU: d.HistorySKU, Quantity: d.Quantity, Price: d.HistoryPrice, Total: d.HistoryPrice * d.Quantity})
+		}
+		lines = append(lines, details)
+	}
+	orderDetails := make([]*model.OrderDetail, 0, len(lines))
+	for _, details := range lines {
+		for _, d := range details {
+			d.Line = len(orderDetails) + 1
+			orderDetails = append(orderDetails, d)
+		}
+	}
+
+	// calc total price (after possible discount)
+	quote.Shipping = a.Cfg().ShippingSettings.Fee
+	quote.Total = quote.Subtotal + quote.Shipping
+	quote.Discounts = make([]*model.OrderDiscount, 0)
+	quote.Rejected = make([]*model.PromotionRejection, 0)
+	if err := a.promotionEngine(userID).Apply(promoCode, quote); err != nil {
+		return nil, nil, err
+	}
+
+	// the item discount is split over its order lines, the bundle lines by the component prices
+	for i, item := range quote.Items {
+		weights := make([]int, len(lines[i]))
+		for j, d := range lines[i] {
+			weights[j] = d.HistoryPrice * d.Quantity
+		}
+		for j, amount := range model.AllocateAmount(item.Discount, weights) {
+			lines[i][j].Discount = amount
+		}
+	}
+
+	return quote, orderDetails, nil
+}
@@ -0,0 +1,219 @@
+package app
+
+import (
+	"math"
+	"net/http"
+
+	"github.com/dankobgd/ecommerce-shop/model"
+	"github.com/dankobgd/ecommerce-shop/store"
+	"github.com/dankobgd/ecommerce-shop/utils/locale"
+	"github.com/dankobgd/ecommerce-shop/zlog"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+var (
+	msgNothingToRefund      = &i18n.Message{ID: "app.refund.refund_order.nothing_to_refund.app_error", Other: "order has already been fully refunded"}
+	msgRefundAmountZero     = &i18n.Message{ID: "app.refund.refund_order.amount_zero.app_error", Other: "refund amount must be greater than 0"}
+	msgRefundAmountTooLarge = &i18n.Message{ID: "app.refund.refund_order.amount.app_error", Other: "refund amount is greater than the remaining order amount"}
+	msgRefundItemNotInOrder = &i18n.Message{ID: "app.refund.refund_order.item_not_in_order.app_error", Other: "refund item is not part of the order"}
+	msgRefundItemQuantity   = &i18n.Message{ID: "app.refund.refund_order.item_quantity.app_error", Other: "refund item quantity is greater than the remaining ordered quantity"}
+	msgRefundItemVariant    = &i18n.Message{ID: "app.refund.refund_order.item_variant.app_error", Other: "product is on multiple order lines, refund item must have the variant_id or the line"}
+	msgRefundStatus         = &i18n.Message{ID: "app.refund.refund_order.status.app_error", Other: "order in its current status can not be refunded"}
+	msgRefundPayment        = &i18n.Message{ID: "app.refund.refund_order.payment.app_error", Other: "could not refund the payment"}
+)
+
+// RefundOrder refunds the whole remaining order amount, the arbitrary amount or the specific order lines
+func (a *App) RefundOrder(orderID int64, rr *model.RefundRequest, userID int64, scope *model.IdempotencyScope) (*model.OrderRefund, *model.AppErr) {
+	if err := rr.Validate(); err != nil {
+		return nil, err
+	}
+
+	tx, err := a.Srv().Store.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// lock the order so the concurrent refunds can't go over the order total
+	o, err := tx.Order().GetForUpdate(orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	refunded, err := tx.Refund().GetRefundedAmount(orderID)
+	if err != nil {
+		return nil, err
+	}
+	remaining := o.Total - refunded
+	if remaining <= 0 {
+		return nil, model.NewAppErr("RefundOrder", model.ErrConflict, locale.GetUserLocalizer("en"), msgNothingToRefund, http.StatusConflict, nil)
+	}
+
+	r := &model.OrderRefund{
+		OrderID:   orderID,
+		CreatedBy: &userID,
+		Items:     make([]*model.OrderRefundItem, 0),
+	}
+	if rr.Reason != "" {
+		r.Reason = model.NewString(rr.Reason)
+	}
+
+	switch {
+	case rr.IsFull():
+		r.Amount = remaining
+	case rr.Amount != nil:
+		if *rr.Amount > remaining {
+			return nil, model.NewAppErr("RefundOrder", model.ErrInvalid, locale.GetUserLocalizer("en"), msgRefundAmountTooLarge, http.StatusBadRequest, map[string]interface{}{"remaining": remaining})
+		}
+		r.Amount = *rr.Amount
+	default:
+		items, amount, err := a.refundOrderItems(tx, o, rr.Items, remaining)
+		if err != nil {
+			return nil, err
+		}
+		r.Items = items
+		r.Amount = amount
+	}
+	// the lines with the 100% discount have nothing to refund
+	if r.Amount <= 0 {
+		return nil, model.NewAppErr("RefundOrder", model.ErrInvalid, locale.GetUserLocalizer("en"), msgRefundAmountZero, http.StatusBadRequest, nil)
+	}
+
+	from, _ := o.StatusValue()
+	to := model.OrderStatusPartiallyRefunded
+	if refunded+r.Amount == o.Total {
+		to = model.OrderStatusRefunded
+	}
+	if !CanTransitionOrderStatus(from, to) {
+		return nil, model.NewAppErr("RefundOrder", model.ErrConflict, locale.GetUserLocalizer("en"), msgRefundStatus, http.StatusConflict, map[string]interface{}{"status": o.Status})
+	}
+
+	scope.AttemptPayment()
+	refundID, pErr := a.PaymentProvider().Refund(o.PaymentIntentID, uint64(r.Amount), "usd")
+	if pErr != nil {
+		a.Log().Error(pErr.Error(), zlog.Int64("order_id", orderID), zlog.Err(pErr))
+		return nil, model.NewAppErr("RefundOrder", model.ErrInternal, locale.GetUserLocalizer("en"), msgRefundPayment, http.StatusInternalServerError, map[string]interface{}{"reason": pErr.Error()})
+	}
+
+	r.ProviderRefundID = refundID
+	r.PreSave()
+
+	// the money has already been returned, so the failures bellow have to be logged for the manual fix
+	if _, err := tx.Refund().Save(r); err != nil {
+		a.Log().Error("could not save the completed refund", zlog.Int64("order_id", orderID), zlog.String("refund_id", refundID), zlog.Err(err))
+		return nil, err
+	}
+	if _, err := a.transitionOrderStatus(tx, o, to, rr.Reason, &userID); err != nil {
+		a.Log().Error("could not update the refunded order status", zlog.Int64("order_id", orderID), zlog.String("refund_id", refundID), zlog.Err(err))
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		a.Log().Error("could not commit the completed refund", zlog.Int64("order_id", orderID), zlog.String("refund_id", refundID), zlog.Err(err))
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// GetOrderRefunds gets all refunds of the order
+func (a *App) GetOrderRefunds(orderID int64) ([]*model.OrderRefund, *model.AppErr) {
+	return a.Srv().Store.Refund().GetAll(orderID)
+}
+
+// refundOrderItems calculates the refund of the order lines
+// the line amount is reduced by the discounts allocated to the line, so the promotions are not refunded as cash
+func (a *App) refundOrderItems(st store.Store, o *model.Order, reqItems []*model.RefundItemRequest, remaining int) ([]*model.OrderRefundItem, int, *model.AppErr) {
+	details, err := st.OrderDetail().GetAll(o.ID)
+	if err != nil {
+		return nil, 0, err
+	}
+	alreadyRefunded, err := st.Refund().GetRefundedQuantities(o.ID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	requested := make(map[int]int)
+	order := make([]int, 0)
+	ordered := make(map[int]*model.OrderInfo, len(details))
+	for _, item := range reqItems {
+		d, err := refundItemLine(details, item)
+		if err != nil {
+			return nil, 0, err
+		}
+		line := d.OrderDetail.Line
+		if _, ok := requested[line]; !ok {
+			order = append(order, line)
+		}
+		requested[line] += item.Quantity
+		ordered[line] = d
+	}
+
+	items := make([]*model.OrderRefundItem, 0, len(order))
+	amount := 0
+	for _, line := range order {
+		d := ordered[line]
+		qty := requested[line]
+		if alreadyRefunded[line]+qty > d.OrderDetail.Quantity {
+			return nil, 0, model.NewAppErr("RefundOrder", model.ErrInvalid, locale.GetUserLocalizer("en"), msgRefundItemQuantity, http.StatusBadRequest, map[string]interface{}{"product_id": d.OrderDetail.ProductID, "variant_id": d.OrderDetail.VariantID, "line": line, "refundable": d.OrderDetail.Quantity - alreadyRefunded[line]})
+		}
+
+		lineAmount := lineRefundAmount(&d.OrderDetail, qty)
+		items = append(items, &model.OrderRefundItem{Line: line, ProductID: d.OrderDetail.ProductID, VariantID: d.OrderDetail.VariantID, Quantity: qty, Amount: lineAmount})
+		amount += lineAmount
+		alreadyRefunded[line] += qty
+	}
+
+	// the last refunded lines take whatever is left, so rounding never leaves cents behind
+	allRefunded := true
+	for _, d := range details {
+		if alreadyRefunded[d.OrderDetail.Line] < d.OrderDetail.Quantity {
+			allRefunded = false
+			break
+		}
+	}
+	if allRefunded || amount > remaining {
+		amount = remaining
+	}
+
+	return items, amount, nil
+}
+
+// refundItemLine finds the order line of the refund item
+func refundItemLine(details []*model.OrderInfo, item *model.RefundItemRequest) (*model.OrderInfo, *model.AppErr) {
+	var line *model.OrderInfo
+	for _, d := range details {
+		if d.OrderDetail.ProductID != item.ProductID {
+			continue
+		}
+		if item.VariantID != nil && d.OrderDetail.VariantID != *item.VariantID {
+			continue
+		}
+		if item.Line != nil && d.OrderDetail.Line != *item.Line {
+			continue
+		}
+		if line != nil {
+			return nil, model.NewAppErr("RefundOrder", model.ErrInvalid, locale.GetUserLocalizer("en"), msgRefundItemVariant, http.StatusBadRequest, map[string]interface{}{"product_id": item.ProductID})
+		}
+		line = d
+	}
+
+	if line == nil {
+		details := map[string]interface{}{"product_id": item.ProductID}
+		if item.VariantID != nil {
+			details["variant_id"] = *item.VariantID
+		}
+		if item.Line != nil {
+			details["line"] = *item.Line
+		}
+		return nil, model.NewAppErr("RefundOrder", model.ErrInvalid, locale.GetUserLocalizer("en"), msgRefundItemNotInOrder, http.StatusBadRequest, details)
+	}
+	return line, nil
+}
+
+// lineRefundAmount is the paid amount of the refunded quantity of the line, after the line discount
+func lineRefundAmount(d *model.OrderDetail, qty int) int {
+	if d.Quantity == 0 {
+		return 0
+	}
+	return int(math.Round(float64(d.HistoryPrice*d.Quantity-d.Discount) * float64(qty) / float64(d.Quantity)))
+}
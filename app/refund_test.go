@@ -0,0 +1,36 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/dankobgd/ecommerce-shop/model"
+)
+
+func TestLineRefundAmount(t *testing.T) {
+	tests := []struct {
+		name     string
+		quantity int
+		price    int
+		discount int
+		qty      int
+		want     int
+	}{
+		{"whole line", 2, 1000, 0, 2, 2000},
+		{"part of the line", 3, 1000, 0, 1, 1000},
+		{"discount is shared by the quantity", 4, 1000, 400, 1, 900},
+		{"discounted whole line", 4, 1000, 400, 4, 3600},
+		{"rounded share", 3, 100, 100, 1, 67},
+		{"fully discounted line", 2, 500, 1000, 1, 0},
+		{"nothing refunded", 2, 500, 0, 0, 0},
+		{"empty line", 0, 500, 0, 1, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &model.OrderDetail{Quantity: tt.quantity, HistoryPrice: tt.price, Discount: tt.discount}
+			if got := lineRefundAmount(d, tt.qty); got != tt.want {
+				t.Errorf("lineRefundAmount(%+v, %d) = %d, want %d", d, tt.qty, got, tt.want)
+			}
+		})
+	}
+}
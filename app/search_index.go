@@ -0,0 +1,28 @@
+package app
+
+import (
+	"github.com/dankobgd/ecommerce-shop/model"
+	"github.com/dankobgd/ecommerce-shop/zlog"
+)
+
+// searchIndexBatch is the max number of the products reindexed in one transaction
+const searchIndexBatch = 500
+
+// refreshSearchIndex reindexes the queued products batch by batch until the queue is drained
+func (a *App) refreshSearchIndex() {
+	for {
+		n, err := a.Srv().Store.SearchIndex().Refresh(searchIndexBatch)
+		if err != nil {
+			a.Log().Error(err.Error(), zlog.Err(err))
+			return
+		}
+		if n < searchIndexBatch {
+			return
+		}
+	}
+}
+
+// RebuildSearchIndex rebuilds the whole product search index and returns the number of the indexed products
+func (a *App) RebuildSearchIndex() (int, *model.AppErr) {
+	return a.Srv().Store.SearchIndex().Rebuild()
+}
@@ -0,0 +1,276 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dankobgd/ecommerce-shop/model"
+	"github.com/dankobgd/ecommerce-shop/payment"
+	"github.com/dankobgd/ecommerce-shop/utils/locale"
+	"github.com/dankobgd/ecommerce-shop/zlog"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+var (
+	msgWebhookUnknownProvider = &i18n.Message{ID: "app.webhook.handle_payment_webhook.provider.app_error", Other: "unknown payment provider"}
+	msgWebhookSignature       = &i18n.Message{ID: "app.webhook.handle_payment_webhook.signature.app_error", Other: "invalid webhook signature"}
+	msgWebhookPayload         = &i18n.Message{ID: "app.webhook.handle_payment_webhook.payload.app_error", Other: "invalid webhook payload"}
+	msgWebhookInProgress      = &i18n.Message{ID: "app.webhook.handle_payment_webhook.in_progress.app_error", Other: "payment event is already being processed"}
+)
+
+// paymentEventClaimTimeout is how long the claimed event is locked for the other deliveries of the same event
+const paymentEventClaimTimeout = 5 * time.Minute
+
+// HandlePaymentWebhook verifies, stores and applies the payment provider event
+// events that were already processed are acknowledged without applying them again
+// the event is claimed before it is applied, the concurrent delivery of the same event is rejected so the provider retries it
+func (a *App) HandlePaymentWebhook(provider string, payload []byte, header http.Header) *model.AppErr {
+	if !strings.EqualFold(provider, a.PaymentProvider().Name()) {
+		return model.NewAppErr("HandlePaymentWebhook", model.ErrNotFound, locale.GetUserLocalizer("en"), msgWebhookUnknownProvider, http.StatusNotFound, nil)
+	}
+
+	e, pErr := a.PaymentProvider().ParseWebhook(payload, header)
+	if pErr == payment.ErrInvalidSignature {
+		return model.NewAppErr("HandlePaymentWebhook", model.ErrUnauthenticated, locale.GetUserLocalizer("en"), msgWebhookSignature, http.StatusBadRequest, nil)
+	}
+	if pErr != nil || e.ID == "" {
+		return model.NewAppErr("HandlePaymentWebhook", model.ErrBadRequest, locale.GetUserLocalizer("en"), msgWebhookPayload, http.StatusBadRequest, nil)
+	}
+
+	pe := &model.PaymentEvent{
+		Provider:     e.Provider,
+		EventID:      e.ID,
+		Type:         string(e.Type),
+		ProviderType: e.ProviderType,
+		Payload:      e.Payload,
+	}
+	if e.ChargeID != "" {
+		pe.ChargeID = model.NewString(e.ChargeID)
+	}
+	pe.PreSave()
+
+	saved, err := a.Srv().Store.PaymentEvent().Save(pe)
+	if err != nil {
+		return err
+	}
+	if saved.IsProcessed() {
+		return nil
+	}
+	claimed, err := a.Srv().Store.PaymentEvent().Claim(saved.ID, paymentEventClaimTimeout)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return model.NewAppErr("HandlePaymentWebhook", model.ErrConflict, locale.GetUserLocalizer("en"), msgWebhookInProgress, http.StatusConflict, nil)
+	}
+
+	if err := a.applyPaymentEvent(e); err != nil {
+		a.Log().Error("could not apply payment event", zlog.String("event_id", e.ID), zlog.String("type", string(e.Type)), zlog.Err(err))
+		if mErr := a.Srv().Store.PaymentEvent().MarkFailed(saved.ID, err.Error()); mErr != nil {
+			a.Log().Error(mErr.Error(), zlog.Err(mErr))
+		}
+		return err
+	}
+
+	return a.Srv().Store.PaymentEvent().MarkProcessed(saved.ID)
+}
+
+// applyPaymentEvent drives the order state from the payment event
+func (a *App) applyPaymentEvent(e *payment.Event) *model.AppErr {
+	if e.Type == payment.EventUnhandled || e.ChargeID == "" {
+		return nil
+	}
+
+	o, err := a.paymentEventOrder(e)
+	if err != nil {
+		// the payment wasn't made through the checkout, nothing to apply
+		if err.StatusCode == http.StatusNotFound {
+			return nil
+		}
+		return err
+	}
+
+	status, _ := o.StatusValue()
+
+	switch e.Type {
+	case payment.EventPaymentSucceeded:
+		// the order was cancelled (expired stock reservation etc...) before the payment completed
+		if status == model.OrderStatusCancelled {
+			return a.refundCancelledOrderPayment(o, e)
+		}
+		if status != model.OrderStatusPendingPayment {
+			return nil
+		}
+		receiptURL := e.ReceiptURL
+		if receiptURL == "" {
+			receiptURL = o.ReceiptURL
+		}
+		return a.finalizeOrder(o, &payment.ChargeResult{ChargeID: e.ChargeID, Status: payment.ChargeStatusSucceeded, ReceiptURL: receiptURL})
+	case payment.EventPaymentFailed:
+		if status != model.OrderStatusPendingPayment {
+			return nil
+		}
+		a.cancelPendingOrder(o, paymentEventNote("payment failed", e.Reason))
+		return nil
+	case payment.EventChargeRefunded:
+		return a.recordProviderRefund(o.ID, e)
+	case payment.EventDisputeOpened, payment.EventDisputeClosed:
+		note := paymentEventNote("dispute opened", e.Reason)
+		if e.Type == payment.EventDisputeClosed {
+			note = paymentEventNote("dispute closed", e.Reason)
+		}
+		h := &model.OrderStatusHistory{OrderID: o.ID, FromStatus: model.NewString(o.Status), ToStatus: o.Status, Note: model.NewString(note)}
+		h.PreSave()
+		_, err := a.Srv().Store.Order().InsertStatusHistory(h)
+		return err
+	}
+
+	return nil
+}
+
+// paymentEventOrder gets the order of the payment event, by the payment intent first and then by the order id
+// from the intent metadata, the intent isn't saved on the order when the charge result never reached the checkout
+func (a *App) paymentEventOrder(e *payment.Event) (*model.Order, *model.AppErr) {
+	o, err := a.Srv().Store.Order().GetByPaymentIntentID(e.ChargeID)
+	if err == nil || err.StatusCode != http.StatusNotFound || e.OrderID == 0 {
+		return o, err
+	}
+
+	o, oErr := a.Srv().Store.Order().Get(e.OrderID)
+	if oErr != nil {
+		return nil, oErr
+	}
+	if o.PaymentIntentID != "" && o.PaymentIntentID != e.ChargeID {
+		return nil, err
+	}
+	return o, nil
+}
+
+// recordProviderRefund records the refund made directly in the payment provider (dashboard etc...)
+func (a *App) recordProviderRefund(orderID int64, e *payment.Event) *model.AppErr {
+	tx, err := a.Srv().Store.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	o, err := tx.Order().GetForUpdate(orderID)
+	if err != nil {
+		return err
+	}
+	refunded, err := tx.Refund().GetRefundedAmount(orderID)
+	if err != nil {
+		return err
+	}
+
+	// refunds made through the api are already recorded
+	missing := int(e.AmountRefunded) - refunded
+	if missing <= 0 {
+		return nil
+	}
+
+	refundID := e.RefundID
+	if refundID == "" {
+		refundID = e.ID
+	}
+
+	r := &model.OrderRefund{
+		OrderID:          orderID,
+		ProviderRefundID: refundID,
+		Amount:           missing,
+		Reason:           model.NewString(fmt.Sprintf("refunded in %s", e.Provider)),
+	}
+	r.PreSave()
+
+	if _, err := tx.Refund().Save(r); err != nil {
+		return err
+	}
+
+	from, _ := o.StatusValue()
+	to := model.OrderStatusPartiallyRefunded
+	if refunded+missing >= o.Total {
+		to = model.OrderStatusRefunded
+	}
+	if CanTransitionOrderStatus(from, to) {
+		if _, err := a.transitionOrderStatus(tx, o, to, *r.Reason, nil); err != nil {
+			return err
+		}
+	} else {
+		a.Log().Warn("refund recorded without the status change", zlog.Int64("order_id", orderID), zlog.String("status", o.Status))
+	}
+
+	return tx.Commit()
+}
+
+// refundCancelledOrderPayment gives the money back for the payment that completed after the order was cancelled
+// the order cancelled after the payment already had its payment refunded
+// the refund is saved with the order locked, so the redelivered event finds it and doesn't refund again
+func (a *App) refundCancelledOrderPayment(o *model.Order, e *payment.Event) *model.AppErr {
+	tx, err := a.Srv().Store.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	o, err = tx.Order().GetForUpdate(o.ID)
+	if err != nil {
+		return err
+	}
+	refunded, err := tx.Refund().GetRefundedAmount(o.ID)
+	if err != nil {
+		return err
+	}
+	if refunded >= o.Total {
+		return nil
+	}
+
+	refundID, pErr := a.PaymentProvider().Refund(e.ChargeID, uint64(o.Total-refunded), "usd")
+	if pErr != nil {
+		a.Log().Error("could not refund the payment of the cancelled order", zlog.Int64("order_id", o.ID), zlog.String("charge_id", e.ChargeID), zlog.Err(pErr))
+		return model.NewAppErr("applyPaymentEvent", model.ErrInternal, locale.GetUserLocalizer("en"), msgRefundPayment, http.StatusInternalServerError, map[string]interface{}{"reason": pErr.Error()})
+	}
+
+	note := "payment completed after the order was cancelled, refunded"
+	r := &model.OrderRefund{
+		OrderID:          o.ID,
+		ProviderRefundID: refundID,
+		Amount:           o.Total - refunded,
+		Reason:           model.NewString(note),
+		Items:            make([]*model.OrderRefundItem, 0),
+	}
+	r.PreSave()
+
+	// the money has already been returned, so the failures bellow have to be logged for the manual fix
+	if _, err := tx.Refund().Save(r); err != nil {
+		a.logUnsavedRefund(o, r, err)
+		return err
+	}
+	if o.PaymentIntentID == "" {
+		if err := tx.Order().UpdatePayment(o.ID, e.ChargeID, e.ReceiptURL); err != nil {
+			a.logUnsavedRefund(o, r, err)
+			return err
+		}
+	}
+	h := &model.OrderStatusHistory{OrderID: o.ID, FromStatus: model.NewString(o.Status), ToStatus: o.Status, Note: model.NewString(note)}
+	h.PreSave()
+	if _, err := tx.Order().InsertStatusHistory(h); err != nil {
+		a.logUnsavedRefund(o, r, err)
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		a.logUnsavedRefund(o, r, err)
+		return err
+	}
+
+	a.Log().Info("refunded the payment of the cancelled order", zlog.Int64("order_id", o.ID), zlog.String("charge_id", e.ChargeID), zlog.String("refund_id", refundID))
+	return nil
+}
+
+func paymentEventNote(note, reason string) string {
+	if reason == "" {
+		return note
+	}
+	return fmt.Sprintf("%s: %s", note, reason)
+}
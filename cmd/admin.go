@@ -2,6 +2,8 @@ package cmd
 
 import (
 	"errors"
+	"io"
+	"os"
 
 	"github.com/dankobgd/ecommerce-shop/app"
 	"github.com/dankobgd/ecommerce-shop/model"
@@ -25,6 +27,24 @@ var createSuperAdminCmd = &cobra.Command{
 	PreRun:  loadApp,
 }
 
+var generateCodesCmd = &cobra.Command{
+	Use:     "generatecodes",
+	Short:   "Generate promotion codes",
+	Long:    "Generates the single use codes of the campaign promotion and writes them as csv",
+	Example: "  admin generatecodes --promo-code SUMMER --count 10000 --pattern SUMMER-######## --out summer.csv",
+	RunE:    generateCodesFn,
+	PreRun:  loadApp,
+}
+
+var exportCodesCmd = &cobra.Command{
+	Use:     "exportcodes",
+	Short:   "Export promotion codes",
+	Long:    "Writes all generated codes of the campaign promotion with their redemptions as csv",
+	Example: "  admin exportcodes --promo-code SUMMER --out summer.csv",
+	RunE:    exportCodesFn,
+	PreRun:  loadApp,
+}
+
 var createUserCmd = &cobra.Command{
 	Use:     "createuser",
 	Short:   "Create user",
@@ -49,8 +69,15 @@ func init() {
 	createUserCmd.Flags().StringP("username", "u", "", "Required. Username for the new user account.")
 	createUserCmd.Flags().StringP("password", "p", "", "Required. The password for the new user account.")
 	deleteUserCmd.Flags().Int("id", 0, "Required. The ID for deleting the user.")
-
-	userCmd.AddCommand(createSuperAdminCmd, createUserCmd, deleteUserCmd)
+	generateCodesCmd.Flags().String("promo-code", "", "Required. The promo code of the campaign promotion.")
+	generateCodesCmd.Flags().Int("count", 0, "Required. The number of the codes to generate.")
+	generateCodesCmd.Flags().String("pattern", "", "The code pattern, every '#' is the random character, without '#' it's the prefix.")
+	generateCodesCmd.Flags().Int("length", 0, "The number of the random characters after the prefix pattern.")
+	generateCodesCmd.Flags().StringP("out", "o", "", "The csv file path, stdout by default.")
+	exportCodesCmd.Flags().String("promo-code", "", "Required. The promo code of the campaign promotion.")
+	exportCodesCmd.Flags().StringP("out", "o", "", "The csv file path, stdout by default.")
+
+	userCmd.AddCommand(createSuperAdminCmd, createUserCmd, deleteUserCmd, generateCodesCmd, exportCodesCmd)
 	rootCmd.AddCommand(userCmd)
 }
 
@@ -120,3 +147,59 @@ func deleteUserFn(command *cobra.Command, args []string) error {
 	cmdApp.Log().Info("deleted user")
 	return nil
 }
+
+func generateCodesFn(command *cobra.Command, args []string) error {
+	promoCode, err := command.Flags().GetString("promo-code")
+	if err != nil || promoCode == "" {
+		return errors.New("Promo code is required")
+	}
+	count, err := command.Flags().GetInt("count")
+	if err != nil || count == 0 {
+		return errors.New("Count is required")
+	}
+	pattern, _ := command.Flags().GetString("pattern")
+	length, _ := command.Flags().GetInt("length")
+
+	codes, e := cmdApp.GeneratePromotionCodes(promoCode, &model.PromotionCodeBatch{Count: count, Pattern: pattern, Length: length})
+	if e != nil {
+		return errors.New(e.Message)
+	}
+
+	if err := writeCodesCSV(command, func(w io.Writer) error { return model.WritePromotionCodesCSV(w, codes) }); err != nil {
+		return err
+	}
+	cmdApp.Log().Info("generated promotion codes", zlog.String("promo_code", promoCode), zlog.Int("count", len(codes)))
+	return nil
+}
+
+func exportCodesFn(command *cobra.Command, args []string) error {
+	promoCode, err := command.Flags().GetString("promo-code")
+	if err != nil || promoCode == "" {
+		return errors.New("Promo code is required")
+	}
+
+	return writeCodesCSV(command, func(w io.Writer) error {
+		if e := cmdApp.ExportPromotionCodes(promoCode, w); e != nil {
+			return errors.New(e.Message)
+		}
+		return nil
+	})
+}
+
+// writeCodesCSV writes the csv to the out file, or to stdout when there is no out file
+func writeCodesCSV(command *cobra.Command, write func(w io.Writer) error) error {
+	out, _ := command.Flags().GetString("out")
+	if out == "" {
+		return write(os.Stdout)
+	}
+
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
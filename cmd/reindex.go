@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"errors"
+
+	"github.com/dankobgd/ecommerce-shop/zlog"
+	"github.com/spf13/cobra"
+)
+
+var reindexCmd = &cobra.Command{
+	Use:     "reindex",
+	Short:   "Rebuild the search index",
+	Long:    "Rebuilds the whole product search index, the queued incremental updates are dropped",
+	Example: "  reindex",
+	RunE:    reindexFn,
+	PreRun:  loadApp,
+}
+
+func init() {
+	rootCmd.AddCommand(reindexCmd)
+}
+
+func reindexFn(command *cobra.Command, args []string) error {
+	n, err := cmdApp.RebuildSearchIndex()
+	if err != nil {
+		cmdApp.Log().Error("could not rebuild the search index", zlog.String("err: ", err.Message))
+		return errors.New(err.Message)
+	}
+
+	cmdApp.Log().Info("rebuilt the search index", zlog.Int("products", n))
+	return nil
+}
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/dankobgd/ecommerce-shop/model"
+	"github.com/dankobgd/ecommerce-shop/payment/fake"
 	"github.com/dankobgd/ecommerce-shop/zlog"
 	"github.com/jmoiron/sqlx/types"
 	"github.com/spf13/cobra"
@@ -214,28 +215,24 @@ func seedOrders() error {
 			ids = append(ids, x.ProductID)
 		}
 
-		products, err := cmdApp.GetProductsbyIDS(ids)
+		variants, err := cmdApp.Srv().Store.ProductVariant().ListDefaultsByProductIDS(ids)
 		if err != nil {
-			cmdApp.Log().Error("could not get products by id", zlog.String("err: ", err.Error()))
+			cmdApp.Log().Error("could not get product variants by product id", zlog.String("err: ", err.Error()))
 			return err
 		}
+		defaults := make(map[int64]*model.ProductVariant, len(variants))
+		for _, v := range variants {
+			defaults[v.ProductID] = v
+		}
 
 		var total int
-		for i, p := range products {
-			total += p.Price * orderData.Items[i].Quantity
+		for _, item := range orderData.Items {
+			if v, ok := defaults[item.ProductID]; ok {
+				total += v.Price * item.Quantity
+			}
 		}
 
-		stripe.Key = os.Getenv("STRIPE_SECRET_KEY")
-		params := &stripe.PaymentMethodParams{
-			Card: &stripe.PaymentMethodCardParams{
-				Number:   stripe.String("4242424242424242"),
-				ExpMonth: stripe.String("2"),
-				ExpYear:  stripe.String("2022"),
-				CVC:      stripe.String("314"),
-			},
-			Type: stripe.String("card"),
-		}
-		pm, e := paymentmethod.New(params)
+		paymentMethodID, e := seedPaymentMethodID()
 		if e != nil {
 			cmdApp.Log().Error("stripe payment method error", zlog.String("err: ", e.Error()))
 			return e
@@ -249,8 +246,8 @@ func seedOrders() error {
 			UserID:                   int64(userID),
 			Subtotal:                 total,
 			Total:                    total,
-			Status:                   model.OrderStatusSuccess.String(),
-			PaymentMethodID:          pm.ID,
+			Status:                   model.OrderStatusPaid.String(),
+			PaymentMethodID:          paymentMethodID,
 			BillingAddressLine1:      orderData.BillingAddress.Line1,
 			BillingAddressLine2:      orderData.BillingAddress.Line2,
 			BillingAddressCity:       orderData.BillingAddress.City,
@@ -269,14 +266,14 @@ func seedOrders() error {
 			ShippingAddressLongitude: orderData.ShippingAddress.Longitude,
 		}
 
-		pi, cErr := cmdApp.PaymentProvider().Charge(pm.ID, o, user, uint64(total), "usd")
+		charge, cErr := cmdApp.PaymentProvider().Charge(paymentMethodID, o, user, uint64(total), "usd", "")
 		if cErr != nil {
 			cmdApp.Log().Error("stripe charge err", zlog.String("err: ", cErr.Error()))
 			return cErr
 		}
 
-		o.PaymentIntentID = pi.ID
-		o.ReceiptURL = pi.Charges.Data[0].ReceiptURL
+		o.PaymentIntentID = charge.ChargeID
+		o.ReceiptURL = charge.ReceiptURL
 
 		o.PreSave()
 		order, err := cmdApp.Srv().Store.Order().Save(o)
@@ -286,13 +283,20 @@ func seedOrders() error {
 		}
 
 		orderDetails := make([]*model.OrderDetail, 0)
-		for i, p := range products {
+		for _, item := range orderData.Items {
+			v, ok := defaults[item.ProductID]
+			if !ok {
+				continue
+			}
 			detail := &model.OrderDetail{
-				OrderID:      order.ID,
-				ProductID:    p.ID,
-				Quantity:     orderData.Items[i].Quantity,
-				HistoryPrice: p.Price,
-				HistorySKU:   p.SKU,
+				OrderID:        order.ID,
+				Line:           len(orderDetails) + 1,
+				ProductID:      v.ProductID,
+				VariantID:      v.ID,
+				Quantity:       item.Quantity,
+				HistoryPrice:   v.Price,
+				HistorySKU:     v.SKU,
+				HistoryOptions: v.Options,
 			}
 			orderDetails = append(orderDetails, detail)
 		}
@@ -427,12 +431,8 @@ func seedProducts() error {
 		return err
 	}
 
-	products := make([]*model.Product, 0)
-	pricings := make([]*model.ProductPricing, 0)
-	productTags := make([]*model.ProductTag, 0)
-	productImgs := make([]*model.ProductImage, 0)
-
-	for i, x := range ps {
+	products := make([]*model.Product, 0, len(ps))
+	for _, x := range ps {
 		p := &model.Product{
 			BrandID:       x.BrandID,
 			CategoryID:    x.CategoryID,
@@ -441,7 +441,7 @@ func seedProducts() error {
 			ImageURL:      x.ImageURL,
 			ImagePublicID: x.ImagePublicID,
 			Description:   x.Description,
-			InStock:       x.InStock,
+			StockQuantity: seedStockQuantity(x.InStock),
 			SKU:           x.SKU,
 			IsFeatured:    x.IsFeatured,
 			Properties:    &x.Properties,
@@ -450,17 +450,35 @@ func seedProducts() error {
 				OriginalPrice: x.Price,
 			},
 		}
+		p.PreSave()
+		products = append(products, p)
+	}
+
+	if err := cmdApp.Srv().Store.Product().BulkInsert(products); err != nil {
+		cmdApp.Log().Error("could not seed products", zlog.String("err: ", err.Message))
+		return err
+	}
+
+	// the related rows use the ids of the inserted products, the sequence may not start at 1
+	ids := make([]int64, 0, len(products))
+	pricings := make([]*model.ProductPricing, 0, len(products))
+	productTags := make([]*model.ProductTag, 0)
+	productImgs := make([]*model.ProductImage, 0)
+
+	for i, x := range ps {
+		id := products[i].ID
+		ids = append(ids, id)
 
 		for _, tagID := range x.Tags {
 			productTags = append(productTags, &model.ProductTag{
 				TagID:     model.NewInt64(tagID),
-				ProductID: model.NewInt64(int64(i + 1)),
+				ProductID: model.NewInt64(id),
 			})
 		}
 		for _, img := range x.Images {
 			now := time.Now()
 			productImgs = append(productImgs, &model.ProductImage{
-				ProductID: model.NewInt64(int64(i + 1)),
+				ProductID: model.NewInt64(id),
 				URL:       model.NewString(img),
 				PublicID:  model.NewString(""),
 				CreatedAt: &now,
@@ -468,28 +486,22 @@ func seedProducts() error {
 			})
 		}
 
-		price := &model.ProductPricing{
-			ProductID:     int64(i + 1),
+		pricings = append(pricings, &model.ProductPricing{
+			ProductID:     id,
 			Price:         x.Price,
 			OriginalPrice: x.Price,
 			SaleStarts:    time.Now(),
 			SaleEnds:      model.FutureSaleEndsTime,
-		}
-
-		products = append(products, p)
-		pricings = append(pricings, price)
+		})
 	}
 
-	for _, p := range products {
-		p.PreSave()
-	}
-	if err := cmdApp.Srv().Store.Product().BulkInsert(products); err != nil {
-		cmdApp.Log().Error("could not seed products", zlog.String("err: ", err.Message))
+	if err := cmdApp.Srv().Store.Product().InsertPricingBulk(pricings); err != nil {
+		cmdApp.Log().Error("could not seed product pricings", zlog.String("err: ", err.Message))
 		return err
 	}
 
-	if err := cmdApp.Srv().Store.Product().InsertPricingBulk(pricings); err != nil {
-		cmdApp.Log().Error("could not seed product pricings", zlog.String("err: ", err.Message))
+	if err := cmdApp.Srv().Store.ProductVariant().CreateDefaults(ids); err != nil {
+		cmdApp.Log().Error("could not seed default product variants", zlog.String("err: ", err.Message))
 		return err
 	}
 
@@ -509,3 +521,35 @@ func seedProducts() error {
 	cmdApp.Log().Info("products seeded")
 	return nil
 }
+
+// seedPaymentMethodID creates the stripe test card, or uses the fake one when not seeding against stripe
+func seedPaymentMethodID() (string, error) {
+	if cmdApp.Cfg().PaymentSettings.Provider != "stripe" {
+		return fake.MethodSucceed, nil
+	}
+
+	stripe.Key = os.Getenv("STRIPE_SECRET_KEY")
+	params := &stripe.PaymentMethodParams{
+		Card: &stripe.PaymentMethodCardParams{
+			Number:   stripe.String("4242424242424242"),
+			ExpMonth: stripe.String("2"),
+			ExpYear:  stripe.String("2022"),
+			CVC:      stripe.String("314"),
+		},
+		Type: stripe.String("card"),
+	}
+	pm, err := paymentmethod.New(params)
+	if err != nil {
+		return "", err
+	}
+
+	return pm.ID, nil
+}
+
+// seedStockQuantity gives the products that are in stock the random quantity
+func seedStockQuantity(inStock bool) int {
+	if !inStock {
+		return 0
+	}
+	return rand.Intn(100) + 1
+}
@@ -1,11 +1,14 @@
 package cmd
 
 import (
+	"fmt"
 	"log"
 
 	api "github.com/dankobgd/ecommerce-shop/api/v1"
 	"github.com/dankobgd/ecommerce-shop/app"
 	"github.com/dankobgd/ecommerce-shop/config"
+	"github.com/dankobgd/ecommerce-shop/payment"
+	"github.com/dankobgd/ecommerce-shop/payment/fake"
 	"github.com/dankobgd/ecommerce-shop/payment/stripe"
 	"github.com/dankobgd/ecommerce-shop/store/postgres"
 	"github.com/dankobgd/ecommerce-shop/store/redis"
@@ -32,6 +35,7 @@ func serverCmdFn(command *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
+	a.StartJobs()
 	return runServer(a.Srv())
 }
 
@@ -54,11 +58,6 @@ func setupApp() (*app.App, error) {
 
 	cfg := config.New()
 
-	paymentProvider, pErr := stripe.NewPaymentProvider(cfg.StripeSettings.SecretKey)
-	if pErr != nil {
-		return nil, pErr
-	}
-
 	logger := zlog.NewLogger(&zlog.LoggerConfig{
 		EnableConsole: true,
 		ConsoleLevel:  "debug",
@@ -73,6 +72,12 @@ func setupApp() (*app.App, error) {
 
 	locale.InitTranslations()
 
+	// the provider config errors are localized, so the translations are loaded first
+	paymentProvider, pErr := newPaymentProvider(cfg)
+	if pErr != nil {
+		return nil, pErr
+	}
+
 	appOpts := []app.Option{
 		app.SetConfig(cfg),
 		app.SetServer(server),
@@ -87,6 +92,25 @@ func setupApp() (*app.App, error) {
 	return a, nil
 }
 
+func newPaymentProvider(cfg *config.Config) (payment.Provider, error) {
+	switch cfg.PaymentSettings.Provider {
+	case "stripe":
+		p, err := stripe.NewPaymentProvider(cfg.StripeSettings.SecretKey, cfg.StripeSettings.WebhookSecret)
+		if err != nil {
+			return nil, err
+		}
+		return p, nil
+	case "fake":
+		p, err := fake.NewPaymentProvider(cfg.PaymentSettings.FakeOutcome, cfg.PaymentSettings.FakeWebhookSecret)
+		if err != nil {
+			return nil, err
+		}
+		return p, nil
+	default:
+		return nil, fmt.Errorf("unknown payment provider: %s", cfg.PaymentSettings.Provider)
+	}
+}
+
 func runServer(srv *app.Server) error {
 	srvErr := srv.Start()
 	if srvErr != nil {
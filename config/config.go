@@ -91,7 +91,25 @@ type LoggerSettings struct {
 
 // StripeSettings contains the stripe settings
 type StripeSettings struct {
-	SecretKey string `envconfig:"STRIPE_SECRET_KEY"`
+	SecretKey     string `envconfig:"STRIPE_SECRET_KEY"`
+	WebhookSecret string `envconfig:"STRIPE_WEBHOOK_SECRET"`
+}
+
+// PaymentSettings contains the payment provider settings
+type PaymentSettings struct {
+	Provider          string `envconfig:"PAYMENT_PROVIDER"`
+	FakeOutcome       string `envconfig:"PAYMENT_FAKE_OUTCOME"`
+	FakeWebhookSecret string `envconfig:"PAYMENT_FAKE_WEBHOOK_SECRET"`
+}
+
+// InventorySettings contains the stock settings
+type InventorySettings struct {
+	ReservationTTLMinutes int `envconfig:"INVENTORY_RESERVATION_TTL_MINUTES"`
+}
+
+// ShippingSettings contains the shipping settings, the fee is the flat shipping fee of the order in cents
+type ShippingSettings struct {
+	Fee int `envconfig:"SHIPPING_FEE"`
 }
 
 // CloudinarySettings contains the cloudinary settings
@@ -116,6 +134,9 @@ type Config struct {
 	CloudinarySettings CloudinarySettings
 	GeocodingSettings  GeocodingSettings
 	StripeSettings     StripeSettings
+	PaymentSettings    PaymentSettings
+	InventorySettings  InventorySettings
+	ShippingSettings   ShippingSettings
 }
 
 func loadEnvironment() {
@@ -134,6 +155,8 @@ func (c *Config) ApplyDefaults() {
 	c.CookieSettings.SetDefaults()
 	c.PasswordSettings.SetDefaults()
 	c.LoggerSettings.SetDefaults()
+	c.PaymentSettings.SetDefaults()
+	c.InventorySettings.SetDefaults()
 }
 
 // New creates the new config
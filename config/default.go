@@ -144,3 +144,20 @@ func (s *LoggerSettings) SetDefaults() {
 		s.FileLocation = ""
 	}
 }
+
+// SetDefaults sets default values for PaymentSettings
+func (s *PaymentSettings) SetDefaults() {
+	if s.Provider == "" {
+		s.Provider = "stripe"
+	}
+	if s.FakeOutcome == "" {
+		s.FakeOutcome = "succeed"
+	}
+}
+
+// SetDefaults sets default values for InventorySettings
+func (s *InventorySettings) SetDefaults() {
+	if s.ReservationTTLMinutes == 0 {
+		s.ReservationTTLMinutes = 15
+	}
+}
@@ -0,0 +1,7 @@
+drop table public.order_status_history;
+
+alter table public.order alter column status set default 'pending';
+
+update public.order set status = 'success' where status in ('paid', 'processing', 'shipped', 'delivered', 'refunded', 'partially_refunded');
+update public.order set status = 'pending' where status = 'pending_payment';
+update public.order set status = 'fail' where status = 'cancelled';
@@ -0,0 +1,19 @@
+update public.order set status = 'paid' where status = 'success';
+update public.order set status = 'pending_payment' where status = 'pending';
+update public.order set status = 'cancelled' where status = 'fail';
+
+alter table public.order alter column status set default 'pending_payment';
+
+create table public.order_status_history (
+  id int generated always as identity primary key,
+  order_id int not null,
+  from_status varchar(30),
+  to_status varchar(30) not null,
+  note text,
+  changed_by int,
+  created_at timestamptz not null,
+  foreign key (order_id) references public.order (id) on delete cascade,
+  foreign key (changed_by) references public.user (id) on delete set null
+);
+
+create index order_status_history_order_id_idx on public.order_status_history (order_id);
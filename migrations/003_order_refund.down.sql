@@ -0,0 +1,2 @@
+drop table public.order_refund_item;
+drop table public.order_refund;
@@ -0,0 +1,26 @@
+create table public.order_refund (
+  id int generated always as identity primary key,
+  order_id int not null,
+  provider_refund_id text not null,
+  amount int not null,
+  reason text,
+  created_by int,
+  created_at timestamptz not null,
+  foreign key (order_id) references public.order (id) on delete cascade,
+  foreign key (created_by) references public.user (id) on delete set null,
+  check (amount > 0)
+);
+
+create table public.order_refund_item (
+  refund_id int not null,
+  order_id int not null,
+  product_id int not null,
+  quantity int not null,
+  amount int not null,
+  foreign key (refund_id) references public.order_refund (id) on delete cascade,
+  foreign key (order_id, product_id) references public.order_detail (order_id, product_id) on delete cascade,
+  check (quantity > 0),
+  primary key (refund_id, product_id)
+);
+
+create index order_refund_order_id_idx on public.order_refund (order_id);
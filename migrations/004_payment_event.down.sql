@@ -0,0 +1,2 @@
+drop index public.order_payment_intent_id_idx;
+drop table public.payment_event;
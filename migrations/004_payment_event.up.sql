@@ -0,0 +1,17 @@
+create table public.payment_event (
+  id int generated always as identity primary key,
+  provider varchar(30) not null,
+  event_id text not null,
+  type varchar(64) not null,
+  provider_type text not null,
+  charge_id text,
+  payload jsonb not null,
+  attempts int default 1 not null,
+  error text,
+  processing_at timestamptz,
+  processed_at timestamptz,
+  created_at timestamptz not null,
+  unique (provider, event_id)
+);
+
+create index order_payment_intent_id_idx on public.order (payment_intent_id);
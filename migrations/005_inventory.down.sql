@@ -0,0 +1,45 @@
+drop table public.stock_movement;
+drop view product_search_view;
+
+alter table public.product drop column in_stock;
+alter table public.product add column in_stock bool default true not null;
+update public.product set in_stock = stock_quantity > 0;
+
+alter table public.product drop constraint product_stock_quantity_check;
+alter table public.product drop column stock_quantity;
+
+create view product_search_view as
+select
+p.*,
+b.name AS brand_name,
+b.slug AS brand_slug,
+b.type AS brand_type,
+b.description AS brand_description,
+b.email AS brand_email,
+b.logo AS brand_logo,
+b.website_url AS brand_website_url,
+b.created_at AS brand_created_at,
+b.updated_at AS brand_updated_at,
+c.name AS category_name,
+c.slug AS category_slug,
+c.description AS category_description,
+c.logo AS category_logo,
+c.created_at AS category_created_at,
+c.updated_at AS category_updated_at,
+pp.id AS pricing_id,
+pp.product_id AS pricing_product_id,
+pp.price AS pricing_price,
+pp.original_price AS pricing_original_price,
+pp.sale_starts AS pricing_sale_starts,
+pp.sale_ends AS pricing_sale_ends,
+(
+setweight(to_tsvector(coalesce(p.name,'')), 'A') ||
+setweight(to_tsvector(coalesce(p.description,'')), 'B') ||
+setweight(to_tsvector(coalesce(c.name,'')), 'C') ||
+setweight(to_tsvector(coalesce(b.name,'')), 'D')
+) as tsv
+FROM product p
+LEFT JOIN product_pricing pp ON p.id = pp.product_id
+LEFT JOIN brand b ON p.brand_id = b.id
+LEFT JOIN category c ON p.category_id = c.id
+WHERE CURRENT_TIMESTAMP BETWEEN pp.sale_starts AND pp.sale_ends;
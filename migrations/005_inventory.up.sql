@@ -0,0 +1,71 @@
+alter table public.product add column stock_quantity int default 0 not null;
+alter table public.product add constraint product_stock_quantity_check check (stock_quantity >= 0);
+
+-- the search view selects p.*, so it has to be recreated around the column change
+drop view product_search_view;
+
+-- the real stock levels are not known, so the products that were in stock get the opening quantity to stay sellable
+-- it is 100 unless the app.opening_stock_quantity setting is given, e.g. with the "options=-c app.opening_stock_quantity=20"
+-- database url param or with "alter database ... set app.opening_stock_quantity = 20"
+update public.product set stock_quantity = coalesce(nullif(current_setting('app.opening_stock_quantity', true), '')::int, 100) where in_stock;
+
+alter table public.product drop column in_stock;
+alter table public.product add column in_stock bool generated always as (stock_quantity > 0) stored;
+
+create view product_search_view as
+select
+p.*,
+b.name AS brand_name,
+b.slug AS brand_slug,
+b.type AS brand_type,
+b.description AS brand_description,
+b.email AS brand_email,
+b.logo AS brand_logo,
+b.website_url AS brand_website_url,
+b.created_at AS brand_created_at,
+b.updated_at AS brand_updated_at,
+c.name AS category_name,
+c.slug AS category_slug,
+c.description AS category_description,
+c.logo AS category_logo,
+c.created_at AS category_created_at,
+c.updated_at AS category_updated_at,
+pp.id AS pricing_id,
+pp.product_id AS pricing_product_id,
+pp.price AS pricing_price,
+pp.original_price AS pricing_original_price,
+pp.sale_starts AS pricing_sale_starts,
+pp.sale_ends AS pricing_sale_ends,
+(
+setweight(to_tsvector(coalesce(p.name,'')), 'A') ||
+setweight(to_tsvector(coalesce(p.description,'')), 'B') ||
+setweight(to_tsvector(coalesce(c.name,'')), 'C') ||
+setweight(to_tsvector(coalesce(b.name,'')), 'D')
+) as tsv
+FROM product p
+LEFT JOIN product_pricing pp ON p.id = pp.product_id
+LEFT JOIN brand b ON p.brand_id = b.id
+LEFT JOIN category c ON p.category_id = c.id
+WHERE CURRENT_TIMESTAMP BETWEEN pp.sale_starts AND pp.sale_ends;
+
+create table public.stock_movement (
+  id int generated always as identity primary key,
+  product_id int not null,
+  order_id int,
+  quantity int not null,
+  reason varchar(30) not null,
+  note text,
+  created_by int,
+  created_at timestamptz not null,
+  foreign key (product_id) references public.product (id) on delete cascade,
+  foreign key (order_id) references public.order (id) on delete set null,
+  foreign key (created_by) references public.user (id) on delete set null,
+  check (quantity <> 0)
+);
+
+create index stock_movement_product_id_idx on public.stock_movement (product_id, created_at desc);
+create index stock_movement_order_id_idx on public.stock_movement (order_id);
+
+-- the opening quantity is recorded in the ledger, so the stock level is always the sum of the movements
+insert into public.stock_movement (product_id, quantity, reason, note, created_at)
+select id, stock_quantity, 'initial', 'opening stock', now() from public.product where stock_quantity > 0;
@@ -0,0 +1,64 @@
+drop view product_search_view;
+
+alter table public.order_refund_item drop constraint order_refund_item_order_id_variant_id_fkey;
+alter table public.order_refund_item drop constraint order_refund_item_pkey;
+alter table public.order_detail drop constraint order_detail_pkey;
+
+-- the orders with multiple variants of the same product can't be represented anymore
+delete from public.order_detail od using public.order_detail other
+where od.order_id = other.order_id and od.product_id = other.product_id and od.variant_id > other.variant_id;
+delete from public.order_refund_item ri
+where not exists (select 1 from public.order_detail od where od.order_id = ri.order_id and od.variant_id = ri.variant_id);
+
+alter table public.order_detail add primary key (order_id, product_id);
+alter table public.order_refund_item add primary key (refund_id, product_id);
+alter table public.order_refund_item add foreign key (order_id, product_id) references public.order_detail (order_id, product_id) on delete cascade;
+alter table public.order_refund_item drop column variant_id;
+alter table public.order_detail drop column history_options;
+alter table public.order_detail drop column variant_id;
+
+alter table public.stock_movement drop column sku;
+alter table public.stock_movement drop column variant_id;
+alter table public.product_image drop column variant_id;
+
+delete from public.product_pricing where variant_id is not null;
+alter table public.product_pricing drop column variant_id;
+
+drop table public.product_variant;
+drop table public.product_option;
+
+create view product_search_view as
+select
+p.*,
+b.name AS brand_name,
+b.slug AS brand_slug,
+b.type AS brand_type,
+b.description AS brand_description,
+b.email AS brand_email,
+b.logo AS brand_logo,
+b.website_url AS brand_website_url,
+b.created_at AS brand_created_at,
+b.updated_at AS brand_updated_at,
+c.name AS category_name,
+c.slug AS category_slug,
+c.description AS category_description,
+c.logo AS category_logo,
+c.created_at AS category_created_at,
+c.updated_at AS category_updated_at,
+pp.id AS pricing_id,
+pp.product_id AS pricing_product_id,
+pp.price AS pricing_price,
+pp.original_price AS pricing_original_price,
+pp.sale_starts AS pricing_sale_starts,
+pp.sale_ends AS pricing_sale_ends,
+(
+setweight(to_tsvector(coalesce(p.name,'')), 'A') ||
+setweight(to_tsvector(coalesce(p.description,'')), 'B') ||
+setweight(to_tsvector(coalesce(c.name,'')), 'C') ||
+setweight(to_tsvector(coalesce(b.name,'')), 'D')
+) as tsv
+FROM product p
+LEFT JOIN product_pricing pp ON p.id = pp.product_id
+LEFT JOIN brand b ON p.brand_id = b.id
+LEFT JOIN category c ON p.category_id = c.id
+WHERE CURRENT_TIMESTAMP BETWEEN pp.sale_starts AND pp.sale_ends;
@@ -0,0 +1,106 @@
+create table public.product_option (
+  id int generated always as identity primary key,
+  product_id int not null,
+  name varchar(64) not null,
+  "values" jsonb default '[]' not null,
+  position int default 0 not null,
+  foreign key (product_id) references public.product (id) on delete cascade,
+  unique (product_id, name)
+);
+
+create table public.product_variant (
+  id int generated always as identity primary key,
+  product_id int not null,
+  sku text not null,
+  options jsonb default '{}' not null,
+  stock_quantity int default 0 not null,
+  in_stock bool generated always as (stock_quantity > 0) stored,
+  is_default bool default false not null,
+  created_at timestamptz not null,
+  updated_at timestamptz not null,
+  foreign key (product_id) references public.product (id) on delete cascade,
+  unique (product_id, options),
+  check (stock_quantity >= 0)
+);
+
+create unique index product_variant_default_idx on public.product_variant (product_id) where is_default;
+create index product_variant_sku_idx on public.product_variant (sku);
+
+-- every existing product gets the default variant which takes over its sku and stock
+insert into public.product_variant (product_id, sku, stock_quantity, is_default, created_at, updated_at)
+select id, sku, stock_quantity, true, created_at, updated_at from public.product;
+
+-- the rows without the variant are the product price, the variants without their own rows are sold for the product price
+alter table public.product_pricing add column variant_id int;
+alter table public.product_pricing add foreign key (variant_id) references public.product_variant (id) on delete cascade;
+create index product_pricing_variant_id_idx on public.product_pricing (variant_id);
+
+alter table public.product_image add column variant_id int;
+alter table public.product_image add foreign key (variant_id) references public.product_variant (id) on delete set null;
+
+-- the ledger outlives the removed variants, the sku keeps their entries readable
+alter table public.stock_movement add column variant_id int;
+alter table public.stock_movement add column sku text;
+update public.stock_movement sm set variant_id = v.id, sku = v.sku from public.product_variant v where v.product_id = sm.product_id and v.is_default;
+alter table public.stock_movement alter column sku set not null;
+alter table public.stock_movement add foreign key (variant_id) references public.product_variant (id) on delete set null;
+
+-- the order lines reference the variant, so the same product can be ordered in multiple variants
+-- like the product, the variant is not a foreign key so the order history survives its removal
+alter table public.order_detail add column variant_id int;
+alter table public.order_detail add column history_options jsonb default '{}' not null;
+update public.order_detail od set variant_id = v.id from public.product_variant v where v.product_id = od.product_id and v.is_default;
+-- the lines of the already deleted products have no variant, the negated product id keeps them unique within the order
+update public.order_detail set variant_id = -product_id where variant_id is null;
+
+alter table public.order_refund_item add column variant_id int;
+update public.order_refund_item ri set variant_id = od.variant_id from public.order_detail od where od.order_id = ri.order_id and od.product_id = ri.product_id;
+
+alter table public.order_refund_item drop constraint order_refund_item_order_id_product_id_fkey;
+alter table public.order_refund_item drop constraint order_refund_item_pkey;
+alter table public.order_detail drop constraint order_detail_pkey;
+
+alter table public.order_detail alter column variant_id set not null;
+alter table public.order_detail add primary key (order_id, variant_id);
+
+alter table public.order_refund_item alter column variant_id set not null;
+alter table public.order_refund_item add primary key (refund_id, variant_id);
+alter table public.order_refund_item add foreign key (order_id, variant_id) references public.order_detail (order_id, variant_id) on delete cascade;
+
+-- the search view only shows the product display price
+drop view product_search_view;
+create view product_search_view as
+select
+p.*,
+b.name AS brand_name,
+b.slug AS brand_slug,
+b.type AS brand_type,
+b.description AS brand_description,
+b.email AS brand_email,
+b.logo AS brand_logo,
+b.website_url AS brand_website_url,
+b.created_at AS brand_created_at,
+b.updated_at AS brand_updated_at,
+c.name AS category_name,
+c.slug AS category_slug,
+c.description AS category_description,
+c.logo AS category_logo,
+c.created_at AS category_created_at,
+c.updated_at AS category_updated_at,
+pp.id AS pricing_id,
+pp.product_id AS pricing_product_id,
+pp.price AS pricing_price,
+pp.original_price AS pricing_original_price,
+pp.sale_starts AS pricing_sale_starts,
+pp.sale_ends AS pricing_sale_ends,
+(
+setweight(to_tsvector(coalesce(p.name,'')), 'A') ||
+setweight(to_tsvector(coalesce(p.description,'')), 'B') ||
+setweight(to_tsvector(coalesce(c.name,'')), 'C') ||
+setweight(to_tsvector(coalesce(b.name,'')), 'D')
+) as tsv
+FROM product p
+LEFT JOIN product_pricing pp ON p.id = pp.product_id AND pp.variant_id IS NULL
+LEFT JOIN brand b ON p.brand_id = b.id
+LEFT JOIN category c ON p.category_id = c.id
+WHERE CURRENT_TIMESTAMP BETWEEN pp.sale_starts AND pp.sale_ends;
@@ -0,0 +1,10 @@
+update public.category c set properties = (
+  select coalesce(jsonb_agg(
+    case
+      when attr->>'type' = 'boolean' then jsonb_set(attr, '{type}', '"bool"')
+      when attr->>'type' = 'enum' then jsonb_set(attr, '{type}', '"text"')
+      else attr
+    end order by ord), '[]'::jsonb)
+  from jsonb_array_elements(c.properties) with ordinality as a(attr, ord)
+)
+where jsonb_typeof(c.properties) = 'array';
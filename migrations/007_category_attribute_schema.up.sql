@@ -0,0 +1,15 @@
+-- category properties become the typed attribute schema:
+-- text attributes with choices are enums and bool attributes are booleans
+update public.category c set properties = (
+  select coalesce(jsonb_agg(
+    case
+      when attr->>'type' = 'bool' then jsonb_set(attr, '{type}', '"boolean"')
+      when attr->>'type' = 'text' then
+        case when jsonb_typeof(attr->'choices') = 'array' then
+          case when jsonb_array_length(attr->'choices') > 0 then jsonb_set(attr, '{type}', '"enum"') else attr end
+        else attr end
+      else attr
+    end order by ord), '[]'::jsonb)
+  from jsonb_array_elements(c.properties) with ordinality as a(attr, ord)
+)
+where jsonb_typeof(c.properties) = 'array';
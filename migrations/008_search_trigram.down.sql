@@ -0,0 +1,6 @@
+drop index public.tag_name_trgm_idx;
+drop index public.category_name_trgm_idx;
+drop index public.brand_name_trgm_idx;
+drop index public.product_name_trgm_idx;
+
+drop extension if exists pg_trgm;
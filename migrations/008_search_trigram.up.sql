@@ -0,0 +1,7 @@
+create extension if not exists pg_trgm;
+
+-- trigram indexes for the typo tolerant search and the type-ahead suggestions
+create index product_name_trgm_idx on public.product using gin (name gin_trgm_ops);
+create index brand_name_trgm_idx on public.brand using gin (name gin_trgm_ops);
+create index category_name_trgm_idx on public.category using gin (name gin_trgm_ops);
+create index tag_name_trgm_idx on public.tag using gin (name gin_trgm_ops);
@@ -0,0 +1,12 @@
+drop trigger product_search_category_trg on public.category;
+drop trigger product_search_brand_trg on public.brand;
+drop trigger product_search_pricing_trg on public.product_pricing;
+drop trigger product_search_product_trg on public.product;
+
+drop function product_search_enqueue_category();
+drop function product_search_enqueue_brand();
+drop function product_search_enqueue_pricing();
+drop function product_search_enqueue_product();
+
+drop table public.product_search_queue;
+drop table public.product_search;
@@ -0,0 +1,65 @@
+-- the search index is the materialized product_search_view, the view stays as the definition of the indexed rows
+-- the product that has overlapping sale windows is indexed with its latest started price
+create table public.product_search as
+select distinct on (id) * from product_search_view order by id, pricing_sale_starts desc;
+
+alter table public.product_search add primary key (id);
+create index product_search_tsv_idx on public.product_search using gin (tsv);
+create index product_search_name_trgm_idx on public.product_search using gin (name gin_trgm_ops);
+create index product_search_brand_name_trgm_idx on public.product_search using gin (brand_name gin_trgm_ops);
+create index product_search_category_name_trgm_idx on public.product_search using gin (category_name gin_trgm_ops);
+
+-- the products whose index rows are stale, the refresh job reindexes them
+create table public.product_search_queue (
+  product_id bigint primary key,
+  queued_at timestamptz not null default now()
+);
+
+create function product_search_enqueue_product() returns trigger as $$
+begin
+  if TG_OP = 'DELETE' then
+    delete from public.product_search where id = old.id;
+  else
+    insert into public.product_search_queue (product_id) values (new.id) on conflict do nothing;
+  end if;
+  return null;
+end;
+$$ language plpgsql;
+
+create function product_search_enqueue_pricing() returns trigger as $$
+begin
+  if TG_OP in ('UPDATE', 'DELETE') then
+    insert into public.product_search_queue (product_id) values (old.product_id) on conflict do nothing;
+  end if;
+  if TG_OP in ('INSERT', 'UPDATE') then
+    insert into public.product_search_queue (product_id) values (new.product_id) on conflict do nothing;
+  end if;
+  return null;
+end;
+$$ language plpgsql;
+
+create function product_search_enqueue_brand() returns trigger as $$
+begin
+  insert into public.product_search_queue (product_id) select id from public.product where brand_id = new.id on conflict do nothing;
+  return null;
+end;
+$$ language plpgsql;
+
+create function product_search_enqueue_category() returns trigger as $$
+begin
+  insert into public.product_search_queue (product_id) select id from public.product where category_id = new.id on conflict do nothing;
+  return null;
+end;
+$$ language plpgsql;
+
+create trigger product_search_product_trg after insert or update or delete on public.product
+for each row execute procedure product_search_enqueue_product();
+
+create trigger product_search_pricing_trg after insert or update or delete on public.product_pricing
+for each row execute procedure product_search_enqueue_pricing();
+
+create trigger product_search_brand_trg after update on public.brand
+for each row execute procedure product_search_enqueue_brand();
+
+create trigger product_search_category_trg after update on public.category
+for each row execute procedure product_search_enqueue_category();
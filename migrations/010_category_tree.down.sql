@@ -0,0 +1,9 @@
+drop trigger category_set_path_trg on public.category;
+drop function category_set_path();
+
+drop index public.category_path_idx;
+drop index public.category_parent_id_idx;
+
+alter table public.category drop column depth;
+alter table public.category drop column path;
+alter table public.category drop column parent_id;
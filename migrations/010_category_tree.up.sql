@@ -0,0 +1,29 @@
+-- categories form the tree, the path is the materialized list of the ancestor ids and the own id: /1/5/12/
+-- the descendants of the category are the categories whose path starts with its path
+alter table public.category add column parent_id bigint references public.category (id) on delete restrict;
+alter table public.category add column path text;
+alter table public.category add column depth int not null default 0;
+
+update public.category set path = '/' || id || '/';
+alter table public.category alter column path set not null;
+
+create index category_parent_id_idx on public.category (parent_id);
+create index category_path_idx on public.category (path text_pattern_ops);
+
+-- the path of the new category is set from its parent, the moves update the paths of the whole subtree in the store
+create function category_set_path() returns trigger as $$
+begin
+  if new.parent_id is null then
+    new.path := '/' || new.id || '/';
+    new.depth := 0;
+  else
+    -- the same lock as the moves take, so the parent path can't change until the insert commits
+    perform pg_advisory_xact_lock(hashtext('category_tree'));
+    select p.path || new.id || '/', p.depth + 1 into new.path, new.depth from public.category p where p.id = new.parent_id;
+  end if;
+  return new;
+end;
+$$ language plpgsql;
+
+create trigger category_set_path_trg before insert on public.category
+for each row execute procedure category_set_path();
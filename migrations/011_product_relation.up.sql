@@ -0,0 +1,12 @@
+-- the admin managed links between the products, ordered by the position within the relation type
+create table public.product_relation (
+  product_id bigint not null references public.product (id) on delete cascade,
+  related_id bigint not null references public.product (id) on delete cascade,
+  type varchar(32) not null check (type in ('related', 'upsell', 'cross_sell', 'accessory', 'replacement')),
+  position int not null default 0,
+  created_at timestamptz not null default now(),
+  primary key (product_id, type, related_id),
+  check (product_id <> related_id)
+);
+
+create index product_relation_related_id_idx on public.product_relation (related_id);
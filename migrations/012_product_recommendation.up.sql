@@ -0,0 +1,12 @@
+-- the top co-purchased products of every product, recomputed from the order history by the background job
+create table public.product_recommendation (
+  product_id bigint not null references public.product (id) on delete cascade,
+  recommended_id bigint not null references public.product (id) on delete cascade,
+  score double precision not null,
+  support int not null,
+  rank int not null,
+  computed_at timestamptz not null,
+  primary key (product_id, recommended_id)
+);
+
+create index product_recommendation_rank_idx on public.product_recommendation (product_id, rank);
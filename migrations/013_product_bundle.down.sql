@@ -0,0 +1,25 @@
+-- the orders with the same variant on multiple lines can't be represented anymore, they have to be fixed by hand
+do $$
+begin
+  if exists (select 1 from public.order_detail group by order_id, variant_id having count(*) > 1) then
+    raise exception 'order_detail has the orders with the same variant on multiple lines, merge them before the downgrade';
+  end if;
+end
+$$;
+
+alter table public.order_refund_item drop constraint order_refund_item_order_id_line_fkey;
+alter table public.order_refund_item drop constraint order_refund_item_pkey;
+alter table public.order_detail drop constraint order_detail_pkey;
+
+alter table public.order_detail add primary key (order_id, variant_id);
+alter table public.order_refund_item add primary key (refund_id, variant_id);
+alter table public.order_refund_item add foreign key (order_id, variant_id) references public.order_detail (order_id, variant_id) on delete cascade;
+alter table public.order_refund_item drop column line;
+alter table public.order_detail drop column line;
+
+alter table public.order_detail drop column bundle_price;
+alter table public.order_detail drop column bundle_quantity;
+alter table public.order_detail drop column bundle_id;
+
+drop table public.product_bundle_item;
+drop table public.product_bundle;
@@ -0,0 +1,43 @@
+-- the bundle product is sold as the set of the component variants, for the fixed price or the percentage off the components price
+create table public.product_bundle (
+  product_id int primary key references public.product (id) on delete cascade,
+  pricing_type varchar(16) not null check (pricing_type in ('fixed', 'percentage')),
+  amount int not null check (amount >= 0),
+  check (pricing_type <> 'percentage' or amount <= 100)
+);
+
+create table public.product_bundle_item (
+  bundle_id int not null references public.product_bundle (product_id) on delete cascade,
+  variant_id int not null references public.product_variant (id) on delete cascade,
+  quantity int not null check (quantity > 0),
+  position int not null default 0,
+  primary key (bundle_id, variant_id)
+);
+
+create index product_bundle_item_variant_id_idx on public.product_bundle_item (variant_id);
+
+-- the ordered bundle is expanded into its component lines, the lines remember the bundle for the invoice
+-- the component lines carry the share of the bundle price, the bundle price is the price of one bundle
+alter table public.order_detail add column bundle_id int;
+alter table public.order_detail add column bundle_quantity int;
+alter table public.order_detail add column bundle_price int;
+
+-- the order lines are numbered within the order, so the bundles can share the component with each other and with the other items
+alter table public.order_detail add column line int;
+update public.order_detail od set line = n.line
+from (select order_id, variant_id, row_number() over (partition by order_id order by variant_id) as line from public.order_detail) n
+where n.order_id = od.order_id and n.variant_id = od.variant_id;
+
+alter table public.order_refund_item add column line int;
+update public.order_refund_item ri set line = od.line from public.order_detail od where od.order_id = ri.order_id and od.variant_id = ri.variant_id;
+
+alter table public.order_refund_item drop constraint order_refund_item_order_id_variant_id_fkey;
+alter table public.order_refund_item drop constraint order_refund_item_pkey;
+alter table public.order_detail drop constraint order_detail_pkey;
+
+alter table public.order_detail alter column line set not null;
+alter table public.order_detail add primary key (order_id, line);
+
+alter table public.order_refund_item alter column line set not null;
+alter table public.order_refund_item add primary key (refund_id, line);
+alter table public.order_refund_item add foreign key (order_id, line) references public.order_detail (order_id, line) on delete cascade;
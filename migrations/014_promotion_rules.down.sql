@@ -0,0 +1,13 @@
+drop index public.promotion_detail_order_id_idx;
+drop index public.promotion_detail_promo_code_user_id_idx;
+alter table public.promotion_detail drop column created_at;
+alter table public.promotion_detail drop column order_id;
+alter table public.promotion_detail add constraint promotion_detail_user_id_promo_code_key unique (user_id, promo_code);
+
+drop table public.promotion_target;
+
+alter table public.promotion drop column usage_limit_per_user;
+alter table public.promotion drop column usage_limit;
+alter table public.promotion drop column max_discount_amount;
+alter table public.promotion drop column min_order_amount;
+alter table public.promotion drop constraint promotion_type_check;
@@ -0,0 +1,23 @@
+alter table public.promotion add constraint promotion_type_check check (type in ('percentage', 'fixed'));
+
+-- the conditions and the caps of the promotion, the null cap is unlimited
+alter table public.promotion add column min_order_amount int not null default 0 check (min_order_amount >= 0);
+alter table public.promotion add column max_discount_amount int check (max_discount_amount > 0);
+alter table public.promotion add column usage_limit int check (usage_limit > 0);
+alter table public.promotion add column usage_limit_per_user int default 1 check (usage_limit_per_user > 0);
+
+-- the promotion without the targets applies to the whole cart, otherwise only to the targeted products
+-- the category target includes its subcategories
+create table public.promotion_target (
+  promo_code varchar(30) not null references public.promotion (promo_code) on update cascade on delete cascade,
+  target_type varchar(16) not null check (target_type in ('product', 'category', 'brand', 'tag')),
+  target_id int not null,
+  primary key (promo_code, target_type, target_id)
+);
+
+-- every redemption is the row of its own, the user can redeem the code up to the per user limit
+alter table public.promotion_detail drop constraint promotion_detail_user_id_promo_code_key;
+alter table public.promotion_detail add column order_id int references public.order (id) on delete cascade;
+alter table public.promotion_detail add column created_at timestamptz not null default now();
+create index promotion_detail_promo_code_user_id_idx on public.promotion_detail (promo_code, user_id);
+create unique index promotion_detail_order_id_idx on public.promotion_detail (order_id);
@@ -0,0 +1,11 @@
+drop table public.order_discount;
+
+alter table public.order_detail drop column discount;
+alter table public.order drop column discount;
+alter table public.order drop column shipping;
+
+alter table public.promotion drop column tiers;
+alter table public.promotion drop column get_quantity;
+alter table public.promotion drop column buy_quantity;
+alter table public.promotion drop constraint promotion_type_check;
+alter table public.promotion add constraint promotion_type_check check (type in ('percentage', 'fixed'));
@@ -0,0 +1,32 @@
+-- buy x get y discounts the y items of every x + y items of the line, by the amount percentage
+-- tiered discounts the line by the percentage of the highest tier its quantity reaches
+-- free shipping discounts the shipping fee
+alter table public.promotion drop constraint promotion_type_check;
+alter table public.promotion add constraint promotion_type_check check (type in ('percentage', 'fixed', 'buy_x_get_y', 'free_shipping', 'tiered'));
+alter table public.promotion add column buy_quantity int check (buy_quantity > 0);
+alter table public.promotion add column get_quantity int check (get_quantity > 0);
+alter table public.promotion add column tiers jsonb;
+
+-- the discounts applied to the order, the line discount is the share of the discounts allocated to the line
+alter table public.order add column shipping int not null default 0;
+alter table public.order add column discount int not null default 0;
+alter table public.order_detail add column discount int not null default 0;
+
+create table public.order_discount (
+  id int generated always as identity primary key,
+  order_id int not null references public.order (id) on delete cascade,
+  promo_code varchar(30),
+  type varchar(30) not null,
+  description text not null default '',
+  amount int not null,
+  shipping_amount int not null default 0
+);
+
+create index order_discount_order_id_idx on public.order_discount (order_id);
+
+-- the existing orders keep their promo code discount, split over the lines proportionally
+update public.order set discount = subtotal - total where total < subtotal;
+update public.order_detail od set discount = round(od.history_price * od.quantity * o.discount::numeric / o.subtotal)
+from public.order o where o.id = od.order_id and o.discount > 0 and o.subtotal > 0;
+insert into public.order_discount (order_id, promo_code, type, amount)
+select id, promo_code, coalesce(promo_code_type, 'fixed'), discount from public.order where discount > 0;
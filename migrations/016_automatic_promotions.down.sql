@@ -0,0 +1,7 @@
+drop index public.promotion_detail_order_id_promo_code_idx;
+create unique index promotion_detail_order_id_idx on public.promotion_detail (order_id);
+
+drop index public.promotion_automatic_idx;
+alter table public.promotion drop column exclusive;
+alter table public.promotion drop column priority;
+alter table public.promotion drop column automatic;
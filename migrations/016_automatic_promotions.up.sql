@@ -0,0 +1,11 @@
+-- the automatic promotion applies without the promo code, its promo code is only the promotion key
+-- the promotions are evaluated by the priority, the exclusive promotion is never combined with another one
+alter table public.promotion add column automatic bool not null default false;
+alter table public.promotion add column priority int not null default 0;
+alter table public.promotion add column exclusive bool not null default true;
+
+create index promotion_automatic_idx on public.promotion (priority desc) where automatic;
+
+-- the order can redeem multiple promotions, each one once
+drop index public.promotion_detail_order_id_idx;
+create unique index promotion_detail_order_id_promo_code_idx on public.promotion_detail (order_id, promo_code);
@@ -0,0 +1,3 @@
+drop table if exists public.promotion_code;
+
+alter table public.promotion drop column campaign;
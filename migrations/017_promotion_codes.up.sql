@@ -0,0 +1,15 @@
+-- the campaign is the promotion that owns the generated single use codes, its promo code can't be entered by itself
+alter table public.promotion add column campaign bool not null default false;
+
+-- the generated code is redeemed once, by the order that used it
+create table public.promotion_code (
+  code varchar(30) primary key,
+  promo_code varchar(30) not null references public.promotion (promo_code) on update cascade on delete cascade,
+  user_id int references public.user (id) on delete set null,
+  order_id int references public.order (id) on delete set null,
+  redeemed_at timestamptz,
+  created_at timestamptz not null default now()
+);
+
+create index promotion_code_promo_code_idx on public.promotion_code (promo_code, created_at);
+create index promotion_code_order_id_idx on public.promotion_code (order_id) where order_id is not null;
@@ -0,0 +1,6 @@
+drop trigger promotion_detail_release_trg on public.promotion_detail;
+drop trigger promotion_detail_claim_trg on public.promotion_detail;
+drop function promotion_detail_release();
+drop function promotion_detail_claim();
+
+alter table public.promotion drop column usage_count;
@@ -0,0 +1,41 @@
+-- the redemptions are counted on the promotion, the row lock of the counter serializes the concurrent claims of the promo code
+alter table public.promotion add column usage_count int not null default 0 check (usage_count >= 0);
+update public.promotion p set usage_count = (select count(*) from public.promotion_detail pd where pd.promo_code = p.promo_code);
+
+-- the redemption is claimed only while the promotion is under its usage limit and the user under the per user limit
+create function promotion_detail_claim() returns trigger as $$
+declare
+  per_user int;
+begin
+  update public.promotion set usage_count = usage_count + 1
+  where promo_code = new.promo_code and (usage_limit is null or usage_count < usage_limit)
+  returning usage_limit_per_user into per_user;
+
+  if not found then
+    if exists (select 1 from public.promotion where promo_code = new.promo_code) then
+      raise exception 'promotion % has reached its usage limit', new.promo_code using errcode = 'PU001';
+    end if;
+    return new;
+  end if;
+
+  if per_user is not null and new.user_id is not null
+    and (select count(*) from public.promotion_detail where promo_code = new.promo_code and user_id = new.user_id) >= per_user then
+    raise exception 'user % has reached the usage limit of promotion %', new.user_id, new.promo_code using errcode = 'PU002';
+  end if;
+  return new;
+end;
+$$ language plpgsql;
+
+-- the released redemption, also the one deleted together with its order, frees the usage again
+create function promotion_detail_release() returns trigger as $$
+begin
+  update public.promotion set usage_count = usage_count - 1 where promo_code = old.promo_code;
+  return old;
+end;
+$$ language plpgsql;
+
+create trigger promotion_detail_claim_trg before insert on public.promotion_detail
+for each row execute procedure promotion_detail_claim();
+
+create trigger promotion_detail_release_trg after delete on public.promotion_detail
+for each row execute procedure promotion_detail_release();
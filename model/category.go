@@ -7,7 +7,6 @@ import (
 	"time"
 
 	"github.com/dankobgd/ecommerce-shop/gocloudinary"
-	"github.com/dankobgd/ecommerce-shop/utils/is"
 	"github.com/dankobgd/ecommerce-shop/utils/locale"
 	"github.com/jmoiron/sqlx/types"
 	"github.com/nicksnyder/go-i18n/v2/i18n"
@@ -17,19 +16,23 @@ import (
 var (
 	msgInvalidCategory            = &i18n.Message{ID: "model.category.validate.app_error", Other: "invalid category data"}
 	msgValidateCategoryID         = &i18n.Message{ID: "model.category.validate.id.app_error", Other: "invalid category id"}
+	msgValidateCategoryParent     = &i18n.Message{ID: "model.category.validate.parent_id.app_error", Other: "invalid parent category id"}
 	msgValidateCategoryName       = &i18n.Message{ID: "model.category.validate.name.app_error", Other: "invalid category name"}
 	msgValidateCategorySlug       = &i18n.Message{ID: "model.category.validate.created_at.app_error", Other: "invalid category slug"}
 	msgValidateCategoryLogo       = &i18n.Message{ID: "model.category.validate.logo.app_error", Other: "invalid logo"}
 	msgValidateCategoryLogoSize   = &i18n.Message{ID: "model.category.validate.logo.app_error", Other: "category file size exceeded, max 3MB"}
 	msgValidateCategoryCrAt       = &i18n.Message{ID: "model.category.validate.created_at.app_error", Other: "invalid category created_at timestamp"}
 	msgValidateCategoryUpAt       = &i18n.Message{ID: "model.category.validate.updated_at.app_error", Other: "invalid category updated_at timestamp"}
-	msgValidateCategoryProperties = &i18n.Message{ID: "model.category.validate.properties.app_error", Other: "invalid json provided as properties"}
+	msgValidateCategoryProperties = &i18n.Message{ID: "model.category.validate.properties.app_error", Other: "properties must be the list of the category attributes"}
 )
 
 // Category is the category
 type Category struct {
 	TotalRecordsCount
 	ID             int64           `json:"id" db:"id" schema:"-"`
+	ParentID       *int64          `json:"parent_id" db:"parent_id" schema:"parent_id"`
+	Path           string          `json:"-" db:"path" schema:"-"`
+	Depth          int             `json:"depth" db:"depth" schema:"-"`
 	Name           string          `json:"name" db:"name" schema:"name"`
 	Slug           string          `json:"slug" db:"slug" schema:"slug"`
 	Description    string          `json:"description,omitempty" db:"description" schema:"description"`
@@ -40,6 +43,7 @@ type Category struct {
 	UpdatedAt      time.Time       `json:"updated_at" db:"updated_at" schema:"-"`
 	Properties     *types.JSONText `json:"properties" db:"properties" schema:"-"`
 	PropertiesText *string         `json:"-" schema:"properties"`
+	Children       []*Category     `json:"children,omitempty" db:"-" schema:"-"`
 }
 
 // Validate validates the category and returns an error if it doesn't pass criteria
@@ -50,6 +54,9 @@ func (c *Category) Validate(fh *multipart.FileHeader) *AppErr {
 	if c.ID != 0 {
 		errs.Add(Invalid("id", l, msgValidateCategoryID))
 	}
+	if c.ParentID != nil && *c.ParentID <= 0 {
+		errs.Add(Invalid("parent_id", l, msgValidateCategoryParent))
+	}
 	if c.Name == "" {
 		errs.Add(Invalid("name", l, msgValidateCategoryName))
 	}
@@ -68,9 +75,8 @@ func (c *Category) Validate(fh *multipart.FileHeader) *AppErr {
 	if fh != nil && fh.Size > FileUploadSizeLimit {
 		errs.Add(Invalid("logo", l, msgValidateCategoryLogoSize))
 	}
-	// ideally validate properties against json schema to check for the right keys, values and structure...
-	if c.PropertiesText != nil && !is.ValidJSON(*c.PropertiesText) {
-		errs.Add(Invalid("properties", l, msgValidateCategoryProperties))
+	if c.PropertiesText != nil {
+		validateCategorySchema(&errs, l, *c.PropertiesText)
 	}
 
 	if !errs.IsZero() {
@@ -128,9 +134,8 @@ func (patch *CategoryPatch) Validate(fh *multipart.FileHeader) *AppErr {
 	if fh != nil && fh.Size > FileUploadSizeLimit {
 		errs.Add(Invalid("logo", l, msgValidateCategoryLogoSize))
 	}
-	// ideally validate properties against json schema to check for the right keys, values and structure...
-	if patch.PropertiesText != nil && *patch.PropertiesText != "" && !is.ValidJSON(*patch.PropertiesText) {
-		errs.Add(Invalid("properties", l, msgValidateCategoryProperties))
+	if patch.PropertiesText != nil && *patch.PropertiesText != "" {
+		validateCategorySchema(&errs, l, *patch.PropertiesText)
 	}
 
 	if !errs.IsZero() {
@@ -191,6 +196,16 @@ func (patch *CategoryPatch) SetProperties(properties *string) {
 	}
 }
 
+// validateCategorySchema adds the errors of the category properties, which are the attribute schema
+func validateCategorySchema(errs *ValidationErrors, l *i18n.Localizer, properties string) {
+	schema, err := ParseCategorySchema([]byte(properties))
+	if err != nil {
+		errs.Add(Invalid("properties", l, msgValidateCategoryProperties))
+		return
+	}
+	schema.validate(errs, l)
+}
+
 // SetLogoDetails sets the category logo and public_id
 func (c *Category) SetLogoDetails(details *gocloudinary.ResourceDetails) {
 	c.Logo = details.SecureURL
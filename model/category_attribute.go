@@ -0,0 +1,238 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/dankobgd/ecommerce-shop/utils/locale"
+	"github.com/jmoiron/sqlx/types"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+// error msgs
+var (
+	msgValidateAttributeName       = &i18n.Message{ID: "model.category_attribute.validate.name.app_error", Other: "attribute name must be lowercase letters, numbers and underscores"}
+	msgValidateAttributeNameUnique = &i18n.Message{ID: "model.category_attribute.validate.name_unique.app_error", Other: "attribute names must be unique"}
+	msgValidateAttributeType       = &i18n.Message{ID: "model.category_attribute.validate.type.app_error", Other: "attribute type must be enum, number, boolean or text"}
+	msgValidateAttributeChoices    = &i18n.Message{ID: "model.category_attribute.validate.choices.app_error", Other: "enum attribute must have unique choices, other types can't have choices"}
+	msgValidateAttributeRange      = &i18n.Message{ID: "model.category_attribute.validate.range.app_error", Other: "attribute min must not be greater than max, only number attributes have the range"}
+	msgValidatePropertyUnknown     = &i18n.Message{ID: "model.product.validate.properties.unknown.app_error", Other: "property is not declared by the product category"}
+	msgValidatePropertyRequired    = &i18n.Message{ID: "model.product.validate.properties.required.app_error", Other: "property is required by the product category"}
+	msgValidatePropertyEnum        = &i18n.Message{ID: "model.product.validate.properties.enum.app_error", Other: "property value is not one of the attribute choices"}
+	msgValidatePropertyNumber      = &i18n.Message{ID: "model.product.validate.properties.number.app_error", Other: "property value must be the number within the attribute range"}
+	msgValidatePropertyBoolean     = &i18n.Message{ID: "model.product.validate.properties.boolean.app_error", Other: "property value must be true or false"}
+	msgValidatePropertyText        = &i18n.Message{ID: "model.product.validate.properties.text.app_error", Other: "property value must be the text up to 255 characters"}
+)
+
+// AttributeType is the type of the category attribute value
+type AttributeType string
+
+// attribute types
+const (
+	AttributeTypeEnum    AttributeType = "enum"
+	AttributeTypeNumber  AttributeType = "number"
+	AttributeTypeBoolean AttributeType = "boolean"
+	AttributeTypeText    AttributeType = "text"
+)
+
+// attributeTextMaxLength is the max length of the text property value
+const attributeTextMaxLength = 255
+
+var attributeNameRegex = regexp.MustCompile(`^[a-z0-9_]{1,64}$`)
+
+// AttributeChoice is the allowed value of the enum attribute
+type AttributeChoice struct {
+	Name  string `json:"name"`
+	Label string `json:"label"`
+}
+
+// CategoryAttribute is the typed product property declared by the category
+type CategoryAttribute struct {
+	Name       string             `json:"name"`
+	Label      string             `json:"label"`
+	Type       AttributeType      `json:"type"`
+	Importance int                `json:"importance"`
+	Filterable bool               `json:"filterable"`
+	Required   bool               `json:"required,omitempty"`
+	Choices    []*AttributeChoice `json:"choices,omitempty"`
+	Min        *float64           `json:"min,omitempty"`
+	Max        *float64           `json:"max,omitempty"`
+}
+
+// CategorySchema is the list of the category attributes, stored as the category properties
+type CategorySchema []*CategoryAttribute
+
+// ParseCategorySchema decodes the category properties json into the schema
+// the empty properties have the empty schema
+func ParseCategorySchema(data []byte) (CategorySchema, error) {
+	var schema CategorySchema
+	if len(data) == 0 || string(data) == "null" {
+		return schema, nil
+	}
+	err := json.Unmarshal(data, &schema)
+	return schema, err
+}
+
+// Schema returns the category attribute schema, the properties that are not the valid schema have the empty one
+func (c *Category) Schema() CategorySchema {
+	if c.Properties == nil {
+		return nil
+	}
+	schema, err := ParseCategorySchema(*c.Properties)
+	if err != nil {
+		return nil
+	}
+	return schema
+}
+
+// Get returns the attribute with the given name, nil if it is not declared
+func (s CategorySchema) Get(name string) *CategoryAttribute {
+	for _, attr := range s {
+		if attr.Name == name {
+			return attr
+		}
+	}
+	return nil
+}
+
+// Filters returns the filterable attributes, the most important first
+func (s CategorySchema) Filters() CategorySchema {
+	filters := make(CategorySchema, 0, len(s))
+	for _, attr := range s {
+		if attr.Filterable {
+			filters = append(filters, attr)
+		}
+	}
+	sort.SliceStable(filters, func(i, j int) bool {
+		return filters[i].Importance < filters[j].Importance
+	})
+	return filters
+}
+
+// validate adds the errors of the schema definition
+func (s CategorySchema) validate(errs *ValidationErrors, l *i18n.Localizer) {
+	names := make(map[string]bool, len(s))
+	for i, attr := range s {
+		field := fmt.Sprintf("properties[%d]", i)
+		if attr == nil || !attributeNameRegex.MatchString(attr.Name) {
+			errs.Add(Invalid(field+".name", l, msgValidateAttributeName))
+			continue
+		}
+		if names[attr.Name] {
+			errs.Add(Invalid(field+".name", l, msgValidateAttributeNameUnique))
+		}
+		names[attr.Name] = true
+
+		switch attr.Type {
+		case AttributeTypeEnum, AttributeTypeNumber, AttributeTypeBoolean, AttributeTypeText:
+		default:
+			errs.Add(Invalid(field+".type", l, msgValidateAttributeType))
+			continue
+		}
+
+		if !attr.validChoices() {
+			errs.Add(Invalid(field+".choices", l, msgValidateAttributeChoices))
+		}
+		if (attr.Type != AttributeTypeNumber && (attr.Min != nil || attr.Max != nil)) || (attr.Min != nil && attr.Max != nil && *attr.Min > *attr.Max) {
+			errs.Add(Invalid(field+".min", l, msgValidateAttributeRange))
+		}
+	}
+}
+
+func (attr *CategoryAttribute) validChoices() bool {
+	if attr.Type != AttributeTypeEnum {
+		return len(attr.Choices) == 0
+	}
+	if len(attr.Choices) == 0 {
+		return false
+	}
+	seen := make(map[string]bool, len(attr.Choices))
+	for _, c := range attr.Choices {
+		if c == nil || c.Name == "" || seen[c.Name] {
+			return false
+		}
+		seen[c.Name] = true
+	}
+	return true
+}
+
+// HasChoice returns true if the enum attribute allows the value
+func (attr *CategoryAttribute) HasChoice(value string) bool {
+	for _, c := range attr.Choices {
+		if c.Name == value {
+			return true
+		}
+	}
+	return false
+}
+
+// InRange returns true if the number is within the attribute range
+func (attr *CategoryAttribute) InRange(n float64) bool {
+	return (attr.Min == nil || n >= *attr.Min) && (attr.Max == nil || n <= *attr.Max)
+}
+
+// ValidateProperties validates the product properties against the schema and returns an error if they don't pass criteria
+func (s CategorySchema) ValidateProperties(props *types.JSONText) *AppErr {
+	var errs ValidationErrors
+	l := locale.GetUserLocalizer("en")
+
+	values := make(map[string]interface{})
+	if props != nil && len(*props) > 0 && string(*props) != "null" {
+		if err := json.Unmarshal(*props, &values); err != nil {
+			errs.Add(Invalid("properties", l, msgValidateProductProperties))
+			return NewValidationError("Product", msgInvalidProduct, "", errs)
+		}
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		attr := s.Get(name)
+		if attr == nil {
+			errs.Add(Invalid("properties."+name, l, msgValidatePropertyUnknown))
+			continue
+		}
+		if msg := attr.validateValue(values[name]); msg != nil {
+			errs.Add(Invalid("properties."+name, l, msg))
+		}
+	}
+	for _, attr := range s {
+		if _, ok := values[attr.Name]; attr.Required && !ok {
+			errs.Add(Invalid("properties."+attr.Name, l, msgValidatePropertyRequired))
+		}
+	}
+
+	if !errs.IsZero() {
+		return NewValidationError("Product", msgInvalidProduct, "", errs)
+	}
+	return nil
+}
+
+// validateValue returns the error message if the property value doesn't match the attribute
+func (attr *CategoryAttribute) validateValue(v interface{}) *i18n.Message {
+	switch attr.Type {
+	case AttributeTypeEnum:
+		if s, ok := v.(string); !ok || !attr.HasChoice(s) {
+			return msgValidatePropertyEnum
+		}
+	case AttributeTypeNumber:
+		if n, ok := v.(float64); !ok || !attr.InRange(n) {
+			return msgValidatePropertyNumber
+		}
+	case AttributeTypeBoolean:
+		if _, ok := v.(bool); !ok {
+			return msgValidatePropertyBoolean
+		}
+	case AttributeTypeText:
+		if s, ok := v.(string); !ok || len(s) > attributeTextMaxLength {
+			return msgValidatePropertyText
+		}
+	}
+	return nil
+}
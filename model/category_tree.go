@@ -0,0 +1,47 @@
+package model
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+// CategoryMove is the new parent of the moved category, the nil parent makes it the root category
+type CategoryMove struct {
+	ParentID *int64 `json:"parent_id"`
+}
+
+// CategoryMoveFromJSON decodes the input and returns the CategoryMove
+func CategoryMoveFromJSON(data io.Reader) (*CategoryMove, error) {
+	var m *CategoryMove
+	err := json.NewDecoder(data).Decode(&m)
+	return m, err
+}
+
+// IsDescendantOf returns true if the category is in the subtree of the other category, including the category itself
+func (c *Category) IsDescendantOf(other *Category) bool {
+	return strings.HasPrefix(c.Path, other.Path)
+}
+
+// BuildCategoryTree links the categories to their parents and returns the roots
+// the categories whose parent is not in the list are the roots, so the subtree builds from its own rows
+// the order of the categories is kept among the siblings
+func BuildCategoryTree(categories []*Category) []*Category {
+	byID := make(map[int64]*Category, len(categories))
+	for _, c := range categories {
+		c.Children = nil
+		byID[c.ID] = c
+	}
+
+	roots := make([]*Category, 0)
+	for _, c := range categories {
+		if c.ParentID != nil {
+			if parent, ok := byID[*c.ParentID]; ok {
+				parent.Children = append(parent.Children, c)
+				continue
+			}
+		}
+		roots = append(roots, c)
+	}
+	return roots
+}
@@ -0,0 +1,99 @@
+package model
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// renderCategoryTree writes the tree as the ids with the children in the parens: 1(2,3(4))
+func renderCategoryTree(categories []*Category) string {
+	parts := make([]string, 0, len(categories))
+	for _, c := range categories {
+		s := strconv.FormatInt(c.ID, 10)
+		if len(c.Children) > 0 {
+			s += "(" + renderCategoryTree(c.Children) + ")"
+		}
+		parts = append(parts, s)
+	}
+	return strings.Join(parts, ",")
+}
+
+func TestBuildCategoryTree(t *testing.T) {
+	category := func(id int64, parentID *int64) *Category {
+		return &Category{ID: id, ParentID: parentID}
+	}
+
+	tests := []struct {
+		name       string
+		categories []*Category
+		want       string
+	}{
+		{"empty", []*Category{}, ""},
+		{"flat roots", []*Category{category(1, nil), category(2, nil)}, "1,2"},
+		{
+			name:       "nested",
+			categories: []*Category{category(1, nil), category(2, NewInt64(1)), category(3, NewInt64(1)), category(4, NewInt64(3)), category(5, nil)},
+			want:       "1(2,3(4)),5",
+		},
+		{
+			name:       "children before their parent",
+			categories: []*Category{category(4, NewInt64(3)), category(3, NewInt64(1)), category(1, nil)},
+			want:       "1(3(4))",
+		},
+		{
+			name:       "sibling order is kept",
+			categories: []*Category{category(1, nil), category(9, NewInt64(1)), category(2, NewInt64(1)), category(5, NewInt64(1))},
+			want:       "1(9,2,5)",
+		},
+		{
+			name:       "subtree without its parent",
+			categories: []*Category{category(3, NewInt64(1)), category(4, NewInt64(3)), category(6, NewInt64(3))},
+			want:       "3(4,6)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := renderCategoryTree(BuildCategoryTree(tt.categories)); got != tt.want {
+				t.Errorf("BuildCategoryTree = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildCategoryTreeRebuild(t *testing.T) {
+	categories := []*Category{{ID: 1}, {ID: 2, ParentID: NewInt64(1)}}
+
+	BuildCategoryTree(categories)
+	if got := renderCategoryTree(BuildCategoryTree(categories)); got != "1(2)" {
+		t.Errorf("rebuilt tree = %q, want %q", got, "1(2)")
+	}
+}
+
+func TestCategoryIsDescendantOf(t *testing.T) {
+	root := &Category{ID: 1, Path: "/1/"}
+	child := &Category{ID: 5, Path: "/1/5/"}
+	other := &Category{ID: 15, Path: "/15/"}
+
+	tests := []struct {
+		name  string
+		c     *Category
+		other *Category
+		want  bool
+	}{
+		{"child of the root", child, root, true},
+		{"itself", root, root, true},
+		{"root of the child", root, child, false},
+		{"other tree", other, root, false},
+		{"id prefix is not the ancestor", &Category{ID: 12, Path: "/12/"}, root, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.c.IsDescendantOf(tt.other); got != tt.want {
+				t.Errorf("%q.IsDescendantOf(%q) = %v, want %v", tt.c.Path, tt.other.Path, got, tt.want)
+			}
+		})
+	}
+}
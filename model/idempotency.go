@@ -0,0 +1,110 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// IdempotencyStatus is the state of the idempotent request
+type IdempotencyStatus string
+
+// idempotency statuses
+const (
+	IdempotencyStatusProcessing IdempotencyStatus = "processing"
+	IdempotencyStatusCompleted  IdempotencyStatus = "completed"
+)
+
+// IdempotencyKeyMaxLength is the max length of the Idempotency-Key header value
+const IdempotencyKeyMaxLength = 255
+
+// IdempotencyRecord is the stored request fingerprint and the response of the idempotent request
+type IdempotencyRecord struct {
+	Fingerprint string            `json:"fingerprint"`
+	Status      IdempotencyStatus `json:"status"`
+	StatusCode  int               `json:"status_code,omitempty"`
+	ContentType string            `json:"content_type,omitempty"`
+	Body        []byte            `json:"body,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+}
+
+// NewIdempotencyRecord returns the record of the request that is being processed
+func NewIdempotencyRecord(fingerprint string) *IdempotencyRecord {
+	return &IdempotencyRecord{
+		Fingerprint: fingerprint,
+		Status:      IdempotencyStatusProcessing,
+		CreatedAt:   time.Now(),
+	}
+}
+
+// IsCompleted returns true if the response has been stored
+func (r *IdempotencyRecord) IsCompleted() bool {
+	return r.Status == IdempotencyStatusCompleted
+}
+
+// Complete stores the response
+func (r *IdempotencyRecord) Complete(statusCode int, contentType string, body []byte) {
+	r.Status = IdempotencyStatusCompleted
+	r.StatusCode = statusCode
+	r.ContentType = contentType
+	r.Body = body
+}
+
+// ToJSON converts the record to json string
+func (r *IdempotencyRecord) ToJSON() string {
+	b, _ := json.Marshal(r)
+	return string(b)
+}
+
+// IdempotencyRecordFromJSON decodes the input and returns the IdempotencyRecord
+func IdempotencyRecordFromJSON(data string) (*IdempotencyRecord, error) {
+	var r *IdempotencyRecord
+	err := json.Unmarshal([]byte(data), &r)
+	return r, err
+}
+
+// IdempotencyScope is the idempotency key of the request being processed
+// once the payment provider has been called the key is never released, the retried request must not pay again
+type IdempotencyScope struct {
+	Key              string
+	paymentAttempted bool
+}
+
+// NewIdempotencyScope returns the scope of the request with the key
+func NewIdempotencyScope(key string) *IdempotencyScope {
+	return &IdempotencyScope{Key: key}
+}
+
+// AttemptPayment marks that the payment provider is called, it does nothing for the request without the key
+func (s *IdempotencyScope) AttemptPayment() {
+	if s != nil {
+		s.paymentAttempted = true
+	}
+}
+
+// PaymentAttempted returns true if the payment provider has been called
+func (s *IdempotencyScope) PaymentAttempted() bool {
+	return s != nil && s.paymentAttempted
+}
+
+// ProviderKey returns the payment provider idempotency key of the operation derived from the request key
+// it is empty for the request without the key
+func (s *IdempotencyScope) ProviderKey(op string) string {
+	if s == nil || s.Key == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(s.Key))
+	return op + "_" + hex.EncodeToString(sum[:])
+}
+
+// RequestFingerprint returns the hash of the request parts, used to detect the key reuse with different request
+func RequestFingerprint(method, path string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method))
+	h.Write([]byte{0})
+	h.Write([]byte(path))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,14 @@
+package model
+
+import (
+	"os"
+	"testing"
+
+	"github.com/dankobgd/ecommerce-shop/utils/locale"
+)
+
+func TestMain(m *testing.M) {
+	// the validation errors are localized, the default messages are used without the locales dir
+	locale.InitTranslations()
+	os.Exit(m.Run())
+}
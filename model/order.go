@@ -17,28 +17,65 @@ var msgValidateBillingAddressID = &i18n.Message{ID: "model.order.validate.billin
 var msgValidateShippingAddress = &i18n.Message{ID: "model.order.validate.shipping_address.app_error", Other: "Invalid shipping address"}
 var msgValidateShippingAddressNeedsBilling = &i18n.Message{ID: "model.order.validate.shipping_address.app_error", Other: "No billing address provided but same_shipping_as_billing is true"}
 
-type orderStatus int
+// OrderStatus is the order lifecycle status
+type OrderStatus int
 
 // order statuses
 const (
-	OrderStatusPending orderStatus = iota
-	OrderStatusSuccess
-	OrderStatusFailed
+	OrderStatusPendingPayment OrderStatus = iota
+	OrderStatusPaid
+	OrderStatusProcessing
+	OrderStatusShipped
+	OrderStatusDelivered
+	OrderStatusCancelled
+	OrderStatusRefunded
+	OrderStatusPartiallyRefunded
 )
 
-func (s orderStatus) String() string {
+var orderStatuses = []OrderStatus{
+	OrderStatusPendingPayment,
+	OrderStatusPaid,
+	OrderStatusProcessing,
+	OrderStatusShipped,
+	OrderStatusDelivered,
+	OrderStatusCancelled,
+	OrderStatusRefunded,
+	OrderStatusPartiallyRefunded,
+}
+
+func (s OrderStatus) String() string {
 	switch s {
-	case OrderStatusPending:
-		return "pending"
-	case OrderStatusSuccess:
-		return "success"
-	case OrderStatusFailed:
-		return "fail"
+	case OrderStatusPendingPayment:
+		return "pending_payment"
+	case OrderStatusPaid:
+		return "paid"
+	case OrderStatusProcessing:
+		return "processing"
+	case OrderStatusShipped:
+		return "shipped"
+	case OrderStatusDelivered:
+		return "delivered"
+	case OrderStatusCancelled:
+		return "cancelled"
+	case OrderStatusRefunded:
+		return "refunded"
+	case OrderStatusPartiallyRefunded:
+		return "partially_refunded"
 	default:
 		return "unknown"
 	}
 }
 
+// OrderStatusFromString returns the order status for the given name
+func OrderStatusFromString(s string) (OrderStatus, bool) {
+	for _, status := range orderStatuses {
+		if status.String() == s {
+			return status, true
+		}
+	}
+	return 0, false
+}
+
 // Order represents the transaction
 type Order struct {
 	TotalRecordsCount
@@ -49,6 +86,8 @@ type Order struct {
 	PromoCodeAmount          *int       `json:"promo_code_amount" db:"promo_code_amount"`
 	Status                   string     `json:"status" db:"status"`
 	Subtotal                 int        `json:"subtotal" db:"subtotal"`
+	Shipping                 int        `json:"shipping" db:"shipping"`
+	Discount                 int        `json:"discount" db:"discount"`
 	Total                    int        `json:"total" db:"total"`
 	ShippedAt                *time.Time `json:"shipped_at" db:"shipped_at"`
 	CreatedAt                time.Time  `json:"created_at" db:"created_at"`
@@ -77,14 +116,32 @@ type Order struct {
 func (o *Order) PreSave() {
 	o.CreatedAt = time.Now()
 	if o.Status == "" {
-		o.Status = OrderStatusPending.String()
+		o.Status = OrderStatusPendingPayment.String()
 	}
 }
 
+// StatusValue returns the typed order status
+func (o *Order) StatusValue() (OrderStatus, bool) {
+	return OrderStatusFromString(o.Status)
+}
+
+// CheckoutResult is the result of the order checkout
+// when the payment requires the customer authentication (3DS etc...) the order stays pending
+// until the client completes it with the client secret and confirms the order
+type CheckoutResult struct {
+	Order              *Order                `json:"order"`
+	RequiresAction     bool                  `json:"requires_action"`
+	ClientSecret       string                `json:"client_secret,omitempty"`
+	Discounts          []*OrderDiscount      `json:"discounts,omitempty"`
+	RejectedPromotions []*PromotionRejection `json:"rejected_promotions,omitempty"`
+}
+
 // CartItem is the cart item info
+// without the variant the default product variant is ordered
 type CartItem struct {
-	ProductID int64 `json:"product_id"`
-	Quantity  int   `json:"quantity"`
+	ProductID int64  `json:"product_id"`
+	VariantID *int64 `json:"variant_id"`
+	Quantity  int    `json:"quantity"`
 }
 
 // OrderRequestData is used to create new order
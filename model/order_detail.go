@@ -1,16 +1,27 @@
 package model
 
-// OrderDetail ties order with product items
+// OrderDetail ties order with the product variant items
+// the discount is the share of the order discounts allocated to the whole line
+// the component lines of the ordered bundle keep the bundle product, the number of bundles and the price of one bundle
+// the line is numbered within the order, the same variant can be on multiple lines when the bundles share the component
 type OrderDetail struct {
-	OrderID      int64  `json:"order_id" db:"order_id"`
-	ProductID    int64  `json:"product_id" db:"product_id"`
-	Quantity     int    `json:"quantity" db:"quantity"`
-	HistoryPrice int    `json:"history_price" db:"history_price"`
-	HistorySKU   string `json:"history_sku" db:"history_sku"`
+	OrderID        int64          `json:"order_id" db:"order_id"`
+	Line           int            `json:"line" db:"line"`
+	ProductID      int64          `json:"product_id" db:"product_id"`
+	VariantID      int64          `json:"variant_id" db:"variant_id"`
+	Quantity       int            `json:"quantity" db:"quantity"`
+	HistoryPrice   int            `json:"history_price" db:"history_price"`
+	HistorySKU     string         `json:"history_sku" db:"history_sku"`
+	HistoryOptions VariantOptions `json:"history_options" db:"history_options"`
+	Discount       int            `json:"discount" db:"discount"`
+	BundleID       *int64         `json:"bundle_id,omitempty" db:"bundle_id"`
+	BundleQuantity *int           `json:"bundle_quantity,omitempty" db:"bundle_quantity"`
+	BundlePrice    *int           `json:"bundle_price,omitempty" db:"bundle_price"`
 }
 
 // OrderInfo returns the order details info with the product data
 type OrderInfo struct {
 	OrderDetail
 	Product
+	BundleName *string `json:"bundle_name,omitempty" db:"bundle_name"`
 }
@@ -0,0 +1,63 @@
+package model
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/dankobgd/ecommerce-shop/utils/locale"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+var (
+	msgInvalidOrderStatusChange = &i18n.Message{ID: "model.order_status_change.validate.app_error", Other: "invalid order status change data"}
+	msgValidateOrderStatus      = &i18n.Message{ID: "model.order_status_change.validate.status.app_error", Other: "invalid order status"}
+	msgValidateOrderStatusNote  = &i18n.Message{ID: "model.order_status_change.validate.note.app_error", Other: "note must be less than 1000 characters"}
+)
+
+// OrderStatusHistory is the single order status change
+type OrderStatusHistory struct {
+	ID         int64     `json:"id" db:"id"`
+	OrderID    int64     `json:"order_id" db:"order_id"`
+	FromStatus *string   `json:"from_status" db:"from_status"`
+	ToStatus   string    `json:"to_status" db:"to_status"`
+	Note       *string   `json:"note" db:"note"`
+	ChangedBy  *int64    `json:"changed_by" db:"changed_by"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+}
+
+// PreSave fills the defaults
+func (h *OrderStatusHistory) PreSave() {
+	h.CreatedAt = time.Now()
+}
+
+// OrderStatusChange is the admin request to move the order to the other status
+type OrderStatusChange struct {
+	Status string `json:"status"`
+	Note   string `json:"note"`
+}
+
+// OrderStatusChangeFromJSON decodes the input and returns the OrderStatusChange
+func OrderStatusChangeFromJSON(data io.Reader) (*OrderStatusChange, error) {
+	var sc *OrderStatusChange
+	err := json.NewDecoder(data).Decode(&sc)
+	return sc, err
+}
+
+// Validate validates the status change and returns an error if it doesn't pass criteria
+func (sc *OrderStatusChange) Validate() *AppErr {
+	var errs ValidationErrors
+	l := locale.GetUserLocalizer("en")
+
+	if _, ok := OrderStatusFromString(sc.Status); !ok {
+		errs.Add(Invalid("status", l, msgValidateOrderStatus))
+	}
+	if len(sc.Note) > 1000 {
+		errs.Add(Invalid("note", l, msgValidateOrderStatusNote))
+	}
+
+	if !errs.IsZero() {
+		return NewValidationError("OrderStatusChange", msgInvalidOrderStatusChange, "", errs)
+	}
+	return nil
+}
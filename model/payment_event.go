@@ -0,0 +1,34 @@
+package model
+
+import (
+	"time"
+
+	"github.com/jmoiron/sqlx/types"
+)
+
+// PaymentEvent is the received payment provider webhook event
+type PaymentEvent struct {
+	ID           int64          `json:"id" db:"id"`
+	Provider     string         `json:"provider" db:"provider"`
+	EventID      string         `json:"event_id" db:"event_id"`
+	Type         string         `json:"type" db:"type"`
+	ProviderType string         `json:"provider_type" db:"provider_type"`
+	ChargeID     *string        `json:"charge_id" db:"charge_id"`
+	Payload      types.JSONText `json:"payload" db:"payload"`
+	Attempts     int            `json:"attempts" db:"attempts"`
+	Error        *string        `json:"error" db:"error"`
+	ProcessingAt *time.Time     `json:"processing_at" db:"processing_at"`
+	ProcessedAt  *time.Time     `json:"processed_at" db:"processed_at"`
+	CreatedAt    time.Time      `json:"created_at" db:"created_at"`
+}
+
+// PreSave fills the defaults
+func (e *PaymentEvent) PreSave() {
+	e.CreatedAt = time.Now()
+	e.Attempts = 1
+}
+
+// IsProcessed returns true if the event has already been applied to the order
+func (e *PaymentEvent) IsProcessed() bool {
+	return e.ProcessedAt != nil
+}
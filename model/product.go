@@ -54,7 +54,8 @@ type Product struct {
 	ImageURL       string          `json:"image_url" db:"image_url" schema:"-"`
 	ImagePublicID  string          `json:"image_public_id" db:"image_public_id" schema:"-"`
 	Description    string          `json:"description" db:"description" schema:"description"`
-	InStock        bool            `json:"in_stock" db:"in_stock" schema:"in_stock"`
+	InStock        bool            `json:"in_stock" db:"in_stock" schema:"-"`
+	StockQuantity  int             `json:"stock_quantity" db:"stock_quantity" schema:"stock_quantity"`
 	SKU            string          `json:"sku" db:"sku" schema:"-"`
 	IsFeatured     bool            `json:"is_featured" db:"is_featured" schema:"is_featured"`
 	CreatedAt      time.Time       `json:"created_at" db:"created_at" schema:"-"`
@@ -63,8 +64,12 @@ type Product struct {
 	PropertiesText *string         `json:"-" schema:"properties"`
 
 	*ProductPricing `schema:"-"`
-	Brand           *Brand    `json:"brand" schema:"-"`
-	Category        *Category `json:"category" schema:"-"`
+	Brand           *Brand            `json:"brand" schema:"-"`
+	Category        *Category         `json:"category" schema:"-"`
+	Options         []*ProductOption  `json:"options,omitempty" db:"-" schema:"-"`
+	Variants        []*ProductVariant `json:"variants,omitempty" db:"-" schema:"-"`
+	Highlight       *ProductHighlight `json:"highlight,omitempty" db:"-" schema:"-"`
+	Bundle          *BundleInfo       `json:"bundle,omitempty" db:"-" schema:"-"`
 }
 
 // ProductPatch is the product patch model
@@ -76,7 +81,6 @@ type ProductPatch struct {
 	ImageURL       *string         `json:"image_url,omitempty" schema:"-"`
 	ImagePublicID  *string         `json:"image_public_id,omitempty" schema:"-"`
 	Description    *string         `json:"description,omitempty" schema:"description"`
-	InStock        *bool           `json:"in_stock,omitempty" schema:"in_stock"`
 	IsFeatured     *bool           `json:"is_featured,omitempty" schema:"is_featured"`
 	Properties     *types.JSONText `json:"properties,omitempty" schema:"-"`
 	PropertiesText *string         `json:"-" schema:"properties"`
@@ -102,9 +106,6 @@ func (p *Product) Patch(patch *ProductPatch) {
 	if patch.Description != nil {
 		p.Description = *patch.Description
 	}
-	if patch.InStock != nil {
-		p.InStock = *patch.InStock
-	}
 	if patch.IsFeatured != nil {
 		p.IsFeatured = *patch.IsFeatured
 	}
@@ -198,6 +199,9 @@ func (p *Product) Validate(fh *multipart.FileHeader) *AppErr {
 	if p.UpdatedAt.IsZero() {
 		errs.Add(Invalid("updated_at", l, msgValidateProductUpAt))
 	}
+	if p.StockQuantity < 0 {
+		errs.Add(Invalid("stock_quantity", l, msgValidateProductStockQuantity))
+	}
 	if fh == nil {
 		errs.Add(Invalid("image", l, msgValidateProductImage))
 	}
@@ -253,6 +257,7 @@ func (patch *ProductPatch) Validate(fh *multipart.FileHeader) *AppErr {
 type ProductPricing struct {
 	PriceID       int64     `json:"price_id" db:"price_id"`
 	ProductID     int64     `json:"product_id" db:"product_id"`
+	VariantID     *int64    `json:"variant_id,omitempty" db:"variant_id"`
 	Price         int       `json:"price" db:"price"`
 	OriginalPrice int       `json:"original_price" db:"original_price"`
 	SaleStarts    time.Time `json:"sale_starts" db:"sale_starts"`
@@ -0,0 +1,239 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/dankobgd/ecommerce-shop/utils/locale"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+// error msgs
+var (
+	msgInvalidProductBundle          = &i18n.Message{ID: "model.product_bundle.validate.app_error", Other: "invalid product bundle"}
+	msgValidateBundlePricingType     = &i18n.Message{ID: "model.product_bundle.validate.pricing_type.app_error", Other: "invalid pricing type, must be fixed or percentage"}
+	msgValidateBundleAmount          = &i18n.Message{ID: "model.product_bundle.validate.amount.app_error", Other: "invalid bundle amount"}
+	msgValidateBundlePercentage      = &i18n.Message{ID: "model.product_bundle.validate.amount.percentage.app_error", Other: "percentage must be between 0 and 100"}
+	msgValidateBundleItems           = &i18n.Message{ID: "model.product_bundle.validate.items.app_error", Other: "bundle must have between 1 and 20 components"}
+	msgValidateBundleVariantID       = &i18n.Message{ID: "model.product_bundle.validate.variant_id.app_error", Other: "invalid component variant id"}
+	msgValidateBundleDuplicate       = &i18n.Message{ID: "model.product_bundle.validate.variant_id.duplicate.app_error", Other: "duplicate component variant"}
+	msgValidateBundleQuantity        = &i18n.Message{ID: "model.product_bundle.validate.quantity.app_error", Other: "component quantity must be greater than 0"}
+	msgValidateBundleComponentSelf   = &i18n.Message{ID: "model.product_bundle.validate.component.self.app_error", Other: "bundle can't contain its own variant"}
+	msgValidateBundleComponentBundle = &i18n.Message{ID: "model.product_bundle.validate.component.bundle.app_error", Other: "bundle can't contain another bundle"}
+	msgValidateBundleIsComponent     = &i18n.Message{ID: "model.product_bundle.validate.product.component.app_error", Other: "product is a component of another bundle"}
+)
+
+// BundlePricingType is the way the bundle price is calculated
+type BundlePricingType string
+
+// bundle pricing types
+const (
+	BundlePricingFixed      BundlePricingType = "fixed"
+	BundlePricingPercentage BundlePricingType = "percentage"
+)
+
+// ProductBundleMaxItems is the max number of the components of one bundle
+const ProductBundleMaxItems = 20
+
+// ProductBundle is the product sold as the set of the component variants
+// the fixed amount is the bundle price, the percentage amount is the discount off the components price
+type ProductBundle struct {
+	ProductID   int64             `json:"product_id" db:"product_id"`
+	PricingType BundlePricingType `json:"pricing_type" db:"pricing_type"`
+	Amount      int               `json:"amount" db:"amount"`
+	Items       []*BundleItem     `json:"items" db:"-"`
+}
+
+// BundleItem is the component variant of the bundle with its quantity in one bundle
+type BundleItem struct {
+	BundleID  int64           `json:"bundle_id" db:"bundle_id"`
+	VariantID int64           `json:"variant_id" db:"variant_id"`
+	Quantity  int             `json:"quantity" db:"quantity"`
+	Position  int             `json:"position" db:"position"`
+	Variant   *ProductVariant `json:"variant,omitempty" db:"-"`
+}
+
+// BundleInfo is the bundle with the prices and the availability derived from its components
+type BundleInfo struct {
+	*ProductBundle
+	Price           int `json:"price"`
+	ComponentsPrice int `json:"components_price"`
+	StockQuantity   int `json:"stock_quantity"`
+}
+
+// ProductBundleFromJSON decodes the input and returns the ProductBundle
+func ProductBundleFromJSON(data io.Reader) (*ProductBundle, error) {
+	var b *ProductBundle
+	err := json.NewDecoder(data).Decode(&b)
+	return b, err
+}
+
+// PreSave sets the bundle of the components and their positions in the given order
+func (b *ProductBundle) PreSave() {
+	for i, item := range b.Items {
+		item.BundleID = b.ProductID
+		item.Position = i
+	}
+}
+
+// Validate validates the bundle and returns an error if it doesn't pass criteria
+func (b *ProductBundle) Validate() *AppErr {
+	var errs ValidationErrors
+	l := locale.GetUserLocalizer("en")
+
+	switch b.PricingType {
+	case BundlePricingFixed:
+		if b.Amount < 0 {
+			errs.Add(Invalid("amount", l, msgValidateBundleAmount))
+		}
+	case BundlePricingPercentage:
+		if b.Amount < 0 || b.Amount > 100 {
+			errs.Add(Invalid("amount", l, msgValidateBundlePercentage))
+		}
+	default:
+		errs.Add(Invalid("pricing_type", l, msgValidateBundlePricingType))
+	}
+
+	if len(b.Items) == 0 || len(b.Items) > ProductBundleMaxItems {
+		errs.Add(Invalid("items", l, msgValidateBundleItems))
+	}
+
+	seen := make(map[int64]bool, len(b.Items))
+	for i, item := range b.Items {
+		if item == nil || item.VariantID <= 0 {
+			errs.Add(Invalid(fmt.Sprintf("items[%d].variant_id", i), l, msgValidateBundleVariantID))
+			continue
+		}
+		if seen[item.VariantID] {
+			errs.Add(Invalid(fmt.Sprintf("items[%d].variant_id", i), l, msgValidateBundleDuplicate))
+		}
+		seen[item.VariantID] = true
+		if item.Quantity <= 0 {
+			errs.Add(Invalid(fmt.Sprintf("items[%d].quantity", i), l, msgValidateBundleQuantity))
+		}
+	}
+
+	if !errs.IsZero() {
+		return NewValidationError("ProductBundle", msgInvalidProductBundle, "", errs)
+	}
+	return nil
+}
+
+// ValidateComponents validates the loaded component variants, the components of the other bundles are given by their product id
+// the component can't be the bundle product itself or another bundle, and the bundle product can't be the component of another bundle
+func (b *ProductBundle) ValidateComponents(bundles map[int64]bool, isComponent bool) *AppErr {
+	var errs ValidationErrors
+	l := locale.GetUserLocalizer("en")
+
+	if isComponent {
+		errs.Add(Invalid("product_id", l, msgValidateBundleIsComponent))
+	}
+	for i, item := range b.Items {
+		field := fmt.Sprintf("items[%d].variant_id", i)
+		switch {
+		case item.Variant == nil:
+			errs.Add(Invalid(field, l, msgValidateBundleVariantID))
+		case item.Variant.ProductID == b.ProductID:
+			errs.Add(Invalid(field, l, msgValidateBundleComponentSelf))
+		case bundles[item.Variant.ProductID]:
+			errs.Add(Invalid(field, l, msgValidateBundleComponentBundle))
+		}
+	}
+
+	if !errs.IsZero() {
+		return NewValidationError("ProductBundle", msgInvalidProductBundle, "", errs)
+	}
+	return nil
+}
+
+// ComponentsPrice is the price of one bundle when its components are bought separately
+func (b *ProductBundle) ComponentsPrice() int {
+	total := 0
+	for _, item := range b.Items {
+		if item.Variant != nil {
+			total += item.Variant.Price * item.Quantity
+		}
+	}
+	return total
+}
+
+// Price is the price of one bundle
+func (b *ProductBundle) Price() int {
+	if b.PricingType == BundlePricingFixed {
+		return b.Amount
+	}
+	cp := b.ComponentsPrice()
+	return cp - int(math.Round(float64(cp)*float64(b.Amount)/100))
+}
+
+// Stock is the number of the bundles that can be assembled from the component stock
+func (b *ProductBundle) Stock() int {
+	if len(b.Items) == 0 {
+		return 0
+	}
+	stock := math.MaxInt32
+	for _, item := range b.Items {
+		if item.Variant == nil {
+			return 0
+		}
+		if n := item.Variant.StockQuantity / item.Quantity; n < stock {
+			stock = n
+		}
+	}
+	return stock
+}
+
+// Info returns the bundle with its derived prices and availability
+func (b *ProductBundle) Info() *BundleInfo {
+	return &BundleInfo{ProductBundle: b, Price: b.Price(), ComponentsPrice: b.ComponentsPrice(), StockQuantity: b.Stock()}
+}
+
+// SplitPrice splits the bundle price over the components proportionally to their prices and returns the unit price of every component
+// the rounding leftover goes to the first component with the single quantity, so the component lines add up to the bundle price when possible
+func (b *ProductBundle) SplitPrice(price int) []int {
+	units := make([]int, len(b.Items))
+	cp := b.ComponentsPrice()
+
+	allocated := 0
+	for i, item := range b.Items {
+		if cp > 0 && item.Variant != nil {
+			units[i] = int(int64(price) * int64(item.Variant.Price) / int64(cp))
+		}
+		allocated += units[i] * item.Quantity
+	}
+
+	if leftover := price - allocated; leftover > 0 {
+		for i, item := range b.Items {
+			if item.Quantity == 1 {
+				units[i] += leftover
+				break
+			}
+		}
+	}
+	return units
+}
+
+// OrderDetails expands the ordered bundles into the order lines of the components
+func (b *ProductBundle) OrderDetails(quantity int) []*OrderDetail {
+	price := b.Price()
+	units := b.SplitPrice(price)
+
+	details := make([]*OrderDetail, 0, len(b.Items))
+	for i, item := range b.Items {
+		bundleID, bundleQuantity, bundlePrice := b.ProductID, quantity, price
+		details = append(details, &OrderDetail{
+			ProductID:      item.Variant.ProductID,
+			VariantID:      item.Variant.ID,
+			Quantity:       item.Quantity * quantity,
+			HistoryPrice:   units[i],
+			HistorySKU:     item.Variant.SKU,
+			HistoryOptions: item.Variant.Options,
+			BundleID:       &bundleID,
+			BundleQuantity: &bundleQuantity,
+			BundlePrice:    &bundlePrice,
+		})
+	}
+	return details
+}
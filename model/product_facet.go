@@ -0,0 +1,82 @@
+package model
+
+// PriceBucketEdges are the lower bounds of the price facet buckets in cents, the last bucket is open
+var PriceBucketEdges = []int{0, 2500, 5000, 10000, 20000}
+
+// FacetValue is the facet value with the number of the matching products
+type FacetValue struct {
+	Value string `json:"value"`
+	Label string `json:"label"`
+	Count int    `json:"count"`
+}
+
+// PriceBucket is the price range with the number of the matching products, the nil max is open
+type PriceBucket struct {
+	Min   int  `json:"min"`
+	Max   *int `json:"max"`
+	Count int  `json:"count"`
+}
+
+// PropertyFacet is the facet of the filterable category attribute
+// number attributes have the range of the values instead of the values
+type PropertyFacet struct {
+	Category  string        `json:"category"`
+	Attribute string        `json:"attribute"`
+	Label     string        `json:"label"`
+	Type      AttributeType `json:"type"`
+	Values    []*FacetValue `json:"values,omitempty"`
+	Min       *float64      `json:"min,omitempty"`
+	Max       *float64      `json:"max,omitempty"`
+	Count     int           `json:"count"`
+}
+
+// ProductFacets are the facets of the product listing
+// every facet is counted with all listing filters except its own, so it shows what selecting the other values gives
+type ProductFacets struct {
+	Brands     []*FacetValue    `json:"brands"`
+	Tags       []*FacetValue    `json:"tags"`
+	Categories []*FacetValue    `json:"categories"`
+	Prices     []*PriceBucket   `json:"prices"`
+	Properties []*PropertyFacet `json:"properties"`
+}
+
+// FacetFilter returns the filter tree that the facet of the field is counted with, without the filter of the field
+// the category facet also drops the property filters, since they belong to the category filter
+func FacetFilter(filter FilterNode, field FilterField) FilterNode {
+	if field == FilterFieldCategory {
+		return WithoutFilter(filter, func(n FilterNode) bool {
+			_, ok := n.(FilterOr)
+			return ok
+		})
+	}
+	return WithoutFilter(filter, func(n FilterNode) bool {
+		f, ok := n.(*FilterIn)
+		if ok {
+			return f.Field == field
+		}
+		r, ok := n.(*FilterRange)
+		return ok && r.Field == field
+	})
+}
+
+// PropertyFacetFilter returns the filter tree that the property facet is counted with, without the filter of the attribute
+// the facet counts only the products of its category
+func PropertyFacetFilter(filter FilterNode, category string, attr *CategoryAttribute) FilterNode {
+	without := WithoutFilter(filter, func(n FilterNode) bool {
+		f, ok := n.(*PropertyFilter)
+		return ok && f.Category == category && f.Attribute.Name == attr.Name
+	})
+	return FilterAnd{without, &FilterIn{Field: FilterFieldCategory, Values: []string{category}}}
+}
+
+// SetLabels sets the labels of the enum property values from the attribute choices
+func (f *PropertyFacet) SetLabels(attr *CategoryAttribute) {
+	for _, v := range f.Values {
+		v.Label = v.Value
+		for _, c := range attr.Choices {
+			if c.Name == v.Value && c.Label != "" {
+				v.Label = c.Label
+			}
+		}
+	}
+}
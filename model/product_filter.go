@@ -0,0 +1,310 @@
+package model
+
+import (
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dankobgd/ecommerce-shop/utils/locale"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+// error msgs
+var (
+	msgInvalidProductFilter     = &i18n.Message{ID: "model.product_filter.validate.app_error", Other: "invalid product filters"}
+	msgValidateFilterUnknown    = &i18n.Message{ID: "model.product_filter.validate.unknown.app_error", Other: "unknown filter"}
+	msgValidateFilterCategory   = &i18n.Message{ID: "model.product_filter.validate.category.app_error", Other: "category does not exist"}
+	msgValidateFilterValue      = &i18n.Message{ID: "model.product_filter.validate.value.app_error", Other: "invalid filter value"}
+	msgValidateFilterPriceRange = &i18n.Message{ID: "model.product_filter.validate.price_range.app_error", Other: "price_min must not be greater than price_max"}
+	msgValidateFilterProperty   = &i18n.Message{ID: "model.product_filter.validate.property.app_error", Other: "property filter requires its category in the category filter"}
+)
+
+// FilterField is the product field that the filter applies to
+type FilterField string
+
+// filter fields
+const (
+	FilterFieldBrand    FilterField = "brand"
+	FilterFieldTag      FilterField = "tag"
+	FilterFieldCategory FilterField = "category"
+	FilterFieldPrice    FilterField = "price"
+)
+
+// ProductFilterParams are the query params of the product listings that are not filters
+var ProductFilterParams = []string{"page", "per_page", "sort", "facets", "q"}
+
+// FilterNode is the node of the product filter tree
+type FilterNode interface {
+	filterNode()
+}
+
+// FilterAnd matches the products that match all nodes
+type FilterAnd []FilterNode
+
+// FilterOr matches the products that match any of the nodes
+type FilterOr []FilterNode
+
+// FilterIn matches the products whose field is one of the values
+type FilterIn struct {
+	Field  FilterField
+	Values []string
+}
+
+// FilterRange matches the products whose field is within the range, the nil bound is open
+type FilterRange struct {
+	Field FilterField
+	Min   *int
+	Max   *int
+}
+
+// PropertyFilter matches the products whose property is one of the values, or within the range for the number attributes
+type PropertyFilter struct {
+	Category  string
+	Attribute *CategoryAttribute
+	Values    []string
+	Min       *float64
+	Max       *float64
+}
+
+// FilterSearch matches the products found by the full text search query
+type FilterSearch struct {
+	Query *SearchQuery
+}
+
+func (FilterAnd) filterNode()       {}
+func (FilterOr) filterNode()        {}
+func (*FilterIn) filterNode()       {}
+func (*FilterRange) filterNode()    {}
+func (*PropertyFilter) filterNode() {}
+func (*FilterSearch) filterNode()   {}
+
+// WithoutFilter returns the copy of the filter tree without the nodes that match, the tree itself is not changed
+func WithoutFilter(node FilterNode, match func(FilterNode) bool) FilterNode {
+	switch n := node.(type) {
+	case FilterAnd:
+		out := FilterAnd{}
+		for _, child := range n {
+			if !match(child) {
+				out = append(out, WithoutFilter(child, match))
+			}
+		}
+		return out
+	case FilterOr:
+		out := FilterOr{}
+		for _, child := range n {
+			if !match(child) {
+				out = append(out, WithoutFilter(child, match))
+			}
+		}
+		return out
+	default:
+		return node
+	}
+}
+
+// ParseProductFilter parses the listing query into the filter tree
+// brand, tag and price filters are ANDed, the categories are ORed, each with its own property filters
+// property filters are the {category}_{attribute} keys declared as filterable by the category schema,
+// number attributes also take the {category}_{attribute}_min and {category}_{attribute}_max range keys
+// categories are the ones the category filter refers to, unknown keys and invalid values are validation errors
+func ParseProductFilter(query url.Values, categories []*Category) (FilterNode, *AppErr) {
+	var errs ValidationErrors
+	l := locale.GetUserLocalizer("en")
+
+	bySlug := make(map[string]*Category, len(categories))
+	for _, c := range categories {
+		bySlug[c.Slug] = c
+	}
+
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	root := FilterAnd{}
+	var priceMin, priceMax *int
+	props := make(map[string]FilterAnd)
+
+	for _, key := range keys {
+		values := nonEmptyValues(query[key])
+
+		switch key {
+		case "brand":
+			if len(values) > 0 {
+				root = append(root, &FilterIn{Field: FilterFieldBrand, Values: values})
+			}
+			continue
+		case "tag":
+			if len(values) > 0 {
+				root = append(root, &FilterIn{Field: FilterFieldTag, Values: values})
+			}
+			continue
+		case "category":
+			for _, slug := range values {
+				if bySlug[slug] == nil {
+					errs.Add(Invalid("category", l, msgValidateFilterCategory))
+				}
+			}
+			continue
+		case "price_min", "price_max":
+			n, err := singleInt(values)
+			if err != nil || n < 0 {
+				errs.Add(Invalid(key, l, msgValidateFilterValue))
+			} else if key == "price_min" {
+				priceMin = &n
+			} else {
+				priceMax = &n
+			}
+			continue
+		}
+		if isProductFilterParam(key) {
+			continue
+		}
+
+		slug, attr, bound := lookupPropertyFilter(categories, key)
+		if attr == nil {
+			errs.Add(Invalid(key, l, msgValidateFilterUnknown))
+			continue
+		}
+		if !containsString(query["category"], slug) {
+			errs.Add(Invalid(key, l, msgValidateFilterProperty))
+			continue
+		}
+		f, ok := parsePropertyFilter(attr, bound, values)
+		if !ok {
+			errs.Add(Invalid(key, l, msgValidateFilterValue))
+			continue
+		}
+		f.Category = slug
+		props[slug] = append(props[slug], f)
+	}
+
+	if priceMin != nil && priceMax != nil && *priceMin > *priceMax {
+		errs.Add(Invalid("price_min", l, msgValidateFilterPriceRange))
+	}
+	if !errs.IsZero() {
+		return nil, NewValidationError("ProductFilter", msgInvalidProductFilter, "", errs)
+	}
+
+	if priceMin != nil || priceMax != nil {
+		root = append(root, &FilterRange{Field: FilterFieldPrice, Min: priceMin, Max: priceMax})
+	}
+
+	if slugs := nonEmptyValues(query["category"]); len(slugs) > 0 {
+		either := FilterOr{}
+		for _, slug := range slugs {
+			node := FilterAnd{&FilterIn{Field: FilterFieldCategory, Values: []string{slug}}}
+			either = append(either, append(node, props[slug]...))
+		}
+		root = append(root, either)
+	}
+
+	return root, nil
+}
+
+// lookupPropertyFilter finds the filterable category attribute of the query key
+// it returns the category slug, the attribute and the range bound of the number attribute keys
+func lookupPropertyFilter(categories []*Category, key string) (string, *CategoryAttribute, string) {
+	for _, c := range categories {
+		if !strings.HasPrefix(key, c.Slug+"_") {
+			continue
+		}
+		schema := c.Schema().Filters()
+		name := strings.TrimPrefix(key, c.Slug+"_")
+		if attr := schema.Get(name); attr != nil {
+			return c.Slug, attr, ""
+		}
+		for _, bound := range []string{"min", "max"} {
+			if !strings.HasSuffix(name, "_"+bound) {
+				continue
+			}
+			if attr := schema.Get(strings.TrimSuffix(name, "_"+bound)); attr != nil && attr.Type == AttributeTypeNumber {
+				return c.Slug, attr, bound
+			}
+		}
+	}
+	return "", nil, ""
+}
+
+// parsePropertyFilter parses the query values of the attribute filter, it returns false if any value is invalid
+func parsePropertyFilter(attr *CategoryAttribute, bound string, values []string) (*PropertyFilter, bool) {
+	if len(values) == 0 {
+		return nil, false
+	}
+	f := &PropertyFilter{Attribute: attr}
+
+	switch attr.Type {
+	case AttributeTypeNumber:
+		if len(values) != 1 {
+			return nil, false
+		}
+		n, err := strconv.ParseFloat(values[0], 64)
+		if err != nil {
+			return nil, false
+		}
+		switch bound {
+		case "min":
+			f.Min = &n
+		case "max":
+			f.Max = &n
+		default:
+			f.Min, f.Max = &n, &n
+		}
+	case AttributeTypeBoolean:
+		for _, v := range values {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, false
+			}
+			f.Values = append(f.Values, strconv.FormatBool(b))
+		}
+	case AttributeTypeEnum:
+		for _, v := range values {
+			if !attr.HasChoice(v) {
+				return nil, false
+			}
+			f.Values = append(f.Values, v)
+		}
+	default:
+		f.Values = values
+	}
+
+	return f, true
+}
+
+// CategorySlugs returns the category slugs of the listing query
+func CategorySlugs(query url.Values) []string {
+	return nonEmptyValues(query["category"])
+}
+
+func isProductFilterParam(key string) bool {
+	return containsString(ProductFilterParams, key)
+}
+
+func singleInt(values []string) (int, error) {
+	if len(values) != 1 {
+		return 0, strconv.ErrSyntax
+	}
+	return strconv.Atoi(values[0])
+}
+
+func nonEmptyValues(values []string) []string {
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		if v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func containsString(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
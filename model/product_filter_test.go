@@ -0,0 +1,143 @@
+package model
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/jmoiron/sqlx/types"
+)
+
+func filterTestCategories() []*Category {
+	laptops := types.JSONText(`[
+		{"name": "ram", "label": "RAM", "type": "number", "filterable": true},
+		{"name": "color", "label": "Color", "type": "enum", "filterable": true, "choices": [{"name": "black", "label": "Black"}, {"name": "silver", "label": "Silver"}]},
+		{"name": "touch", "label": "Touch screen", "type": "boolean", "filterable": true},
+		{"name": "notes", "label": "Notes", "type": "text"}
+	]`)
+	return []*Category{
+		{ID: 1, Slug: "laptops", Properties: &laptops},
+		{ID: 2, Slug: "phones"},
+	}
+}
+
+func TestParseProductFilter(t *testing.T) {
+	categories := filterTestCategories()
+	schema := categories[0].Schema()
+	n := func(v float64) *float64 { return &v }
+
+	tests := []struct {
+		name  string
+		query string
+		want  FilterNode
+	}{
+		{
+			name:  "empty",
+			query: "",
+			want:  FilterAnd{},
+		},
+		{
+			name:  "listing params are not filters",
+			query: "page=2&per_page=10&sort=price_asc&facets=true&q=shoes",
+			want:  FilterAnd{},
+		},
+		{
+			name:  "brand, tag and price are ANDed",
+			query: "brand=nike&brand=adidas&brand=&tag=sale&price_min=100&price_max=500",
+			want: FilterAnd{
+				&FilterIn{Field: FilterFieldBrand, Values: []string{"nike", "adidas"}},
+				&FilterIn{Field: FilterFieldTag, Values: []string{"sale"}},
+				&FilterRange{Field: FilterFieldPrice, Min: NewInt(100), Max: NewInt(500)},
+			},
+		},
+		{
+			name:  "open price range",
+			query: "price_max=500",
+			want:  FilterAnd{&FilterRange{Field: FilterFieldPrice, Max: NewInt(500)}},
+		},
+		{
+			name:  "categories are ORed with their own property filters",
+			query: "category=laptops&category=phones&laptops_color=black&laptops_ram_min=8&laptops_touch=1",
+			want: FilterAnd{
+				FilterOr{
+					FilterAnd{
+						&FilterIn{Field: FilterFieldCategory, Values: []string{"laptops"}},
+						&PropertyFilter{Category: "laptops", Attribute: schema.Get("color"), Values: []string{"black"}},
+						&PropertyFilter{Category: "laptops", Attribute: schema.Get("ram"), Min: n(8)},
+						&PropertyFilter{Category: "laptops", Attribute: schema.Get("touch"), Values: []string{"true"}},
+					},
+					FilterAnd{
+						&FilterIn{Field: FilterFieldCategory, Values: []string{"phones"}},
+					},
+				},
+			},
+		},
+		{
+			name:  "exact number",
+			query: "category=laptops&laptops_ram=16",
+			want: FilterAnd{
+				FilterOr{
+					FilterAnd{
+						&FilterIn{Field: FilterFieldCategory, Values: []string{"laptops"}},
+						&PropertyFilter{Category: "laptops", Attribute: schema.Get("ram"), Min: n(16), Max: n(16)},
+					},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatalf("url.ParseQuery(%q) error: %v", tt.query, err)
+			}
+
+			got, appErr := ParseProductFilter(query, categories)
+			if appErr != nil {
+				t.Fatalf("ParseProductFilter(%q) error: %v", tt.query, appErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseProductFilter(%q) = %#v, want %#v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseProductFilterInvalid(t *testing.T) {
+	categories := filterTestCategories()
+
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"unknown key", "color=red"},
+		{"unknown category", "category=shoes"},
+		{"property without its category", "laptops_color=black"},
+		{"property of the other category", "category=phones&laptops_color=black"},
+		{"not filterable property", "category=laptops&laptops_notes=fast"},
+		{"unknown enum choice", "category=laptops&laptops_color=red"},
+		{"invalid boolean", "category=laptops&laptops_touch=maybe"},
+		{"invalid number", "category=laptops&laptops_ram=lots"},
+		{"multiple numbers", "category=laptops&laptops_ram=8&laptops_ram=16"},
+		{"range of the enum", "category=laptops&laptops_color_min=1"},
+		{"empty property value", "category=laptops&laptops_color="},
+		{"invalid price", "price_min=cheap"},
+		{"negative price", "price_min=-1"},
+		{"multiple prices", "price_max=10&price_max=20"},
+		{"inverted price range", "price_min=500&price_max=100"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatalf("url.ParseQuery(%q) error: %v", tt.query, err)
+			}
+
+			if got, appErr := ParseProductFilter(query, categories); appErr == nil {
+				t.Errorf("ParseProductFilter(%q) = %#v, want the error", tt.query, got)
+			}
+		})
+	}
+}
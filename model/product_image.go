@@ -21,6 +21,7 @@ var (
 type ProductImage struct {
 	ID        *int64     `json:"id" db:"id" schema:"-"`
 	ProductID *int64     `json:"product_id" db:"product_id" schema:"-"`
+	VariantID *int64     `json:"variant_id" db:"variant_id" schema:"variant_id"`
 	URL       *string    `json:"url" db:"url" schema:"-"`
 	PublicID  *string    `json:"public_id" db:"public_id" schema:"-"`
 	CreatedAt *time.Time `json:"created_at" db:"created_at" schema:"-"`
@@ -0,0 +1,20 @@
+package model
+
+// PurchasedOrderStatuses are the statuses of the orders whose products were bought,
+// the unpaid, cancelled and fully refunded orders don't count
+var PurchasedOrderStatuses = []string{
+	OrderStatusPaid.String(),
+	OrderStatusProcessing.String(),
+	OrderStatusShipped.String(),
+	OrderStatusDelivered.String(),
+	OrderStatusPartiallyRefunded.String(),
+}
+
+// RecommendationParams are the params of the co-purchase affinities computation
+// the score of the product pair is the cosine similarity of their orders: together / sqrt(orders of a * orders of b)
+// the pairs bought together in less than min support orders are left out, every product keeps its top n neighbours
+type RecommendationParams struct {
+	Days       int // the days of the order history
+	MinSupport int
+	TopN       int
+}
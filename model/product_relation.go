@@ -0,0 +1,96 @@
+package model
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dankobgd/ecommerce-shop/utils/locale"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+// error msgs
+var (
+	msgInvalidProductRelations     = &i18n.Message{ID: "model.product_relation.validate.app_error", Other: "invalid product relations"}
+	msgValidateProductRelationType = &i18n.Message{ID: "model.product_relation.validate.type.app_error", Other: "invalid relation type"}
+	msgValidateRelatedID           = &i18n.Message{ID: "model.product_relation.validate.related_id.app_error", Other: "invalid related product id"}
+	msgValidateRelatedSelf         = &i18n.Message{ID: "model.product_relation.validate.related_id.self.app_error", Other: "product can't be related to itself"}
+	msgValidateRelatedDuplicate    = &i18n.Message{ID: "model.product_relation.validate.related_id.duplicate.app_error", Other: "duplicate related product"}
+	msgValidateRelatedCount        = &i18n.Message{ID: "model.product_relation.validate.count.app_error", Other: "too many related products, max 50 per relation type"}
+)
+
+// ProductRelationType is the kind of the link between the products
+type ProductRelationType string
+
+// product relation types
+const (
+	ProductRelationRelated     ProductRelationType = "related"
+	ProductRelationUpsell      ProductRelationType = "upsell"
+	ProductRelationCrossSell   ProductRelationType = "cross_sell"
+	ProductRelationAccessory   ProductRelationType = "accessory"
+	ProductRelationReplacement ProductRelationType = "replacement"
+)
+
+// ProductRelationMaxCount is the max number of the related products of one relation type
+const ProductRelationMaxCount = 50
+
+// CartSuggestionTypes are the relation types that suggest the products to add to the cart
+var CartSuggestionTypes = []ProductRelationType{ProductRelationCrossSell, ProductRelationAccessory}
+
+// ProductRelation is the ordered link from the product to the related product
+type ProductRelation struct {
+	ProductID int64               `json:"product_id" db:"product_id"`
+	RelatedID int64               `json:"related_id" db:"related_id"`
+	Type      ProductRelationType `json:"type" db:"type"`
+	Position  int                 `json:"position" db:"position"`
+	CreatedAt time.Time           `json:"created_at" db:"created_at"`
+	Product   *Product            `json:"product,omitempty" db:"-"`
+}
+
+// IsValid returns true if the relation type is known
+func (t ProductRelationType) IsValid() bool {
+	switch t {
+	case ProductRelationRelated, ProductRelationUpsell, ProductRelationCrossSell, ProductRelationAccessory, ProductRelationReplacement:
+		return true
+	}
+	return false
+}
+
+// ParseProductRelationType returns the relation type of the url param
+func ParseProductRelationType(value string) (ProductRelationType, *AppErr) {
+	t := ProductRelationType(value)
+	if !t.IsValid() {
+		var errs ValidationErrors
+		errs.Add(Invalid("type", locale.GetUserLocalizer("en"), msgValidateProductRelationType))
+		return "", NewValidationError("ProductRelation", msgInvalidProductRelations, "", errs)
+	}
+	return t, nil
+}
+
+// ValidateRelatedIDs validates the ordered related products that replace the relations of the product
+func ValidateRelatedIDs(pid int64, ids []int) *AppErr {
+	var errs ValidationErrors
+	l := locale.GetUserLocalizer("en")
+
+	if len(ids) > ProductRelationMaxCount {
+		errs.Add(Invalid("related_ids", l, msgValidateRelatedCount))
+	}
+
+	seen := make(map[int]bool, len(ids))
+	for i, id := range ids {
+		field := fmt.Sprintf("related_ids[%d]", i)
+		switch {
+		case id <= 0:
+			errs.Add(Invalid(field, l, msgValidateRelatedID))
+		case int64(id) == pid:
+			errs.Add(Invalid(field, l, msgValidateRelatedSelf))
+		case seen[id]:
+			errs.Add(Invalid(field, l, msgValidateRelatedDuplicate))
+		}
+		seen[id] = true
+	}
+
+	if !errs.IsZero() {
+		return NewValidationError("ProductRelation", msgInvalidProductRelations, "", errs)
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package model
+
+import (
+	"github.com/dankobgd/ecommerce-shop/utils/locale"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+// error msgs
+var (
+	msgInvalidProductSort  = &i18n.Message{ID: "model.product_sort.validate.app_error", Other: "invalid product sort"}
+	msgValidateProductSort = &i18n.Message{ID: "model.product_sort.validate.sort.app_error", Other: "sort must be one of price_asc, price_desc, newest, best_selling, top_rated or discount"}
+)
+
+// ProductSort is the sort order of the product listings
+type ProductSort string
+
+// product sort orders, the empty sort is the default order of the listing
+const (
+	ProductSortDefault     ProductSort = ""
+	ProductSortPriceAsc    ProductSort = "price_asc"
+	ProductSortPriceDesc   ProductSort = "price_desc"
+	ProductSortNewest      ProductSort = "newest"
+	ProductSortBestSelling ProductSort = "best_selling"
+	ProductSortTopRated    ProductSort = "top_rated"
+	ProductSortDiscount    ProductSort = "discount"
+)
+
+// ParseProductSort parses the sort query param and returns an error if it is not the supported order
+func ParseProductSort(value string) (ProductSort, *AppErr) {
+	switch s := ProductSort(value); s {
+	case ProductSortDefault, ProductSortPriceAsc, ProductSortPriceDesc, ProductSortNewest, ProductSortBestSelling, ProductSortTopRated, ProductSortDiscount:
+		return s, nil
+	}
+
+	var errs ValidationErrors
+	errs.Add(Invalid("sort", locale.GetUserLocalizer("en"), msgValidateProductSort))
+	return ProductSortDefault, NewValidationError("ProductSort", msgInvalidProductSort, "", errs)
+}
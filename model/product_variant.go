@@ -1,55 +1,283 @@
 package model
 
-// ProductProperties contains the variant props
-type ProductProperties struct {
-	BackpacksColor    []string `json:"backpacks_color"`
-	BackpacksSize     []string `json:"backpacks_size"`
-	BackpacksTheme    []string `json:"backpacks_theme"`
-	BagsColor         []string `json:"bags_color"`
-	BagsSize          []string `json:"bags_size"`
-	BagsTheme         []string `json:"bags_theme"`
-	BallsColor        []string `json:"balls_color"`
-	BallsType         []string `json:"balls_type"`
-	BootsColor        []string `json:"boots_color"`
-	BootsSize         []string `json:"boots_size"`
-	BootsTheme        []string `json:"boots_theme"`
-	ByciclesColor     []string `json:"bycicles_color"`
-	ByciclesGears     []string `json:"bycicles_gears"`
-	ByciclesSize      []string `json:"bycicles_size"`
-	HatsColor         []string `json:"hats_color"`
-	HatsTheme         []string `json:"hats_theme"`
-	HelmetsColor      []string `json:"helmets_color"`
-	HelmetsType       []string `json:"helmets_type"`
-	JacketsColor      []string `json:"jackets_color"`
-	JacketsSize       []string `json:"jackets_size"`
-	JacketsTheme      []string `json:"jackets_theme"`
-	JeansColor        []string `json:"jeans_color"`
-	JeansSize         []string `json:"jeans_size"`
-	JeansTheme        []string `json:"jeans_theme"`
-	RacketsSize       []string `json:"rackets_size"`
-	RacketsType       []string `json:"rackets_type"`
-	RollerbladesColor []string `json:"rollerblades_color"`
-	ShirtsColor       []string `json:"shirts_color"`
-	ShirtsSize        []string `json:"shirts_size"`
-	ShirtsTheme       []string `json:"shirts_theme"`
-	ShoesColor        []string `json:"shoes_color"`
-	ShoesLaceSize     []string `json:"shoes_lace_size"`
-	ShoesSize         []string `json:"shoes_size"`
-	ShoesTheme        []string `json:"shoes_theme"`
-	ShortsColor       []string `json:"shorts_color"`
-	ShortsSize        []string `json:"shorts_size"`
-	ShortsTheme       []string `json:"shorts_theme"`
-	SkateboardsColor  []string `json:"skateboards_color"`
-	SneakersColor     []string `json:"sneakers_color"`
-	SneakersLaceSize  []string `json:"sneakers_lace_size"`
-	SneakersSize      []string `json:"sneakers_size"`
-	SneakersTheme     []string `json:"sneakers_theme"`
-	SuitcasesColor    []string `json:"suitcases_color"`
-	SuitcasesSize     []string `json:"suitcases_size"`
-	SweatpantsColor   []string `json:"sweatpants_color"`
-	SweatpantsSize    []string `json:"sweatpants_size"`
-	SweatpantsTheme   []string `json:"sweatpants_theme"`
-	TshirtsColor      []string `json:"tshirts_color"`
-	TshirtsSize       []string `json:"tshirts_size"`
-	TshirtsTheme      []string `json:"tshirts_theme"`
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dankobgd/ecommerce-shop/utils/locale"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+// error msgs
+var (
+	msgInvalidProductOption      = &i18n.Message{ID: "model.product_option.validate.app_error", Other: "invalid product option data"}
+	msgValidateOptionName        = &i18n.Message{ID: "model.product_option.validate.name.app_error", Other: "option name must be between 1 and 64 characters"}
+	msgValidateOptionNameUnique  = &i18n.Message{ID: "model.product_option.validate.name_unique.app_error", Other: "option names must be unique"}
+	msgValidateOptionValues      = &i18n.Message{ID: "model.product_option.validate.values.app_error", Other: "option values must not be empty or duplicated"}
+	msgInvalidProductVariant     = &i18n.Message{ID: "model.product_variant.validate.app_error", Other: "invalid product variant data"}
+	msgValidateVariantSKU        = &i18n.Message{ID: "model.product_variant.validate.sku.app_error", Other: "invalid variant sku"}
+	msgValidateVariantPrice      = &i18n.Message{ID: "model.product_variant.validate.price.app_error", Other: "invalid variant price"}
+	msgValidateVariantStock      = &i18n.Message{ID: "model.product_variant.validate.stock_quantity.app_error", Other: "stock quantity must not be negative"}
+	msgValidateVariantOptions    = &i18n.Message{ID: "model.product_variant.validate.options.app_error", Other: "variant must have a value for every product option"}
+	msgValidateVariantOptionVal  = &i18n.Message{ID: "model.product_variant.validate.option_value.app_error", Other: "variant option value is not allowed by the product option"}
+	msgValidateVariantProductID  = &i18n.Message{ID: "model.product_variant.validate.product_id.app_error", Other: "invalid variant product id"}
+	msgValidateVariantCreatedAt  = &i18n.Message{ID: "model.product_variant.validate.created_at.app_error", Other: "invalid created_at timestamp"}
+	msgValidateVariantUpdatedAt  = &i18n.Message{ID: "model.product_variant.validate.updated_at.app_error", Other: "invalid updated_at timestamp"}
+	msgValidateVariantNotInOrder = &i18n.Message{ID: "model.order_request_data.validate.variant.app_error", Other: "variant does not belong to the product"}
+)
+
+// OptionValues is the list of the allowed option values, stored as json
+type OptionValues []string
+
+// Value implements the driver.Valuer interface
+func (v OptionValues) Value() (driver.Value, error) {
+	if v == nil {
+		return "[]", nil
+	}
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+// Scan implements the sql.Scanner interface
+func (v *OptionValues) Scan(src interface{}) error {
+	return scanJSON(src, v)
+}
+
+// VariantOptions maps the option name to the value of the variant (color: red, size: M), stored as json
+type VariantOptions map[string]string
+
+// Value implements the driver.Valuer interface
+func (o VariantOptions) Value() (driver.Value, error) {
+	if o == nil {
+		return "{}", nil
+	}
+	b, err := json.Marshal(o)
+	return string(b), err
+}
+
+// Scan implements the sql.Scanner interface
+func (o *VariantOptions) Scan(src interface{}) error {
+	return scanJSON(src, o)
+}
+
+// String returns the human readable options, sorted by the option name
+func (o VariantOptions) String() string {
+	keys := make([]string, 0, len(o))
+	for k := range o {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, k+": "+o[k])
+	}
+	return strings.Join(parts, ", ")
+}
+
+func scanJSON(src interface{}, dst interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return json.Unmarshal(v, dst)
+	case string:
+		return json.Unmarshal([]byte(v), dst)
+	}
+	return errors.New("unsupported json column type")
+}
+
+// ProductOption is the product option axis (color, size...) with its allowed values
+// empty values allow any value
+type ProductOption struct {
+	ID        int64        `json:"id" db:"id"`
+	ProductID int64        `json:"product_id" db:"product_id"`
+	Name      string       `json:"name" db:"name"`
+	Values    OptionValues `json:"values" db:"values"`
+	Position  int          `json:"position" db:"position"`
+}
+
+// ProductOptionsFromJSON decodes the input and returns the ProductOption list
+func ProductOptionsFromJSON(data io.Reader) ([]*ProductOption, error) {
+	var opts []*ProductOption
+	err := json.NewDecoder(data).Decode(&opts)
+	return opts, err
+}
+
+// ValidateProductOptions validates the product option axes and returns an error if they don't pass criteria
+func ValidateProductOptions(opts []*ProductOption) *AppErr {
+	var errs ValidationErrors
+	l := locale.GetUserLocalizer("en")
+
+	names := make(map[string]bool, len(opts))
+	for _, opt := range opts {
+		if opt == nil || opt.Name == "" || len(opt.Name) > 64 {
+			errs.Add(Invalid("name", l, msgValidateOptionName))
+			continue
+		}
+		if names[opt.Name] {
+			errs.Add(Invalid("name", l, msgValidateOptionNameUnique))
+		}
+		names[opt.Name] = true
+
+		values := make(map[string]bool, len(opt.Values))
+		for _, v := range opt.Values {
+			if v == "" || values[v] {
+				errs.Add(Invalid("values", l, msgValidateOptionValues))
+				break
+			}
+			values[v] = true
+		}
+	}
+
+	if !errs.IsZero() {
+		return NewValidationError("ProductOption", msgInvalidProductOption, "", errs)
+	}
+	return nil
+}
+
+// ProductVariant is the sellable version of the product with its own sku, price, stock and images
+// the variant without its own pricing is sold for the product price
+type ProductVariant struct {
+	ID            int64           `json:"id" db:"id"`
+	ProductID     int64           `json:"product_id" db:"product_id"`
+	SKU           string          `json:"sku" db:"sku"`
+	Options       VariantOptions  `json:"options" db:"options"`
+	StockQuantity int             `json:"stock_quantity" db:"stock_quantity"`
+	InStock       bool            `json:"in_stock" db:"in_stock"`
+	IsDefault     bool            `json:"is_default" db:"is_default"`
+	Price         int             `json:"price" db:"price"`
+	OriginalPrice int             `json:"original_price" db:"original_price"`
+	CreatedAt     time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at" db:"updated_at"`
+	Images        []*ProductImage `json:"images" db:"-"`
+}
+
+// ProductVariantPatch is the product variant patch model
+type ProductVariantPatch struct {
+	SKU     *string        `json:"sku"`
+	Options VariantOptions `json:"options"`
+}
+
+// ProductVariantFromJSON decodes the input and returns the ProductVariant
+func ProductVariantFromJSON(data io.Reader) (*ProductVariant, error) {
+	var v *ProductVariant
+	err := json.NewDecoder(data).Decode(&v)
+	return v, err
+}
+
+// ProductVariantPatchFromJSON decodes the input and returns the ProductVariantPatch
+func ProductVariantPatchFromJSON(data io.Reader) (*ProductVariantPatch, error) {
+	var p *ProductVariantPatch
+	err := json.NewDecoder(data).Decode(&p)
+	return p, err
+}
+
+// Patch patches the product variant fields that are provided
+func (v *ProductVariant) Patch(patch *ProductVariantPatch) {
+	if patch.SKU != nil {
+		v.SKU = *patch.SKU
+	}
+	if patch.Options != nil {
+		v.Options = patch.Options
+	}
+}
+
+// PreSave will fill timestamps and other defaults
+func (v *ProductVariant) PreSave() {
+	v.CreatedAt = time.Now()
+	v.UpdatedAt = v.CreatedAt
+	if v.Options == nil {
+		v.Options = VariantOptions{}
+	}
+	if v.OriginalPrice == 0 {
+		v.OriginalPrice = v.Price
+	}
+}
+
+// PreUpdate sets the update timestamp
+func (v *ProductVariant) PreUpdate() {
+	v.UpdatedAt = time.Now()
+}
+
+// Validate validates the product variant against the product option axes and returns an error if it doesn't pass criteria
+func (v *ProductVariant) Validate(opts []*ProductOption) *AppErr {
+	var errs ValidationErrors
+	l := locale.GetUserLocalizer("en")
+
+	if v.ProductID == 0 {
+		errs.Add(Invalid("product_id", l, msgValidateVariantProductID))
+	}
+	if v.SKU == "" || len(v.SKU) > 64 {
+		errs.Add(Invalid("sku", l, msgValidateVariantSKU))
+	}
+	if v.Price < 0 || v.OriginalPrice < v.Price {
+		errs.Add(Invalid("price", l, msgValidateVariantPrice))
+	}
+	if v.StockQuantity < 0 {
+		errs.Add(Invalid("stock_quantity", l, msgValidateVariantStock))
+	}
+	if v.CreatedAt.IsZero() {
+		errs.Add(Invalid("created_at", l, msgValidateVariantCreatedAt))
+	}
+	if v.UpdatedAt.IsZero() {
+		errs.Add(Invalid("updated_at", l, msgValidateVariantUpdatedAt))
+	}
+	if !v.isBareDefault() {
+		validateVariantOptions(&errs, l, v.Options, opts)
+	}
+
+	if !errs.IsZero() {
+		return NewValidationError("ProductVariant", msgInvalidProductVariant, "", errs)
+	}
+	return nil
+}
+
+// MatchesOptions returns true if the variant has the allowed value for every product option axis
+func (v *ProductVariant) MatchesOptions(opts []*ProductOption) bool {
+	if v.isBareDefault() {
+		return true
+	}
+	var errs ValidationErrors
+	validateVariantOptions(&errs, locale.GetUserLocalizer("en"), v.Options, opts)
+	return errs.IsZero()
+}
+
+// isBareDefault returns true for the default variant without options, it stands for the product itself
+func (v *ProductVariant) isBareDefault() bool {
+	return v.IsDefault && len(v.Options) == 0
+}
+
+func validateVariantOptions(errs *ValidationErrors, l *i18n.Localizer, options VariantOptions, opts []*ProductOption) {
+	if len(options) != len(opts) {
+		errs.Add(Invalid("options", l, msgValidateVariantOptions))
+		return
+	}
+
+	for _, opt := range opts {
+		val, ok := options[opt.Name]
+		if !ok || val == "" {
+			errs.Add(Invalid("options", l, msgValidateVariantOptions))
+			return
+		}
+		if len(opt.Values) == 0 {
+			continue
+		}
+
+		allowed := false
+		for _, x := range opt.Values {
+			if x == val {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			errs.Add(Invalid("options."+opt.Name, l, msgValidateVariantOptionVal))
+		}
+	}
 }
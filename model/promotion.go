@@ -2,6 +2,7 @@ package model
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
 	"time"
 
@@ -11,33 +12,107 @@ import (
 
 // error msgs
 var (
-	msgInvalidPromotion           = &i18n.Message{ID: "model.promotion.validate.app_error", Other: "invalid promotion data"}
-	msgValidatePromotionPromoCode = &i18n.Message{ID: "model.promotion.validate.promo_code.app_error", Other: "invalid promo code"}
-	msgValidatePromotionType      = &i18n.Message{ID: "model.promotion.validate.type.app_error", Other: "invalid promotion type"}
-	msgValidatePromotionAmount    = &i18n.Message{ID: "model.promotion.validate.amount.app_error", Other: "invalid promotion amount value"}
-	msgValidatePromotionStartsAt  = &i18n.Message{ID: "model.promotion.validate.starts_at.app_error", Other: "invalid promotion starts_at timestamp"}
-	msgValidatePromotionEndsAt    = &i18n.Message{ID: "model.promotion.validate.ends_at.app_error", Other: "invalid promotion ends_at timestamp"}
-	msgValidatePromotionCreatedAt = &i18n.Message{ID: "model.promotion.validate.created_at.app_error", Other: "invalid promotion created_at timestamp"}
-	msgValidatePromotionUpdatedAt = &i18n.Message{ID: "model.promotion.validate.updated_at.app_error", Other: "invalid promotion updated_at timestamp"}
+	msgInvalidPromotion            = &i18n.Message{ID: "model.promotion.validate.app_error", Other: "invalid promotion data"}
+	msgValidatePromotionPromoCode  = &i18n.Message{ID: "model.promotion.validate.promo_code.app_error", Other: "invalid promo code"}
+	msgValidatePromotionType       = &i18n.Message{ID: "model.promotion.validate.type.app_error", Other: "invalid promotion type"}
+	msgValidatePromotionAmount     = &i18n.Message{ID: "model.promotion.validate.amount.app_error", Other: "invalid promotion amount value"}
+	msgValidatePromotionStartsAt   = &i18n.Message{ID: "model.promotion.validate.starts_at.app_error", Other: "invalid promotion starts_at timestamp"}
+	msgValidatePromotionEndsAt     = &i18n.Message{ID: "model.promotion.validate.ends_at.app_error", Other: "invalid promotion ends_at timestamp"}
+	msgValidatePromotionCreatedAt  = &i18n.Message{ID: "model.promotion.validate.created_at.app_error", Other: "invalid promotion created_at timestamp"}
+	msgValidatePromotionUpdatedAt  = &i18n.Message{ID: "model.promotion.validate.updated_at.app_error", Other: "invalid promotion updated_at timestamp"}
+	msgValidatePromotionPercentage = &i18n.Message{ID: "model.promotion.validate.amount.percentage.app_error", Other: "percentage must be between 1 and 100"}
+	msgValidatePromotionMinOrder   = &i18n.Message{ID: "model.promotion.validate.min_order_amount.app_error", Other: "min order amount must not be negative"}
+	msgValidatePromotionMaxDisc    = &i18n.Message{ID: "model.promotion.validate.max_discount_amount.app_error", Other: "max discount amount must be greater than 0"}
+	msgValidatePromotionUsageLimit = &i18n.Message{ID: "model.promotion.validate.usage_limit.app_error", Other: "usage limit must be greater than 0"}
+	msgValidatePromotionUserLimit  = &i18n.Message{ID: "model.promotion.validate.usage_limit_per_user.app_error", Other: "usage limit per user must be greater than 0"}
+	msgValidatePromotionTargets    = &i18n.Message{ID: "model.promotion.validate.targets.app_error", Other: "too many promotion targets, max 100"}
+	msgValidatePromotionTargetType = &i18n.Message{ID: "model.promotion.validate.target_type.app_error", Other: "invalid target type, must be product, category, brand or tag"}
+	msgValidatePromotionTargetID   = &i18n.Message{ID: "model.promotion.validate.target_id.app_error", Other: "invalid target id"}
+	msgValidatePromotionTargetDup  = &i18n.Message{ID: "model.promotion.validate.target.duplicate.app_error", Other: "duplicate promotion target"}
+	msgValidatePromotionBuyQty     = &i18n.Message{ID: "model.promotion.validate.buy_quantity.app_error", Other: "buy quantity must be greater than 0"}
+	msgValidatePromotionGetQty     = &i18n.Message{ID: "model.promotion.validate.get_quantity.app_error", Other: "get quantity must be greater than 0"}
+	msgValidatePromotionTiers      = &i18n.Message{ID: "model.promotion.validate.tiers.app_error", Other: "tiered promotion must have between 1 and 10 tiers"}
+	msgValidatePromotionTierQty    = &i18n.Message{ID: "model.promotion.validate.tiers.min_quantity.app_error", Other: "tier min quantities must be greater than 1 and ascending"}
+	msgValidatePromotionTierAmount = &i18n.Message{ID: "model.promotion.validate.tiers.amount.app_error", Other: "tier percentage must be between 1 and 100"}
+	msgValidatePromotionCampaign   = &i18n.Message{ID: "model.promotion.validate.automatic.campaign.app_error", Other: "campaign with the generated codes can't be automatic"}
 )
 
+// promotion types
+const (
+	PromotionTypePercentage   = "percentage"
+	PromotionTypeFixed        = "fixed"
+	PromotionTypeBuyXGetY     = "buy_x_get_y"
+	PromotionTypeFreeShipping = "free_shipping"
+	PromotionTypeTiered       = "tiered"
+)
+
+// PromotionMaxTiers is the max number of the tiers of the tiered promotion
+const PromotionMaxTiers = 10
+
+// PromotionTargetType is the kind of the cart items the promotion applies to
+type PromotionTargetType string
+
+// promotion target types
+const (
+	PromotionTargetProduct  PromotionTargetType = "product"
+	PromotionTargetCategory PromotionTargetType = "category"
+	PromotionTargetBrand    PromotionTargetType = "brand"
+	PromotionTargetTag      PromotionTargetType = "tag"
+)
+
+// PromotionMaxTargets is the max number of the targets of one promotion
+const PromotionMaxTargets = 100
+
 // Promotion is the promotion model (discount for order)
+// the promotion without the targets applies to the whole cart, the nil caps and limits are unlimited
+// the amount is the percentage or the fixed discount, for buy x get y it's the percentage off the y items
+// the automatic promotion applies without entering its promo code, the higher priority is evaluated first
+// the exclusive promotion is never combined with the other promotions, the stackable ones are combined with each other
+// the campaign is redeemed only by its generated codes, it becomes the campaign when the codes are generated
+// the usage count is the number of the claimed redemptions, it's maintained by the db
 type Promotion struct {
 	TotalRecordsCount
-	PromoCode   string    `json:"promo_code" db:"promo_code"`
-	Type        string    `json:"type" db:"type"`
-	Amount      int       `json:"amount" db:"amount"`
-	Description string    `json:"description,omitempty" db:"description"`
-	StartsAt    time.Time `json:"starts_at" db:"starts_at"`
-	EndsAt      time.Time `json:"ends_at" db:"ends_at"`
-	CreatedAt   time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+	PromoCode         string             `json:"promo_code" db:"promo_code"`
+	Type              string             `json:"type" db:"type"`
+	Amount            int                `json:"amount" db:"amount"`
+	Description       string             `json:"description,omitempty" db:"description"`
+	MinOrderAmount    int                `json:"min_order_amount" db:"min_order_amount"`
+	MaxDiscountAmount *int               `json:"max_discount_amount" db:"max_discount_amount"`
+	UsageLimit        *int               `json:"usage_limit" db:"usage_limit"`
+	UsageLimitPerUser *int               `json:"usage_limit_per_user" db:"usage_limit_per_user"`
+	BuyQuantity       *int               `json:"buy_quantity,omitempty" db:"buy_quantity"`
+	GetQuantity       *int               `json:"get_quantity,omitempty" db:"get_quantity"`
+	Tiers             PromotionTiers     `json:"tiers,omitempty" db:"tiers"`
+	Automatic         bool               `json:"automatic" db:"automatic"`
+	Priority          int                `json:"priority" db:"priority"`
+	Exclusive         bool               `json:"exclusive" db:"exclusive"`
+	Campaign          bool               `json:"campaign" db:"campaign"`
+	UsageCount        int                `json:"usage_count" db:"usage_count"`
+	StartsAt          time.Time          `json:"starts_at" db:"starts_at"`
+	EndsAt            time.Time          `json:"ends_at" db:"ends_at"`
+	CreatedAt         time.Time          `json:"created_at" db:"created_at"`
+	UpdatedAt         time.Time          `json:"updated_at" db:"updated_at"`
+	Targets           []*PromotionTarget `json:"targets,omitempty" db:"-"`
 }
 
-// PromotionDetail is is the promotion association
+// PromotionTarget limits the promotion to the product, or to the products of the category, brand or tag
+type PromotionTarget struct {
+	PromoCode  string              `json:"-" db:"promo_code"`
+	TargetType PromotionTargetType `json:"target_type" db:"target_type"`
+	TargetID   int64               `json:"target_id" db:"target_id"`
+}
+
+// PromotionDetail is is the promotion association, one row per redemption of the promo code
 type PromotionDetail struct {
 	UserID    int64  `json:"user_id" db:"user_id"`
 	PromoCode string `json:"promo_code" db:"promo_code"`
+	OrderID   *int64 `json:"order_id,omitempty" db:"order_id"`
+}
+
+// PromotionUsage is the number of the redemptions of the promo code, in total and by the user
+type PromotionUsage struct {
+	Total  int `db:"total"`
+	ByUser int `db:"by_user"`
 }
 
 // PreSave will fill timestamps and other defaults
@@ -59,18 +134,52 @@ func (p *Promotion) Validate() *AppErr {
 	if p.PromoCode == "" {
 		errs.Add(Invalid("promo_code", l, msgValidatePromotionPromoCode))
 	}
-	if p.Type == "" {
+	if !IsValidPromotionType(p.Type) {
 		errs.Add(Invalid("type", l, msgValidatePromotionType))
 	}
-	if p.Amount == 0 {
-		errs.Add(Invalid("amount", l, msgValidatePromotionAmount))
+	switch p.Type {
+	case PromotionTypeFixed:
+		if p.Amount <= 0 {
+			errs.Add(Invalid("amount", l, msgValidatePromotionAmount))
+		}
+	case PromotionTypePercentage, PromotionTypeBuyXGetY:
+		if p.Amount <= 0 || p.Amount > 100 {
+			errs.Add(Invalid("amount", l, msgValidatePromotionPercentage))
+		}
+	}
+	if p.Type == PromotionTypeBuyXGetY {
+		if p.BuyQuantity == nil || *p.BuyQuantity <= 0 {
+			errs.Add(Invalid("buy_quantity", l, msgValidatePromotionBuyQty))
+		}
+		if p.GetQuantity == nil || *p.GetQuantity <= 0 {
+			errs.Add(Invalid("get_quantity", l, msgValidatePromotionGetQty))
+		}
+	}
+	if p.Type == PromotionTypeTiered {
+		validatePromotionTiers(&errs, p.Tiers)
+	}
+	if p.MinOrderAmount < 0 {
+		errs.Add(Invalid("min_order_amount", l, msgValidatePromotionMinOrder))
 	}
+	if p.MaxDiscountAmount != nil && *p.MaxDiscountAmount <= 0 {
+		errs.Add(Invalid("max_discount_amount", l, msgValidatePromotionMaxDisc))
+	}
+	if p.UsageLimit != nil && *p.UsageLimit <= 0 {
+		errs.Add(Invalid("usage_limit", l, msgValidatePromotionUsageLimit))
+	}
+	if p.UsageLimitPerUser != nil && *p.UsageLimitPerUser <= 0 {
+		errs.Add(Invalid("usage_limit_per_user", l, msgValidatePromotionUserLimit))
+	}
+	validatePromotionTargets(&errs, p.Targets)
 	if p.StartsAt.IsZero() {
 		errs.Add(Invalid("starts_at", l, msgValidatePromotionStartsAt))
 	}
 	if p.EndsAt.IsZero() || p.EndsAt.Before(p.StartsAt) {
 		errs.Add(Invalid("ends_at", l, msgValidatePromotionEndsAt))
 	}
+	if p.Automatic && p.Campaign {
+		errs.Add(Invalid("automatic", l, msgValidatePromotionCampaign))
+	}
 	if p.CreatedAt.IsZero() {
 		errs.Add(Invalid("created_at", l, msgValidatePromotionCreatedAt))
 	}
@@ -84,13 +193,93 @@ func (p *Promotion) Validate() *AppErr {
 	return nil
 }
 
+// validatePromotionTargets validates the targets of the promotion
+func validatePromotionTargets(errs *ValidationErrors, targets []*PromotionTarget) {
+	l := locale.GetUserLocalizer("en")
+
+	if len(targets) > PromotionMaxTargets {
+		errs.Add(Invalid("targets", l, msgValidatePromotionTargets))
+	}
+
+	seen := make(map[PromotionTarget]bool, len(targets))
+	for i, t := range targets {
+		if t == nil {
+			errs.Add(Invalid(fmt.Sprintf("targets[%d]", i), l, msgValidatePromotionTargetType))
+			continue
+		}
+		if !t.TargetType.IsValid() {
+			errs.Add(Invalid(fmt.Sprintf("targets[%d].target_type", i), l, msgValidatePromotionTargetType))
+		}
+		if t.TargetID <= 0 {
+			errs.Add(Invalid(fmt.Sprintf("targets[%d].target_id", i), l, msgValidatePromotionTargetID))
+		}
+		key := PromotionTarget{TargetType: t.TargetType, TargetID: t.TargetID}
+		if seen[key] {
+			errs.Add(Invalid(fmt.Sprintf("targets[%d]", i), l, msgValidatePromotionTargetDup))
+		}
+		seen[key] = true
+	}
+}
+
+// validatePromotionTiers validates the tiers of the tiered promotion
+func validatePromotionTiers(errs *ValidationErrors, tiers PromotionTiers) {
+	l := locale.GetUserLocalizer("en")
+
+	if len(tiers) == 0 || len(tiers) > PromotionMaxTiers {
+		errs.Add(Invalid("tiers", l, msgValidatePromotionTiers))
+		return
+	}
+
+	prev := 1
+	for i, t := range tiers {
+		if t == nil || t.MinQuantity <= prev {
+			errs.Add(Invalid(fmt.Sprintf("tiers[%d].min_quantity", i), l, msgValidatePromotionTierQty))
+			continue
+		}
+		if t.Amount <= 0 || t.Amount > 100 {
+			errs.Add(Invalid(fmt.Sprintf("tiers[%d].amount", i), l, msgValidatePromotionTierAmount))
+		}
+		prev = t.MinQuantity
+	}
+}
+
+// IsValidPromotionType returns true if the promotion type is known
+func IsValidPromotionType(t string) bool {
+	switch t {
+	case PromotionTypePercentage, PromotionTypeFixed, PromotionTypeBuyXGetY, PromotionTypeFreeShipping, PromotionTypeTiered:
+		return true
+	}
+	return false
+}
+
+// IsValid returns true if the target type is known
+func (t PromotionTargetType) IsValid() bool {
+	switch t {
+	case PromotionTargetProduct, PromotionTargetCategory, PromotionTargetBrand, PromotionTargetTag:
+		return true
+	}
+	return false
+}
+
 // PromotionPatch is the category patch model
+// the zero cap or limit removes it, the provided targets replace all of the targets
 type PromotionPatch struct {
-	Type        *string    `json:"type,omitempty"`
-	Amount      *int       `json:"amount,omitempty"`
-	Description *string    `json:"description,omitempty"`
-	StartsAt    *time.Time `json:"starts_at,omitempty"`
-	EndsAt      *time.Time `json:"ends_at,omitempty"`
+	Type              *string             `json:"type,omitempty"`
+	Amount            *int                `json:"amount,omitempty"`
+	Description       *string             `json:"description,omitempty"`
+	MinOrderAmount    *int                `json:"min_order_amount,omitempty"`
+	MaxDiscountAmount *int                `json:"max_discount_amount,omitempty"`
+	UsageLimit        *int                `json:"usage_limit,omitempty"`
+	UsageLimitPerUser *int                `json:"usage_limit_per_user,omitempty"`
+	BuyQuantity       *int                `json:"buy_quantity,omitempty"`
+	GetQuantity       *int                `json:"get_quantity,omitempty"`
+	Tiers             *PromotionTiers     `json:"tiers,omitempty"`
+	Automatic         *bool               `json:"automatic,omitempty"`
+	Priority          *int                `json:"priority,omitempty"`
+	Exclusive         *bool               `json:"exclusive,omitempty"`
+	StartsAt          *time.Time          `json:"starts_at,omitempty"`
+	EndsAt            *time.Time          `json:"ends_at,omitempty"`
+	Targets           *[]*PromotionTarget `json:"targets,omitempty"`
 }
 
 // Patch patches the category fields that are provided
@@ -104,6 +293,39 @@ func (p *Promotion) Patch(patch *PromotionPatch) {
 	if patch.Description != nil {
 		p.Description = *patch.Description
 	}
+	if patch.MinOrderAmount != nil {
+		p.MinOrderAmount = *patch.MinOrderAmount
+	}
+	if patch.MaxDiscountAmount != nil {
+		p.MaxDiscountAmount = patchLimit(*patch.MaxDiscountAmount)
+	}
+	if patch.UsageLimit != nil {
+		p.UsageLimit = patchLimit(*patch.UsageLimit)
+	}
+	if patch.UsageLimitPerUser != nil {
+		p.UsageLimitPerUser = patchLimit(*patch.UsageLimitPerUser)
+	}
+	if patch.BuyQuantity != nil {
+		p.BuyQuantity = patchLimit(*patch.BuyQuantity)
+	}
+	if patch.GetQuantity != nil {
+		p.GetQuantity = patchLimit(*patch.GetQuantity)
+	}
+	if patch.Tiers != nil {
+		p.Tiers = *patch.Tiers
+	}
+	if patch.Automatic != nil {
+		p.Automatic = *patch.Automatic
+	}
+	if patch.Priority != nil {
+		p.Priority = *patch.Priority
+	}
+	if patch.Exclusive != nil {
+		p.Exclusive = *patch.Exclusive
+	}
+	if patch.Targets != nil {
+		p.Targets = *patch.Targets
+	}
 	if patch.StartsAt != nil {
 		p.StartsAt = *patch.StartsAt
 	}
@@ -117,12 +339,27 @@ func (patch *PromotionPatch) Validate() *AppErr {
 	var errs ValidationErrors
 	l := locale.GetUserLocalizer("en")
 
-	if patch.Type != nil && *patch.Type == "" {
+	if patch.Type != nil && !IsValidPromotionType(*patch.Type) {
 		errs.Add(Invalid("type", l, msgValidatePromotionType))
 	}
-	if patch.Amount != nil && *patch.Amount == 0 {
+	if patch.Amount != nil && *patch.Amount <= 0 {
 		errs.Add(Invalid("amount", l, msgValidatePromotionAmount))
 	}
+	if patch.MinOrderAmount != nil && *patch.MinOrderAmount < 0 {
+		errs.Add(Invalid("min_order_amount", l, msgValidatePromotionMinOrder))
+	}
+	if patch.MaxDiscountAmount != nil && *patch.MaxDiscountAmount < 0 {
+		errs.Add(Invalid("max_discount_amount", l, msgValidatePromotionMaxDisc))
+	}
+	if patch.UsageLimit != nil && *patch.UsageLimit < 0 {
+		errs.Add(Invalid("usage_limit", l, msgValidatePromotionUsageLimit))
+	}
+	if patch.UsageLimitPerUser != nil && *patch.UsageLimitPerUser < 0 {
+		errs.Add(Invalid("usage_limit_per_user", l, msgValidatePromotionUserLimit))
+	}
+	if patch.Targets != nil {
+		validatePromotionTargets(&errs, *patch.Targets)
+	}
 	if patch.StartsAt != nil && patch.StartsAt.IsZero() {
 		errs.Add(Invalid("starts_at", l, msgValidatePromotionStartsAt))
 	}
@@ -144,9 +381,12 @@ func PromotionPatchFromJSON(data io.Reader) (*PromotionPatch, error) {
 }
 
 // PromotionFromJSON decodes the input and returns the Promotion
+// the promo code can be used once per user unless the input sets the per user limit, the null limit is unlimited
+// the promotion is exclusive unless the input makes it stackable
 func PromotionFromJSON(data io.Reader) (*Promotion, error) {
-	var p *Promotion
-	err := json.NewDecoder(data).Decode(&p)
+	perUser := 1
+	p := &Promotion{UsageLimitPerUser: &perUser, Exclusive: true}
+	err := json.NewDecoder(data).Decode(p)
 	return p, err
 }
 
@@ -160,3 +400,11 @@ func (p *Promotion) ToJSON() string {
 func (p *Promotion) IsActive(t time.Time) bool {
 	return t.After(p.StartsAt) && t.Before(p.EndsAt)
 }
+
+// patchLimit returns the patched cap or limit, the zero removes it
+func patchLimit(v int) *int {
+	if v == 0 {
+		return nil
+	}
+	return &v
+}
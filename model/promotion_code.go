@@ -0,0 +1,131 @@
+package model
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dankobgd/ecommerce-shop/utils/locale"
+	"github.com/dankobgd/ecommerce-shop/utils/random"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+// error msgs
+var (
+	msgInvalidPromotionCodeBatch = &i18n.Message{ID: "model.promotion_code_batch.validate.app_error", Other: "invalid promotion code batch"}
+	msgValidateCodeBatchCount    = &i18n.Message{ID: "model.promotion_code_batch.validate.count.app_error", Other: "count must be between 1 and 10000"}
+	msgValidateCodeBatchPattern  = &i18n.Message{ID: "model.promotion_code_batch.validate.pattern.app_error", Other: "pattern can contain only uppercase letters, digits, '-', '_' and the '#' placeholders"}
+	msgValidateCodeBatchLength   = &i18n.Message{ID: "model.promotion_code_batch.validate.length.app_error", Other: "code must have between 6 random characters and 30 characters in total"}
+)
+
+// promotion code batch limits
+const (
+	PromotionCodeMaxBatch      = 10000
+	PromotionCodeMaxLength     = 30
+	PromotionCodeMinRandom     = 6
+	PromotionCodeDefaultLength = 10
+	PromotionCodePlaceholder   = '#'
+)
+
+var promotionCodePatternRe = regexp.MustCompile(`^[A-Z0-9_#-]*$`)
+
+// PromotionCode is the generated single use code of the campaign
+// the redeemed code has the user and the order that used it
+type PromotionCode struct {
+	TotalRecordsCount
+	Code       string     `json:"code" db:"code"`
+	PromoCode  string     `json:"promo_code" db:"promo_code"`
+	UserID     *int64     `json:"user_id" db:"user_id"`
+	OrderID    *int64     `json:"order_id" db:"order_id"`
+	RedeemedAt *time.Time `json:"redeemed_at" db:"redeemed_at"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+}
+
+// IsRedeemed returns true if the code was already used by the order
+func (c *PromotionCode) IsRedeemed() bool {
+	return c.OrderID != nil
+}
+
+// PromotionCodeBatch is the request to generate the codes of the campaign
+// every '#' in the pattern is the random character, the pattern without the placeholders is the prefix of length random characters
+type PromotionCodeBatch struct {
+	Count   int    `json:"count"`
+	Pattern string `json:"pattern"`
+	Length  int    `json:"length"`
+}
+
+// PromotionCodeBatchFromJSON decodes the input and returns the PromotionCodeBatch
+func PromotionCodeBatchFromJSON(data io.Reader) (*PromotionCodeBatch, error) {
+	var b *PromotionCodeBatch
+	err := json.NewDecoder(data).Decode(&b)
+	return b, err
+}
+
+// PreSave normalizes the pattern and expands the prefix pattern into the pattern with the placeholders
+func (b *PromotionCodeBatch) PreSave() {
+	b.Pattern = strings.ToUpper(strings.TrimSpace(b.Pattern))
+	if strings.ContainsRune(b.Pattern, PromotionCodePlaceholder) {
+		return
+	}
+	if b.Length == 0 {
+		b.Length = PromotionCodeDefaultLength
+	}
+	if b.Length > 0 && b.Length <= PromotionCodeMaxLength {
+		b.Pattern += strings.Repeat(string(PromotionCodePlaceholder), b.Length)
+	}
+}
+
+// Validate validates the batch and returns an error if it doesn't pass criteria
+func (b *PromotionCodeBatch) Validate() *AppErr {
+	var errs ValidationErrors
+	l := locale.GetUserLocalizer("en")
+
+	if b.Count <= 0 || b.Count > PromotionCodeMaxBatch {
+		errs.Add(Invalid("count", l, msgValidateCodeBatchCount))
+	}
+	if !promotionCodePatternRe.MatchString(b.Pattern) {
+		errs.Add(Invalid("pattern", l, msgValidateCodeBatchPattern))
+	}
+	if len(b.Pattern) > PromotionCodeMaxLength || strings.Count(b.Pattern, string(PromotionCodePlaceholder)) < PromotionCodeMinRandom {
+		errs.Add(Invalid("length", l, msgValidateCodeBatchLength))
+	}
+
+	if !errs.IsZero() {
+		return NewValidationError("PromotionCodeBatch", msgInvalidPromotionCodeBatch, "", errs)
+	}
+	return nil
+}
+
+// Generate generates the new random code of the pattern
+func (b *PromotionCodeBatch) Generate() string {
+	return random.SecureCode(b.Pattern, PromotionCodePlaceholder)
+}
+
+// WritePromotionCodesCSV writes the codes with their redemptions as the csv, the header first
+func WritePromotionCodesCSV(w io.Writer, codes []*PromotionCode) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"code", "promo_code", "user_id", "order_id", "redeemed_at", "created_at"}); err != nil {
+		return err
+	}
+	for _, c := range codes {
+		var userID, orderID, redeemedAt string
+		if c.UserID != nil {
+			userID = strconv.FormatInt(*c.UserID, 10)
+		}
+		if c.OrderID != nil {
+			orderID = strconv.FormatInt(*c.OrderID, 10)
+		}
+		if c.RedeemedAt != nil {
+			redeemedAt = c.RedeemedAt.UTC().Format(time.RFC3339)
+		}
+		if err := cw.Write([]string{c.Code, c.PromoCode, userID, orderID, redeemedAt, c.CreatedAt.UTC().Format(time.RFC3339)}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
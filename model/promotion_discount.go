@@ -0,0 +1,170 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"math"
+)
+
+// PromotionTier is the percentage discount of the line whose quantity reaches the min quantity
+type PromotionTier struct {
+	MinQuantity int `json:"min_quantity"`
+	Amount      int `json:"amount"`
+}
+
+// PromotionTiers are the tiers of the tiered promotion, ordered by the min quantity
+type PromotionTiers []*PromotionTier
+
+// Value implements the driver.Valuer interface
+func (t PromotionTiers) Value() (driver.Value, error) {
+	if t == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(t)
+	return string(b), err
+}
+
+// Scan implements the sql.Scanner interface
+func (t *PromotionTiers) Scan(src interface{}) error {
+	if src == nil {
+		*t = nil
+		return nil
+	}
+	return scanJSON(src, t)
+}
+
+// OrderDiscount is the discount of the promotion applied to the cart
+// the amount is split over the cart lines, the shipping amount is the discount of the shipping fee
+type OrderDiscount struct {
+	ID             int64   `json:"-" db:"id"`
+	OrderID        int64   `json:"-" db:"order_id"`
+	PromoCode      *string `json:"promo_code" db:"promo_code"`
+	Type           string  `json:"type" db:"type"`
+	Description    string  `json:"description" db:"description"`
+	Amount         int     `json:"amount" db:"amount"`
+	ShippingAmount int     `json:"shipping_amount" db:"shipping_amount"`
+	Lines          []int   `json:"-" db:"-"`
+}
+
+// Total is the whole discount, of the lines and of the shipping
+func (d *OrderDiscount) Total() int {
+	return d.Amount + d.ShippingAmount
+}
+
+// PromotionRejection explains why the promotion was not applied to the cart
+type PromotionRejection struct {
+	PromoCode string `json:"promo_code"`
+	Automatic bool   `json:"automatic"`
+	Reason    string `json:"reason"`
+	ReasonID  string `json:"reason_id"`
+}
+
+// ApplyTo calculates the discount of the promotion for the cart items, only the targeted items are discounted
+// the discounts already applied to the items and the shipping are left out, so the stacked promotions never discount more than the price
+// the line discounts are in the items order, the discount never exceeds the max discount amount
+func (p *Promotion) ApplyTo(items []*QuoteItem, targeted []bool, shipping int) *OrderDiscount {
+	d := &OrderDiscount{Type: p.Type, Description: p.Description, Lines: make([]int, len(items))}
+	if p.PromoCode != "" {
+		code := p.PromoCode
+		d.PromoCode = &code
+	}
+
+	due := make([]int, len(items))
+	for i, item := range items {
+		if targeted[i] {
+			due[i] = item.Total - item.Discount
+		}
+	}
+
+	switch p.Type {
+	case PromotionTypePercentage, PromotionTypeFixed:
+		subtotal := sum(due)
+		amount := p.Amount
+		if p.Type == PromotionTypePercentage {
+			amount = percentOf(subtotal, p.Amount)
+		}
+		if amount > subtotal {
+			amount = subtotal
+		}
+		d.Lines = AllocateAmount(amount, due)
+	case PromotionTypeBuyXGetY:
+		for i, item := range items {
+			if targeted[i] && p.BuyQuantity != nil && p.GetQuantity != nil {
+				free := item.Quantity / (*p.BuyQuantity + *p.GetQuantity) * *p.GetQuantity
+				d.Lines[i] = percentOf(free*item.Price, p.Amount)
+			}
+		}
+	case PromotionTypeTiered:
+		for i, item := range items {
+			if !targeted[i] {
+				continue
+			}
+			if tier := p.Tiers.For(item.Quantity); tier != nil {
+				d.Lines[i] = percentOf(due[i], tier.Amount)
+			}
+		}
+	case PromotionTypeFreeShipping:
+		d.ShippingAmount = shipping
+	}
+
+	for i := range d.Lines {
+		if d.Lines[i] > due[i] {
+			d.Lines[i] = due[i]
+		}
+	}
+	if p.MaxDiscountAmount != nil {
+		maxAmount := *p.MaxDiscountAmount
+		if d.ShippingAmount > maxAmount {
+			d.ShippingAmount = maxAmount
+		}
+		if sum(d.Lines) > maxAmount {
+			d.Lines = AllocateAmount(maxAmount, d.Lines)
+		}
+	}
+	d.Amount = sum(d.Lines)
+	return d
+}
+
+// For returns the highest tier the quantity reaches, nil if it reaches none
+func (t PromotionTiers) For(quantity int) *PromotionTier {
+	var best *PromotionTier
+	for _, tier := range t {
+		if quantity >= tier.MinQuantity && (best == nil || tier.MinQuantity > best.MinQuantity) {
+			best = tier
+		}
+	}
+	return best
+}
+
+// AllocateAmount splits the amount proportionally to the weights, the last weighted share takes the rounding leftover
+func AllocateAmount(amount int, weights []int) []int {
+	shares := make([]int, len(weights))
+	total := sum(weights)
+	if total <= 0 || amount <= 0 {
+		return shares
+	}
+
+	allocated, last := 0, -1
+	for i, w := range weights {
+		if w <= 0 {
+			continue
+		}
+		shares[i] = int(int64(amount) * int64(w) / int64(total))
+		allocated += shares[i]
+		last = i
+	}
+	shares[last] += amount - allocated
+	return shares
+}
+
+func percentOf(amount, percent int) int {
+	return int(math.Round(float64(amount) * float64(percent) / 100))
+}
+
+func sum(values []int) int {
+	total := 0
+	for _, v := range values {
+		total += v
+	}
+	return total
+}
@@ -0,0 +1,173 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAllocateAmount(t *testing.T) {
+	tests := []struct {
+		name    string
+		amount  int
+		weights []int
+		want    []int
+	}{
+		{"even split", 100, []int{50, 50}, []int{50, 50}},
+		{"proportional split", 250, []int{2000, 500}, []int{200, 50}},
+		{"last share takes the leftover", 100, []int{1, 1, 1}, []int{33, 33, 34}},
+		{"zero weights get nothing", 10, []int{0, 3, 1, 0}, []int{0, 7, 3, 0}},
+		{"zero amount", 0, []int{1, 2}, []int{0, 0}},
+		{"negative amount", -5, []int{1}, []int{0}},
+		{"no weight", 10, []int{0, 0}, []int{0, 0}},
+		{"no weights", 10, []int{}, []int{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := AllocateAmount(tt.amount, tt.weights)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("AllocateAmount(%d, %v) = %v, want %v", tt.amount, tt.weights, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPromotionApplyTo(t *testing.T) {
+	item := func(quantity, price, discount int) *QuoteItem {
+		return &QuoteItem{Quantity: quantity, Price: price, Total: quantity * price, Discount: discount}
+	}
+	tiers := PromotionTiers{{MinQuantity: 2, Amount: 10}, {MinQuantity: 5, Amount: 20}}
+
+	tests := []struct {
+		name         string
+		promo        *Promotion
+		items        []*QuoteItem
+		targeted     []bool
+		shipping     int
+		wantLines    []int
+		wantShipping int
+	}{
+		{
+			name:      "percentage",
+			promo:     &Promotion{Type: PromotionTypePercentage, Amount: 10},
+			items:     []*QuoteItem{item(2, 1000, 0), item(1, 500, 0)},
+			targeted:  []bool{true, true},
+			wantLines: []int{200, 50},
+		},
+		{
+			name:      "percentage over the max discount",
+			promo:     &Promotion{Type: PromotionTypePercentage, Amount: 10, MaxDiscountAmount: NewInt(100)},
+			items:     []*QuoteItem{item(2, 1000, 0), item(1, 500, 0)},
+			targeted:  []bool{true, true},
+			wantLines: []int{80, 20},
+		},
+		{
+			name:      "fixed only the targeted items",
+			promo:     &Promotion{Type: PromotionTypeFixed, Amount: 300},
+			items:     []*QuoteItem{item(2, 1000, 0), item(1, 500, 0)},
+			targeted:  []bool{true, false},
+			wantLines: []int{300, 0},
+		},
+		{
+			name:      "fixed over the subtotal",
+			promo:     &Promotion{Type: PromotionTypeFixed, Amount: 5000},
+			items:     []*QuoteItem{item(2, 1000, 0), item(1, 500, 0)},
+			targeted:  []bool{true, true},
+			wantLines: []int{2000, 500},
+		},
+		{
+			name:      "fixed after the stacked discount",
+			promo:     &Promotion{Type: PromotionTypeFixed, Amount: 800},
+			items:     []*QuoteItem{item(2, 1000, 1500), item(1, 500, 0)},
+			targeted:  []bool{true, true},
+			wantLines: []int{400, 400},
+		},
+		{
+			name:      "fixed under the max discount",
+			promo:     &Promotion{Type: PromotionTypeFixed, Amount: 300, MaxDiscountAmount: NewInt(500)},
+			items:     []*QuoteItem{item(1, 1000, 0)},
+			targeted:  []bool{true},
+			wantLines: []int{300},
+		},
+		{
+			name:      "buy x get y",
+			promo:     &Promotion{Type: PromotionTypeBuyXGetY, Amount: 100, BuyQuantity: NewInt(2), GetQuantity: NewInt(1)},
+			items:     []*QuoteItem{item(7, 100, 0), item(2, 50, 0)},
+			targeted:  []bool{true, true},
+			wantLines: []int{200, 0},
+		},
+		{
+			name:      "buy x get y half off",
+			promo:     &Promotion{Type: PromotionTypeBuyXGetY, Amount: 50, BuyQuantity: NewInt(1), GetQuantity: NewInt(1)},
+			items:     []*QuoteItem{item(4, 100, 0)},
+			targeted:  []bool{true},
+			wantLines: []int{100},
+		},
+		{
+			name:      "buy x get y over the max discount",
+			promo:     &Promotion{Type: PromotionTypeBuyXGetY, Amount: 100, BuyQuantity: NewInt(2), GetQuantity: NewInt(1), MaxDiscountAmount: NewInt(150)},
+			items:     []*QuoteItem{item(7, 100, 0), item(2, 50, 0)},
+			targeted:  []bool{true, true},
+			wantLines: []int{150, 0},
+		},
+		{
+			name:      "tiered",
+			promo:     &Promotion{Type: PromotionTypeTiered, Tiers: tiers},
+			items:     []*QuoteItem{item(6, 100, 0), item(3, 100, 0), item(1, 100, 0)},
+			targeted:  []bool{true, true, true},
+			wantLines: []int{120, 30, 0},
+		},
+		{
+			name:      "tiered over the max discount",
+			promo:     &Promotion{Type: PromotionTypeTiered, Tiers: tiers, MaxDiscountAmount: NewInt(100)},
+			items:     []*QuoteItem{item(6, 100, 0), item(3, 100, 0), item(1, 100, 0)},
+			targeted:  []bool{true, true, true},
+			wantLines: []int{80, 20, 0},
+		},
+		{
+			name:         "free shipping",
+			promo:        &Promotion{Type: PromotionTypeFreeShipping},
+			items:        []*QuoteItem{item(1, 1000, 0)},
+			targeted:     []bool{true},
+			shipping:     500,
+			wantLines:    []int{0},
+			wantShipping: 500,
+		},
+		{
+			name:         "free shipping over the max discount",
+			promo:        &Promotion{Type: PromotionTypeFreeShipping, MaxDiscountAmount: NewInt(300)},
+			items:        []*QuoteItem{item(1, 1000, 0)},
+			targeted:     []bool{true},
+			shipping:     500,
+			wantLines:    []int{0},
+			wantShipping: 300,
+		},
+		{
+			name:      "nothing targeted",
+			promo:     &Promotion{Type: PromotionTypePercentage, Amount: 50},
+			items:     []*QuoteItem{item(1, 1000, 0)},
+			targeted:  []bool{false},
+			wantLines: []int{0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.promo.PromoCode = "PROMO"
+			d := tt.promo.ApplyTo(tt.items, tt.targeted, tt.shipping)
+
+			if !reflect.DeepEqual(d.Lines, tt.wantLines) {
+				t.Errorf("lines = %v, want %v", d.Lines, tt.wantLines)
+			}
+			if d.Amount != sum(tt.wantLines) {
+				t.Errorf("amount = %d, want %d", d.Amount, sum(tt.wantLines))
+			}
+			if d.ShippingAmount != tt.wantShipping {
+				t.Errorf("shipping amount = %d, want %d", d.ShippingAmount, tt.wantShipping)
+			}
+			if d.PromoCode == nil || *d.PromoCode != "PROMO" || d.Type != tt.promo.Type {
+				t.Errorf("discount is not of the promotion: %+v", d)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package model
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/dankobgd/ecommerce-shop/utils/locale"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+var msgInvalidQuoteData = &i18n.Message{ID: "model.quote.validate.app_error", Other: "invalid quote data"}
+
+// QuoteRequestData is the cart that is priced without placing the order
+type QuoteRequestData struct {
+	Items     []*CartItem `json:"items"`
+	PromoCode *string     `json:"promo_code"`
+}
+
+// QuoteItem is the priced cart line, the bundle line has the component lines with their share of the bundle price
+type QuoteItem struct {
+	ProductID  int64        `json:"product_id"`
+	VariantID  int64        `json:"variant_id"`
+	SKU        string       `json:"sku"`
+	Quantity   int          `json:"quantity"`
+	Price      int          `json:"price"`
+	Total      int          `json:"total"`
+	Discount   int          `json:"discount"`
+	Components []*QuoteItem `json:"components,omitempty"`
+}
+
+// Quote is the price of the cart, the same one the order would be charged
+// the discount is the sum of the applied discounts, of the items and of the shipping fee
+// the rejected promotions are the automatic ones and the promo code that were not applied, with the reason
+// when the entered code is the generated code, the promo code is its campaign
+// cross sells are the products suggested by the relations of the cart products, only when they are asked for
+type Quote struct {
+	Items           []*QuoteItem          `json:"items"`
+	Subtotal        int                   `json:"subtotal"`
+	Shipping        int                   `json:"shipping"`
+	Discount        int                   `json:"discount"`
+	Total           int                   `json:"total"`
+	Discounts       []*OrderDiscount      `json:"discounts"`
+	Rejected        []*PromotionRejection `json:"rejected_promotions"`
+	PromoCode       *string               `json:"promo_code,omitempty"`
+	GeneratedCode   *string               `json:"generated_code,omitempty"`
+	PromoCodeType   *string               `json:"promo_code_type,omitempty"`
+	PromoCodeAmount *int                  `json:"promo_code_amount,omitempty"`
+	CrossSells      []*Product            `json:"cross_sells,omitempty"`
+}
+
+// QuoteRequestDataFromJSON decodes the input and returns the quote request data
+func QuoteRequestDataFromJSON(data io.Reader) (*QuoteRequestData, error) {
+	var q *QuoteRequestData
+	err := json.NewDecoder(data).Decode(&q)
+	return q, err
+}
+
+// Validate validates the quote request data and returns an error if it doesn't pass criteria
+func (data *QuoteRequestData) Validate() *AppErr {
+	var errs ValidationErrors
+	l := locale.GetUserLocalizer("en")
+
+	if len(data.Items) == 0 {
+		errs.Add(Invalid("items", l, msgValidateNoItems))
+	}
+
+	if !errs.IsZero() {
+		return NewValidationError("QuoteRequestData", msgInvalidQuoteData, "", errs)
+	}
+	return nil
+}
+
+// ProductIDs returns the distinct product ids of the cart items
+func (data *QuoteRequestData) ProductIDs() []int64 {
+	seen := make(map[int64]bool, len(data.Items))
+	ids := make([]int64, 0, len(data.Items))
+	for _, item := range data.Items {
+		if !seen[item.ProductID] {
+			seen[item.ProductID] = true
+			ids = append(ids, item.ProductID)
+		}
+	}
+	return ids
+}
+
+// AddDiscount adds the applied discount to the items and to the quote total
+func (q *Quote) AddDiscount(d *OrderDiscount) {
+	for i, item := range q.Items {
+		item.Discount += d.Lines[i]
+	}
+	q.Discounts = append(q.Discounts, d)
+	q.Discount += d.Total()
+	q.Total = q.Subtotal + q.Shipping - q.Discount
+}
+
+// ShippingDue is the shipping fee left after the applied shipping discounts
+func (q *Quote) ShippingDue() int {
+	due := q.Shipping
+	for _, d := range q.Discounts {
+		due -= d.ShippingAmount
+	}
+	return due
+}
+
+// Rejection returns the reason the promotion of the promo code was not applied, nil if it was not rejected
+func (q *Quote) Rejection(code string) *PromotionRejection {
+	for _, r := range q.Rejected {
+		if r.PromoCode == code {
+			return r
+		}
+	}
+	return nil
+}
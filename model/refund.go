@@ -0,0 +1,108 @@
+package model
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/dankobgd/ecommerce-shop/utils/locale"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+// error msgs
+var (
+	msgInvalidRefundRequest      = &i18n.Message{ID: "model.refund_request.validate.app_error", Other: "invalid refund data"}
+	msgValidateRefundAmount      = &i18n.Message{ID: "model.refund_request.validate.amount.app_error", Other: "refund amount must be greater than 0"}
+	msgValidateRefundAmountItems = &i18n.Message{ID: "model.refund_request.validate.amount_items.app_error", Other: "refund can have either the amount or the items, not both"}
+	msgValidateRefundItem        = &i18n.Message{ID: "model.refund_request.validate.items.app_error", Other: "invalid refund item"}
+	msgValidateRefundReason      = &i18n.Message{ID: "model.refund_request.validate.reason.app_error", Other: "reason must be less than 1000 characters"}
+)
+
+// OrderRefund is the money returned to the customer for the order
+type OrderRefund struct {
+	ID               int64              `json:"id" db:"id"`
+	OrderID          int64              `json:"order_id" db:"order_id"`
+	ProviderRefundID string             `json:"provider_refund_id" db:"provider_refund_id"`
+	Amount           int                `json:"amount" db:"amount"`
+	Reason           *string            `json:"reason" db:"reason"`
+	CreatedBy        *int64             `json:"created_by" db:"created_by"`
+	CreatedAt        time.Time          `json:"created_at" db:"created_at"`
+	Items            []*OrderRefundItem `json:"items" db:"-"`
+}
+
+// OrderRefundItem is the refunded quantity of the order line
+type OrderRefundItem struct {
+	RefundID  int64 `json:"refund_id" db:"refund_id"`
+	OrderID   int64 `json:"order_id" db:"order_id"`
+	Line      int   `json:"line" db:"line"`
+	ProductID int64 `json:"product_id" db:"product_id"`
+	VariantID int64 `json:"variant_id" db:"variant_id"`
+	Quantity  int   `json:"quantity" db:"quantity"`
+	Amount    int   `json:"amount" db:"amount"`
+}
+
+// PreSave fills the defaults
+func (r *OrderRefund) PreSave() {
+	r.CreatedAt = time.Now()
+}
+
+// RefundItemRequest is the order line and quantity to refund
+// the variant is required only when the product was ordered in multiple variants
+// the line is required only when the variant is on multiple order lines (the component of multiple bundles etc...)
+type RefundItemRequest struct {
+	ProductID int64  `json:"product_id"`
+	VariantID *int64 `json:"variant_id"`
+	Line      *int   `json:"line"`
+	Quantity  int    `json:"quantity"`
+}
+
+// RefundRequest is used to refund the order
+// without amount and items the whole remaining order amount is refunded
+type RefundRequest struct {
+	Amount *int                 `json:"amount"`
+	Items  []*RefundItemRequest `json:"items"`
+	Reason string               `json:"reason"`
+}
+
+// RefundRequestFromJSON decodes the input and returns the RefundRequest
+// an empty body is the full refund request
+func RefundRequestFromJSON(data io.Reader) (*RefundRequest, error) {
+	var rr *RefundRequest
+	err := json.NewDecoder(data).Decode(&rr)
+	if err == io.EOF || (err == nil && rr == nil) {
+		return &RefundRequest{}, nil
+	}
+	return rr, err
+}
+
+// IsFull returns true if the whole remaining amount should be refunded
+func (rr *RefundRequest) IsFull() bool {
+	return rr.Amount == nil && len(rr.Items) == 0
+}
+
+// Validate validates the refund request and returns an error if it doesn't pass criteria
+func (rr *RefundRequest) Validate() *AppErr {
+	var errs ValidationErrors
+	l := locale.GetUserLocalizer("en")
+
+	if rr.Amount != nil && len(rr.Items) > 0 {
+		errs.Add(Invalid("amount", l, msgValidateRefundAmountItems))
+	}
+	if rr.Amount != nil && *rr.Amount <= 0 {
+		errs.Add(Invalid("amount", l, msgValidateRefundAmount))
+	}
+	for _, item := range rr.Items {
+		if item == nil || item.ProductID == 0 || item.Quantity <= 0 {
+			errs.Add(Invalid("items", l, msgValidateRefundItem))
+			break
+		}
+	}
+	if len(rr.Reason) > 1000 {
+		errs.Add(Invalid("reason", l, msgValidateRefundReason))
+	}
+
+	if !errs.IsZero() {
+		return NewValidationError("RefundRequest", msgInvalidRefundRequest, "", errs)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package model
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/dankobgd/ecommerce-shop/utils/locale"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+// error msgs
+var (
+	msgInvalidSearchQuery  = &i18n.Message{ID: "model.search_query.validate.app_error", Other: "invalid search query"}
+	msgValidateSearchQuery = &i18n.Message{ID: "model.search_query.validate.q.app_error", Other: "search query must have at least one word that is not negated"}
+)
+
+// searchQueryMaxTerms is the max number of the search terms, the rest of the query is ignored
+const searchQueryMaxTerms = 16
+
+// SearchTerm is the term of the full text search query
+// the phrase matches the words next to each other, the prefix matches the words that start with the last word
+type SearchTerm struct {
+	Words  []string
+	Phrase bool
+	Prefix bool
+	Negate bool
+}
+
+// SearchQuery is the parsed full text search query, its terms are ANDed
+// the fuzzy query matches the words by the trigram similarity, it is the fallback when the full text search finds nothing
+type SearchQuery struct {
+	Terms []*SearchTerm
+	Fuzzy bool
+}
+
+// ProductHighlight are the highlighted snippets of the search hit, the matches are wrapped in <mark> tags
+type ProductHighlight struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// ParseSearchQuery parses the search input:
+// "quoted words" is the phrase, the word ending with * is the prefix and the term starting with - is negated
+// only the letters and digits make the words, so the query can't break the tsquery syntax
+func ParseSearchQuery(input string) (*SearchQuery, *AppErr) {
+	q := &SearchQuery{}
+	positive := false
+
+	for _, raw := range splitSearchInput(input) {
+		if len(q.Terms) == searchQueryMaxTerms {
+			break
+		}
+
+		t := &SearchTerm{}
+		if strings.HasPrefix(raw, "-") {
+			t.Negate = true
+			raw = raw[1:]
+		}
+		if strings.HasPrefix(raw, `"`) {
+			t.Phrase = true
+			raw = strings.Trim(raw, `"`)
+		} else if strings.HasSuffix(raw, "*") {
+			t.Prefix = true
+		}
+
+		t.Words = searchWords(raw)
+		if len(t.Words) == 0 {
+			continue
+		}
+		if len(t.Words) > 1 {
+			t.Phrase = true
+		}
+		if !t.Negate {
+			positive = true
+		}
+		q.Terms = append(q.Terms, t)
+	}
+
+	if !positive {
+		var errs ValidationErrors
+		errs.Add(Invalid("q", locale.GetUserLocalizer("en"), msgValidateSearchQuery))
+		return nil, NewValidationError("SearchQuery", msgInvalidSearchQuery, "", errs)
+	}
+	return q, nil
+}
+
+// TSQuery returns the to_tsquery text of the search query
+func (q *SearchQuery) TSQuery() string {
+	terms := make([]string, 0, len(q.Terms))
+	for _, t := range q.Terms {
+		words := make([]string, len(t.Words))
+		copy(words, t.Words)
+		if t.Prefix {
+			words[len(words)-1] += ":*"
+		}
+
+		term := strings.Join(words, " <-> ")
+		if len(words) > 1 {
+			term = "(" + term + ")"
+		}
+		if t.Negate {
+			term = "!" + term
+		}
+		terms = append(terms, term)
+	}
+	return strings.Join(terms, " & ")
+}
+
+// FuzzyText returns the words of the terms that are not negated, for the trigram similarity match
+func (q *SearchQuery) FuzzyText() string {
+	var words []string
+	for _, t := range q.Terms {
+		if !t.Negate {
+			words = append(words, t.Words...)
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// NegatedTSQuery returns the to_tsquery text that matches any of the negated terms, the empty string if there are none
+func (q *SearchQuery) NegatedTSQuery() string {
+	var terms []string
+	for _, t := range q.Terms {
+		if t.Negate {
+			positive := *t
+			positive.Negate = false
+			terms = append(terms, (&SearchQuery{Terms: []*SearchTerm{&positive}}).TSQuery())
+		}
+	}
+	return strings.Join(terms, " | ")
+}
+
+// splitSearchInput splits the input on the spaces outside the quotes, the quotes stay on their term
+func splitSearchInput(input string) []string {
+	var terms []string
+	var current strings.Builder
+	quoted := false
+
+	for _, r := range input {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			current.WriteRune(r)
+			if !quoted {
+				terms = append(terms, current.String())
+				current.Reset()
+			}
+		case unicode.IsSpace(r) && !quoted:
+			if current.Len() > 0 {
+				terms = append(terms, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if current.Len() > 0 {
+		terms = append(terms, current.String())
+	}
+	return terms
+}
+
+// searchWords returns the lowercase words of the term, every other character separates the words
+func searchWords(term string) []string {
+	return strings.FieldsFunc(strings.ToLower(term), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
@@ -0,0 +1,185 @@
+package model
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSearchQuery(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		wantTerms   []SearchTerm
+		wantTSQuery string
+		wantNegated string
+		wantFuzzy   string
+	}{
+		{
+			name:        "words",
+			input:       "Red  Shoes",
+			wantTerms:   []SearchTerm{{Words: []string{"red"}}, {Words: []string{"shoes"}}},
+			wantTSQuery: "red & shoes",
+			wantFuzzy:   "red shoes",
+		},
+		{
+			name:        "phrase and negated word",
+			input:       `"running shoes" -leather`,
+			wantTerms:   []SearchTerm{{Words: []string{"running", "shoes"}, Phrase: true}, {Words: []string{"leather"}, Negate: true}},
+			wantTSQuery: "(running <-> shoes) & !leather",
+			wantNegated: "leather",
+			wantFuzzy:   "running shoes",
+		},
+		{
+			name:        "prefix",
+			input:       "nik*",
+			wantTerms:   []SearchTerm{{Words: []string{"nik"}, Prefix: true}},
+			wantTSQuery: "nik:*",
+			wantFuzzy:   "nik",
+		},
+		{
+			name:        "negated phrase and prefix",
+			input:       `-"red shoes" blue*`,
+			wantTerms:   []SearchTerm{{Words: []string{"red", "shoes"}, Phrase: true, Negate: true}, {Words: []string{"blue"}, Prefix: true}},
+			wantTSQuery: "!(red <-> shoes) & blue:*",
+			wantNegated: "(red <-> shoes)",
+			wantFuzzy:   "blue",
+		},
+		{
+			name:        "separated word is the phrase",
+			input:       "t-shirt",
+			wantTerms:   []SearchTerm{{Words: []string{"t", "shirt"}, Phrase: true}},
+			wantTSQuery: "(t <-> shirt)",
+			wantFuzzy:   "t shirt",
+		},
+		{
+			name:        "tsquery operators are dropped",
+			input:       "foo's & bar | !baz:*",
+			wantTerms:   []SearchTerm{{Words: []string{"foo", "s"}, Phrase: true}, {Words: []string{"bar"}}, {Words: []string{"baz"}, Prefix: true}},
+			wantTSQuery: "(foo <-> s) & bar & baz:*",
+			wantFuzzy:   "foo s bar baz",
+		},
+		{
+			name:        "unicode words",
+			input:       "Čokolada",
+			wantTerms:   []SearchTerm{{Words: []string{"čokolada"}}},
+			wantTSQuery: "čokolada",
+			wantFuzzy:   "čokolada",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := ParseSearchQuery(tt.input)
+			if err != nil {
+				t.Fatalf("ParseSearchQuery(%q) error: %v", tt.input, err)
+			}
+
+			terms := make([]SearchTerm, len(q.Terms))
+			for i, term := range q.Terms {
+				terms[i] = *term
+			}
+			if !reflect.DeepEqual(terms, tt.wantTerms) {
+				t.Errorf("terms = %+v, want %+v", terms, tt.wantTerms)
+			}
+			if got := q.TSQuery(); got != tt.wantTSQuery {
+				t.Errorf("TSQuery() = %q, want %q", got, tt.wantTSQuery)
+			}
+			if got := q.NegatedTSQuery(); got != tt.wantNegated {
+				t.Errorf("NegatedTSQuery() = %q, want %q", got, tt.wantNegated)
+			}
+			if got := q.FuzzyText(); got != tt.wantFuzzy {
+				t.Errorf("FuzzyText() = %q, want %q", got, tt.wantFuzzy)
+			}
+		})
+	}
+}
+
+func TestParseSearchQueryInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"empty", ""},
+		{"spaces", "   "},
+		{"only negated", "-shoes -\"red boots\""},
+		{"no words", "!!! & | :*"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if q, err := ParseSearchQuery(tt.input); err == nil {
+				t.Errorf("ParseSearchQuery(%q) = %+v, want the error", tt.input, q)
+			}
+		})
+	}
+}
+
+func TestParseSearchQueryMaxTerms(t *testing.T) {
+	words := make([]string, searchQueryMaxTerms+4)
+	for i := range words {
+		words[i] = "word"
+	}
+
+	q, err := ParseSearchQuery(strings.Join(words, " "))
+	if err != nil {
+		t.Fatalf("ParseSearchQuery error: %v", err)
+	}
+	if len(q.Terms) != searchQueryMaxTerms {
+		t.Errorf("terms = %d, want %d", len(q.Terms), searchQueryMaxTerms)
+	}
+}
+
+func TestSplitSearchInput(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{"words", "red  shoes", []string{"red", "shoes"}},
+		{"phrase", `"running shoes" -leather`, []string{`"running shoes"`, "-leather"}},
+		{"negated phrase", `-"red shoes" blue*`, []string{`-"red shoes"`, "blue*"}},
+		{"phrase ends the term", `a"b c"d`, []string{`a"b c"`, "d"}},
+		{"unclosed phrase", `"running shoes`, []string{`"running shoes`}},
+		{"tabs and newlines", "red\tshoes\n", []string{"red", "shoes"}},
+		{"only spaces", " \t ", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitSearchInput(tt.input)
+			if len(got) == 0 && len(tt.want) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitSearchInput(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchWords(t *testing.T) {
+	tests := []struct {
+		term string
+		want []string
+	}{
+		{"Shoes", []string{"shoes"}},
+		{"T-Shirt's", []string{"t", "shirt", "s"}},
+		{`-"Red Shoes"`, []string{"red", "shoes"}},
+		{"nik*", []string{"nik"}},
+		{"Čokolada 2kg", []string{"čokolada", "2kg"}},
+		{"!&|:*", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.term, func(t *testing.T) {
+			got := searchWords(tt.term)
+			if len(got) == 0 && len(tt.want) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("searchWords(%q) = %q, want %q", tt.term, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package model
+
+import (
+	"encoding/json"
+	"strings"
+	"unicode/utf8"
+)
+
+// SuggestionType is the kind of the suggested search completion
+type SuggestionType string
+
+// suggestion types
+const (
+	SuggestionTypeProduct  SuggestionType = "product"
+	SuggestionTypeBrand    SuggestionType = "brand"
+	SuggestionTypeCategory SuggestionType = "category"
+	SuggestionTypeTag      SuggestionType = "tag"
+)
+
+// suggestion input limits, shorter inputs don't get suggestions and longer inputs are cut
+const (
+	SuggestionMinLength = 2
+	SuggestionMaxLength = 64
+)
+
+// SearchSuggestion is the type-ahead completion of the search input
+type SearchSuggestion struct {
+	Type SuggestionType `json:"type" db:"type"`
+	ID   int64          `json:"id" db:"id"`
+	Text string         `json:"text" db:"text"`
+	Slug string         `json:"slug" db:"slug"`
+}
+
+// NormalizeSuggestionInput returns the lowercase input with the collapsed spaces, cut to the max length
+// the empty string is returned for the inputs too short to suggest
+func NormalizeSuggestionInput(input string) string {
+	s := strings.ToLower(strings.Join(strings.Fields(input), " "))
+	if utf8.RuneCountInString(s) < SuggestionMinLength {
+		return ""
+	}
+	if r := []rune(s); len(r) > SuggestionMaxLength {
+		s = string(r[:SuggestionMaxLength])
+	}
+	return s
+}
+
+// SearchSuggestionsToJSON converts the suggestions to json string
+func SearchSuggestionsToJSON(s []*SearchSuggestion) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+// SearchSuggestionsFromJSON decodes the input and returns the suggestions
+func SearchSuggestionsFromJSON(data string) ([]*SearchSuggestion, error) {
+	var s []*SearchSuggestion
+	err := json.Unmarshal([]byte(data), &s)
+	return s, err
+}
@@ -0,0 +1,164 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/dankobgd/ecommerce-shop/utils/locale"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+// error msgs
+var (
+	msgInvalidStockAdjustment       = &i18n.Message{ID: "model.stock_adjustment.validate.app_error", Other: "invalid stock adjustment data"}
+	msgValidateStockAdjustQuantity  = &i18n.Message{ID: "model.stock_adjustment.validate.quantity.app_error", Other: "quantity change must not be 0"}
+	msgValidateStockAdjustReason    = &i18n.Message{ID: "model.stock_adjustment.validate.reason.app_error", Other: "reason must be restock or adjustment"}
+	msgValidateStockAdjustNote      = &i18n.Message{ID: "model.stock_adjustment.validate.note.app_error", Other: "note must be less than 1000 characters"}
+	msgValidateProductStockQuantity = &i18n.Message{ID: "model.product.validate.stock_quantity.app_error", Other: "stock quantity must not be negative"}
+	msgValidateItemOutOfStock       = &i18n.Message{ID: "model.order_request_data.validate.out_of_stock.app_error", Other: "product is out of stock"}
+	msgValidateItemNotEnoughStock   = &i18n.Message{ID: "model.order_request_data.validate.not_enough_stock.app_error", Other: "not enough products in stock"}
+	msgValidateItemQuantity         = &i18n.Message{ID: "model.order_request_data.validate.quantity.app_error", Other: "quantity must be greater than 0"}
+	msgValidateItemDuplicateVariant = &i18n.Message{ID: "model.order_request_data.validate.duplicate_variant.app_error", Other: "variant is ordered more than once"}
+)
+
+// stock movement reasons
+const (
+	StockMovementInitial    = "initial"
+	StockMovementRestock    = "restock"
+	StockMovementAdjustment = "adjustment"
+	StockMovementSale       = "sale"
+	StockMovementRelease    = "release"
+)
+
+// StockMovement is the ledger entry of the product stock change
+// positive quantity adds the stock, negative removes it
+type StockMovement struct {
+	TotalRecordsCount
+	ID        int64     `json:"id" db:"id"`
+	ProductID int64     `json:"product_id" db:"product_id"`
+	VariantID *int64    `json:"variant_id" db:"variant_id"`
+	SKU       string    `json:"sku" db:"sku"`
+	OrderID   *int64    `json:"order_id" db:"order_id"`
+	Quantity  int       `json:"quantity" db:"quantity"`
+	Reason    string    `json:"reason" db:"reason"`
+	Note      *string   `json:"note" db:"note"`
+	CreatedBy *int64    `json:"created_by" db:"created_by"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// PreSave fills the defaults
+func (m *StockMovement) PreSave() {
+	m.CreatedAt = time.Now()
+}
+
+// ProductStock is the current stock of the product variant
+type ProductStock struct {
+	ProductID     int64          `json:"product_id" db:"product_id"`
+	VariantID     int64          `json:"variant_id" db:"variant_id"`
+	SKU           string         `json:"sku" db:"sku"`
+	Options       VariantOptions `json:"options" db:"options"`
+	StockQuantity int            `json:"stock_quantity" db:"stock_quantity"`
+	InStock       bool           `json:"in_stock" db:"in_stock"`
+}
+
+// StockAdjustment is the manual stock change made by the admin
+// without the variant the default product variant is adjusted
+type StockAdjustment struct {
+	VariantID *int64 `json:"variant_id"`
+	Quantity  int    `json:"quantity"`
+	Reason    string `json:"reason"`
+	Note      string `json:"note"`
+}
+
+// StockAdjustmentFromJSON decodes the input and returns the StockAdjustment
+func StockAdjustmentFromJSON(data io.Reader) (*StockAdjustment, error) {
+	var sa *StockAdjustment
+	err := json.NewDecoder(data).Decode(&sa)
+	return sa, err
+}
+
+// Validate validates the stock adjustment and returns an error if it doesn't pass criteria
+func (sa *StockAdjustment) Validate() *AppErr {
+	var errs ValidationErrors
+	l := locale.GetUserLocalizer("en")
+
+	if sa.Quantity == 0 {
+		errs.Add(Invalid("quantity", l, msgValidateStockAdjustQuantity))
+	}
+	if sa.Reason != StockMovementRestock && sa.Reason != StockMovementAdjustment {
+		errs.Add(Invalid("reason", l, msgValidateStockAdjustReason))
+	}
+	if len(sa.Note) > 1000 {
+		errs.Add(Invalid("note", l, msgValidateStockAdjustNote))
+	}
+
+	if !errs.IsZero() {
+		return NewValidationError("StockAdjustment", msgInvalidStockAdjustment, "", errs)
+	}
+	return nil
+}
+
+// ValidateItems checks that every ordered line has the variant of its product and that it is in stock
+// variants[i] is the variant resolved for the data.Items[i], nil if it doesn't exist
+// the stock check is not authoritative, the stock is reserved atomically when the order is saved
+func (data *OrderRequestData) ValidateItems(variants []*ProductVariant) *AppErr {
+	return ValidateCartItems(data.Items, variants)
+}
+
+// ValidateCartItems validates the cart items against their variants, in the items order
+func ValidateCartItems(items []*CartItem, variants []*ProductVariant) *AppErr {
+	var errs ValidationErrors
+	l := locale.GetUserLocalizer("en")
+
+	seen := make(map[int64]bool, len(variants))
+	for i, item := range items {
+		if item.Quantity <= 0 {
+			errs.Add(Invalid(fmt.Sprintf("items[%d].quantity", i), l, msgValidateItemQuantity))
+			continue
+		}
+		v := variants[i]
+		if v == nil || v.ProductID != item.ProductID {
+			errs.Add(Invalid(fmt.Sprintf("items[%d].variant_id", i), l, msgValidateVariantNotInOrder))
+			continue
+		}
+		if seen[v.ID] {
+			errs.Add(Invalid(fmt.Sprintf("items[%d].variant_id", i), l, msgValidateItemDuplicateVariant))
+			continue
+		}
+		seen[v.ID] = true
+		if err := stockItemError(i, v.ID, item.Quantity, v.StockQuantity); err != nil {
+			errs.Add(err)
+		}
+	}
+
+	if !errs.IsZero() {
+		return NewValidationError("OrderRequestData", msgInvalidOrderData, "", errs)
+	}
+	return nil
+}
+
+// NewOutOfStockError returns the validation error for the order line that can't be reserved
+func NewOutOfStockError(variantID int64, quantity, available int) *AppErr {
+	var errs ValidationErrors
+	errs.Add(stockItemError(-1, variantID, quantity, available))
+	return NewValidationError("OrderRequestData", msgInvalidOrderData, "", errs)
+}
+
+func stockItemError(index int, variantID int64, quantity, available int) *FieldError {
+	if quantity <= available {
+		return nil
+	}
+
+	field := fmt.Sprintf("items[variant_id=%d]", variantID)
+	if index >= 0 {
+		field = fmt.Sprintf("items[%d]", index)
+	}
+
+	l := locale.GetUserLocalizer("en")
+	if available <= 0 {
+		return Invalid(field, l, msgValidateItemOutOfStock)
+	}
+	return Invalid(field, l, msgValidateItemNotEnoughStock)
+}
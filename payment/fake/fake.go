@@ -0,0 +1,233 @@
+package fake
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/dankobgd/ecommerce-shop/model"
+	"github.com/dankobgd/ecommerce-shop/payment"
+)
+
+// Outcome is the scripted result of the fake charge
+type Outcome string
+
+// charge outcomes
+const (
+	OutcomeSucceed        Outcome = "succeed"
+	OutcomeDecline        Outcome = "decline"
+	OutcomeRequiresAction Outcome = "requires_action"
+	OutcomeError          Outcome = "error"
+)
+
+// payment method ids that always produce the same outcome, similar to the stripe test cards
+const (
+	MethodSucceed        = "pm_fake_succeed"
+	MethodDecline        = "pm_fake_decline"
+	MethodRequiresAction = "pm_fake_requires_action"
+	MethodError          = "pm_fake_error"
+)
+
+// DefaultDeclineCode is used for the declines that don't specify the code
+const DefaultDeclineCode = "generic_decline"
+
+type intent struct {
+	id       string
+	amount   uint64
+	refunded uint64
+	status   payment.ChargeStatus
+}
+
+// Provider is the in-process payment provider which never touches the network
+type Provider struct {
+	mu             sync.Mutex
+	seq            int
+	defaultOutcome Outcome
+	webhookSecret  string
+	script         []Outcome
+	intents        map[string]*intent
+	idempotent     map[string]string
+}
+
+// NewPaymentProvider returns the fake payment provider
+// defaultOutcome is used when there is no scripted outcome and the payment method is not a known fake one
+func NewPaymentProvider(defaultOutcome, webhookSecret string) (*Provider, error) {
+	outcome := Outcome(defaultOutcome)
+	if outcome == "" {
+		outcome = OutcomeSucceed
+	}
+	if !outcome.IsValid() {
+		return nil, fmt.Errorf("unknown fake payment outcome: %q", defaultOutcome)
+	}
+
+	p := &Provider{
+		defaultOutcome: outcome,
+		webhookSecret:  webhookSecret,
+		intents:        make(map[string]*intent),
+		idempotent:     make(map[string]string),
+	}
+
+	return p, nil
+}
+
+// IsValid returns true if the outcome is one of the known outcomes
+func (o Outcome) IsValid() bool {
+	switch o {
+	case OutcomeSucceed, OutcomeDecline, OutcomeRequiresAction, OutcomeError:
+		return true
+	}
+	return false
+}
+
+// Script queues the outcomes for the next charges and confirmations, in order
+func (p *Provider) Script(outcomes ...Outcome) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.script = append(p.script, outcomes...)
+}
+
+// Name returns the provider name
+func (p *Provider) Name() string {
+	return "Fake"
+}
+
+// Charge charges the fake card. The outcome is taken from the script first,
+// then from the payment method id and lastly from the configured default.
+// Decline codes can be given as "pm_fake_decline:insufficient_funds"
+// the charge with the already used idempotency key returns the same payment without charging again
+func (p *Provider) Charge(paymentID string, order *model.Order, user *model.User, amount uint64, currency, idempotencyKey string) (*payment.ChargeResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if id, ok := p.idempotent[idempotencyKey]; ok {
+		in := p.intents[id]
+		res := &payment.ChargeResult{ChargeID: in.id, Status: in.status}
+		if in.status == payment.ChargeStatusRequiresAction {
+			res.ClientSecret = fmt.Sprintf("%s_secret_fake", in.id)
+		} else {
+			res.ReceiptURL = receiptURL(in.id)
+		}
+		return res, nil
+	}
+
+	outcome, declineCode := p.nextOutcome(paymentID)
+
+	switch outcome {
+	case OutcomeDecline:
+		return nil, &payment.Error{Provider: p.Name(), Code: payment.ErrCodeCardDeclined, DeclineCode: declineCode, Message: "Your card was declined."}
+	case OutcomeError:
+		return nil, &payment.Error{Provider: p.Name(), Code: payment.ErrCodeProcessing, Message: "An error occurred while processing your card."}
+	}
+
+	p.seq++
+	in := &intent{
+		id:     fmt.Sprintf("pi_fake_%d", p.seq),
+		amount: amount,
+		status: payment.ChargeStatusSucceeded,
+	}
+
+	res := &payment.ChargeResult{ChargeID: in.id}
+	if outcome == OutcomeRequiresAction {
+		in.status = payment.ChargeStatusRequiresAction
+		res.ClientSecret = fmt.Sprintf("%s_secret_fake", in.id)
+	} else {
+		res.ReceiptURL = receiptURL(in.id)
+	}
+	res.Status = in.status
+	p.intents[in.id] = in
+	if idempotencyKey != "" {
+		p.idempotent[idempotencyKey] = in.id
+	}
+
+	return res, nil
+}
+
+// Refund refunds the amount of the fake charge
+func (p *Provider) Refund(paymentID string, amount uint64, currency string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	in, ok := p.intents[paymentID]
+	if !ok {
+		return "", &payment.Error{Provider: p.Name(), Code: payment.ErrCodeInvalid, Message: fmt.Sprintf("No such payment: %s", paymentID)}
+	}
+	if in.status != payment.ChargeStatusSucceeded {
+		return "", &payment.Error{Provider: p.Name(), Code: payment.ErrCodeInvalid, Message: "Payment has not succeeded"}
+	}
+	if in.refunded+amount > in.amount {
+		return "", &payment.Error{Provider: p.Name(), Code: payment.ErrCodeInvalid, Message: "Refund amount is greater than the unrefunded amount"}
+	}
+
+	in.refunded += amount
+	p.seq++
+
+	return fmt.Sprintf("re_fake_%d", p.seq), nil
+}
+
+// Confirm completes the authentication of the fake charge that requires action
+// the scripted outcome is the result of the authentication: decline fails the payment the same way the failed 3DS does,
+// requires action leaves it unauthenticated and error is the processing error, without the script it succeeds
+// confirming the settled payment is the invalid request, the same as in stripe
+func (p *Provider) Confirm(paymentID string) (*payment.ChargeResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	in, ok := p.intents[paymentID]
+	if !ok {
+		return nil, &payment.Error{Provider: p.Name(), Code: payment.ErrCodeInvalid, Message: fmt.Sprintf("No such payment: %s", paymentID)}
+	}
+	if in.status != payment.ChargeStatusRequiresAction {
+		return nil, &payment.Error{Provider: p.Name(), Code: payment.ErrCodeInvalid, Message: fmt.Sprintf("You cannot confirm this payment because it has a status of %s", in.status)}
+	}
+
+	outcome := OutcomeSucceed
+	if len(p.script) > 0 {
+		outcome = p.script[0]
+		p.script = p.script[1:]
+	}
+
+	res := &payment.ChargeResult{ChargeID: in.id}
+	switch outcome {
+	case OutcomeError:
+		return nil, &payment.Error{Provider: p.Name(), Code: payment.ErrCodeProcessing, Message: "An error occurred while processing your card."}
+	case OutcomeDecline:
+		in.status = payment.ChargeStatusFailed
+	case OutcomeRequiresAction:
+		res.ClientSecret = fmt.Sprintf("%s_secret_fake", in.id)
+	default:
+		in.status = payment.ChargeStatusSucceeded
+		res.ReceiptURL = receiptURL(in.id)
+	}
+	res.Status = in.status
+	return res, nil
+}
+
+func (p *Provider) nextOutcome(paymentID string) (Outcome, string) {
+	method, declineCode := paymentID, DefaultDeclineCode
+	if i := strings.Index(paymentID, ":"); i != -1 {
+		method, declineCode = paymentID[:i], paymentID[i+1:]
+	}
+
+	if len(p.script) > 0 {
+		outcome := p.script[0]
+		p.script = p.script[1:]
+		return outcome, declineCode
+	}
+
+	switch method {
+	case MethodSucceed:
+		return OutcomeSucceed, declineCode
+	case MethodDecline:
+		return OutcomeDecline, declineCode
+	case MethodRequiresAction:
+		return OutcomeRequiresAction, declineCode
+	case MethodError:
+		return OutcomeError, declineCode
+	}
+
+	return p.defaultOutcome, declineCode
+}
+
+func receiptURL(id string) string {
+	return fmt.Sprintf("http://localhost:3001/fake-receipts/%s", id)
+}
@@ -0,0 +1,128 @@
+package fake
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dankobgd/ecommerce-shop/payment"
+)
+
+// SignatureHeader is the header carrying the fake webhook signature
+const SignatureHeader = "Fake-Signature"
+
+// signatureTolerance is the max age of the signed payload
+const signatureTolerance = 5 * time.Minute
+
+// WebhookPayload is the body of the fake webhook, the type is one of the payment.EventType values
+type WebhookPayload struct {
+	ID             string `json:"id"`
+	Type           string `json:"type"`
+	ChargeID       string `json:"charge_id"`
+	OrderID        int64  `json:"order_id,omitempty"`
+	ReceiptURL     string `json:"receipt_url,omitempty"`
+	RefundID       string `json:"refund_id,omitempty"`
+	AmountRefunded uint64 `json:"amount_refunded,omitempty"`
+	Reason         string `json:"reason,omitempty"`
+}
+
+// SignPayload returns the signature header value for the payload, used to send the locally signed fixtures
+func SignPayload(secret string, payload []byte, t time.Time) string {
+	return fmt.Sprintf("t=%d,v1=%s", t.Unix(), hex.EncodeToString(computeSignature(secret, payload, t)))
+}
+
+// WebhookEnabled returns true if the webhook secret is set, the fake webhook is off without it
+func (p *Provider) WebhookEnabled() bool {
+	return p.webhookSecret != ""
+}
+
+// ParseWebhook verifies the signature and converts the payload to the payment event
+func (p *Provider) ParseWebhook(payload []byte, header http.Header) (*payment.Event, error) {
+	if !p.WebhookEnabled() {
+		return nil, payment.ErrInvalidSignature
+	}
+	if err := p.verifySignature(payload, header.Get(SignatureHeader)); err != nil {
+		return nil, err
+	}
+
+	var wp WebhookPayload
+	if err := json.Unmarshal(payload, &wp); err != nil {
+		return nil, err
+	}
+
+	e := &payment.Event{
+		ID:             wp.ID,
+		Provider:       p.Name(),
+		Type:           payment.EventType(wp.Type),
+		ProviderType:   wp.Type,
+		ChargeID:       wp.ChargeID,
+		OrderID:        wp.OrderID,
+		ReceiptURL:     wp.ReceiptURL,
+		RefundID:       wp.RefundID,
+		AmountRefunded: wp.AmountRefunded,
+		Reason:         wp.Reason,
+		Payload:        payload,
+	}
+
+	switch e.Type {
+	case payment.EventPaymentSucceeded, payment.EventPaymentFailed, payment.EventChargeRefunded, payment.EventDisputeOpened, payment.EventDisputeClosed:
+	default:
+		e.Type = payment.EventUnhandled
+	}
+
+	return e, nil
+}
+
+func (p *Provider) verifySignature(payload []byte, header string) error {
+	var ts int64
+	var sig []byte
+
+	for _, part := range strings.Split(header, ",") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return payment.ErrInvalidSignature
+		}
+		switch kv[0] {
+		case "t":
+			n, err := strconv.ParseInt(kv[1], 10, 64)
+			if err != nil {
+				return payment.ErrInvalidSignature
+			}
+			ts = n
+		case "v1":
+			b, err := hex.DecodeString(kv[1])
+			if err != nil {
+				return payment.ErrInvalidSignature
+			}
+			sig = b
+		}
+	}
+
+	if ts == 0 || sig == nil {
+		return payment.ErrInvalidSignature
+	}
+
+	t := time.Unix(ts, 0)
+	if time.Since(t) > signatureTolerance {
+		return payment.ErrInvalidSignature
+	}
+	if !hmac.Equal(sig, computeSignature(p.webhookSecret, payload, t)) {
+		return payment.ErrInvalidSignature
+	}
+
+	return nil
+}
+
+func computeSignature(secret string, payload []byte, t time.Time) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(fmt.Sprintf("%d", t.Unix())))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
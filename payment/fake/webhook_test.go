@@ -0,0 +1,79 @@
+package fake
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/dankobgd/ecommerce-shop/payment"
+)
+
+func TestParseWebhook(t *testing.T) {
+	const secret = "whsec_test"
+	payload := []byte(`{"id":"evt_1","type":"payment_succeeded","charge_id":"ch_1","order_id":7}`)
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		secret  string
+		header  string
+		payload []byte
+		wantErr bool
+	}{
+		{"valid signature", secret, SignPayload(secret, payload, now), payload, false},
+		{"other secret", secret, SignPayload("whsec_other", payload, now), payload, true},
+		{"changed payload", secret, SignPayload(secret, payload, now), []byte(`{"id":"evt_1","type":"payment_succeeded","charge_id":"ch_2","order_id":7}`), true},
+		{"expired signature", secret, SignPayload(secret, payload, now.Add(-signatureTolerance-time.Minute)), payload, true},
+		{"missing header", secret, "", payload, true},
+		{"missing timestamp", secret, "v1=00ff", payload, true},
+		{"bad timestamp", secret, "t=abc,v1=00ff", payload, true},
+		{"bad signature encoding", secret, "t=1,v1=xyz", payload, true},
+		{"part without value", secret, "t", payload, true},
+		{"webhook disabled", "", SignPayload("", payload, now), payload, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewPaymentProvider("", tt.secret)
+			if err != nil {
+				t.Fatalf("NewPaymentProvider error: %v", err)
+			}
+
+			h := http.Header{}
+			h.Set(SignatureHeader, tt.header)
+			e, err := p.ParseWebhook(tt.payload, h)
+			if tt.wantErr {
+				if err != payment.ErrInvalidSignature {
+					t.Errorf("ParseWebhook error = %v, want %v", err, payment.ErrInvalidSignature)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseWebhook error: %v", err)
+			}
+			if e.ID != "evt_1" || e.Type != payment.EventPaymentSucceeded || e.ChargeID != "ch_1" || e.OrderID != 7 {
+				t.Errorf("event = %+v, want the payment_succeeded event of the order 7", e)
+			}
+		})
+	}
+}
+
+func TestParseWebhookUnhandledType(t *testing.T) {
+	const secret = "whsec_test"
+	payload := []byte(`{"id":"evt_2","type":"customer.created"}`)
+
+	p, err := NewPaymentProvider("", secret)
+	if err != nil {
+		t.Fatalf("NewPaymentProvider error: %v", err)
+	}
+	h := http.Header{}
+	h.Set(SignatureHeader, SignPayload(secret, payload, time.Now()))
+
+	e, err := p.ParseWebhook(payload, h)
+	if err != nil {
+		t.Fatalf("ParseWebhook error: %v", err)
+	}
+	if e.Type != payment.EventUnhandled || e.ProviderType != "customer.created" {
+		t.Errorf("event type = %q (%q), want %q", e.Type, e.ProviderType, payment.EventUnhandled)
+	}
+}
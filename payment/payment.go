@@ -1,14 +1,119 @@
 package payment
 
 import (
+	"errors"
+	"fmt"
+	"net/http"
+
 	"github.com/dankobgd/ecommerce-shop/model"
-	"github.com/stripe/stripe-go"
 )
 
+// ChargeStatus is the provider neutral status of the charge
+type ChargeStatus string
+
+// charge statuses
+const (
+	ChargeStatusSucceeded      ChargeStatus = "succeeded"
+	ChargeStatusRequiresAction ChargeStatus = "requires_action"
+	ChargeStatusProcessing     ChargeStatus = "processing"
+	ChargeStatusFailed         ChargeStatus = "failed"
+)
+
+// ChargeResult is the provider neutral result of the charge
+type ChargeResult struct {
+	ChargeID     string       `json:"charge_id"`
+	Status       ChargeStatus `json:"status"`
+	ReceiptURL   string       `json:"receipt_url"`
+	ClientSecret string       `json:"client_secret,omitempty"`
+}
+
+// RequiresAction returns true if the customer has to authenticate the charge (3DS etc...)
+func (r *ChargeResult) RequiresAction() bool {
+	return r.Status == ChargeStatusRequiresAction
+}
+
+// Succeeded returns true if the charge went through
+func (r *ChargeResult) Succeeded() bool {
+	return r.Status == ChargeStatusSucceeded
+}
+
+// error codes
+const (
+	ErrCodeCardDeclined = "card_declined"
+	ErrCodeProcessing   = "processing_error"
+	ErrCodeInvalid      = "invalid_request"
+)
+
+// Error is the provider neutral payment error
+type Error struct {
+	Provider    string `json:"provider"`
+	Code        string `json:"code"`
+	DeclineCode string `json:"decline_code,omitempty"`
+	Message     string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	if e.DeclineCode != "" {
+		return fmt.Sprintf("%s: %s (decline code: %s)", e.Provider, e.Message, e.DeclineCode)
+	}
+	return fmt.Sprintf("%s: %s", e.Provider, e.Message)
+}
+
+// IsCardError returns true if the card was declined
+func (e *Error) IsCardError() bool {
+	return e.Code == ErrCodeCardDeclined
+}
+
+// EventType is the provider neutral webhook event type
+type EventType string
+
+// webhook event types
+const (
+	EventPaymentSucceeded EventType = "payment_succeeded"
+	EventPaymentFailed    EventType = "payment_failed"
+	EventChargeRefunded   EventType = "charge_refunded"
+	EventDisputeOpened    EventType = "dispute_opened"
+	EventDisputeClosed    EventType = "dispute_closed"
+	EventUnhandled        EventType = "unhandled"
+)
+
+// ErrInvalidSignature is returned when the webhook payload is not signed by the provider
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// Event is the provider neutral asynchronous payment event
+// AmountRefunded is the total refunded amount of the charge, not only the latest refund
+type Event struct {
+	ID             string    `json:"id"`
+	Provider       string    `json:"provider"`
+	Type           EventType `json:"type"`
+	ProviderType   string    `json:"provider_type"`
+	ChargeID       string    `json:"charge_id"`
+	OrderID        int64     `json:"order_id,omitempty"`
+	ReceiptURL     string    `json:"receipt_url,omitempty"`
+	RefundID       string    `json:"refund_id,omitempty"`
+	AmountRefunded uint64    `json:"amount_refunded,omitempty"`
+	Reason         string    `json:"reason,omitempty"`
+	Payload        []byte    `json:"-"`
+}
+
+// WebhookToggle is implemented by the providers that can run without the webhook
+type WebhookToggle interface {
+	WebhookEnabled() bool
+}
+
+// WebhookEnabled returns true if the provider accepts the webhook events
+func WebhookEnabled(p Provider) bool {
+	if t, ok := p.(WebhookToggle); ok {
+		return t.WebhookEnabled()
+	}
+	return true
+}
+
 // Provider is the payment processor service
 type Provider interface {
 	Name() string
-	Charge(paymentID string, order *model.Order, user *model.User, amount uint64, currency string) (*stripe.PaymentIntent, error)
+	Charge(paymentID string, order *model.Order, user *model.User, amount uint64, currency, idempotencyKey string) (*ChargeResult, error)
 	Refund(paymentID string, amount uint64, currency string) (string, error)
-	Confirm(paymentID string) error
+	Confirm(paymentID string) (*ChargeResult, error)
+	ParseWebhook(payload []byte, header http.Header) (*Event, error)
 }
@@ -1,23 +1,39 @@
 package stripe
 
 import (
-	"errors"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"strconv"
 
 	"github.com/dankobgd/ecommerce-shop/model"
 	"github.com/dankobgd/ecommerce-shop/payment"
+	"github.com/dankobgd/ecommerce-shop/utils/locale"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
 	"github.com/stripe/stripe-go"
 	"github.com/stripe/stripe-go/client"
+	"github.com/stripe/stripe-go/webhook"
+)
+
+var (
+	msgWebhookSecretMissing = &i18n.Message{ID: "payment.stripe.new_payment_provider.webhook_secret.app_error", Other: "stripe webhook secret is required"}
 )
 
 type stripePaymentProvider struct {
-	client *client.API
+	client        *client.API
+	webhookSecret string
 }
 
 // NewPaymentProvider returns the stripe payment provider
-func NewPaymentProvider(secretKey string) (payment.Provider, *model.AppErr) {
+// the webhook secret is required, without it anyone could sign the webhook events
+func NewPaymentProvider(secretKey, webhookSecret string) (payment.Provider, *model.AppErr) {
+	if webhookSecret == "" {
+		return nil, model.NewAppErr("NewPaymentProvider", model.ErrInvalid, locale.GetUserLocalizer("en"), msgWebhookSecretMissing, http.StatusInternalServerError, nil)
+	}
+
 	s := &stripePaymentProvider{
-		client: &client.API{},
+		client:        &client.API{},
+		webhookSecret: webhookSecret,
 	}
 
 	s.client.Init(secretKey, nil)
@@ -29,34 +45,105 @@ func (sp *stripePaymentProvider) Name() string {
 	return "Stripe"
 }
 
-func (sp *stripePaymentProvider) Charge(paymentID string, order *model.Order, user *model.User, amount uint64, currency string) (*stripe.PaymentIntent, error) {
-	return sp.chargePaymentIntent(paymentID, amount, currency, order, user)
+func (sp *stripePaymentProvider) Charge(paymentID string, order *model.Order, user *model.User, amount uint64, currency, idempotencyKey string) (*payment.ChargeResult, error) {
+	intent, err := sp.chargePaymentIntent(paymentID, amount, currency, order, user, idempotencyKey)
+	if err != nil {
+		return nil, sp.toPaymentError(err)
+	}
+	return toChargeResult(intent), nil
 }
 
-func (sp *stripePaymentProvider) Refund(transactionID string, amount uint64, currency string) (string, error) {
+func (sp *stripePaymentProvider) Refund(paymentIntentID string, amount uint64, currency string) (string, error) {
 	stripeAmount := int64(amount)
 	ref, err := sp.client.Refunds.New(&stripe.RefundParams{
-		Charge: &transactionID,
-		Amount: &stripeAmount,
+		PaymentIntent: &paymentIntentID,
+		Amount:        &stripeAmount,
 	})
 	if err != nil {
-		return "", err
+		return "", sp.toPaymentError(err)
 	}
 
-	return ref.ID, err
+	return ref.ID, nil
 }
 
-func (sp *stripePaymentProvider) Confirm(paymentID string) error {
-	_, err := sp.client.PaymentIntents.Confirm(paymentID, nil)
+// Confirm gets the result of the intent after the customer authentication, the intent is created with the confirmation
+// so after the 3DS it is usually already settled and only the intent that still requires the confirmation is confirmed
+func (sp *stripePaymentProvider) Confirm(paymentID string) (*payment.ChargeResult, error) {
+	intent, err := sp.client.PaymentIntents.Get(paymentID, nil)
+	if err != nil {
+		return nil, sp.toPaymentError(err)
+	}
+	if intent.Status != stripe.PaymentIntentStatusRequiresConfirmation {
+		return toChargeResult(intent), nil
+	}
 
-	if stripeErr, ok := err.(*stripe.Error); ok {
-		return errors.New(stripeErr.Msg)
+	intent, err = sp.client.PaymentIntents.Confirm(paymentID, nil)
+	if err != nil {
+		return nil, sp.toPaymentError(err)
 	}
+	return toChargeResult(intent), nil
+}
 
-	return err
+func (sp *stripePaymentProvider) ParseWebhook(payload []byte, header http.Header) (*payment.Event, error) {
+	se, err := webhook.ConstructEvent(payload, header.Get("Stripe-Signature"), sp.webhookSecret)
+	if err != nil {
+		return nil, payment.ErrInvalidSignature
+	}
+
+	e := &payment.Event{
+		ID:           se.ID,
+		Provider:     sp.Name(),
+		Type:         payment.EventUnhandled,
+		ProviderType: se.Type,
+		Payload:      payload,
+	}
+
+	switch se.Type {
+	case "payment_intent.succeeded", "payment_intent.payment_failed":
+		var intent stripe.PaymentIntent
+		if err := json.Unmarshal(se.Data.Raw, &intent); err != nil {
+			return nil, err
+		}
+		e.ChargeID = intent.ID
+		e.OrderID, _ = strconv.ParseInt(intent.Metadata["order_id"], 10, 64)
+		e.ReceiptURL = toChargeResult(&intent).ReceiptURL
+		e.Type = payment.EventPaymentSucceeded
+		if se.Type == "payment_intent.payment_failed" {
+			e.Type = payment.EventPaymentFailed
+			if intent.LastPaymentError != nil {
+				e.Reason = intent.LastPaymentError.Msg
+			}
+		}
+	case "charge.refunded":
+		var ch stripe.Charge
+		if err := json.Unmarshal(se.Data.Raw, &ch); err != nil {
+			return nil, err
+		}
+		e.Type = payment.EventChargeRefunded
+		e.ChargeID = ch.PaymentIntent
+		e.AmountRefunded = uint64(ch.AmountRefunded)
+		if ch.Refunds != nil && len(ch.Refunds.Data) > 0 {
+			e.RefundID = ch.Refunds.Data[0].ID
+		}
+	case "charge.dispute.created", "charge.dispute.closed":
+		var d stripe.Dispute
+		if err := json.Unmarshal(se.Data.Raw, &d); err != nil {
+			return nil, err
+		}
+		e.Type = payment.EventDisputeOpened
+		if se.Type == "charge.dispute.closed" {
+			e.Type = payment.EventDisputeClosed
+		}
+		if d.PaymentIntent != nil {
+			e.ChargeID = d.PaymentIntent.ID
+		}
+		e.Reason = fmt.Sprintf("%s (%s)", d.Reason, d.Status)
+	}
+
+	return e, nil
 }
 
-func (sp *stripePaymentProvider) chargePaymentIntent(paymentMethodID string, amount uint64, currency string, order *model.Order, user *model.User) (*stripe.PaymentIntent, error) {
+func (sp *stripePaymentProvider) chargePaymentIntent(paymentMethodID string, amount uint64, currency string, order *model.Order, user *model.User, idempotencyKey string) (*stripe.PaymentIntent, error) {
 	params := &stripe.PaymentIntentParams{
 		PaymentMethodTypes: []*string{
 			stripe.String("card"),
@@ -67,20 +154,57 @@ func (sp *stripePaymentProvider) chargePaymentIntent(paymentMethodID string, amo
 		Shipping:      prepareShippingAddress(order, user),
 		Confirm:       stripe.Bool(true),
 	}
-	intent, err := sp.client.PaymentIntents.New(params)
-	if err != nil {
-		return nil, err
+	// the order id lets the webhook match the intent whose charge result never reached the checkout
+	params.AddMetadata("order_id", strconv.FormatInt(order.ID, 10))
+	// the retried request gets the same intent instead of the second charge
+	if idempotencyKey != "" {
+		params.SetIdempotencyKey(idempotencyKey)
+	}
+
+	return sp.client.PaymentIntents.New(params)
+}
+
+// toPaymentError maps the stripe errors to the provider neutral error
+func (sp *stripePaymentProvider) toPaymentError(err error) *payment.Error {
+	pErr := &payment.Error{Provider: sp.Name(), Code: payment.ErrCodeProcessing, Message: err.Error()}
+
+	stripeErr, ok := err.(*stripe.Error)
+	if !ok {
+		return pErr
+	}
+
+	pErr.Message = stripeErr.Msg
+	if stripeErr.Type == stripe.ErrorTypeInvalidRequest {
+		pErr.Code = payment.ErrCodeInvalid
 	}
+	if cardErr, ok := stripeErr.Err.(*stripe.CardError); ok {
+		pErr.Code = payment.ErrCodeCardDeclined
+		pErr.DeclineCode = string(cardErr.DeclineCode)
+	}
+
+	return pErr
+}
 
-	if intent.Status == stripe.PaymentIntentStatusRequiresAction {
-		return intent, fmt.Errorf("PaymentIntent status %s", intent.ClientSecret)
+func toChargeResult(intent *stripe.PaymentIntent) *payment.ChargeResult {
+	res := &payment.ChargeResult{ChargeID: intent.ID}
+
+	switch intent.Status {
+	case stripe.PaymentIntentStatusSucceeded:
+		res.Status = payment.ChargeStatusSucceeded
+	case stripe.PaymentIntentStatusRequiresAction:
+		res.Status = payment.ChargeStatusRequiresAction
+		res.ClientSecret = intent.ClientSecret
+	case stripe.PaymentIntentStatusProcessing:
+		res.Status = payment.ChargeStatusProcessing
+	default:
+		res.Status = payment.ChargeStatusFailed
 	}
 
-	if intent.Status == stripe.PaymentIntentStatusSucceeded {
-		return intent, nil
+	if intent.Charges != nil && len(intent.Charges.Data) > 0 {
+		res.ReceiptURL = intent.Charges.Data[0].ReceiptURL
 	}
 
-	return nil, fmt.Errorf("Invalid PaymentIntent status: %s", intent.Status)
+	return res
 }
 
 func prepareShippingAddress(order *model.Order, user *model.User) *stripe.ShippingDetailsParams {
@@ -1,7 +1,9 @@
 package postgres
 
 import (
+	"database/sql"
 	"net/http"
+	"strconv"
 
 	"github.com/dankobgd/ecommerce-shop/model"
 	"github.com/dankobgd/ecommerce-shop/store"
@@ -29,11 +31,14 @@ var (
 	msgGetCategories            = &i18n.Message{ID: "store.postgres.category.get.app_error", Other: "could not get categories"}
 	msgDeleteCategory           = &i18n.Message{ID: "store.postgres.category.delete.app_error", Other: "could not delete category"}
 	msgBulkDeleteCategories     = &i18n.Message{ID: "store.postgres.category.delete.app_error", Other: "could not bulk delete categories"}
+	msgCategoryNotFound         = &i18n.Message{ID: "store.postgres.category.get.not_found.app_error", Other: "category not found"}
+	msgMoveCategory             = &i18n.Message{ID: "store.postgres.category.move.app_error", Other: "could not move category"}
+	msgMoveCategoryCycle        = &i18n.Message{ID: "store.postgres.category.move.cycle.app_error", Other: "category can't be moved under itself or its descendant"}
 )
 
 // BulkInsert inserts multiple categories in the db
 func (s PgCategoryStore) BulkInsert(categories []*model.Category) *model.AppErr {
-	q := `INSERT INTO public.category(name, slug, logo, logo_public_id, description, is_featured, properties, created_at, updated_at) VALUES(:name, :slug, :logo, :logo_public_id, :description, :is_featured, :properties, :created_at, :updated_at) RETURNING id`
+	q := `INSERT INTO public.category(parent_id, name, slug, logo, logo_public_id, description, is_featured, properties, created_at, updated_at) VALUES(:parent_id, :name, :slug, :logo, :logo_public_id, :description, :is_featured, :properties, :created_at, :updated_at) RETURNING id, path, depth`
 
 	if _, err := s.db.NamedExec(q, categories); err != nil {
 		return model.NewAppErr("PgCategoryStore.BulkInsert", model.ErrInternal, locale.GetUserLocalizer("en"), msgBulkInsertCategories, http.StatusInternalServerError, nil)
@@ -43,7 +48,7 @@ func (s PgCategoryStore) BulkInsert(categories []*model.Category) *model.AppErr
 
 // Save inserts the new category in the db
 func (s PgCategoryStore) Save(category *model.Category) (*model.Category, *model.AppErr) {
-	q := `INSERT INTO public.category(name, slug, logo, logo_public_id, description, is_featured, properties, created_at, updated_at) VALUES(:name, :slug, :logo, :logo_public_id, :description, :is_featured, :properties, :created_at, :updated_at) RETURNING id`
+	q := `INSERT INTO public.category(parent_id, name, slug, logo, logo_public_id, description, is_featured, properties, created_at, updated_at) VALUES(:parent_id, :name, :slug, :logo, :logo_public_id, :description, :is_featured, :properties, :created_at, :updated_at) RETURNING id, path, depth`
 
 	var id int64
 	rows, err := s.db.NamedQuery(q, category)
@@ -52,7 +57,7 @@ func (s PgCategoryStore) Save(category *model.Category) (*model.Category, *model
 	}
 	defer rows.Close()
 	for rows.Next() {
-		rows.Scan(&id)
+		rows.Scan(&id, &category.Path, &category.Depth)
 	}
 	if err := rows.Err(); err != nil {
 		if IsUniqueConstraintViolationError(err) {
@@ -83,6 +88,24 @@ func (s PgCategoryStore) Get(id int64) (*model.Category, *model.AppErr) {
 	return &category, nil
 }
 
+// ListBySlugs returns the categories with the given slugs
+func (s PgCategoryStore) ListBySlugs(slugs []string) ([]*model.Category, *model.AppErr) {
+	var categories = make([]*model.Category, 0)
+	if len(slugs) == 0 {
+		return categories, nil
+	}
+
+	q, args, err := sqlx.In(`SELECT * FROM public.category WHERE slug IN (?)`, slugs)
+	if err != nil {
+		return nil, model.NewAppErr("PgCategoryStore.ListBySlugs", model.ErrInternal, locale.GetUserLocalizer("en"), msgGetCategories, http.StatusInternalServerError, nil)
+	}
+	if err := s.db.Select(&categories, s.db.Rebind(q), args...); err != nil {
+		return nil, model.NewAppErr("PgCategoryStore.ListBySlugs", model.ErrInternal, locale.GetUserLocalizer("en"), msgGetCategories, http.StatusInternalServerError, nil)
+	}
+
+	return categories, nil
+}
+
 // GetAll returns all categories
 func (s PgCategoryStore) GetAll(limit, offset int) ([]*model.Category, *model.AppErr) {
 	var categories = make([]*model.Category, 0)
@@ -103,6 +126,109 @@ func (s PgCategoryStore) GetFeatured(limit, offset int) ([]*model.Category, *mod
 	return categories, nil
 }
 
+// GetTree returns all categories ordered by the depth and the name, for building the tree
+func (s PgCategoryStore) GetTree() ([]*model.Category, *model.AppErr) {
+	var categories = make([]*model.Category, 0)
+	if err := s.db.Select(&categories, `SELECT * FROM public.category ORDER BY depth ASC, name ASC, id ASC`); err != nil {
+		return nil, model.NewAppErr("PgCategoryStore.GetTree", model.ErrInternal, locale.GetUserLocalizer("en"), msgGetCategories, http.StatusInternalServerError, nil)
+	}
+	return categories, nil
+}
+
+// GetSubtree returns the category and all its descendants ordered by the depth and the name
+func (s PgCategoryStore) GetSubtree(id int64) ([]*model.Category, *model.AppErr) {
+	q := `SELECT d.* FROM public.category r JOIN public.category d ON d.path LIKE r.path || '%' WHERE r.id = $1 ORDER BY d.depth ASC, d.name ASC, d.id ASC`
+
+	var categories = make([]*model.Category, 0)
+	if err := s.db.Select(&categories, q, id); err != nil {
+		return nil, model.NewAppErr("PgCategoryStore.GetSubtree", model.ErrInternal, locale.GetUserLocalizer("en"), msgGetCategories, http.StatusInternalServerError, nil)
+	}
+	if len(categories) == 0 {
+		return nil, model.NewAppErr("PgCategoryStore.GetSubtree", model.ErrNotFound, locale.GetUserLocalizer("en"), msgCategoryNotFound, http.StatusNotFound, nil)
+	}
+	return categories, nil
+}
+
+// GetAncestors returns the breadcrumbs of the category, from the root to the category itself
+func (s PgCategoryStore) GetAncestors(id int64) ([]*model.Category, *model.AppErr) {
+	q := `SELECT a.* FROM public.category c JOIN public.category a ON c.path LIKE a.path || '%' WHERE c.id = $1 ORDER BY a.depth ASC`
+
+	var categories = make([]*model.Category, 0)
+	if err := s.db.Select(&categories, q, id); err != nil {
+		return nil, model.NewAppErr("PgCategoryStore.GetAncestors", model.ErrInternal, locale.GetUserLocalizer("en"), msgGetCategories, http.StatusInternalServerError, nil)
+	}
+	if len(categories) == 0 {
+		return nil, model.NewAppErr("PgCategoryStore.GetAncestors", model.ErrNotFound, locale.GetUserLocalizer("en"), msgCategoryNotFound, http.StatusNotFound, nil)
+	}
+	return categories, nil
+}
+
+// Move reparents the category with its whole subtree, the nil parent makes it the root category
+// the moves and the inserts of the child categories are serialized by the advisory lock (the insert trigger takes it too),
+// so the concurrent moves can't make the cycle together and the new category never gets the stale parent path
+func (s PgCategoryStore) Move(id int64, parentID *int64) (*model.Category, *model.AppErr) {
+	appErr := func() *model.AppErr {
+		return model.NewAppErr("PgCategoryStore.Move", model.ErrInternal, locale.GetUserLocalizer("en"), msgMoveCategory, http.StatusInternalServerError, nil)
+	}
+	notFound := func() *model.AppErr {
+		return model.NewAppErr("PgCategoryStore.Move", model.ErrNotFound, locale.GetUserLocalizer("en"), msgCategoryNotFound, http.StatusNotFound, nil)
+	}
+
+	tx, err := s.beginx()
+	if err != nil {
+		return nil, appErr()
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext('category_tree'))`); err != nil {
+		return nil, appErr()
+	}
+
+	var c model.Category
+	if err := tx.Get(&c, `SELECT * FROM public.category WHERE id = $1`, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, notFound()
+		}
+		return nil, appErr()
+	}
+
+	path := "/"
+	depth := 0
+	if parentID != nil {
+		var parent model.Category
+		if err := tx.Get(&parent, `SELECT * FROM public.category WHERE id = $1`, *parentID); err != nil {
+			if err == sql.ErrNoRows {
+				return nil, notFound()
+			}
+			return nil, appErr()
+		}
+		if parent.IsDescendantOf(&c) {
+			return nil, model.NewAppErr("PgCategoryStore.Move", model.ErrInvalid, locale.GetUserLocalizer("en"), msgMoveCategoryCycle, http.StatusBadRequest, nil)
+		}
+		path = parent.Path
+		depth = parent.Depth + 1
+	}
+	path += strconv.FormatInt(id, 10) + "/"
+
+	q := `UPDATE public.category SET
+		path = $1 || substr(path, length($2) + 1),
+		depth = depth + $3,
+		parent_id = CASE WHEN id = $4 THEN $5 ELSE parent_id END,
+		updated_at = CASE WHEN id = $4 THEN now() ELSE updated_at END
+	WHERE path LIKE $2 || '%'`
+	if _, err := tx.Exec(q, path, c.Path, depth-c.Depth, id, parentID); err != nil {
+		return nil, appErr()
+	}
+
+	if err := tx.Get(&c, `SELECT * FROM public.category WHERE id = $1`, id); err != nil {
+		return nil, appErr()
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, appErr()
+	}
+	return &c, nil
+}
+
 // Delete deletes the category
 func (s PgCategoryStore) Delete(id int64) *model.AppErr {
 	if _, err := s.db.NamedExec("DELETE from public.category WHERE id = :id", map[string]interface{}{"id": id}); err != nil {
@@ -6,6 +6,10 @@ import "github.com/jackc/pgx"
 const (
 	foreignKeyViolation = "23503"
 	uniqueViolation     = "23505"
+
+	// raised by the promotion redemption claim trigger
+	promotionUsageLimit = "PU001"
+	promotionUserLimit  = "PU002"
 )
 
 // IsUniqueConstraintViolationError checks for postgres unique constraint error code
@@ -23,3 +27,19 @@ func IsForeignKeyConstraintViolationError(err error) bool {
 	}
 	return false
 }
+
+// IsPromotionUsageLimitError checks for the error of the claim of the promotion that reached its usage limit
+func IsPromotionUsageLimitError(err error) bool {
+	if pqErr, ok := err.(pgx.PgError); ok && pqErr.Code == promotionUsageLimit {
+		return true
+	}
+	return false
+}
+
+// IsPromotionUserLimitError checks for the error of the claim of the promotion the user already used up to the per user limit
+func IsPromotionUserLimitError(err error) bool {
+	if pqErr, ok := err.(pgx.PgError); ok && pqErr.Code == promotionUserLimit {
+		return true
+	}
+	return false
+}
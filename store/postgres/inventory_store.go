@@ -0,0 +1,105 @@
+package postgres
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/dankobgd/ecommerce-shop/model"
+	"github.com/dankobgd/ecommerce-shop/store"
+	"github.com/dankobgd/ecommerce-shop/utils/locale"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+// PgInventoryStore is the postgres implementation
+type PgInventoryStore struct {
+	PgStore
+}
+
+// NewPgInventoryStore creates the new inventory store
+func NewPgInventoryStore(pgst *PgStore) store.InventoryStore {
+	return &PgInventoryStore{*pgst}
+}
+
+var (
+	msgAdjustStock        = &i18n.Message{ID: "store.postgres.inventory.adjust.app_error", Other: "could not adjust the product variant stock"}
+	msgInsufficientStock  = &i18n.Message{ID: "store.postgres.inventory.adjust.insufficient.app_error", Other: "not enough products in stock"}
+	msgGetStock           = &i18n.Message{ID: "store.postgres.inventory.get_stock.app_error", Other: "could not get the product stock"}
+	msgSaveStockMovement  = &i18n.Message{ID: "store.postgres.inventory.save_movement.app_error", Other: "could not save the stock movement"}
+	msgGetStockMovements  = &i18n.Message{ID: "store.postgres.inventory.get_movements.app_error", Other: "could not get the stock movements"}
+	msgStockVariantExists = &i18n.Message{ID: "store.postgres.inventory.get_stock.not_found.app_error", Other: "product variant not found"}
+)
+
+// Adjust atomically changes the variant stock by the quantity and returns the new stock
+// the product stock is kept as the sum of its variants, it fails with conflict if the stock would go bellow zero
+func (s PgInventoryStore) Adjust(variantID int64, quantity int) (*model.ProductStock, *model.AppErr) {
+	q := `WITH v AS (
+		UPDATE public.product_variant SET stock_quantity = stock_quantity + $1 WHERE id = $2 AND stock_quantity + $1 >= 0
+		RETURNING product_id, id AS variant_id, sku, options, stock_quantity, in_stock
+	), p AS (
+		UPDATE public.product SET stock_quantity = public.product.stock_quantity + $1 FROM v WHERE public.product.id = v.product_id
+	)
+	SELECT * FROM v`
+
+	var ps model.ProductStock
+	if err := s.db.Get(&ps, q, quantity, variantID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, model.NewAppErr("PgInventoryStore.Adjust", model.ErrConflict, locale.GetUserLocalizer("en"), msgInsufficientStock, http.StatusConflict, map[string]interface{}{"variant_id": variantID})
+		}
+		return nil, model.NewAppErr("PgInventoryStore.Adjust", model.ErrInternal, locale.GetUserLocalizer("en"), msgAdjustStock, http.StatusInternalServerError, nil)
+	}
+	return &ps, nil
+}
+
+// GetStock gets the current variant stock
+func (s PgInventoryStore) GetStock(variantID int64) (*model.ProductStock, *model.AppErr) {
+	var ps model.ProductStock
+	if err := s.db.Get(&ps, `SELECT product_id, id AS variant_id, sku, options, stock_quantity, in_stock FROM public.product_variant WHERE id = $1`, variantID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, model.NewAppErr("PgInventoryStore.GetStock", model.ErrNotFound, locale.GetUserLocalizer("en"), msgStockVariantExists, http.StatusNotFound, nil)
+		}
+		return nil, model.NewAppErr("PgInventoryStore.GetStock", model.ErrInternal, locale.GetUserLocalizer("en"), msgGetStock, http.StatusInternalServerError, nil)
+	}
+	return &ps, nil
+}
+
+// GetProductStock gets the current stock of all product variants, the default one first
+func (s PgInventoryStore) GetProductStock(productID int64) ([]*model.ProductStock, *model.AppErr) {
+	var stock = make([]*model.ProductStock, 0)
+	if err := s.db.Select(&stock, `SELECT product_id, id AS variant_id, sku, options, stock_quantity, in_stock FROM public.product_variant WHERE product_id = $1 ORDER BY is_default DESC, id ASC`, productID); err != nil {
+		return nil, model.NewAppErr("PgInventoryStore.GetProductStock", model.ErrInternal, locale.GetUserLocalizer("en"), msgGetStock, http.StatusInternalServerError, nil)
+	}
+	return stock, nil
+}
+
+// SaveMovement inserts the stock ledger entry, the variant sku is copied so the entry stays readable after the variant removal
+func (s PgInventoryStore) SaveMovement(m *model.StockMovement) (*model.StockMovement, *model.AppErr) {
+	q := `INSERT INTO public.stock_movement (product_id, variant_id, sku, order_id, quantity, reason, note, created_by, created_at)
+	VALUES (:product_id, :variant_id, (SELECT sku FROM public.product_variant WHERE id = :variant_id), :order_id, :quantity, :reason, :note, :created_by, :created_at) RETURNING id, sku`
+
+	var id int64
+	var sku string
+	rows, err := s.db.NamedQuery(q, m)
+	if err != nil {
+		return nil, model.NewAppErr("PgInventoryStore.SaveMovement", model.ErrInternal, locale.GetUserLocalizer("en"), msgSaveStockMovement, http.StatusInternalServerError, nil)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		rows.Scan(&id, &sku)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, model.NewAppErr("PgInventoryStore.SaveMovement", model.ErrInternal, locale.GetUserLocalizer("en"), msgSaveStockMovement, http.StatusInternalServerError, nil)
+	}
+
+	m.ID = id
+	m.SKU = sku
+	return m, nil
+}
+
+// GetMovements gets the stock ledger of the product, latest first
+func (s PgInventoryStore) GetMovements(productID int64, limit, offset int) ([]*model.StockMovement, *model.AppErr) {
+	var movements = make([]*model.StockMovement, 0)
+	if err := s.db.Select(&movements, `SELECT COUNT(*) OVER() AS total_count, * FROM public.stock_movement WHERE product_id = $1 ORDER BY created_at DESC, id DESC LIMIT $2 OFFSET $3`, productID, limit, offset); err != nil {
+		return nil, model.NewAppErr("PgInventoryStore.GetMovements", model.ErrInternal, locale.GetUserLocalizer("en"), msgGetStockMovements, http.StatusInternalServerError, nil)
+	}
+	return movements, nil
+}
@@ -27,7 +27,7 @@ var (
 
 // BulkInsert inserts multiple order details into the db
 func (s *PgOrderDetailStore) BulkInsert(items []*model.OrderDetail) *model.AppErr {
-	if _, err := s.db.NamedExec(`INSERT INTO public.order_detail (order_id, product_id, quantity, history_price, history_sku) VALUES (:order_id, :product_id, :quantity, :history_price, :history_sku)`, items); err != nil {
+	if _, err := s.db.NamedExec(`INSERT INTO public.order_detail (order_id, line, product_id, variant_id, quantity, history_price, history_sku, history_options, discount, bundle_id, bundle_quantity, bundle_price) VALUES (:order_id, :line, :product_id, :variant_id, :quantity, :history_price, :history_sku, :history_options, :discount, :bundle_id, :bundle_quantity, :bundle_price)`, items); err != nil {
 		return model.NewAppErr("PgOrderDetailStore.BulkInsert", model.ErrInternal, locale.GetUserLocalizer("en"), msgBulkInsertOrderDetails, http.StatusInternalServerError, nil)
 	}
 	return nil
@@ -35,17 +35,21 @@ func (s *PgOrderDetailStore) BulkInsert(items []*model.OrderDetail) *model.AppEr
 
 // Save creates the new order detail
 func (s *PgOrderDetailStore) Save(o *model.OrderDetail) (*model.OrderDetail, *model.AppErr) {
-	if _, err := s.db.NamedExec(`INSERT INTO public.order_detail (order_id, product_id, quantity, history_price, history_sku) VALUES (:order_id, :product_id, :quantity, :history_price, :history_sku)`, o); err != nil {
+	if _, err := s.db.NamedExec(`INSERT INTO public.order_detail (order_id, line, product_id, variant_id, quantity, history_price, history_sku, history_options, discount, bundle_id, bundle_quantity, bundle_price) VALUES (:order_id, :line, :product_id, :variant_id, :quantity, :history_price, :history_sku, :history_options, :discount, :bundle_id, :bundle_quantity, :bundle_price)`, o); err != nil {
 		return nil, model.NewAppErr("PgOrderDetailStore.Save", model.ErrInternal, locale.GetUserLocalizer("en"), msgSaveOrderDetail, http.StatusInternalServerError, nil)
 	}
 	return o, nil
 }
 
-// GetAll gets the all order details
+// GetAll gets the all order details, the component lines of the bundles get the bundle name
 func (s *PgOrderDetailStore) GetAll(orderID int64) ([]*model.OrderInfo, *model.AppErr) {
 	var ods = make([]*model.OrderInfo, 0)
 
-	q := `SELECT * FROM public.order_detail od LEFT JOIN public.product p ON od.product_id = p.id WHERE od.order_id = $1`
+	q := `SELECT od.*, p.*, bp.name AS bundle_name FROM public.order_detail od
+	LEFT JOIN public.product p ON od.product_id = p.id
+	LEFT JOIN public.product bp ON od.bundle_id = bp.id
+	WHERE od.order_id = $1
+	ORDER BY od.line ASC`
 	if err := s.db.Select(&ods, q, orderID); err != nil {
 		return nil, model.NewAppErr("PgBrandStore.GetAll", model.ErrInternal, locale.GetUserLocalizer("en"), msgGetOrderDetails, http.StatusInternalServerError, nil)
 	}
@@ -0,0 +1,47 @@
+package postgres
+
+import (
+	"net/http"
+
+	"github.com/dankobgd/ecommerce-shop/model"
+	"github.com/dankobgd/ecommerce-shop/store"
+	"github.com/dankobgd/ecommerce-shop/utils/locale"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+// PgOrderDiscountStore is the postgres implementation
+type PgOrderDiscountStore struct {
+	PgStore
+}
+
+// NewPgOrderDiscountStore creates the new order discount store
+func NewPgOrderDiscountStore(pgst *PgStore) store.OrderDiscountStore {
+	return &PgOrderDiscountStore{*pgst}
+}
+
+var (
+	msgBulkInsertOrderDiscounts = &i18n.Message{ID: "store.postgres.order_discount.bulk_insert.app_error", Other: "could not save order discounts"}
+	msgGetOrderDiscounts        = &i18n.Message{ID: "store.postgres.order_discount.get_all.app_error", Other: "could not get order discounts"}
+)
+
+// BulkInsert inserts the discounts applied to the order
+func (s PgOrderDiscountStore) BulkInsert(discounts []*model.OrderDiscount) *model.AppErr {
+	if len(discounts) == 0 {
+		return nil
+	}
+
+	q := `INSERT INTO public.order_discount (order_id, promo_code, type, description, amount, shipping_amount) VALUES (:order_id, :promo_code, :type, :description, :amount, :shipping_amount)`
+	if _, err := s.db.NamedExec(q, discounts); err != nil {
+		return model.NewAppErr("PgOrderDiscountStore.BulkInsert", model.ErrInternal, locale.GetUserLocalizer("en"), msgBulkInsertOrderDiscounts, http.StatusInternalServerError, nil)
+	}
+	return nil
+}
+
+// GetAll gets the discounts applied to the order, in the order they were applied
+func (s PgOrderDiscountStore) GetAll(orderID int64) ([]*model.OrderDiscount, *model.AppErr) {
+	var discounts = make([]*model.OrderDiscount, 0)
+	if err := s.db.Select(&discounts, `SELECT * FROM public.order_discount WHERE order_id = $1 ORDER BY id ASC`, orderID); err != nil {
+		return nil, model.NewAppErr("PgOrderDiscountStore.GetAll", model.ErrInternal, locale.GetUserLocalizer("en"), msgGetOrderDiscounts, http.StatusInternalServerError, nil)
+	}
+	return discounts, nil
+}
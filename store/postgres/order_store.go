@@ -1,7 +1,9 @@
 package postgres
 
 import (
+	"database/sql"
 	"net/http"
+	"time"
 
 	"github.com/dankobgd/ecommerce-shop/model"
 	"github.com/dankobgd/ecommerce-shop/store"
@@ -24,12 +26,17 @@ var (
 	msgUpdateOrder = &i18n.Message{ID: "store.postgres.order.update.app_error", Other: "could not update order"}
 	msgGetOrder    = &i18n.Message{ID: "store.postgres.order.get.app_error", Other: "could not get order"}
 	msgGetOrders   = &i18n.Message{ID: "store.postgres.orders.get.app_error", Other: "could not get orders"}
+
+	msgUpdateOrderStatus        = &i18n.Message{ID: "store.postgres.order.update_status.app_error", Other: "could not update order status"}
+	msgOrderStatusChanged       = &i18n.Message{ID: "store.postgres.order.update_status.conflict.app_error", Other: "order status has been changed in the meantime"}
+	msgGetOrderStatusHistory    = &i18n.Message{ID: "store.postgres.order.get_status_history.app_error", Other: "could not get order status history"}
+	msgInsertOrderStatusHistory = &i18n.Message{ID: "store.postgres.order.insert_status_history.app_error", Other: "could not save order status history"}
 )
 
 // Save creates the new order
 func (s PgOrderStore) Save(o *model.Order) (*model.Order, *model.AppErr) {
-	q := `INSERT INTO public.order (user_id, promo_code, promo_code_type, promo_code_amount, status, subtotal, total, shipped_at, created_at, payment_method_id, payment_intent_id, receipt_url, billing_address_line_1, billing_address_line_2, billing_address_city, billing_address_country, billing_address_state, billing_address_zip, billing_address_latitude, billing_address_longitude, shipping_address_line_1, shipping_address_line_2, shipping_address_city, shipping_address_country, shipping_address_state, shipping_address_zip, shipping_address_latitude, shipping_address_longitude) 
-	VALUES (:user_id, :promo_code, :promo_code_type, :promo_code_amount, :status, :subtotal, :total, :shipped_at, :created_at, :payment_method_id, :payment_intent_id, :receipt_url, :billing_address_line_1, :billing_address_line_2, :billing_address_city, :billing_address_country, :billing_address_state, :billing_address_zip, :billing_address_latitude, :billing_address_longitude, :shipping_address_line_1, :shipping_address_line_2, :shipping_address_city, :shipping_address_country, :shipping_address_state, :shipping_address_zip, :shipping_address_latitude, :shipping_address_longitude) RETURNING id`
+	q := `INSERT INTO public.order (user_id, promo_code, promo_code_type, promo_code_amount, status, subtotal, shipping, discount, total, shipped_at, created_at, payment_method_id, payment_intent_id, receipt_url, billing_address_line_1, billing_address_line_2, billing_address_city, billing_address_country, billing_address_state, billing_address_zip, billing_address_latitude, billing_address_longitude, shipping_address_line_1, shipping_address_line_2, shipping_address_city, shipping_address_country, shipping_address_state, shipping_address_zip, shipping_address_latitude, shipping_address_longitude) 
+	VALUES (:user_id, :promo_code, :promo_code_type, :promo_code_amount, :status, :subtotal, :shipping, :discount, :total, :shipped_at, :created_at, :payment_method_id, :payment_intent_id, :receipt_url, :billing_address_line_1, :billing_address_line_2, :billing_address_city, :billing_address_country, :billing_address_state, :billing_address_zip, :billing_address_latitude, :billing_address_longitude, :shipping_address_line_1, :shipping_address_line_2, :shipping_address_city, :shipping_address_country, :shipping_address_state, :shipping_address_zip, :shipping_address_latitude, :shipping_address_longitude) RETURNING id`
 
 	var id int64
 	rows, err := s.db.NamedQuery(q, o)
@@ -68,6 +75,30 @@ func (s PgOrderStore) Get(id int64) (*model.Order, *model.AppErr) {
 	return &o, nil
 }
 
+// GetForUpdate gets the order by id and locks it until the end of the transaction
+func (s PgOrderStore) GetForUpdate(id int64) (*model.Order, *model.AppErr) {
+	var o model.Order
+	if err := s.db.Get(&o, `SELECT * FROM public.order WHERE id = $1 FOR UPDATE`, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, model.NewAppErr("PgOrderStore.GetForUpdate", model.ErrNotFound, locale.GetUserLocalizer("en"), msgGetOrder, http.StatusNotFound, nil)
+		}
+		return nil, model.NewAppErr("PgOrderStore.GetForUpdate", model.ErrInternal, locale.GetUserLocalizer("en"), msgGetOrder, http.StatusInternalServerError, nil)
+	}
+	return &o, nil
+}
+
+// GetByPaymentIntentID gets the order paid with the given payment intent
+func (s PgOrderStore) GetByPaymentIntentID(paymentIntentID string) (*model.Order, *model.AppErr) {
+	var o model.Order
+	if err := s.db.Get(&o, `SELECT * FROM public.order WHERE payment_intent_id = $1`, paymentIntentID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, model.NewAppErr("PgOrderStore.GetByPaymentIntentID", model.ErrNotFound, locale.GetUserLocalizer("en"), msgGetOrder, http.StatusNotFound, nil)
+		}
+		return nil, model.NewAppErr("PgOrderStore.GetByPaymentIntentID", model.ErrInternal, locale.GetUserLocalizer("en"), msgGetOrder, http.StatusInternalServerError, nil)
+	}
+	return &o, nil
+}
+
 // GetAll returns all orders
 func (s PgOrderStore) GetAll(limit, offset int) ([]*model.Order, *model.AppErr) {
 	var orders = make([]*model.Order, 0)
@@ -78,6 +109,72 @@ func (s PgOrderStore) GetAll(limit, offset int) ([]*model.Order, *model.AppErr)
 	return orders, nil
 }
 
+// UpdateStatus moves the order from h.FromStatus to h.ToStatus and records the change in the history
+// it fails with conflict if the order status is no longer h.FromStatus
+func (s PgOrderStore) UpdateStatus(h *model.OrderStatusHistory, shippedAt *time.Time) *model.AppErr {
+	tx, err := s.beginx()
+	if err != nil {
+		return model.NewAppErr("PgOrderStore.UpdateStatus", model.ErrInternal, locale.GetUserLocalizer("en"), msgUpdateOrderStatus, http.StatusInternalServerError, nil)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`UPDATE public.order SET status = $1, shipped_at = COALESCE($2, shipped_at) WHERE id = $3 AND status = $4`, h.ToStatus, shippedAt, h.OrderID, h.FromStatus)
+	if err != nil {
+		return model.NewAppErr("PgOrderStore.UpdateStatus", model.ErrInternal, locale.GetUserLocalizer("en"), msgUpdateOrderStatus, http.StatusInternalServerError, nil)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return model.NewAppErr("PgOrderStore.UpdateStatus", model.ErrConflict, locale.GetUserLocalizer("en"), msgOrderStatusChanged, http.StatusConflict, nil)
+	}
+
+	q := `INSERT INTO public.order_status_history (order_id, from_status, to_status, note, changed_by, created_at) VALUES (:order_id, :from_status, :to_status, :note, :changed_by, :created_at)`
+	if _, err := tx.NamedExec(q, h); err != nil {
+		return model.NewAppErr("PgOrderStore.UpdateStatus", model.ErrInternal, locale.GetUserLocalizer("en"), msgInsertOrderStatusHistory, http.StatusInternalServerError, nil)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return model.NewAppErr("PgOrderStore.UpdateStatus", model.ErrInternal, locale.GetUserLocalizer("en"), msgUpdateOrderStatus, http.StatusInternalServerError, nil)
+	}
+	return nil
+}
+
+// UpdatePayment sets the payment info of the charged order
+func (s PgOrderStore) UpdatePayment(id int64, paymentIntentID, receiptURL string) *model.AppErr {
+	if _, err := s.db.Exec(`UPDATE public.order SET payment_intent_id = $1, receipt_url = $2 WHERE id = $3`, paymentIntentID, receiptURL, id); err != nil {
+		return model.NewAppErr("PgOrderStore.UpdatePayment", model.ErrInternal, locale.GetUserLocalizer("en"), msgUpdateOrder, http.StatusInternalServerError, nil)
+	}
+	return nil
+}
+
+// InsertStatusHistory inserts the order status history entry
+func (s PgOrderStore) InsertStatusHistory(h *model.OrderStatusHistory) (*model.OrderStatusHistory, *model.AppErr) {
+	q := `INSERT INTO public.order_status_history (order_id, from_status, to_status, note, changed_by, created_at) VALUES (:order_id, :from_status, :to_status, :note, :changed_by, :created_at) RETURNING id`
+
+	var id int64
+	rows, err := s.db.NamedQuery(q, h)
+	if err != nil {
+		return nil, model.NewAppErr("PgOrderStore.InsertStatusHistory", model.ErrInternal, locale.GetUserLocalizer("en"), msgInsertOrderStatusHistory, http.StatusInternalServerError, nil)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		rows.Scan(&id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, model.NewAppErr("PgOrderStore.InsertStatusHistory", model.ErrInternal, locale.GetUserLocalizer("en"), msgInsertOrderStatusHistory, http.StatusInternalServerError, nil)
+	}
+
+	h.ID = id
+	return h, nil
+}
+
+// GetStatusHistory gets the order status changes, oldest first
+func (s PgOrderStore) GetStatusHistory(orderID int64) ([]*model.OrderStatusHistory, *model.AppErr) {
+	var history = make([]*model.OrderStatusHistory, 0)
+	if err := s.db.Select(&history, `SELECT * FROM public.order_status_history WHERE order_id = $1 ORDER BY created_at ASC, id ASC`, orderID); err != nil {
+		return nil, model.NewAppErr("PgOrderStore.GetStatusHistory", model.ErrInternal, locale.GetUserLocalizer("en"), msgGetOrderStatusHistory, http.StatusInternalServerError, nil)
+	}
+	return history, nil
+}
+
 // Delete deletes the order
 func (s PgOrderStore) Delete(id int64) *model.AppErr {
 	return nil
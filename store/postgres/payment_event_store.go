@@ -0,0 +1,83 @@
+package postgres
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/dankobgd/ecommerce-shop/model"
+	"github.com/dankobgd/ecommerce-shop/store"
+	"github.com/dankobgd/ecommerce-shop/utils/locale"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+// PgPaymentEventStore is the postgres implementation
+type PgPaymentEventStore struct {
+	PgStore
+}
+
+// NewPgPaymentEventStore creates the new payment event store
+func NewPgPaymentEventStore(pgst *PgStore) store.PaymentEventStore {
+	return &PgPaymentEventStore{*pgst}
+}
+
+var (
+	msgSavePaymentEvent   = &i18n.Message{ID: "store.postgres.payment_event.save.app_error", Other: "could not save payment event"}
+	msgUpdatePaymentEvent = &i18n.Message{ID: "store.postgres.payment_event.update.app_error", Other: "could not update payment event"}
+)
+
+// Save inserts the received event, or bumps the attempts if the event was already received
+// the returned event tells if it has already been processed
+func (s PgPaymentEventStore) Save(e *model.PaymentEvent) (*model.PaymentEvent, *model.AppErr) {
+	q := `INSERT INTO public.payment_event (provider, event_id, type, provider_type, charge_id, payload, attempts, created_at)
+	VALUES (:provider, :event_id, :type, :provider_type, :charge_id, :payload, :attempts, :created_at)
+	ON CONFLICT (provider, event_id) DO UPDATE SET attempts = payment_event.attempts + 1
+	RETURNING *`
+
+	rows, err := s.db.NamedQuery(q, e)
+	if err != nil {
+		return nil, model.NewAppErr("PgPaymentEventStore.Save", model.ErrInternal, locale.GetUserLocalizer("en"), msgSavePaymentEvent, http.StatusInternalServerError, nil)
+	}
+	defer rows.Close()
+
+	var saved model.PaymentEvent
+	for rows.Next() {
+		if err := rows.StructScan(&saved); err != nil {
+			return nil, model.NewAppErr("PgPaymentEventStore.Save", model.ErrInternal, locale.GetUserLocalizer("en"), msgSavePaymentEvent, http.StatusInternalServerError, nil)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, model.NewAppErr("PgPaymentEventStore.Save", model.ErrInternal, locale.GetUserLocalizer("en"), msgSavePaymentEvent, http.StatusInternalServerError, nil)
+	}
+
+	return &saved, nil
+}
+
+// Claim atomically takes the unprocessed event for processing, it returns false if the event is processed or is being processed
+// the claim older than staleAfter is taken over, its processing was interrupted
+func (s PgPaymentEventStore) Claim(id int64, staleAfter time.Duration) (bool, *model.AppErr) {
+	now := time.Now()
+	q := `UPDATE public.payment_event SET processing_at = $1
+	WHERE id = $2 AND processed_at IS NULL AND (processing_at IS NULL OR processing_at < $3)`
+	res, err := s.db.Exec(q, now, id, now.Add(-staleAfter))
+	if err != nil {
+		return false, model.NewAppErr("PgPaymentEventStore.Claim", model.ErrInternal, locale.GetUserLocalizer("en"), msgUpdatePaymentEvent, http.StatusInternalServerError, nil)
+	}
+	n, _ := res.RowsAffected()
+	return n == 1, nil
+}
+
+// MarkProcessed marks the event as applied
+func (s PgPaymentEventStore) MarkProcessed(id int64) *model.AppErr {
+	if _, err := s.db.Exec(`UPDATE public.payment_event SET processed_at = $1, processing_at = NULL, error = NULL WHERE id = $2`, time.Now(), id); err != nil {
+		return model.NewAppErr("PgPaymentEventStore.MarkProcessed", model.ErrInternal, locale.GetUserLocalizer("en"), msgUpdatePaymentEvent, http.StatusInternalServerError, nil)
+	}
+	return nil
+}
+
+// MarkFailed stores the processing error, the event will be processed again when the provider retries it
+func (s PgPaymentEventStore) MarkFailed(id int64, reason string) *model.AppErr {
+	if _, err := s.db.Exec(`UPDATE public.payment_event SET error = $1, processing_at = NULL WHERE id = $2`, reason, id); err != nil {
+		return model.NewAppErr("PgPaymentEventStore.MarkFailed", model.ErrInternal, locale.GetUserLocalizer("en"), msgUpdatePaymentEvent, http.StatusInternalServerError, nil)
+	}
+	return nil
+}
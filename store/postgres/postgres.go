@@ -1,15 +1,55 @@
 package postgres
 
 import (
+	"database/sql"
 	"log"
+	"net/http"
 
+	"github.com/dankobgd/ecommerce-shop/model"
+	"github.com/dankobgd/ecommerce-shop/utils/locale"
 	_ "github.com/jackc/pgx/stdlib" // pg driver
 	"github.com/jmoiron/sqlx"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
 )
 
+var (
+	msgBeginTx    = &i18n.Message{ID: "store.postgres.tx.begin.app_error", Other: "could not begin the transaction"}
+	msgNestedTx   = &i18n.Message{ID: "store.postgres.tx.nested.app_error", Other: "store is already bound to the transaction"}
+	msgCommitTx   = &i18n.Message{ID: "store.postgres.tx.commit.app_error", Other: "could not commit the transaction"}
+	msgRollbackTx = &i18n.Message{ID: "store.postgres.tx.rollback.app_error", Other: "could not rollback the transaction"}
+)
+
+// dbExecutor is implemented by both *sqlx.DB and *sqlx.Tx
+type dbExecutor interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Get(dest interface{}, query string, args ...interface{}) error
+	Select(dest interface{}, query string, args ...interface{}) error
+	NamedExec(query string, arg interface{}) (sql.Result, error)
+	NamedQuery(query string, arg interface{}) (*sqlx.Rows, error)
+	Rebind(query string) string
+}
+
+// pgTx is the transaction used by the multi statement store operations
+type pgTx interface {
+	dbExecutor
+	Commit() error
+	Rollback() error
+}
+
+// joinedTx runs the store operation inside the unit of work transaction
+// commit and rollback are left to the owner of the unit of work
+type joinedTx struct {
+	*sqlx.Tx
+}
+
+func (joinedTx) Commit() error   { return nil }
+func (joinedTx) Rollback() error { return nil }
+
 // PgStore has the pg db driver
 type PgStore struct {
-	db *sqlx.DB
+	db   dbExecutor
+	conn *sqlx.DB
+	tx   *sqlx.Tx
 }
 
 // Connect establishes connection to postgres db
@@ -25,5 +65,50 @@ func Connect() (*sqlx.DB, error) {
 
 // NewStore initializes postgres based store
 func NewStore(db *sqlx.DB) *PgStore {
-	return &PgStore{db: db}
+	return &PgStore{db: db, conn: db}
+}
+
+// Begin starts the transaction and returns the store bound to it
+func (s *PgStore) Begin() (*PgStore, *model.AppErr) {
+	if s.tx != nil {
+		return nil, model.NewAppErr("PgStore.Begin", model.ErrInternal, locale.GetUserLocalizer("en"), msgNestedTx, http.StatusInternalServerError, nil)
+	}
+
+	tx, err := s.conn.Beginx()
+	if err != nil {
+		return nil, model.NewAppErr("PgStore.Begin", model.ErrInternal, locale.GetUserLocalizer("en"), msgBeginTx, http.StatusInternalServerError, nil)
+	}
+
+	return &PgStore{db: tx, conn: s.conn, tx: tx}, nil
+}
+
+// Commit commits the transaction the store is bound to
+func (s *PgStore) Commit() *model.AppErr {
+	if s.tx == nil {
+		return nil
+	}
+	if err := s.tx.Commit(); err != nil {
+		return model.NewAppErr("PgStore.Commit", model.ErrInternal, locale.GetUserLocalizer("en"), msgCommitTx, http.StatusInternalServerError, nil)
+	}
+	return nil
+}
+
+// Rollback aborts the transaction the store is bound to, it is a no-op after commit
+func (s *PgStore) Rollback() *model.AppErr {
+	if s.tx == nil {
+		return nil
+	}
+	if err := s.tx.Rollback(); err != nil && err != sql.ErrTxDone {
+		return model.NewAppErr("PgStore.Rollback", model.ErrInternal, locale.GetUserLocalizer("en"), msgRollbackTx, http.StatusInternalServerError, nil)
+	}
+	return nil
+}
+
+// beginx starts the transaction for the multi statement store operation,
+// when the store is already bound to the unit of work it joins that transaction instead
+func (s PgStore) beginx() (pgTx, error) {
+	if s.tx != nil {
+		return joinedTx{s.tx}, nil
+	}
+	return s.conn.Beginx()
 }
@@ -0,0 +1,145 @@
+package postgres
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/dankobgd/ecommerce-shop/model"
+	"github.com/dankobgd/ecommerce-shop/store"
+	"github.com/dankobgd/ecommerce-shop/utils/locale"
+	"github.com/jmoiron/sqlx"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+// PgProductBundleStore is the postgres implementation
+type PgProductBundleStore struct {
+	PgStore
+}
+
+// NewPgProductBundleStore creates the new product bundle store
+func NewPgProductBundleStore(pgst *PgStore) store.ProductBundleStore {
+	return &PgProductBundleStore{*pgst}
+}
+
+var (
+	msgGetProductBundle       = &i18n.Message{ID: "store.postgres.product_bundle.get.app_error", Other: "could not get product bundle"}
+	msgProductBundleNotFound  = &i18n.Message{ID: "store.postgres.product_bundle.get.not_found.app_error", Other: "product bundle not found"}
+	msgGetProductBundles      = &i18n.Message{ID: "store.postgres.product_bundle.list.app_error", Other: "could not get product bundles"}
+	msgSaveProductBundle      = &i18n.Message{ID: "store.postgres.product_bundle.save.app_error", Other: "could not save product bundle"}
+	msgBundleComponentMissing = &i18n.Message{ID: "store.postgres.product_bundle.save.not_found.app_error", Other: "bundle product or component variant does not exist"}
+	msgDeleteProductBundle    = &i18n.Message{ID: "store.postgres.product_bundle.delete.app_error", Other: "could not delete product bundle"}
+)
+
+// Get gets the bundle of the product with its components in their order
+func (s PgProductBundleStore) Get(pid int64) (*model.ProductBundle, *model.AppErr) {
+	var b model.ProductBundle
+	if err := s.db.Get(&b, `SELECT * FROM public.product_bundle WHERE product_id = $1`, pid); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, model.NewAppErr("PgProductBundleStore.Get", model.ErrNotFound, locale.GetUserLocalizer("en"), msgProductBundleNotFound, http.StatusNotFound, nil)
+		}
+		return nil, model.NewAppErr("PgProductBundleStore.Get", model.ErrInternal, locale.GetUserLocalizer("en"), msgGetProductBundle, http.StatusInternalServerError, nil)
+	}
+
+	b.Items = make([]*model.BundleItem, 0)
+	if err := s.db.Select(&b.Items, `SELECT * FROM public.product_bundle_item WHERE bundle_id = $1 ORDER BY position ASC`, pid); err != nil {
+		return nil, model.NewAppErr("PgProductBundleStore.Get", model.ErrInternal, locale.GetUserLocalizer("en"), msgGetProductBundle, http.StatusInternalServerError, nil)
+	}
+	return &b, nil
+}
+
+// ListByProductIDS gets the bundles with their components among the given products, the products that aren't bundles are skipped
+func (s PgProductBundleStore) ListByProductIDS(pids []int64) ([]*model.ProductBundle, *model.AppErr) {
+	var bundles = make([]*model.ProductBundle, 0)
+	if len(pids) == 0 {
+		return bundles, nil
+	}
+
+	q, args, err := sqlx.In(`SELECT * FROM public.product_bundle WHERE product_id IN (?)`, pids)
+	if err != nil {
+		return nil, model.NewAppErr("PgProductBundleStore.ListByProductIDS", model.ErrInternal, locale.GetUserLocalizer("en"), msgGetProductBundles, http.StatusInternalServerError, nil)
+	}
+	if err := s.db.Select(&bundles, s.db.Rebind(q), args...); err != nil {
+		return nil, model.NewAppErr("PgProductBundleStore.ListByProductIDS", model.ErrInternal, locale.GetUserLocalizer("en"), msgGetProductBundles, http.StatusInternalServerError, nil)
+	}
+	if len(bundles) == 0 {
+		return bundles, nil
+	}
+
+	q, args, err = sqlx.In(`SELECT * FROM public.product_bundle_item WHERE bundle_id IN (?) ORDER BY bundle_id ASC, position ASC`, pids)
+	if err != nil {
+		return nil, model.NewAppErr("PgProductBundleStore.ListByProductIDS", model.ErrInternal, locale.GetUserLocalizer("en"), msgGetProductBundles, http.StatusInternalServerError, nil)
+	}
+	var items = make([]*model.BundleItem, 0)
+	if err := s.db.Select(&items, s.db.Rebind(q), args...); err != nil {
+		return nil, model.NewAppErr("PgProductBundleStore.ListByProductIDS", model.ErrInternal, locale.GetUserLocalizer("en"), msgGetProductBundles, http.StatusInternalServerError, nil)
+	}
+
+	byID := make(map[int64]*model.ProductBundle, len(bundles))
+	for _, b := range bundles {
+		b.Items = make([]*model.BundleItem, 0)
+		byID[b.ProductID] = b
+	}
+	for _, item := range items {
+		if b, ok := byID[item.BundleID]; ok {
+			b.Items = append(b.Items, item)
+		}
+	}
+	return bundles, nil
+}
+
+// IsComponent returns true if any variant of the product is the component of some bundle
+func (s PgProductBundleStore) IsComponent(pid int64) (bool, *model.AppErr) {
+	var exists bool
+	q := `SELECT EXISTS (
+		SELECT 1 FROM public.product_bundle_item bi INNER JOIN public.product_variant v ON v.id = bi.variant_id WHERE v.product_id = $1
+	)`
+	if err := s.db.Get(&exists, q, pid); err != nil {
+		return false, model.NewAppErr("PgProductBundleStore.IsComponent", model.ErrInternal, locale.GetUserLocalizer("en"), msgGetProductBundle, http.StatusInternalServerError, nil)
+	}
+	return exists, nil
+}
+
+// Save creates or replaces the bundle of the product together with all of its components
+func (s PgProductBundleStore) Save(b *model.ProductBundle) (*model.ProductBundle, *model.AppErr) {
+	tx, err := s.beginx()
+	if err != nil {
+		return nil, model.NewAppErr("PgProductBundleStore.Save", model.ErrInternal, locale.GetUserLocalizer("en"), msgSaveProductBundle, http.StatusInternalServerError, nil)
+	}
+	defer tx.Rollback()
+
+	q := `INSERT INTO public.product_bundle (product_id, pricing_type, amount) VALUES (:product_id, :pricing_type, :amount)
+	ON CONFLICT (product_id) DO UPDATE SET pricing_type = EXCLUDED.pricing_type, amount = EXCLUDED.amount`
+	if _, err := tx.NamedExec(q, b); err != nil {
+		if IsForeignKeyConstraintViolationError(err) {
+			return nil, model.NewAppErr("PgProductBundleStore.Save", model.ErrInvalid, locale.GetUserLocalizer("en"), msgBundleComponentMissing, http.StatusBadRequest, nil)
+		}
+		return nil, model.NewAppErr("PgProductBundleStore.Save", model.ErrInternal, locale.GetUserLocalizer("en"), msgSaveProductBundle, http.StatusInternalServerError, nil)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM public.product_bundle_item WHERE bundle_id = $1`, b.ProductID); err != nil {
+		return nil, model.NewAppErr("PgProductBundleStore.Save", model.ErrInternal, locale.GetUserLocalizer("en"), msgSaveProductBundle, http.StatusInternalServerError, nil)
+	}
+	if _, err := tx.NamedExec(`INSERT INTO public.product_bundle_item (bundle_id, variant_id, quantity, position) VALUES (:bundle_id, :variant_id, :quantity, :position)`, b.Items); err != nil {
+		if IsForeignKeyConstraintViolationError(err) {
+			return nil, model.NewAppErr("PgProductBundleStore.Save", model.ErrInvalid, locale.GetUserLocalizer("en"), msgBundleComponentMissing, http.StatusBadRequest, nil)
+		}
+		return nil, model.NewAppErr("PgProductBundleStore.Save", model.ErrInternal, locale.GetUserLocalizer("en"), msgSaveProductBundle, http.StatusInternalServerError, nil)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, model.NewAppErr("PgProductBundleStore.Save", model.ErrInternal, locale.GetUserLocalizer("en"), msgSaveProductBundle, http.StatusInternalServerError, nil)
+	}
+	return b, nil
+}
+
+// Delete deletes the bundle of the product, the product stays as the regular product
+func (s PgProductBundleStore) Delete(pid int64) *model.AppErr {
+	res, err := s.db.Exec(`DELETE FROM public.product_bundle WHERE product_id = $1`, pid)
+	if err != nil {
+		return model.NewAppErr("PgProductBundleStore.Delete", model.ErrInternal, locale.GetUserLocalizer("en"), msgDeleteProductBundle, http.StatusInternalServerError, nil)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return model.NewAppErr("PgProductBundleStore.Delete", model.ErrNotFound, locale.GetUserLocalizer("en"), msgProductBundleNotFound, http.StatusNotFound, nil)
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package postgres
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/dankobgd/ecommerce-shop/model"
+	"github.com/dankobgd/ecommerce-shop/utils/locale"
+	"github.com/jmoiron/sqlx"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+var msgGetProductFacets = &i18n.Message{ID: "store.postgres.product.get_facets.app_error", Other: "could not get product facets"}
+
+// facetValuesLimit is the max number of the values of one facet, the most common values are kept
+const facetValuesLimit = 100
+
+// GetFacets counts the facets of the listing filter, the property facets are the filterable attributes of the categories
+func (s PgProductStore) GetFacets(filter model.FilterNode, categories []*model.Category) (*model.ProductFacets, *model.AppErr) {
+	appErr := func() *model.AppErr {
+		return model.NewAppErr("PgProductStore.GetFacets", model.ErrInternal, locale.GetUserLocalizer("en"), msgGetProductFacets, http.StatusInternalServerError, nil)
+	}
+
+	facets := &model.ProductFacets{Properties: make([]*model.PropertyFacet, 0)}
+	var err error
+
+	if facets.Brands, err = s.facetValues(model.FacetFilter(filter, model.FilterFieldBrand), "b.slug", nil, "b.name", nil); err != nil {
+		return nil, appErr()
+	}
+	if facets.Tags, err = s.facetValues(model.FacetFilter(filter, model.FilterFieldTag), "t.slug", nil, "t.name", nil); err != nil {
+		return nil, appErr()
+	}
+	if facets.Categories, err = s.facetValues(model.FacetFilter(filter, model.FilterFieldCategory), "c.slug", nil, "c.name", nil); err != nil {
+		return nil, appErr()
+	}
+	if facets.Prices, err = s.priceBuckets(model.FacetFilter(filter, model.FilterFieldPrice)); err != nil {
+		return nil, appErr()
+	}
+
+	for _, c := range categories {
+		for _, attr := range c.Schema().Filters() {
+			f := &model.PropertyFacet{Category: c.Slug, Attribute: attr.Name, Label: attr.Label, Type: attr.Type}
+			propFilter := model.PropertyFacetFilter(filter, c.Slug, attr)
+
+			if attr.Type == model.AttributeTypeNumber {
+				if err := s.propertyRange(propFilter, f); err != nil {
+					return nil, appErr()
+				}
+			} else {
+				name := []interface{}{attr.Name}
+				values, err := s.facetValues(propFilter, "p.properties->>?::text", name, "p.properties->>?::text", name)
+				if err != nil {
+					return nil, appErr()
+				}
+				f.Values = values
+				f.SetLabels(attr)
+				for _, v := range values {
+					f.Count += v.Count
+				}
+			}
+			facets.Properties = append(facets.Properties, f)
+		}
+	}
+
+	return facets, nil
+}
+
+// facetValues counts the products per value of the facet column, the expressions are the fixed columns with their args
+func (s PgProductStore) facetValues(filter model.FilterNode, value string, valueArgs []interface{}, label string, labelArgs []interface{}) ([]*model.FacetValue, error) {
+	cond, args, err := compileProductFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	q := fmt.Sprintf(`SELECT %s AS value, MIN(%s) AS label, COUNT(DISTINCT p.id) AS count %s%s AND %s IS NOT NULL GROUP BY 1 ORDER BY count DESC, value LIMIT %d`, value, label, productListingFrom, andCondition(cond), value, facetValuesLimit)
+	// the args follow the placeholders: the value and the label in the select, the filter and the value not null check
+	allArgs := append(append([]interface{}{}, valueArgs...), labelArgs...)
+	allArgs = append(append(allArgs, args...), valueArgs...)
+
+	values := make([]*model.FacetValue, 0)
+	if err := s.selectIn(&values, q, allArgs...); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// priceBuckets counts the products per price bucket, the empty buckets are included
+func (s PgProductStore) priceBuckets(filter model.FilterNode) ([]*model.PriceBucket, error) {
+	cond, args, err := compileProductFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	edges := model.PriceBucketEdges
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(edges)), ", ")
+	q := fmt.Sprintf(`SELECT width_bucket(pp.price, ARRAY[%s]::int[]) AS bucket, COUNT(DISTINCT p.id) AS count %s%s GROUP BY 1`, placeholders, productListingFrom, andCondition(cond))
+
+	allArgs := make([]interface{}, 0, len(edges)+len(args))
+	for _, edge := range edges {
+		allArgs = append(allArgs, edge)
+	}
+	allArgs = append(allArgs, args...)
+
+	var rows []struct {
+		Bucket int `db:"bucket"`
+		Count  int `db:"count"`
+	}
+	if err := s.selectIn(&rows, q, allArgs...); err != nil {
+		return nil, err
+	}
+
+	buckets := make([]*model.PriceBucket, len(edges))
+	for i, edge := range edges {
+		buckets[i] = &model.PriceBucket{Min: edge}
+		if i+1 < len(edges) {
+			max := edges[i+1]
+			buckets[i].Max = &max
+		}
+	}
+	for _, r := range rows {
+		if r.Bucket >= 1 && r.Bucket <= len(buckets) {
+			buckets[r.Bucket-1].Count = r.Count
+		}
+	}
+	return buckets, nil
+}
+
+// propertyRange sets the range of the number property values and the number of the products that have it
+func (s PgProductStore) propertyRange(filter model.FilterNode, f *model.PropertyFacet) error {
+	cond, args, err := compileProductFilter(filter)
+	if err != nil {
+		return err
+	}
+
+	q := fmt.Sprintf(`SELECT MIN(%[1]s)::float AS min, MAX(%[1]s)::float AS max, COUNT(DISTINCT p.id) AS count %[2]s%[3]s AND %[1]s IS NOT NULL`, propertyNumber, productListingFrom, andCondition(cond))
+	name := f.Attribute
+	allArgs := append([]interface{}{name, name, name, name}, args...)
+	allArgs = append(allArgs, name, name)
+
+	var row struct {
+		Min   *float64 `db:"min"`
+		Max   *float64 `db:"max"`
+		Count int      `db:"count"`
+	}
+	if err := s.getIn(&row, q, allArgs...); err != nil {
+		return err
+	}
+	f.Min, f.Max, f.Count = row.Min, row.Max, row.Count
+	return nil
+}
+
+// selectIn expands the slice args and selects the rows
+func (s PgProductStore) selectIn(dest interface{}, q string, args ...interface{}) error {
+	q, args, err := sqlx.In(q, args...)
+	if err != nil {
+		return err
+	}
+	return s.db.Select(dest, sqlx.Rebind(sqlx.DOLLAR, q), args...)
+}
+
+// getIn expands the slice args and gets the row
+func (s PgProductStore) getIn(dest interface{}, q string, args ...interface{}) error {
+	q, args, err := sqlx.In(q, args...)
+	if err != nil {
+		return err
+	}
+	return s.db.Get(dest, sqlx.Rebind(sqlx.DOLLAR, q), args...)
+}
+
+func andCondition(cond string) string {
+	if cond == "" {
+		return ""
+	}
+	return "\nAND " + cond
+}
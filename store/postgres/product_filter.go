@@ -0,0 +1,98 @@
+package postgres
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dankobgd/ecommerce-shop/model"
+)
+
+// filterColumns are the listing query columns of the filter fields, the only identifiers the filters put in the query
+var filterColumns = map[model.FilterField]string{
+	model.FilterFieldBrand:    "b.slug",
+	model.FilterFieldTag:      "t.slug",
+	model.FilterFieldCategory: "c.slug",
+	model.FilterFieldPrice:    "pp.price",
+}
+
+// categoryTreeCondition matches the products of the categories and all their descendants
+const categoryTreeCondition = "p.category_id IN (SELECT d.id FROM public.category r JOIN public.category d ON d.path LIKE r.path || '%' WHERE r.slug IN (?))"
+
+// propertyNumber is the number property value, properties of the other json types are null
+const propertyNumber = "CASE WHEN jsonb_typeof(p.properties->?::text) = 'number' THEN (p.properties->>?::text)::numeric END"
+
+// compileProductFilter compiles the filter tree into the where condition, every value is bound as the arg
+// the condition uses ? placeholders, the empty condition is returned for the nil or empty tree
+func compileProductFilter(node model.FilterNode) (string, []interface{}, error) {
+	switch n := node.(type) {
+	case nil:
+		return "", nil, nil
+	case model.FilterAnd:
+		return compileFilterList([]model.FilterNode(n), " AND ")
+	case model.FilterOr:
+		return compileFilterList([]model.FilterNode(n), " OR ")
+	case *model.FilterIn:
+		col, ok := filterColumns[n.Field]
+		if !ok || len(n.Values) == 0 {
+			return "", nil, fmt.Errorf("invalid filter on %q", n.Field)
+		}
+		if n.Field == model.FilterFieldCategory {
+			return categoryTreeCondition, []interface{}{n.Values}, nil
+		}
+		return col + " IN (?)", []interface{}{n.Values}, nil
+	case *model.FilterRange:
+		col, ok := filterColumns[n.Field]
+		if !ok {
+			return "", nil, fmt.Errorf("invalid filter on %q", n.Field)
+		}
+		var conds []string
+		var args []interface{}
+		if n.Min != nil {
+			conds = append(conds, col+" >= ?")
+			args = append(args, *n.Min)
+		}
+		if n.Max != nil {
+			conds = append(conds, col+" <= ?")
+			args = append(args, *n.Max)
+		}
+		return strings.Join(conds, " AND "), args, nil
+	case *model.PropertyFilter:
+		name := n.Attribute.Name
+		if n.Attribute.Type != model.AttributeTypeNumber {
+			return "p.properties->>?::text IN (?)", []interface{}{name, n.Values}, nil
+		}
+		var conds []string
+		var args []interface{}
+		if n.Min != nil {
+			conds = append(conds, propertyNumber+" >= ?")
+			args = append(args, name, name, *n.Min)
+		}
+		if n.Max != nil {
+			conds = append(conds, propertyNumber+" <= ?")
+			args = append(args, name, name, *n.Max)
+		}
+		return strings.Join(conds, " AND "), args, nil
+	case *model.FilterSearch:
+		match, args := searchCondition("v", n.Query)
+		return "p.id IN (SELECT v.id FROM public.product_search v WHERE " + match + ")", args, nil
+	default:
+		return "", nil, fmt.Errorf("unknown filter node %T", node)
+	}
+}
+
+func compileFilterList(nodes []model.FilterNode, op string) (string, []interface{}, error) {
+	var conds []string
+	var args []interface{}
+	for _, node := range nodes {
+		cond, condArgs, err := compileProductFilter(node)
+		if err != nil {
+			return "", nil, err
+		}
+		if cond == "" {
+			continue
+		}
+		conds = append(conds, "("+cond+")")
+		args = append(args, condArgs...)
+	}
+	return strings.Join(conds, op), args, nil
+}
@@ -31,7 +31,7 @@ var (
 
 // BulkInsert inserts multiple images in the db
 func (s PgProductImageStore) BulkInsert(images []*model.ProductImage) *model.AppErr {
-	q := `INSERT INTO public.product_image (product_id, url, public_id, created_at, updated_at) VALUES(:product_id, :url, :public_id, :created_at, :updated_at)`
+	q := `INSERT INTO public.product_image (product_id, variant_id, url, public_id, created_at, updated_at) VALUES(:product_id, :variant_id, :url, :public_id, :created_at, :updated_at)`
 	if _, err := s.db.NamedExec(q, images); err != nil {
 		return model.NewAppErr("PgProductImageStore.BulkInsert", model.ErrInternal, locale.GetUserLocalizer("en"), msgBulkInsertImages, http.StatusInternalServerError, nil)
 	}
@@ -40,10 +40,10 @@ func (s PgProductImageStore) BulkInsert(images []*model.ProductImage) *model.App
 
 // Save inserts image in the product image table
 func (s PgProductImageStore) Save(pid int64, img *model.ProductImage) (*model.ProductImage, *model.AppErr) {
-	q := `INSERT INTO public.product_image (product_id, url, public_id, created_at, updated_at) VALUES(:product_id, :url, :public_id, :created_at, :updated_at) RETURNING id`
+	q := `INSERT INTO public.product_image (product_id, variant_id, url, public_id, created_at, updated_at) VALUES(:product_id, :variant_id, :url, :public_id, :created_at, :updated_at) RETURNING id`
 
 	var id int64
-	rows, err := s.db.NamedQuery(q, map[string]interface{}{"product_id": pid, "url": img.URL, "public_id": img.PublicID, "created_at": img.CreatedAt, "updated_at": img.UpdatedAt})
+	rows, err := s.db.NamedQuery(q, map[string]interface{}{"product_id": pid, "variant_id": img.VariantID, "url": img.URL, "public_id": img.PublicID, "created_at": img.CreatedAt, "updated_at": img.UpdatedAt})
 	if err != nil {
 		return nil, model.NewAppErr("PgProductImageStore.Save", model.ErrInternal, locale.GetUserLocalizer("en"), msgSaveBrand, http.StatusInternalServerError, nil)
 	}
@@ -65,7 +65,7 @@ func (s PgProductImageStore) Save(pid int64, img *model.ProductImage) (*model.Pr
 
 // Get gets single image by id
 func (s PgProductImageStore) Get(pid, id int64) (*model.ProductImage, *model.AppErr) {
-	q := `SELECT img.id AS id, img.product_id AS product_id, img.url AS url, img.public_id AS public_id, img.created_at AS created_at, img.updated_at AS updated_at FROM public.product_image img WHERE img.id = $1 AND product_id= $2`
+	q := `SELECT img.id AS id, img.product_id AS product_id, img.variant_id AS variant_id, img.url AS url, img.public_id AS public_id, img.created_at AS created_at, img.updated_at AS updated_at FROM public.product_image img WHERE img.id = $1 AND product_id= $2`
 	var img model.ProductImage
 	if err := s.db.Get(&img, q, id, pid); err != nil {
 		return nil, model.NewAppErr("PgProductImageStore.Get", model.ErrInternal, locale.GetUserLocalizer("en"), msgGetProductImage, http.StatusInternalServerError, nil)
@@ -75,7 +75,7 @@ func (s PgProductImageStore) Get(pid, id int64) (*model.ProductImage, *model.App
 
 // GetAll gets all product's images
 func (s PgProductImageStore) GetAll(pid int64) ([]*model.ProductImage, *model.AppErr) {
-	q := `SELECT img.id AS id, img.product_id AS product_id, img.url AS url, img.public_id AS public_id, img.created_at AS created_at, img.updated_at AS updated_at FROM public.product_image img WHERE img.product_id = $1`
+	q := `SELECT img.id AS id, img.product_id AS product_id, img.variant_id AS variant_id, img.url AS url, img.public_id AS public_id, img.created_at AS created_at, img.updated_at AS updated_at FROM public.product_image img WHERE img.product_id = $1`
 	imgs := make([]*model.ProductImage, 0)
 	if err := s.db.Select(&imgs, q, pid); err != nil {
 		return nil, model.NewAppErr("PgProductImageStore.GetAll", model.ErrInternal, locale.GetUserLocalizer("en"), msgGetProductImages, http.StatusInternalServerError, nil)
@@ -0,0 +1,117 @@
+package postgres
+
+import (
+	"net/http"
+
+	"github.com/dankobgd/ecommerce-shop/model"
+	"github.com/dankobgd/ecommerce-shop/store"
+	"github.com/dankobgd/ecommerce-shop/utils/locale"
+	"github.com/jmoiron/sqlx"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+// PgProductRecommendationStore is the postgres implementation
+type PgProductRecommendationStore struct {
+	PgStore
+}
+
+// NewPgProductRecommendationStore creates the new product recommendation store
+func NewPgProductRecommendationStore(pgst *PgStore) store.ProductRecommendationStore {
+	return &PgProductRecommendationStore{*pgst}
+}
+
+var (
+	msgRecomputeRecommendations = &i18n.Message{ID: "store.postgres.product_recommendation.recompute.app_error", Other: "could not recompute product recommendations"}
+	msgGetRecommendations       = &i18n.Message{ID: "store.postgres.product_recommendation.get.app_error", Other: "could not get product recommendations"}
+	msgGetCategoryBestSellers   = &i18n.Message{ID: "store.postgres.product_recommendation.best_sellers.app_error", Other: "could not get category best sellers"}
+)
+
+// recomputeRecommendations ranks the co-purchased product pairs of the baskets, a basket is the distinct products of the order
+const recomputeRecommendations = `WITH baskets AS (
+		SELECT DISTINCT od.order_id, od.product_id FROM public.order_detail od
+		JOIN public.order o ON o.id = od.order_id
+		WHERE o.status IN (?) AND o.created_at >= CURRENT_TIMESTAMP - make_interval(days => ?)
+	), popularity AS (
+		SELECT product_id, COUNT(*) AS orders FROM baskets GROUP BY product_id
+	), pairs AS (
+		SELECT a.product_id, b.product_id AS recommended_id, COUNT(*) AS support,
+		COUNT(*) / sqrt(MIN(pa.orders) * MIN(pb.orders)) AS score
+		FROM baskets a
+		JOIN baskets b ON a.order_id = b.order_id AND a.product_id <> b.product_id
+		JOIN popularity pa ON pa.product_id = a.product_id
+		JOIN popularity pb ON pb.product_id = b.product_id
+		GROUP BY a.product_id, b.product_id
+		HAVING COUNT(*) >= ?
+	), ranked AS (
+		SELECT *, ROW_NUMBER() OVER (PARTITION BY product_id ORDER BY score DESC, support DESC, recommended_id ASC) AS rank FROM pairs
+	)
+	INSERT INTO public.product_recommendation (product_id, recommended_id, score, support, rank, computed_at)
+	SELECT product_id, recommended_id, score, support, rank, CURRENT_TIMESTAMP FROM ranked WHERE rank <= ?`
+
+// Recompute replaces all recommendations with the ones computed from the order history, returns the number of the stored pairs
+// the old recommendations are served until the commit
+func (s PgProductRecommendationStore) Recompute(params model.RecommendationParams) (int, *model.AppErr) {
+	appErr := func() *model.AppErr {
+		return model.NewAppErr("PgProductRecommendationStore.Recompute", model.ErrInternal, locale.GetUserLocalizer("en"), msgRecomputeRecommendations, http.StatusInternalServerError, nil)
+	}
+
+	q, args, err := sqlx.In(recomputeRecommendations, model.PurchasedOrderStatuses, params.Days, params.MinSupport, params.TopN)
+	if err != nil {
+		return 0, appErr()
+	}
+
+	tx, err := s.beginx()
+	if err != nil {
+		return 0, appErr()
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM public.product_recommendation`); err != nil {
+		return 0, appErr()
+	}
+	res, err := tx.Exec(tx.Rebind(q), args...)
+	if err != nil {
+		return 0, appErr()
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, appErr()
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, appErr()
+	}
+	return int(n), nil
+}
+
+// GetRecommended gets the ids of the top recommended products of the product, the best first
+func (s PgProductRecommendationStore) GetRecommended(pid int64, limit int) ([]int64, *model.AppErr) {
+	ids := make([]int64, 0)
+	if err := s.db.Select(&ids, `SELECT recommended_id FROM public.product_recommendation WHERE product_id = $1 ORDER BY rank ASC LIMIT $2`, pid, limit); err != nil {
+		return nil, model.NewAppErr("PgProductRecommendationStore.GetRecommended", model.ErrInternal, locale.GetUserLocalizer("en"), msgGetRecommendations, http.StatusInternalServerError, nil)
+	}
+	return ids, nil
+}
+
+// GetCategoryBestSellers gets the ids of the most sold products of the category, without the excluded products
+func (s PgProductRecommendationStore) GetCategoryBestSellers(categoryID int64, exclude []int64, limit int) ([]int64, *model.AppErr) {
+	ids := make([]int64, 0)
+	if len(exclude) == 0 {
+		exclude = []int64{0}
+	}
+
+	q, args, err := sqlx.In(`SELECT od.product_id FROM public.order_detail od
+	JOIN public.order o ON o.id = od.order_id
+	JOIN public.product p ON p.id = od.product_id
+	WHERE p.category_id = ? AND o.status IN (?) AND od.product_id NOT IN (?)
+	GROUP BY od.product_id
+	ORDER BY SUM(od.quantity) DESC, od.product_id DESC
+	LIMIT ?`, categoryID, model.PurchasedOrderStatuses, exclude, limit)
+	if err != nil {
+		return nil, model.NewAppErr("PgProductRecommendationStore.GetCategoryBestSellers", model.ErrInternal, locale.GetUserLocalizer("en"), msgGetCategoryBestSellers, http.StatusInternalServerError, nil)
+	}
+	if err := s.db.Select(&ids, s.db.Rebind(q), args...); err != nil {
+		return nil, model.NewAppErr("PgProductRecommendationStore.GetCategoryBestSellers", model.ErrInternal, locale.GetUserLocalizer("en"), msgGetCategoryBestSellers, http.StatusInternalServerError, nil)
+	}
+	return ids, nil
+}
@@ -0,0 +1,102 @@
+package postgres
+
+import (
+	"net/http"
+
+	"github.com/dankobgd/ecommerce-shop/model"
+	"github.com/dankobgd/ecommerce-shop/store"
+	"github.com/dankobgd/ecommerce-shop/utils/locale"
+	"github.com/jmoiron/sqlx"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+// PgProductRelationStore is the postgres implementation
+type PgProductRelationStore struct {
+	PgStore
+}
+
+// NewPgProductRelationStore creates the new product relation store
+func NewPgProductRelationStore(pgst *PgStore) store.ProductRelationStore {
+	return &PgProductRelationStore{*pgst}
+}
+
+var (
+	msgGetProductRelations     = &i18n.Message{ID: "store.postgres.product_relation.get_all.app_error", Other: "could not get product relations"}
+	msgReplaceProductRelations = &i18n.Message{ID: "store.postgres.product_relation.replace.app_error", Other: "could not replace product relations"}
+	msgRelatedProductNotFound  = &i18n.Message{ID: "store.postgres.product_relation.replace.not_found.app_error", Other: "related product does not exist"}
+	msgGetCartSuggestions      = &i18n.Message{ID: "store.postgres.product_relation.cart_suggestions.app_error", Other: "could not get cart suggestions"}
+)
+
+// GetAll gets the relations of the product ordered by the type and the position, the empty type gets all types
+func (s PgProductRelationStore) GetAll(pid int64, typ model.ProductRelationType) ([]*model.ProductRelation, *model.AppErr) {
+	q := `SELECT * FROM public.product_relation WHERE product_id = $1 AND ($2 = '' OR type = $2) ORDER BY type ASC, position ASC`
+
+	var relations = make([]*model.ProductRelation, 0)
+	if err := s.db.Select(&relations, q, pid, string(typ)); err != nil {
+		return nil, model.NewAppErr("PgProductRelationStore.GetAll", model.ErrInternal, locale.GetUserLocalizer("en"), msgGetProductRelations, http.StatusInternalServerError, nil)
+	}
+	return relations, nil
+}
+
+// Replace replaces the relations of the type with the related products, in the given order
+func (s PgProductRelationStore) Replace(pid int64, typ model.ProductRelationType, relatedIDs []int) ([]*model.ProductRelation, *model.AppErr) {
+	tx, err := s.beginx()
+	if err != nil {
+		return nil, model.NewAppErr("PgProductRelationStore.Replace", model.ErrInternal, locale.GetUserLocalizer("en"), msgReplaceProductRelations, http.StatusInternalServerError, nil)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM public.product_relation WHERE product_id = $1 AND type = $2`, pid, string(typ)); err != nil {
+		return nil, model.NewAppErr("PgProductRelationStore.Replace", model.ErrInternal, locale.GetUserLocalizer("en"), msgReplaceProductRelations, http.StatusInternalServerError, nil)
+	}
+
+	if len(relatedIDs) > 0 {
+		relations := make([]*model.ProductRelation, 0, len(relatedIDs))
+		for i, id := range relatedIDs {
+			relations = append(relations, &model.ProductRelation{ProductID: pid, RelatedID: int64(id), Type: typ, Position: i})
+		}
+		if _, err := tx.NamedExec(`INSERT INTO public.product_relation (product_id, related_id, type, position) VALUES (:product_id, :related_id, :type, :position)`, relations); err != nil {
+			if IsForeignKeyConstraintViolationError(err) {
+				return nil, model.NewAppErr("PgProductRelationStore.Replace", model.ErrInvalid, locale.GetUserLocalizer("en"), msgRelatedProductNotFound, http.StatusBadRequest, nil)
+			}
+			return nil, model.NewAppErr("PgProductRelationStore.Replace", model.ErrInternal, locale.GetUserLocalizer("en"), msgReplaceProductRelations, http.StatusInternalServerError, nil)
+		}
+	}
+
+	var saved = make([]*model.ProductRelation, 0)
+	if err := tx.Select(&saved, `SELECT * FROM public.product_relation WHERE product_id = $1 AND type = $2 ORDER BY position ASC`, pid, string(typ)); err != nil {
+		return nil, model.NewAppErr("PgProductRelationStore.Replace", model.ErrInternal, locale.GetUserLocalizer("en"), msgReplaceProductRelations, http.StatusInternalServerError, nil)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, model.NewAppErr("PgProductRelationStore.Replace", model.ErrInternal, locale.GetUserLocalizer("en"), msgReplaceProductRelations, http.StatusInternalServerError, nil)
+	}
+	return saved, nil
+}
+
+// GetCartSuggestions gets the ids of the products related to the cart products by the relation types
+// the products that are already in the cart are skipped, the ones related to more cart products come first
+func (s PgProductRelationStore) GetCartSuggestions(pids []int64, types []model.ProductRelationType, limit int) ([]int64, *model.AppErr) {
+	ids := make([]int64, 0)
+	if len(pids) == 0 || len(types) == 0 {
+		return ids, nil
+	}
+
+	typeNames := make([]string, 0, len(types))
+	for _, t := range types {
+		typeNames = append(typeNames, string(t))
+	}
+
+	q, args, err := sqlx.In(`SELECT related_id FROM public.product_relation
+	WHERE product_id IN (?) AND type IN (?) AND related_id NOT IN (?)
+	GROUP BY related_id
+	ORDER BY COUNT(*) DESC, MIN(position) ASC, related_id ASC
+	LIMIT ?`, pids, typeNames, pids, limit)
+	if err != nil {
+		return nil, model.NewAppErr("PgProductRelationStore.GetCartSuggestions", model.ErrInternal, locale.GetUserLocalizer("en"), msgGetCartSuggestions, http.StatusInternalServerError, nil)
+	}
+	if err := s.db.Select(&ids, s.db.Rebind(q), args...); err != nil {
+		return nil, model.NewAppErr("PgProductRelationStore.GetCartSuggestions", model.ErrInternal, locale.GetUserLocalizer("en"), msgGetCartSuggestions, http.StatusInternalServerError, nil)
+	}
+	return ids, nil
+}
@@ -0,0 +1,133 @@
+package postgres
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/dankobgd/ecommerce-shop/model"
+	"github.com/dankobgd/ecommerce-shop/utils/locale"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+var msgSuggestProducts = &i18n.Message{ID: "store.postgres.product.suggest.app_error", Other: "could not get search suggestions"}
+
+// searchHeadlineOptions are the ts_headline options of the search hit snippets
+const (
+	searchNameHeadline        = "StartSel=<mark>, StopSel=</mark>, HighlightAll=true"
+	searchDescriptionHeadline = "StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=30, MinWords=10"
+)
+
+// Search returns the page of the search product results that match the listing filter, ordered by rank by default
+// the highlighted snippets are made only for the hits of the page, the fuzzy hits have no snippets
+// the index rows whose sale window has passed are skipped until the refresh job reindexes them
+func (s PgProductStore) Search(search *model.SearchQuery, filter model.FilterNode, sort model.ProductSort, limit, offset int) ([]*model.Product, *model.AppErr) {
+	cond, condArgs, err := compileProductFilter(filter)
+	if err != nil {
+		return nil, model.NewAppErr("PgProductStore.Search", model.ErrInternal, locale.GetUserLocalizer("en"), msgGetProducts, http.StatusInternalServerError, nil)
+	}
+	match, matchArgs := searchCondition("listing", search)
+	rank, rankArgs := searchRank("listing", search)
+
+	hits := `SELECT COUNT(*) OVER() AS total_count, listing.*, ` + rank + ` AS rank FROM public.product_search listing WHERE CURRENT_TIMESTAMP BETWEEN listing.pricing_sale_starts AND listing.pricing_sale_ends AND ` + match
+	if cond != "" {
+		hits += ` AND listing.id IN (SELECT p.id ` + productListingFrom + andCondition(cond) + `)`
+	}
+	hits += ` ` + productSortOrder(sort, "listing", "rank DESC") + ` LIMIT ? OFFSET ?`
+
+	headline := `NULL::text AS name_highlight, NULL::text AS description_highlight`
+	var args []interface{}
+	if !search.Fuzzy {
+		tsquery := search.TSQuery()
+		headline = `ts_headline(hits.name, to_tsquery(?), '` + searchNameHeadline + `') AS name_highlight,
+	ts_headline(hits.description, to_tsquery(?), '` + searchDescriptionHeadline + `') AS description_highlight`
+		args = append(args, tsquery, tsquery)
+	}
+
+	q := `SELECT hits.*, ` + headline + `
+	FROM (` + hits + `) AS hits
+	` + productSortOrder(sort, "hits", "hits.rank DESC")
+
+	args = append(append(append(args, rankArgs...), matchArgs...), condArgs...)
+	args = append(args, limit, offset)
+
+	var pj []productJoin
+	if err := s.selectIn(&pj, q, args...); err != nil {
+		return nil, model.NewAppErr("PgProductStore.Search", model.ErrInternal, locale.GetUserLocalizer("en"), msgGetProducts, http.StatusInternalServerError, nil)
+	}
+
+	products := make([]*model.Product, 0)
+	for _, x := range pj {
+		products = append(products, x.ToProduct())
+	}
+
+	return products, nil
+}
+
+// searchCondition returns the match condition of the search on the search view row
+// the fuzzy search matches the product, brand or category name by the trigram word similarity and still excludes the negated terms
+func searchCondition(alias string, search *model.SearchQuery) (string, []interface{}) {
+	if !search.Fuzzy {
+		return alias + ".tsv @@ to_tsquery(?)", []interface{}{search.TSQuery()}
+	}
+
+	text := search.FuzzyText()
+	cond := fmt.Sprintf("(?::text <%% %[1]s.name OR ?::text <%% %[1]s.brand_name OR ?::text <%% %[1]s.category_name)", alias)
+	args := []interface{}{text, text, text}
+	if negated := search.NegatedTSQuery(); negated != "" {
+		cond += " AND NOT " + alias + ".tsv @@ to_tsquery(?)"
+		args = append(args, negated)
+	}
+	return cond, args
+}
+
+// searchRank returns the rank of the search view row, the fuzzy rank is the best word similarity of the names
+func searchRank(alias string, search *model.SearchQuery) (string, []interface{}) {
+	if !search.Fuzzy {
+		return "ts_rank(" + alias + ".tsv, to_tsquery(?))", []interface{}{search.TSQuery()}
+	}
+	text := search.FuzzyText()
+	return fmt.Sprintf("GREATEST(word_similarity(?, %[1]s.name), word_similarity(?, %[1]s.brand_name), word_similarity(?, %[1]s.category_name))", alias), []interface{}{text, text, text}
+}
+
+// suggestSources are the name columns that the suggestions complete
+var suggestSources = []struct {
+	kind  model.SuggestionType
+	table string
+}{
+	{model.SuggestionTypeProduct, "public.product"},
+	{model.SuggestionTypeBrand, "public.brand"},
+	{model.SuggestionTypeCategory, "public.category"},
+	{model.SuggestionTypeTag, "public.tag"},
+}
+
+// Suggest returns the type-ahead completions of the product, brand, category and tag names
+// the names that start with the input come first, then the ones that contain it or are similar to it
+func (s PgProductStore) Suggest(input string, limit int) ([]*model.SearchSuggestion, *model.AppErr) {
+	escaped := escapeLike(input)
+
+	parts := make([]string, 0, len(suggestSources))
+	for _, src := range suggestSources {
+		parts = append(parts, fmt.Sprintf(`(SELECT '%s' AS type, x.id, x.name AS text, x.slug, x.name ILIKE input.prefix AS starts, word_similarity(input.term, x.name) AS score
+		FROM %s x, input
+		WHERE x.name ILIKE input.pattern OR input.term <%% x.name
+		ORDER BY starts DESC, score DESC, x.id
+		LIMIT %d)`, src.kind, src.table, limit))
+	}
+
+	q := `WITH input AS (SELECT ?::text AS term, ?::text AS prefix, ?::text AS pattern)
+	SELECT type, id, text, slug FROM (` + strings.Join(parts, "\nUNION ALL\n") + `) AS suggestions
+	ORDER BY starts DESC, score DESC, type, text
+	LIMIT ?`
+
+	suggestions := make([]*model.SearchSuggestion, 0)
+	if err := s.selectIn(&suggestions, q, input, escaped+"%", "%"+escaped+"%", limit); err != nil {
+		return nil, model.NewAppErr("PgProductStore.Suggest", model.ErrInternal, locale.GetUserLocalizer("en"), msgSuggestProducts, http.StatusInternalServerError, nil)
+	}
+	return suggestions, nil
+}
+
+// escapeLike escapes the like pattern characters of the input
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,31 @@
+package postgres
+
+import (
+	"fmt"
+
+	"github.com/dankobgd/ecommerce-shop/model"
+)
+
+// productSortOrders are the order by expressions of the listing sorts, %[1]s is the alias of the sorted listing
+// the listing rows have the product columns and the pricing_ prefixed current pricing columns
+var productSortOrders = map[model.ProductSort]string{
+	model.ProductSortPriceAsc:    "%[1]s.pricing_price ASC",
+	model.ProductSortPriceDesc:   "%[1]s.pricing_price DESC",
+	model.ProductSortNewest:      "%[1]s.created_at DESC",
+	model.ProductSortBestSelling: "(SELECT COALESCE(SUM(od.quantity), 0) FROM public.order_detail od WHERE od.product_id = %[1]s.id) DESC",
+	model.ProductSortTopRated:    "(SELECT AVG(pr.rating) FROM public.product_review pr WHERE pr.product_id = %[1]s.id) DESC NULLS LAST",
+	model.ProductSortDiscount:    "(%[1]s.pricing_original_price - %[1]s.pricing_price)::float / NULLIF(%[1]s.pricing_original_price, 0) DESC NULLS LAST",
+}
+
+// productSortOrder returns the order by clause of the listing sort, the default sort uses the fallback order
+// ties are broken by the product id so the pages stay stable
+func productSortOrder(sort model.ProductSort, alias string, fallback string) string {
+	order := fallback
+	if expr, ok := productSortOrders[sort]; ok {
+		order = fmt.Sprintf(expr, alias)
+	}
+	if order != "" {
+		order += ", "
+	}
+	return "ORDER BY " + order + alias + ".id DESC"
+}
@@ -1,10 +1,9 @@
 package postgres
 
 import (
+	"database/sql"
 	"fmt"
 	"net/http"
-	"strconv"
-	"strings"
 
 	"github.com/dankobgd/ecommerce-shop/model"
 	"github.com/dankobgd/ecommerce-shop/store"
@@ -38,21 +37,37 @@ var (
 	msgBulkDeleteProducts      = &i18n.Message{ID: "store.postgres.product.bulk_delete.app_error", Other: "could not bulk delete products"}
 )
 
-// BulkInsert inserts multiple products into db
+// BulkInsert inserts multiple products into db and sets their ids, the ids are returned in the insert order
 func (s PgProductStore) BulkInsert(products []*model.Product) *model.AppErr {
-	q := `INSERT INTO public.product (name, brand_id, category_id, slug, image_url, image_public_id, description, in_stock, sku, is_featured, created_at, updated_at, properties) 
-	VALUES (:name, :brand_id, :category_id, :slug, :image_url, :image_public_id, :description, :in_stock, :sku, :is_featured, :created_at, :updated_at, :properties)`
+	q := `INSERT INTO public.product (name, brand_id, category_id, slug, image_url, image_public_id, description, stock_quantity, sku, is_featured, created_at, updated_at, properties) 
+	VALUES (:name, :brand_id, :category_id, :slug, :image_url, :image_public_id, :description, :stock_quantity, :sku, :is_featured, :created_at, :updated_at, :properties) RETURNING id`
 
-	if _, err := s.db.NamedExec(q, products); err != nil {
+	appErr := func() *model.AppErr {
 		return model.NewAppErr("PgProductStore.BulkInsert", model.ErrInternal, locale.GetUserLocalizer("en"), msgBulkInsertProducts, http.StatusInternalServerError, nil)
 	}
+
+	rows, err := s.db.NamedQuery(q, products)
+	if err != nil {
+		return appErr()
+	}
+	defer rows.Close()
+
+	i := 0
+	for ; rows.Next() && i < len(products); i++ {
+		if err := rows.Scan(&products[i].ID); err != nil {
+			return appErr()
+		}
+	}
+	if err := rows.Err(); err != nil || i != len(products) {
+		return appErr()
+	}
 	return nil
 }
 
 // Save inserts the new product in the db
 func (s PgProductStore) Save(p *model.Product) (*model.Product, *model.AppErr) {
-	q := `INSERT INTO public.product (name, brand_id, category_id, slug, image_url, image_public_id, description, in_stock, sku, is_featured, created_at, updated_at, properties)
-		VALUES (:name, :brand_id, :category_id, :slug, :image_url, :image_public_id, :description, :in_stock, :sku, :is_featured, :created_at, :updated_at, :properties) RETURNING id`
+	q := `INSERT INTO public.product (name, brand_id, category_id, slug, image_url, image_public_id, description, stock_quantity, sku, is_featured, created_at, updated_at, properties)
+		VALUES (:name, :brand_id, :category_id, :slug, :image_url, :image_public_id, :description, :stock_quantity, :sku, :is_featured, :created_at, :updated_at, :properties) RETURNING id`
 
 	var id int64
 	rows, err := s.db.NamedQuery(q, p)
@@ -117,7 +132,7 @@ func (s PgProductStore) Get(id int64) (*model.Product, *model.AppErr) {
    pp.sale_starts AS pricing_sale_starts,
    pp.sale_ends AS pricing_sale_ends
 	 FROM public.product p
-	 LEFT JOIN product_pricing pp ON p.id = pp.product_id
+	 LEFT JOIN product_pricing pp ON p.id = pp.product_id AND pp.variant_id IS NULL
    LEFT JOIN brand b ON p.brand_id = b.id
 	 LEFT JOIN category c ON p.category_id = c.id
 	 WHERE CURRENT_TIMESTAMP BETWEEN pp.sale_starts AND pp.sale_ends
@@ -133,9 +148,18 @@ func (s PgProductStore) Get(id int64) (*model.Product, *model.AppErr) {
 	return pj.ToProduct(), nil
 }
 
-// GetAll returns all products
-func (s PgProductStore) GetAll(filters map[string][]string, limit, offset int) ([]*model.Product, *model.AppErr) {
-	baseQuery := `SELECT DISTINCT ON (p.id)
+// productListingFrom is the from clause of the product listings, the filter conditions are appended to it
+const productListingFrom = `FROM public.product p
+	LEFT JOIN product_pricing pp ON p.id = pp.product_id AND pp.variant_id IS NULL
+	LEFT JOIN brand b ON p.brand_id = b.id
+	LEFT JOIN category c ON p.category_id = c.id
+	LEFT JOIN product_tag pt ON p.id = pt.product_id
+	LEFT JOIN tag t on t.id = pt.tag_id
+	WHERE CURRENT_TIMESTAMP BETWEEN pp.sale_starts AND pp.sale_ends`
+
+// GetAll returns all products that match the filter
+func (s PgProductStore) GetAll(filter model.FilterNode, sort model.ProductSort, limit, offset int) ([]*model.Product, *model.AppErr) {
+	columns := `
 	p.*,
 	b.name AS brand_name,
 	b.slug AS brand_slug,
@@ -158,37 +182,13 @@ func (s PgProductStore) GetAll(filters map[string][]string, limit, offset int) (
 	pp.price AS pricing_price,
 	pp.original_price AS pricing_original_price,
   pp.sale_starts AS pricing_sale_starts,
-  pp.sale_ends AS pricing_sale_ends
-	FROM public.product p
-	LEFT JOIN product_pricing pp ON p.id = pp.product_id
-	LEFT JOIN brand b ON p.brand_id = b.id
-	LEFT JOIN category c ON p.category_id = c.id	
-	LEFT JOIN product_tag pt ON p.id = pt.product_id 
-	LEFT JOIN tag t on t.id = pt.tag_id
-	WHERE CURRENT_TIMESTAMP BETWEEN pp.sale_starts AND pp.sale_ends`
-
-	q, args, _ := buildProductsFilterSearchQuery(baseQuery, filters, limit, offset)
+  pp.sale_ends AS pricing_sale_ends`
 
-	// basically add there where condition inside the select count for getting the total for pagination
-	finalSplit := strings.SplitAfter(q, "ON (p.id)")
-	lastPart := strings.SplitAfter(q, "pt.tag_id")[1]
-
-	var wherePart string
-	if i := strings.Index(lastPart, "GROUP BY"); i >= 0 {
-		wherePart = lastPart[:i]
+	finalQuery, args, err := buildProductsFilterSearchQuery(columns, filter, sort, limit, offset)
+	if err != nil {
+		return nil, model.NewAppErr("PgProductStore.GetAll", model.ErrInternal, locale.GetUserLocalizer("en"), msgGetProducts, http.StatusInternalServerError, nil)
 	}
 
-	countQuery := fmt.Sprintf(`(
-		SELECT COUNT(DISTINCT p.id) FROM product p 
-		LEFT JOIN product_pricing pp on p.id = pp.product_id 
-		LEFT JOIN brand b ON p.brand_id = b.id 
-		LEFT JOIN category c ON p.category_id = c.id 
-		LEFT JOIN product_tag pt ON p.id = pt.product_id 
-		LEFT JOIN tag t on t.id = pt.tag_id %s
-		) AS total_count,`, wherePart)
-
-	finalQuery := fmt.Sprintf("%s %s %s", finalSplit[0], countQuery, finalSplit[1])
-
 	var pj []productJoin
 	if err := s.db.Select(&pj, finalQuery, args...); err != nil {
 		return nil, model.NewAppErr("PgProductStore.GetAll", model.ErrInternal, locale.GetUserLocalizer("en"), msgGetProducts, http.StatusInternalServerError, nil)
@@ -205,7 +205,7 @@ func (s PgProductStore) GetAll(filters map[string][]string, limit, offset int) (
 // ListByIDS returns all products where ids are in slice
 func (s PgProductStore) ListByIDS(ids []int64) ([]*model.Product, *model.AppErr) {
 	q, args, err := sqlx.In(`SELECT DISTINCT ON (p.id)
-	 (SELECT COUNT(DISTINCT product.id) FROM product LEFT JOIN product_pricing on product.id = product_pricing.product_id WHERE CURRENT_TIMESTAMP BETWEEN pp.sale_starts AND pp.sale_ends AND product.id IN (?)) AS total_count,
+	 (SELECT COUNT(DISTINCT product.id) FROM product LEFT JOIN product_pricing on product.id = product_pricing.product_id AND product_pricing.variant_id IS NULL WHERE CURRENT_TIMESTAMP BETWEEN pp.sale_starts AND pp.sale_ends AND product.id IN (?)) AS total_count,
 	 p.*,
    b.name AS brand_name,
    b.slug AS brand_slug,
@@ -230,7 +230,7 @@ func (s PgProductStore) ListByIDS(ids []int64) ([]*model.Product, *model.AppErr)
    pp.sale_starts AS pricing_sale_starts,
    pp.sale_ends AS pricing_sale_ends
 	 FROM public.product p
-	 LEFT JOIN product_pricing pp ON p.id = pp.product_id
+	 LEFT JOIN product_pricing pp ON p.id = pp.product_id AND pp.variant_id IS NULL
    LEFT JOIN brand b ON p.brand_id = b.id
 	 LEFT JOIN category c ON p.category_id = c.id
 	 WHERE CURRENT_TIMESTAMP BETWEEN pp.sale_starts AND pp.sale_ends
@@ -255,7 +255,7 @@ func (s PgProductStore) ListByIDS(ids []int64) ([]*model.Product, *model.AppErr)
 
 // Update updates the product
 func (s PgProductStore) Update(id int64, p *model.Product) (*model.Product, *model.AppErr) {
-	q := `UPDATE public.product SET brand_id=:brand_id, category_id=:category_id, name=:name, slug=:slug, image_url=:image_url, image_public_id=:image_public_id, description=:description, in_stock=:in_stock, sku=:sku, is_featured=:is_featured, updated_at=:updated_at, properties=:properties WHERE id=:id`
+	q := `UPDATE public.product SET brand_id=:brand_id, category_id=:category_id, name=:name, slug=:slug, image_url=:image_url, image_public_id=:image_public_id, description=:description, sku=:sku, is_featured=:is_featured, updated_at=:updated_at, properties=:properties WHERE id=:id`
 	if _, err := s.db.NamedExec(q, p); err != nil {
 		return nil, model.NewAppErr("PgProductStore.Update", model.ErrInternal, locale.GetUserLocalizer("en"), msgUpdateProduct, http.StatusInternalServerError, nil)
 	}
@@ -271,9 +271,9 @@ func (s PgProductStore) Delete(id int64) *model.AppErr {
 }
 
 // GetFeatured returns featured products
-func (s PgProductStore) GetFeatured(limit, offset int) ([]*model.Product, *model.AppErr) {
-	q := `SELECT DISTINCT ON (p.id)
-	(SELECT COUNT(DISTINCT product.id) FROM product LEFT JOIN product_pricing on product.id = product_pricing.product_id WHERE CURRENT_TIMESTAMP BETWEEN pp.sale_starts AND pp.sale_ends AND product.is_featured = true) AS total_count,
+func (s PgProductStore) GetFeatured(sort model.ProductSort, limit, offset int) ([]*model.Product, *model.AppErr) {
+	q := `SELECT * FROM (SELECT DISTINCT ON (p.id)
+	(SELECT COUNT(DISTINCT product.id) FROM product LEFT JOIN product_pricing on product.id = product_pricing.product_id AND product_pricing.variant_id IS NULL WHERE CURRENT_TIMESTAMP BETWEEN pp.sale_starts AND pp.sale_ends AND product.is_featured = true) AS total_count,
 	p.*,
 	b.name AS brand_name,
 	b.slug AS brand_slug,
@@ -298,13 +298,15 @@ func (s PgProductStore) GetFeatured(limit, offset int) ([]*model.Product, *model
 	pp.sale_starts AS pricing_sale_starts,
 	pp.sale_ends AS pricing_sale_ends
 	FROM public.product p
-	LEFT JOIN product_pricing pp ON p.id = pp.product_id
+	LEFT JOIN product_pricing pp ON p.id = pp.product_id AND pp.variant_id IS NULL
 	LEFT JOIN brand b ON p.brand_id = b.id
 	LEFT JOIN category c ON p.category_id = c.id
 	WHERE CURRENT_TIMESTAMP BETWEEN pp.sale_starts AND pp.sale_ends
 	AND p.is_featured = true
 	GROUP BY p.id, b.id, c.id, pp.id
 	ORDER BY p.id DESC, pp.id DESC
+	) AS listing
+	` + productSortOrder(sort, "listing", "") + `
 	LIMIT $1 OFFSET $2`
 
 	var pj []productJoin
@@ -347,7 +349,7 @@ func (s PgProductStore) GetMostSold(limit, offset int) ([]*model.Product, *model
 	pp.sale_starts AS pricing_sale_starts,
 	pp.sale_ends AS pricing_sale_ends
 	FROM public.product p
-	LEFT JOIN product_pricing pp ON p.id = pp.product_id
+	LEFT JOIN product_pricing pp ON p.id = pp.product_id AND pp.variant_id IS NULL
 	LEFT JOIN brand b ON p.brand_id = b.id
 	LEFT JOIN category c ON p.category_id = c.id
 	INNER JOIN order_detail od ON p.id = od.product_id
@@ -396,7 +398,7 @@ func (s PgProductStore) GetBestDeals(limit, offset int) ([]*model.Product, *mode
 	pp.sale_starts AS pricing_sale_starts,
 	pp.sale_ends AS pricing_sale_ends
 	FROM public.product p
-	LEFT JOIN product_pricing pp ON p.id = pp.product_id
+	LEFT JOIN product_pricing pp ON p.id = pp.product_id AND pp.variant_id IS NULL
 	LEFT JOIN brand b ON p.brand_id = b.id
 	LEFT JOIN category c ON p.category_id = c.id
 	WHERE CURRENT_TIMESTAMP BETWEEN pp.sale_starts AND pp.sale_ends
@@ -442,28 +444,14 @@ func (s PgProductStore) GetReviews(id int64) ([]*model.ProductReview, *model.App
 	return reviews, nil
 }
 
-// Search returns all fulltext search product results
-func (s PgProductStore) Search(filter string) ([]*model.Product, *model.AppErr) {
-	q := `SELECT *, ts_rank(tsv, plainto_tsquery($1)) as rank FROM product_search_view WHERE tsv @@ plainto_tsquery($1) order by rank desc limit 200`
-
-	var pj []productJoin
-	if err := s.db.Select(&pj, q, filter); err != nil {
-		return nil, model.NewAppErr("PgProductStore.Search", model.ErrInternal, locale.GetUserLocalizer("en"), msgGetProducts, http.StatusInternalServerError, nil)
-	}
-
-	products := make([]*model.Product, 0)
-	for _, x := range pj {
-		products = append(products, x.ToProduct())
-	}
-
-	return products, nil
-}
-
-// GetLatestPricing gets latest pricing record
-func (s PgProductStore) GetLatestPricing(pid int64) (*model.ProductPricing, *model.AppErr) {
-	q := `SELECT pp.id AS price_id, pp.product_id, pp.price, pp.original_price, pp.sale_starts, pp.sale_ends FROM product_pricing pp WHERE product_id = $1 ORDER BY id DESC LIMIT 1`
+// GetLatestPricing gets latest pricing record of the product, or of its variant when the variant id is given
+func (s PgProductStore) GetLatestPricing(pid int64, variantID *int64) (*model.ProductPricing, *model.AppErr) {
+	q := `SELECT pp.id AS price_id, pp.product_id, pp.variant_id, pp.price, pp.original_price, pp.sale_starts, pp.sale_ends FROM product_pricing pp WHERE product_id = $1 AND variant_id IS NOT DISTINCT FROM $2 ORDER BY id DESC LIMIT 1`
 	var pricing model.ProductPricing
-	if err := s.db.Get(&pricing, q, pid); err != nil {
+	if err := s.db.Get(&pricing, q, pid, variantID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, model.NewAppErr("PgProductStore.GetLatestPricing", model.ErrNotFound, locale.GetUserLocalizer("en"), msgGetPricing, http.StatusNotFound, nil)
+		}
 		return nil, model.NewAppErr("PgProductStore.GetLatestPricing", model.ErrInternal, locale.GetUserLocalizer("en"), msgGetPricing, http.StatusInternalServerError, nil)
 	}
 
@@ -486,7 +474,7 @@ func (s PgProductStore) BulkDelete(ids []int) *model.AppErr {
 
 // InsertPricing inserts the price info into product_pricing
 func (s PgProductStore) InsertPricing(pricing *model.ProductPricing) (*model.ProductPricing, *model.AppErr) {
-	q := `INSERT INTO product_pricing(product_id, price, original_price, sale_starts, sale_ends) VALUES(:product_id, :price, :original_price, :sale_starts, :sale_ends) RETURNING id`
+	q := `INSERT INTO product_pricing(product_id, variant_id, price, original_price, sale_starts, sale_ends) VALUES(:product_id, :variant_id, :price, :original_price, :sale_starts, :sale_ends) RETURNING id`
 
 	var id int64
 	rows, err := s.db.NamedQuery(q, pricing)
@@ -510,7 +498,7 @@ func (s PgProductStore) InsertPricing(pricing *model.ProductPricing) (*model.Pro
 
 // InsertPricingBulk bulk inserts the price info into product_pricing
 func (s PgProductStore) InsertPricingBulk(pricing []*model.ProductPricing) *model.AppErr {
-	q := `INSERT INTO product_pricing(product_id, price, original_price, sale_starts, sale_ends) VALUES(:product_id, :price, :original_price, :sale_starts, :sale_ends) RETURNING id`
+	q := `INSERT INTO product_pricing(product_id, variant_id, price, original_price, sale_starts, sale_ends) VALUES(:product_id, :variant_id, :price, :original_price, :sale_starts, :sale_ends) RETURNING id`
 	if _, err := s.db.NamedExec(q, pricing); err != nil {
 		return model.NewAppErr("PgProductStore.InsertPricingBulk", model.ErrConflict, locale.GetUserLocalizer("en"), msgUniqueConstraintProduct, http.StatusInternalServerError, nil)
 	}
@@ -526,152 +514,33 @@ func (s PgProductStore) UpdatePricing(pricing *model.ProductPricing) (*model.Pro
 	return pricing, nil
 }
 
-// generate dynamic search query for get all products (shop search page with all sidebar filters...)
-
-type prop struct {
-	name   string
-	values []string
-}
-type categoryFilter struct {
-	category string
-	props    []prop
-}
-
-func containsCat(arr []categoryFilter, f categoryFilter) (bool, int) {
-	for i, x := range arr {
-		if x.category == f.category {
-			return true, i
-		}
-	}
-	return false, -1
-}
-func containsProp(arr []prop, p prop) (bool, int) {
-	for i, x := range arr {
-		if x.name == p.name {
-			return true, i
-		}
-	}
-	return false, -1
-}
-
-func buildProductsFilterSearchQuery(queryString string, filters map[string][]string, limit, offset int) (string, []interface{}, error) {
-	basic := make(map[string][]string, 0)
-	specific := make(map[string][]string, 0)
-
-	for filter, val := range filters {
-		if filter == "page" || filter == "per_page" || filter == "category" || filter == "brand" || filter == "tag" || filter == "price_min" || filter == "price_max" {
-			basic[filter] = val
-		} else {
-			specific[filter] = val
-		}
-	}
-
-	query := fmt.Sprintf("%s", queryString)
-	var args []interface{}
-
-	// handle price range filters
-	min, minOk := basic["price_min"]
-	max, maxOk := basic["price_max"]
-	if minOk && maxOk {
-		query += " AND (pp.price >= ? AND pp.price <= ?)\n"
-		args = append(args, min[0], max[0])
-	} else if minOk && !maxOk {
-		query += " AND pp.price >= ?\n"
-		args = append(args, min[0])
-	} else if !minOk && maxOk {
-		query += " AND pp.price <= ?\n"
-		args = append(args, max[0])
-	}
-
-	// handle brand filters
-	if brand, ok := basic["brand"]; ok {
-		query += " AND b.slug IN (?)\n"
-		args = append(args, brand)
+// buildProductsFilterSearchQuery builds the listing query of the columns with the compiled filter
+// the total count subquery has the same condition, so its args are bound twice
+// the distinct listing is sorted in the outer query, since distinct on requires its own order
+func buildProductsFilterSearchQuery(columns string, filter model.FilterNode, sort model.ProductSort, limit, offset int) (string, []interface{}, error) {
+	cond, condArgs, err := compileProductFilter(filter)
+	if err != nil {
+		return "", nil, err
 	}
 
-	// handle tag filters
-	if tag, ok := basic["tag"]; ok {
-		query += " AND t.slug IN (?)\n"
-		args = append(args, tag)
+	from := productListingFrom
+	if cond != "" {
+		from += "\nAND " + cond
 	}
 
-	// handle product category specific property filters
-	if category, ok := basic["category"]; ok {
-		var filtersList = make([]categoryFilter, 0)
-
-		for _, cat := range category {
-			filtersList = append(filtersList, categoryFilter{category: cat, props: make([]prop, 0)})
-		}
-
-		propsList := make([]prop, 0)
-		for filter, values := range specific {
-			full := strings.SplitN(filter, "_", 2)
-			catName, propName := full[0], full[1]
-
-			p := prop{name: propName, values: values}
-			ok, _ := containsProp(propsList, p)
-			if !ok {
-				propsList = append(propsList, p)
-			}
-
-			f := categoryFilter{category: catName, props: propsList}
-			ok2, idx := containsCat(filtersList, f)
-			if ok2 {
-				filtersList[idx].props = append(filtersList[idx].props, p)
-			}
-		}
-
-		for i, cat := range filtersList {
-			str := ""
-			outerClause := ""
-			outerParensClose := ""
-
-			if i == 0 {
-				outerClause = "AND (\n"
-			} else {
-				outerClause = "OR\n"
-			}
-			if i == len(filtersList)-1 {
-				outerParensClose = "\n)"
-			}
-
-			for _, prop := range cat.props {
-				innerClause := ""
-
-				for k, val := range prop.values {
-					groupStart := ""
-					groupEnd := ""
-					if len(prop.values) > 1 {
-						if k == 0 {
-							groupStart = "("
-						}
-						if k == len(prop.values)-1 {
-							groupEnd = ")"
-						}
-					}
-
-					if k == 0 {
-						innerClause = "AND"
-					} else {
-						innerClause = "OR"
-					}
-
-					str += fmt.Sprintf(" %s %sp.properties->>'%s' = '%s'%s", innerClause, groupStart, prop.name, val, groupEnd)
-				}
-			}
-			query += fmt.Sprintf(" %s (c.slug = '%s'%s)%s", outerClause, cat.category, str, outerParensClose)
-		}
-	}
+	query := fmt.Sprintf("SELECT DISTINCT ON (p.id)\n(SELECT COUNT(DISTINCT p.id) %s) AS total_count,%s\n%s", from, columns, from)
+	args := append(append([]interface{}{}, condArgs...), condArgs...)
 
 	query += " \nGROUP BY p.id, b.id, c.id, pp.id"
 	query += " \nORDER BY p.id DESC, pp.id DESC"
+	query = "SELECT * FROM (" + query + ") AS listing\n" + productSortOrder(sort, "listing", "")
 	if limit != 0 {
 		query += " LIMIT ?"
-		args = append(args, strconv.Itoa(limit))
+		args = append(args, limit)
 	}
 	if offset != 0 {
 		query += " OFFSET ?"
-		args = append(args, strconv.Itoa(offset))
+		args = append(args, offset)
 	}
 
 	builtQuery, builtQueryArgs, err := sqlx.In(query, args...)
@@ -98,10 +98,9 @@ func (s PgProductTagStore) Replace(pid int64, tagIDs []int) ([]*model.ProductTag
 		ptags = append(ptags, &model.ProductTag{TagID: model.NewInt64(int64(id)), ProductID: model.NewInt64(pid)})
 	}
 
-	tx, txErr := s.db.Beginx()
+	tx, txErr := s.beginx()
 
 	if txErr != nil {
-		tx.Rollback()
 		return nil, model.NewAppErr("PgProductStore.Replace", model.ErrInternal, locale.GetUserLocalizer("en"), msgReplaceProductTags, http.StatusInternalServerError, nil)
 	}
 
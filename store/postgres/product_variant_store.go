@@ -0,0 +1,248 @@
+package postgres
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/dankobgd/ecommerce-shop/model"
+	"github.com/dankobgd/ecommerce-shop/store"
+	"github.com/dankobgd/ecommerce-shop/utils/locale"
+	"github.com/jmoiron/sqlx"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+// PgProductVariantStore is the postgres implementation
+type PgProductVariantStore struct {
+	PgStore
+}
+
+// NewPgProductVariantStore creates the new product variant store
+func NewPgProductVariantStore(pgst *PgStore) store.ProductVariantStore {
+	return &PgProductVariantStore{*pgst}
+}
+
+var (
+	msgSaveProductVariant     = &i18n.Message{ID: "store.postgres.product_variant.save.app_error", Other: "could not save product variant"}
+	msgUniqueProductVariant   = &i18n.Message{ID: "store.postgres.product_variant.save.unique_constraint.app_error", Other: "product variant with the same options already exists"}
+	msgGetProductVariant      = &i18n.Message{ID: "store.postgres.product_variant.get.app_error", Other: "could not get product variant"}
+	msgProductVariantNotFound = &i18n.Message{ID: "store.postgres.product_variant.get.not_found.app_error", Other: "product variant not found"}
+	msgGetProductVariants     = &i18n.Message{ID: "store.postgres.product_variant.get_all.app_error", Other: "could not get product variants"}
+	msgCreateDefaultVariants  = &i18n.Message{ID: "store.postgres.product_variant.create_defaults.app_error", Other: "could not create default product variants"}
+	msgUpdateProductVariant   = &i18n.Message{ID: "store.postgres.product_variant.update.app_error", Other: "could not update product variant"}
+	msgDeleteProductVariant   = &i18n.Message{ID: "store.postgres.product_variant.delete.app_error", Other: "could not delete product variant"}
+	msgGetProductOptions      = &i18n.Message{ID: "store.postgres.product_option.get_all.app_error", Other: "could not get product options"}
+	msgReplaceProductOptions  = &i18n.Message{ID: "store.postgres.product_option.replace.app_error", Other: "could not replace product options"}
+)
+
+// variantSelect selects the variants with their current price, the variant without its own pricing gets the product price
+const variantSelect = `SELECT v.*, COALESCE(vp.price, pp.price, 0) AS price, COALESCE(vp.original_price, pp.original_price, 0) AS original_price
+	FROM public.product_variant v
+	LEFT JOIN LATERAL (
+		SELECT price, original_price FROM public.product_pricing
+		WHERE variant_id = v.id AND CURRENT_TIMESTAMP BETWEEN sale_starts AND sale_ends ORDER BY id DESC LIMIT 1
+	) vp ON true
+	LEFT JOIN LATERAL (
+		SELECT price, original_price FROM public.product_pricing
+		WHERE product_id = v.product_id AND variant_id IS NULL AND CURRENT_TIMESTAMP BETWEEN sale_starts AND sale_ends ORDER BY id DESC LIMIT 1
+	) pp ON true`
+
+// Save inserts the new variant with its own pricing when it has the price
+// the variant starts without stock, the stock is added through the inventory
+func (s PgProductVariantStore) Save(v *model.ProductVariant) (*model.ProductVariant, *model.AppErr) {
+	tx, err := s.beginx()
+	if err != nil {
+		return nil, model.NewAppErr("PgProductVariantStore.Save", model.ErrInternal, locale.GetUserLocalizer("en"), msgSaveProductVariant, http.StatusInternalServerError, nil)
+	}
+	defer tx.Rollback()
+
+	q := `INSERT INTO public.product_variant (product_id, sku, options, is_default, created_at, updated_at) VALUES (:product_id, :sku, :options, :is_default, :created_at, :updated_at) RETURNING id`
+
+	var id int64
+	rows, err := tx.NamedQuery(q, v)
+	if err != nil {
+		return nil, model.NewAppErr("PgProductVariantStore.Save", model.ErrInternal, locale.GetUserLocalizer("en"), msgSaveProductVariant, http.StatusInternalServerError, nil)
+	}
+	for rows.Next() {
+		rows.Scan(&id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		if IsUniqueConstraintViolationError(err) {
+			return nil, model.NewAppErr("PgProductVariantStore.Save", model.ErrConflict, locale.GetUserLocalizer("en"), msgUniqueProductVariant, http.StatusConflict, nil)
+		}
+		return nil, model.NewAppErr("PgProductVariantStore.Save", model.ErrInternal, locale.GetUserLocalizer("en"), msgSaveProductVariant, http.StatusInternalServerError, nil)
+	}
+
+	if v.Price > 0 {
+		pricing := &model.ProductPricing{
+			ProductID:     v.ProductID,
+			VariantID:     &id,
+			Price:         v.Price,
+			OriginalPrice: v.OriginalPrice,
+			SaleStarts:    v.CreatedAt,
+			SaleEnds:      model.FutureSaleEndsTime,
+		}
+		if _, err := tx.NamedExec(`INSERT INTO product_pricing(product_id, variant_id, price, original_price, sale_starts, sale_ends) VALUES(:product_id, :variant_id, :price, :original_price, :sale_starts, :sale_ends)`, pricing); err != nil {
+			return nil, model.NewAppErr("PgProductVariantStore.Save", model.ErrInternal, locale.GetUserLocalizer("en"), msgSaveProductVariant, http.StatusInternalServerError, nil)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, model.NewAppErr("PgProductVariantStore.Save", model.ErrInternal, locale.GetUserLocalizer("en"), msgSaveProductVariant, http.StatusInternalServerError, nil)
+	}
+
+	return s.Get(v.ProductID, id)
+}
+
+// Get gets the variant of the product
+func (s PgProductVariantStore) Get(pid, vid int64) (*model.ProductVariant, *model.AppErr) {
+	var v model.ProductVariant
+	if err := s.db.Get(&v, variantSelect+` WHERE v.id = $1 AND v.product_id = $2`, vid, pid); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, model.NewAppErr("PgProductVariantStore.Get", model.ErrNotFound, locale.GetUserLocalizer("en"), msgProductVariantNotFound, http.StatusNotFound, nil)
+		}
+		return nil, model.NewAppErr("PgProductVariantStore.Get", model.ErrInternal, locale.GetUserLocalizer("en"), msgGetProductVariant, http.StatusInternalServerError, nil)
+	}
+	return &v, nil
+}
+
+// GetDefault gets the default variant of the product
+func (s PgProductVariantStore) GetDefault(pid int64) (*model.ProductVariant, *model.AppErr) {
+	var v model.ProductVariant
+	if err := s.db.Get(&v, variantSelect+` WHERE v.product_id = $1 AND v.is_default`, pid); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, model.NewAppErr("PgProductVariantStore.GetDefault", model.ErrNotFound, locale.GetUserLocalizer("en"), msgProductVariantNotFound, http.StatusNotFound, nil)
+		}
+		return nil, model.NewAppErr("PgProductVariantStore.GetDefault", model.ErrInternal, locale.GetUserLocalizer("en"), msgGetProductVariant, http.StatusInternalServerError, nil)
+	}
+	return &v, nil
+}
+
+// GetAll gets all variants of the product, the default one first
+func (s PgProductVariantStore) GetAll(pid int64) ([]*model.ProductVariant, *model.AppErr) {
+	var variants = make([]*model.ProductVariant, 0)
+	if err := s.db.Select(&variants, variantSelect+` WHERE v.product_id = $1 ORDER BY v.is_default DESC, v.id ASC`, pid); err != nil {
+		return nil, model.NewAppErr("PgProductVariantStore.GetAll", model.ErrInternal, locale.GetUserLocalizer("en"), msgGetProductVariants, http.StatusInternalServerError, nil)
+	}
+	return variants, nil
+}
+
+// ListByIDS gets the variants with the given ids
+func (s PgProductVariantStore) ListByIDS(ids []int64) ([]*model.ProductVariant, *model.AppErr) {
+	var variants = make([]*model.ProductVariant, 0)
+	if len(ids) == 0 {
+		return variants, nil
+	}
+
+	q, args, err := sqlx.In(variantSelect+` WHERE v.id IN (?)`, ids)
+	if err != nil {
+		return nil, model.NewAppErr("PgProductVariantStore.ListByIDS", model.ErrInternal, locale.GetUserLocalizer("en"), msgGetProductVariants, http.StatusInternalServerError, nil)
+	}
+	if err := s.db.Select(&variants, s.db.Rebind(q), args...); err != nil {
+		return nil, model.NewAppErr("PgProductVariantStore.ListByIDS", model.ErrInternal, locale.GetUserLocalizer("en"), msgGetProductVariants, http.StatusInternalServerError, nil)
+	}
+	return variants, nil
+}
+
+// ListDefaultsByProductIDS gets the default variants of the given products
+func (s PgProductVariantStore) ListDefaultsByProductIDS(pids []int64) ([]*model.ProductVariant, *model.AppErr) {
+	var variants = make([]*model.ProductVariant, 0)
+	if len(pids) == 0 {
+		return variants, nil
+	}
+
+	q, args, err := sqlx.In(variantSelect+` WHERE v.product_id IN (?) AND v.is_default`, pids)
+	if err != nil {
+		return nil, model.NewAppErr("PgProductVariantStore.ListDefaultsByProductIDS", model.ErrInternal, locale.GetUserLocalizer("en"), msgGetProductVariants, http.StatusInternalServerError, nil)
+	}
+	if err := s.db.Select(&variants, s.db.Rebind(q), args...); err != nil {
+		return nil, model.NewAppErr("PgProductVariantStore.ListDefaultsByProductIDS", model.ErrInternal, locale.GetUserLocalizer("en"), msgGetProductVariants, http.StatusInternalServerError, nil)
+	}
+	return variants, nil
+}
+
+// CreateDefaults creates the default variants of the products that don't have one yet
+// the default variant takes over the product sku and stock, and is sold for the product price
+func (s PgProductVariantStore) CreateDefaults(pids []int64) *model.AppErr {
+	if len(pids) == 0 {
+		return nil
+	}
+
+	q, args, err := sqlx.In(`INSERT INTO public.product_variant (product_id, sku, stock_quantity, is_default, created_at, updated_at)
+		SELECT id, sku, stock_quantity, true, created_at, updated_at FROM public.product WHERE id IN (?)
+		ON CONFLICT DO NOTHING`, pids)
+	if err != nil {
+		return model.NewAppErr("PgProductVariantStore.CreateDefaults", model.ErrInternal, locale.GetUserLocalizer("en"), msgCreateDefaultVariants, http.StatusInternalServerError, nil)
+	}
+	if _, err := s.db.Exec(s.db.Rebind(q), args...); err != nil {
+		return model.NewAppErr("PgProductVariantStore.CreateDefaults", model.ErrInternal, locale.GetUserLocalizer("en"), msgCreateDefaultVariants, http.StatusInternalServerError, nil)
+	}
+	return nil
+}
+
+// Update updates the variant sku and options
+func (s PgProductVariantStore) Update(v *model.ProductVariant) (*model.ProductVariant, *model.AppErr) {
+	q := `UPDATE public.product_variant SET sku=:sku, options=:options, updated_at=:updated_at WHERE id=:id AND product_id=:product_id`
+	if _, err := s.db.NamedExec(q, v); err != nil {
+		if IsUniqueConstraintViolationError(err) {
+			return nil, model.NewAppErr("PgProductVariantStore.Update", model.ErrConflict, locale.GetUserLocalizer("en"), msgUniqueProductVariant, http.StatusConflict, nil)
+		}
+		return nil, model.NewAppErr("PgProductVariantStore.Update", model.ErrInternal, locale.GetUserLocalizer("en"), msgUpdateProductVariant, http.StatusInternalServerError, nil)
+	}
+	return v, nil
+}
+
+// Delete deletes the variant which is not the default one, its stock is removed from the product stock
+func (s PgProductVariantStore) Delete(pid, vid int64) *model.AppErr {
+	q := `WITH deleted AS (
+		DELETE FROM public.product_variant WHERE id = $1 AND product_id = $2 AND NOT is_default RETURNING product_id, stock_quantity
+	)
+	UPDATE public.product p SET stock_quantity = p.stock_quantity - d.stock_quantity FROM deleted d WHERE p.id = d.product_id`
+
+	if _, err := s.db.Exec(q, vid, pid); err != nil {
+		return model.NewAppErr("PgProductVariantStore.Delete", model.ErrInternal, locale.GetUserLocalizer("en"), msgDeleteProductVariant, http.StatusInternalServerError, nil)
+	}
+	return nil
+}
+
+// GetOptions gets the option axes of the product
+func (s PgProductVariantStore) GetOptions(pid int64) ([]*model.ProductOption, *model.AppErr) {
+	var opts = make([]*model.ProductOption, 0)
+	if err := s.db.Select(&opts, `SELECT * FROM public.product_option WHERE product_id = $1 ORDER BY position ASC, id ASC`, pid); err != nil {
+		return nil, model.NewAppErr("PgProductVariantStore.GetOptions", model.ErrInternal, locale.GetUserLocalizer("en"), msgGetProductOptions, http.StatusInternalServerError, nil)
+	}
+	return opts, nil
+}
+
+// ReplaceOptions replaces all option axes of the product, in the given order
+func (s PgProductVariantStore) ReplaceOptions(pid int64, opts []*model.ProductOption) ([]*model.ProductOption, *model.AppErr) {
+	tx, err := s.beginx()
+	if err != nil {
+		return nil, model.NewAppErr("PgProductVariantStore.ReplaceOptions", model.ErrInternal, locale.GetUserLocalizer("en"), msgReplaceProductOptions, http.StatusInternalServerError, nil)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM public.product_option WHERE product_id = $1`, pid); err != nil {
+		return nil, model.NewAppErr("PgProductVariantStore.ReplaceOptions", model.ErrInternal, locale.GetUserLocalizer("en"), msgReplaceProductOptions, http.StatusInternalServerError, nil)
+	}
+
+	if len(opts) > 0 {
+		for i, opt := range opts {
+			opt.ProductID = pid
+			opt.Position = i
+		}
+		if _, err := tx.NamedExec(`INSERT INTO public.product_option (product_id, name, "values", position) VALUES (:product_id, :name, :values, :position)`, opts); err != nil {
+			return nil, model.NewAppErr("PgProductVariantStore.ReplaceOptions", model.ErrInternal, locale.GetUserLocalizer("en"), msgReplaceProductOptions, http.StatusInternalServerError, nil)
+		}
+	}
+
+	var saved = make([]*model.ProductOption, 0)
+	if err := tx.Select(&saved, `SELECT * FROM public.product_option WHERE product_id = $1 ORDER BY position ASC, id ASC`, pid); err != nil {
+		return nil, model.NewAppErr("PgProductVariantStore.ReplaceOptions", model.ErrInternal, locale.GetUserLocalizer("en"), msgReplaceProductOptions, http.StatusInternalServerError, nil)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, model.NewAppErr("PgProductVariantStore.ReplaceOptions", model.ErrInternal, locale.GetUserLocalizer("en"), msgReplaceProductOptions, http.StatusInternalServerError, nil)
+	}
+	return saved, nil
+}
@@ -0,0 +1,142 @@
+package postgres
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/dankobgd/ecommerce-shop/model"
+	"github.com/dankobgd/ecommerce-shop/store"
+	"github.com/dankobgd/ecommerce-shop/utils/locale"
+	"github.com/jmoiron/sqlx"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+// PgPromotionCodeStore is the postgres implementation
+type PgPromotionCodeStore struct {
+	PgStore
+}
+
+// NewPgPromotionCodeStore creates the new promotion code store
+func NewPgPromotionCodeStore(pgst *PgStore) store.PromotionCodeStore {
+	return &PgPromotionCodeStore{*pgst}
+}
+
+var (
+	msgBulkInsertPromotionCodes      = &i18n.Message{ID: "store.postgres.promotion_code.bulk_insert.app_error", Other: "could not save promotion codes"}
+	msgUniqueConstraintPromotionCode = &i18n.Message{ID: "store.postgres.promotion_code.bulk_insert.unique_constraint.app_error", Other: "generated promotion code already exists"}
+	msgGetPromotionCode              = &i18n.Message{ID: "store.postgres.promotion_code.get.app_error", Other: "could not get promotion code"}
+	msgPromotionCodeNotFound         = &i18n.Message{ID: "store.postgres.promotion_code.get.not_found.app_error", Other: "promotion code not found"}
+	msgGetPromotionCodes             = &i18n.Message{ID: "store.postgres.promotion_code.get_all.app_error", Other: "could not get promotion codes"}
+	msgRedeemPromotionCode           = &i18n.Message{ID: "store.postgres.promotion_code.redeem.app_error", Other: "could not redeem promotion code"}
+	msgPromotionCodeRedeemed         = &i18n.Message{ID: "store.postgres.promotion_code.redeem.conflict.app_error", Other: "promotion code was already redeemed"}
+	msgReleasePromotionCodes         = &i18n.Message{ID: "store.postgres.promotion_code.release.app_error", Other: "could not release promotion codes"}
+)
+
+// promotionCodeInsertChunk is the number of the codes inserted by one statement, it keeps the statement under the postgres params limit
+const promotionCodeInsertChunk = 1000
+
+// BulkInsert inserts the generated codes of the campaign and marks the promotion as the campaign
+func (s PgPromotionCodeStore) BulkInsert(promoCode string, codes []*model.PromotionCode) *model.AppErr {
+	tx, err := s.beginx()
+	if err != nil {
+		return model.NewAppErr("PgPromotionCodeStore.BulkInsert", model.ErrInternal, locale.GetUserLocalizer("en"), msgBulkInsertPromotionCodes, http.StatusInternalServerError, nil)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`UPDATE public.promotion SET campaign = true WHERE promo_code = $1`, promoCode)
+	if err != nil {
+		return model.NewAppErr("PgPromotionCodeStore.BulkInsert", model.ErrInternal, locale.GetUserLocalizer("en"), msgBulkInsertPromotionCodes, http.StatusInternalServerError, nil)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return model.NewAppErr("PgPromotionCodeStore.BulkInsert", model.ErrNotFound, locale.GetUserLocalizer("en"), msgPromotionNotFound, http.StatusNotFound, nil)
+	}
+
+	q := `INSERT INTO public.promotion_code (code, promo_code, created_at) VALUES (:code, :promo_code, :created_at)`
+	for start := 0; start < len(codes); start += promotionCodeInsertChunk {
+		end := start + promotionCodeInsertChunk
+		if end > len(codes) {
+			end = len(codes)
+		}
+		if _, err := tx.NamedExec(q, codes[start:end]); err != nil {
+			if IsUniqueConstraintViolationError(err) {
+				return model.NewAppErr("PgPromotionCodeStore.BulkInsert", model.ErrConflict, locale.GetUserLocalizer("en"), msgUniqueConstraintPromotionCode, http.StatusConflict, nil)
+			}
+			return model.NewAppErr("PgPromotionCodeStore.BulkInsert", model.ErrInternal, locale.GetUserLocalizer("en"), msgBulkInsertPromotionCodes, http.StatusInternalServerError, nil)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return model.NewAppErr("PgPromotionCodeStore.BulkInsert", model.ErrInternal, locale.GetUserLocalizer("en"), msgBulkInsertPromotionCodes, http.StatusInternalServerError, nil)
+	}
+	return nil
+}
+
+// ListTaken gets the codes among the given ones that are already used by the generated codes or by the promo codes
+func (s PgPromotionCodeStore) ListTaken(codes []string) ([]string, *model.AppErr) {
+	taken := make([]string, 0)
+	if len(codes) == 0 {
+		return taken, nil
+	}
+
+	q, args, err := sqlx.In(`SELECT code FROM public.promotion_code WHERE code IN (?)
+	UNION SELECT promo_code FROM public.promotion WHERE promo_code IN (?)`, codes, codes)
+	if err != nil {
+		return nil, model.NewAppErr("PgPromotionCodeStore.ListTaken", model.ErrInternal, locale.GetUserLocalizer("en"), msgGetPromotionCodes, http.StatusInternalServerError, nil)
+	}
+	if err := s.db.Select(&taken, s.db.Rebind(q), args...); err != nil {
+		return nil, model.NewAppErr("PgPromotionCodeStore.ListTaken", model.ErrInternal, locale.GetUserLocalizer("en"), msgGetPromotionCodes, http.StatusInternalServerError, nil)
+	}
+	return taken, nil
+}
+
+// Get gets the generated code
+func (s PgPromotionCodeStore) Get(code string) (*model.PromotionCode, *model.AppErr) {
+	var c model.PromotionCode
+	if err := s.db.Get(&c, `SELECT * FROM public.promotion_code WHERE code = $1`, code); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, model.NewAppErr("PgPromotionCodeStore.Get", model.ErrNotFound, locale.GetUserLocalizer("en"), msgPromotionCodeNotFound, http.StatusNotFound, nil)
+		}
+		return nil, model.NewAppErr("PgPromotionCodeStore.Get", model.ErrInternal, locale.GetUserLocalizer("en"), msgGetPromotionCode, http.StatusInternalServerError, nil)
+	}
+	return &c, nil
+}
+
+// GetAll gets the page of the generated codes of the campaign, in the order they were generated
+func (s PgPromotionCodeStore) GetAll(promoCode string, limit, offset int) ([]*model.PromotionCode, *model.AppErr) {
+	var codes = make([]*model.PromotionCode, 0)
+	q := `SELECT COUNT(*) OVER() AS total_count, * FROM public.promotion_code WHERE promo_code = $1 ORDER BY created_at ASC, code ASC LIMIT $2 OFFSET $3`
+	if err := s.db.Select(&codes, q, promoCode, limit, offset); err != nil {
+		return nil, model.NewAppErr("PgPromotionCodeStore.GetAll", model.ErrInternal, locale.GetUserLocalizer("en"), msgGetPromotionCodes, http.StatusInternalServerError, nil)
+	}
+	return codes, nil
+}
+
+// ListByPromotion gets all generated codes of the campaign, in the order they were generated
+func (s PgPromotionCodeStore) ListByPromotion(promoCode string) ([]*model.PromotionCode, *model.AppErr) {
+	var codes = make([]*model.PromotionCode, 0)
+	if err := s.db.Select(&codes, `SELECT * FROM public.promotion_code WHERE promo_code = $1 ORDER BY created_at ASC, code ASC`, promoCode); err != nil {
+		return nil, model.NewAppErr("PgPromotionCodeStore.ListByPromotion", model.ErrInternal, locale.GetUserLocalizer("en"), msgGetPromotionCodes, http.StatusInternalServerError, nil)
+	}
+	return codes, nil
+}
+
+// Redeem marks the code as used by the order, the code that was already redeemed by another order is the conflict
+func (s PgPromotionCodeStore) Redeem(code string, userID, orderID int64) *model.AppErr {
+	q := `UPDATE public.promotion_code SET user_id = $2, order_id = $3, redeemed_at = now() WHERE code = $1 AND order_id IS NULL`
+	res, err := s.db.Exec(q, code, userID, orderID)
+	if err != nil {
+		return model.NewAppErr("PgPromotionCodeStore.Redeem", model.ErrInternal, locale.GetUserLocalizer("en"), msgRedeemPromotionCode, http.StatusInternalServerError, nil)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return model.NewAppErr("PgPromotionCodeStore.Redeem", model.ErrConflict, locale.GetUserLocalizer("en"), msgPromotionCodeRedeemed, http.StatusConflict, map[string]interface{}{"code": code})
+	}
+	return nil
+}
+
+// ReleaseOrder makes the codes redeemed by the order available again
+func (s PgPromotionCodeStore) ReleaseOrder(orderID int64) *model.AppErr {
+	if _, err := s.db.Exec(`UPDATE public.promotion_code SET user_id = NULL, order_id = NULL, redeemed_at = NULL WHERE order_id = $1`, orderID); err != nil {
+		return model.NewAppErr("PgPromotionCodeStore.ReleaseOrder", model.ErrInternal, locale.GetUserLocalizer("en"), msgReleasePromotionCodes, http.StatusInternalServerError, nil)
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package postgres
 
 import (
+	"database/sql"
 	"net/http"
 
 	"github.com/dankobgd/ecommerce-shop/model"
@@ -33,12 +34,19 @@ var (
 	msgPromoCodeUsed                   = &i18n.Message{ID: "store.postgres.promotion.is_used.app_error", Other: "you have already used this promo code"}
 	msgPromoCodeInvalid                = &i18n.Message{ID: "store.postgres.promotion.is_valid.app_error", Other: "promo code is invalid or is no longer active"}
 	msgInsertPromotionDetail           = &i18n.Message{ID: "store.postgres.promotion.insert_detail.app_error", Other: "could not save promotion detail"}
+	msgDeletePromotionDetail           = &i18n.Message{ID: "store.postgres.promotion.delete_detail.app_error", Other: "could not delete promotion detail"}
+	msgPromotionNotFound               = &i18n.Message{ID: "store.postgres.promotion.get.not_found.app_error", Other: "promotion not found"}
+	msgGetPromotionTargets             = &i18n.Message{ID: "store.postgres.promotion.get_targeted.app_error", Other: "could not get the products targeted by the promotion"}
 	msgUniqueConstraintPromotionDetail = &i18n.Message{ID: "store.postgres.promotion.insert_detail.unique_constraint.app_error", Other: "promotion already used by the same user"}
+	msgPromoUsageLimitReached          = &i18n.Message{ID: "store.postgres.promotion.claim_detail.usage_limit.app_error", Other: "promo code has reached its usage limit"}
 )
 
+const promotionInsert = `INSERT INTO public.promotion(promo_code, type, amount, description, min_order_amount, max_discount_amount, usage_limit, usage_limit_per_user, buy_quantity, get_quantity, tiers, automatic, priority, exclusive, starts_at, ends_at, created_at, updated_at)
+	VALUES(:promo_code, :type, :amount, :description, :min_order_amount, :max_discount_amount, :usage_limit, :usage_limit_per_user, :buy_quantity, :get_quantity, :tiers, :automatic, :priority, :exclusive, :starts_at, :ends_at, :created_at, :updated_at)`
+
 // BulkInsert inserts multiple promotions in the db
 func (s PgPromotionStore) BulkInsert(promotions []*model.Promotion) *model.AppErr {
-	q := `INSERT INTO public.promotion(promo_code, type, amount, description, starts_at, ends_at, created_at, updated_at) VALUES(:promo_code, :type, :amount, :description, :starts_at, :ends_at, :created_at, :updated_at) RETURNING promo_code`
+	q := promotionInsert
 
 	if _, err := s.db.NamedExec(q, promotions); err != nil {
 		if IsUniqueConstraintViolationError(err) {
@@ -49,47 +57,146 @@ func (s PgPromotionStore) BulkInsert(promotions []*model.Promotion) *model.AppEr
 	return nil
 }
 
-// Save inserts the new promotion in the db
+// Save inserts the new promotion in the db together with its targets
 func (s PgPromotionStore) Save(promotion *model.Promotion) (*model.Promotion, *model.AppErr) {
-	q := `INSERT INTO public.promotion(promo_code, type, amount, description, starts_at, ends_at, created_at, updated_at) VALUES(:promo_code, :type, :amount, :description, :starts_at, :ends_at, :created_at, :updated_at) RETURNING promo_code`
-	if _, err := s.db.NamedExec(q, promotion); err != nil {
+	tx, err := s.beginx()
+	if err != nil {
+		return nil, model.NewAppErr("PgPromotionStore.Save", model.ErrInternal, locale.GetUserLocalizer("en"), msgSavePromotion, http.StatusInternalServerError, nil)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.NamedExec(promotionInsert, promotion); err != nil {
 		if IsUniqueConstraintViolationError(err) {
 			return nil, model.NewAppErr("PgPromotionStore.Save", model.ErrInternal, locale.GetUserLocalizer("en"), msgUniqueConstraintPromotion, http.StatusInternalServerError, nil)
 		}
 		return nil, model.NewAppErr("PgPromotionStore.Save", model.ErrInternal, locale.GetUserLocalizer("en"), msgSavePromotion, http.StatusInternalServerError, nil)
 	}
+	if err := replacePromotionTargets(tx, promotion.PromoCode, promotion.Targets); err != nil {
+		return nil, model.NewAppErr("PgPromotionStore.Save", model.ErrInternal, locale.GetUserLocalizer("en"), msgSavePromotion, http.StatusInternalServerError, nil)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, model.NewAppErr("PgPromotionStore.Save", model.ErrInternal, locale.GetUserLocalizer("en"), msgSavePromotion, http.StatusInternalServerError, nil)
+	}
 	return promotion, nil
 }
 
-// Update updates the promotion
+// Update updates the promotion, the targets are replaced when they are given
 func (s PgPromotionStore) Update(code string, promotion *model.Promotion) (*model.Promotion, *model.AppErr) {
 	m := map[string]interface{}{
-		"code":        code,
-		"promo_code":  promotion.PromoCode,
-		"type":        promotion.Type,
-		"amount":      promotion.Amount,
-		"description": promotion.Description,
-		"starts_at":   promotion.StartsAt,
-		"ends_at":     promotion.EndsAt,
-		"updated_at":  promotion.UpdatedAt,
-	}
-
-	q := `UPDATE public.promotion SET promo_code=:promo_code, type=:type, amount=:amount, description=:description, starts_at=:starts_at, ends_at=:ends_at, updated_at=:updated_at WHERE promo_code=:code`
-	if _, err := s.db.NamedExec(q, m); err != nil {
+		"code":                 code,
+		"promo_code":           promotion.PromoCode,
+		"type":                 promotion.Type,
+		"amount":               promotion.Amount,
+		"description":          promotion.Description,
+		"min_order_amount":     promotion.MinOrderAmount,
+		"max_discount_amount":  promotion.MaxDiscountAmount,
+		"usage_limit":          promotion.UsageLimit,
+		"usage_limit_per_user": promotion.UsageLimitPerUser,
+		"buy_quantity":         promotion.BuyQuantity,
+		"get_quantity":         promotion.GetQuantity,
+		"tiers":                promotion.Tiers,
+		"automatic":            promotion.Automatic,
+		"priority":             promotion.Priority,
+		"exclusive":            promotion.Exclusive,
+		"starts_at":            promotion.StartsAt,
+		"ends_at":              promotion.EndsAt,
+		"updated_at":           promotion.UpdatedAt,
+	}
+
+	tx, err := s.beginx()
+	if err != nil {
+		return nil, model.NewAppErr("PgPromotionStore.Update", model.ErrInternal, locale.GetUserLocalizer("en"), msgUpdatePromotion, http.StatusInternalServerError, nil)
+	}
+	defer tx.Rollback()
+
+	q := `UPDATE public.promotion SET promo_code=:promo_code, type=:type, amount=:amount, description=:description,
+	min_order_amount=:min_order_amount, max_discount_amount=:max_discount_amount, usage_limit=:usage_limit, usage_limit_per_user=:usage_limit_per_user,
+	buy_quantity=:buy_quantity, get_quantity=:get_quantity, tiers=:tiers,
+	automatic=:automatic, priority=:priority, exclusive=:exclusive, starts_at=:starts_at, ends_at=:ends_at, updated_at=:updated_at WHERE promo_code=:code`
+	if _, err := tx.NamedExec(q, m); err != nil {
+		return nil, model.NewAppErr("PgPromotionStore.Update", model.ErrInternal, locale.GetUserLocalizer("en"), msgUpdatePromotion, http.StatusInternalServerError, nil)
+	}
+	if promotion.Targets != nil {
+		if err := replacePromotionTargets(tx, promotion.PromoCode, promotion.Targets); err != nil {
+			return nil, model.NewAppErr("PgPromotionStore.Update", model.ErrInternal, locale.GetUserLocalizer("en"), msgUpdatePromotion, http.StatusInternalServerError, nil)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
 		return nil, model.NewAppErr("PgPromotionStore.Update", model.ErrInternal, locale.GetUserLocalizer("en"), msgUpdatePromotion, http.StatusInternalServerError, nil)
 	}
 	return promotion, nil
 }
 
-// Get gets one promotion by id
+// replacePromotionTargets replaces all targets of the promotion
+func replacePromotionTargets(tx dbExecutor, code string, targets []*model.PromotionTarget) error {
+	if _, err := tx.Exec(`DELETE FROM public.promotion_target WHERE promo_code = $1`, code); err != nil {
+		return err
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+
+	for _, t := range targets {
+		t.PromoCode = code
+	}
+	_, err := tx.NamedExec(`INSERT INTO public.promotion_target (promo_code, target_type, target_id) VALUES (:promo_code, :target_type, :target_id)`, targets)
+	return err
+}
+
+// Get gets one promotion by id with its targets
 func (s PgPromotionStore) Get(code string) (*model.Promotion, *model.AppErr) {
 	var promotion model.Promotion
 	if err := s.db.Get(&promotion, "SELECT * FROM public.promotion WHERE promo_code = $1", code); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, model.NewAppErr("PgPromotionStore.Get", model.ErrNotFound, locale.GetUserLocalizer("en"), msgPromotionNotFound, http.StatusNotFound, nil)
+		}
+		return nil, model.NewAppErr("PgPromotionStore.Get", model.ErrInternal, locale.GetUserLocalizer("en"), msgGetPromotion, http.StatusInternalServerError, nil)
+	}
+
+	promotion.Targets = make([]*model.PromotionTarget, 0)
+	if err := s.db.Select(&promotion.Targets, `SELECT * FROM public.promotion_target WHERE promo_code = $1 ORDER BY target_type ASC, target_id ASC`, code); err != nil {
 		return nil, model.NewAppErr("PgPromotionStore.Get", model.ErrInternal, locale.GetUserLocalizer("en"), msgGetPromotion, http.StatusInternalServerError, nil)
 	}
 	return &promotion, nil
 }
 
+// GetActiveAutomatic gets the automatic promotions active at the moment with their targets, the highest priority first
+func (s PgPromotionStore) GetActiveAutomatic() ([]*model.Promotion, *model.AppErr) {
+	var promotions = make([]*model.Promotion, 0)
+	q := `SELECT * FROM public.promotion WHERE automatic AND CURRENT_TIMESTAMP BETWEEN starts_at AND ends_at ORDER BY priority DESC, promo_code ASC`
+	if err := s.db.Select(&promotions, q); err != nil {
+		return nil, model.NewAppErr("PgPromotionStore.GetActiveAutomatic", model.ErrInternal, locale.GetUserLocalizer("en"), msgGetPromotions, http.StatusInternalServerError, nil)
+	}
+	if len(promotions) == 0 {
+		return promotions, nil
+	}
+
+	codes := make([]string, 0, len(promotions))
+	byCode := make(map[string]*model.Promotion, len(promotions))
+	for _, p := range promotions {
+		p.Targets = make([]*model.PromotionTarget, 0)
+		codes = append(codes, p.PromoCode)
+		byCode[p.PromoCode] = p
+	}
+
+	q, args, err := sqlx.In(`SELECT * FROM public.promotion_target WHERE promo_code IN (?) ORDER BY target_type ASC, target_id ASC`, codes)
+	if err != nil {
+		return nil, model.NewAppErr("PgPromotionStore.GetActiveAutomatic", model.ErrInternal, locale.GetUserLocalizer("en"), msgGetPromotions, http.StatusInternalServerError, nil)
+	}
+	var targets = make([]*model.PromotionTarget, 0)
+	if err := s.db.Select(&targets, s.db.Rebind(q), args...); err != nil {
+		return nil, model.NewAppErr("PgPromotionStore.GetActiveAutomatic", model.ErrInternal, locale.GetUserLocalizer("en"), msgGetPromotions, http.StatusInternalServerError, nil)
+	}
+	for _, t := range targets {
+		if p, ok := byCode[t.PromoCode]; ok {
+			p.Targets = append(p.Targets, t)
+		}
+	}
+	return promotions, nil
+}
+
 // GetAll returns all promotions
 func (s PgPromotionStore) GetAll(limit, offset int) ([]*model.Promotion, *model.AppErr) {
 	var promotions = make([]*model.Promotion, 0)
@@ -108,19 +215,45 @@ func (s PgPromotionStore) Delete(code string) *model.AppErr {
 	return nil
 }
 
-// InsertDetail inserts the new promotion in the db
-func (s PgPromotionStore) InsertDetail(pdetail *model.PromotionDetail) (*model.PromotionDetail, *model.AppErr) {
-	q := `INSERT INTO public.promotion_detail(user_id, promo_code) VALUES(:user_id, :promo_code) RETURNING *`
+// ClaimDetail atomically claims the redemption of the promotion by the user
+// the db rejects the claim when the promotion reached its usage limit or the user its per user limit, so the concurrent claims can't exceed them
+func (s PgPromotionStore) ClaimDetail(pdetail *model.PromotionDetail) (*model.PromotionDetail, *model.AppErr) {
+	q := `INSERT INTO public.promotion_detail(user_id, promo_code, order_id) VALUES(:user_id, :promo_code, :order_id)`
 	if _, err := s.db.NamedExec(q, pdetail); err != nil {
-		if IsUniqueConstraintViolationError(err) {
-			return nil, model.NewAppErr("PgPromotionStore.InsertDetail", model.ErrInternal, locale.GetUserLocalizer("en"), msgUniqueConstraintPromotionDetail, http.StatusInternalServerError, nil)
+		switch {
+		case IsPromotionUsageLimitError(err):
+			return nil, model.NewAppErr("PgPromotionStore.ClaimDetail", model.ErrConflict, locale.GetUserLocalizer("en"), msgPromoUsageLimitReached, http.StatusConflict, map[string]interface{}{"promo_code": pdetail.PromoCode})
+		case IsPromotionUserLimitError(err):
+			return nil, model.NewAppErr("PgPromotionStore.ClaimDetail", model.ErrConflict, locale.GetUserLocalizer("en"), msgPromoCodeUsed, http.StatusConflict, map[string]interface{}{"promo_code": pdetail.PromoCode})
+		case IsUniqueConstraintViolationError(err):
+			return nil, model.NewAppErr("PgPromotionStore.ClaimDetail", model.ErrConflict, locale.GetUserLocalizer("en"), msgUniqueConstraintPromotionDetail, http.StatusConflict, nil)
 		}
-
-		return nil, model.NewAppErr("PgPromotionStore.InsertDetail", model.ErrInternal, locale.GetUserLocalizer("en"), msgInsertPromotionDetail, http.StatusInternalServerError, nil)
+		return nil, model.NewAppErr("PgPromotionStore.ClaimDetail", model.ErrInternal, locale.GetUserLocalizer("en"), msgInsertPromotionDetail, http.StatusInternalServerError, nil)
 	}
 	return pdetail, nil
 }
 
+// DeleteDetail deletes the promotion detail and releases its usage, so the user can use the promo code again
+// the detail of the order deletes only the redemption of that order
+func (s PgPromotionStore) DeleteDetail(pdetail *model.PromotionDetail) *model.AppErr {
+	q := `DELETE FROM public.promotion_detail WHERE user_id = :user_id AND promo_code = :promo_code`
+	if pdetail.OrderID != nil {
+		q += ` AND order_id = :order_id`
+	}
+	if _, err := s.db.NamedExec(q, pdetail); err != nil {
+		return model.NewAppErr("PgPromotionStore.DeleteDetail", model.ErrInternal, locale.GetUserLocalizer("en"), msgDeletePromotionDetail, http.StatusInternalServerError, nil)
+	}
+	return nil
+}
+
+// DeleteOrderDetails deletes all promotion redemptions of the order and releases their usage
+func (s PgPromotionStore) DeleteOrderDetails(orderID int64) *model.AppErr {
+	if _, err := s.db.Exec(`DELETE FROM public.promotion_detail WHERE order_id = $1`, orderID); err != nil {
+		return model.NewAppErr("PgPromotionStore.DeleteOrderDetails", model.ErrInternal, locale.GetUserLocalizer("en"), msgDeletePromotionDetail, http.StatusInternalServerError, nil)
+	}
+	return nil
+}
+
 // BulkDelete deletes tags with given ids
 func (s PgPromotionStore) BulkDelete(codes []string) *model.AppErr {
 	q, args, err := sqlx.In(`DELETE FROM public.promotion WHERE promo_code IN (?)`, codes)
@@ -135,10 +268,13 @@ func (s PgPromotionStore) BulkDelete(codes []string) *model.AppErr {
 	return nil
 }
 
-// IsValid checks if the promo code exists and it is active and valid
+// IsValid checks if the promo code exists and it is active and valid, and still under its usage limit
 func (s PgPromotionStore) IsValid(code string) *model.AppErr {
 	var valid bool
-	q := `SELECT EXISTS (SELECT 1 FROM promotion p LEFT JOIN promotion_detail pd ON p.promo_code = pd.promo_code WHERE p.promo_code = $1 AND CURRENT_TIMESTAMP BETWEEN p.starts_at AND p.ends_at)`
+	q := `SELECT EXISTS (
+		SELECT 1 FROM promotion p WHERE p.promo_code = $1 AND NOT p.campaign AND CURRENT_TIMESTAMP BETWEEN p.starts_at AND p.ends_at
+		AND (p.usage_limit IS NULL OR p.usage_count < p.usage_limit)
+	)`
 	if err := s.db.Get(&valid, q, code); err != nil {
 		return model.NewAppErr("PgPromotionStore.IsValid", model.ErrInternal, locale.GetUserLocalizer("en"), msgPromoStatus, http.StatusInternalServerError, nil)
 	}
@@ -148,10 +284,13 @@ func (s PgPromotionStore) IsValid(code string) *model.AppErr {
 	return nil
 }
 
-// IsUsed checks if promo code has been used by the user already
+// IsUsed checks if promo code has been used by the user already, as many times as the per user limit allows
 func (s PgPromotionStore) IsUsed(code string, userID int64) *model.AppErr {
 	var used bool
-	q := `SELECT EXISTS (SELECT 1 FROM promotion_detail pd WHERE pd.promo_code = $1 AND pd.user_id = $2)`
+	q := `SELECT EXISTS (
+		SELECT 1 FROM promotion p WHERE p.promo_code = $1 AND p.usage_limit_per_user IS NOT NULL
+		AND (SELECT COUNT(*) FROM promotion_detail pd WHERE pd.promo_code = p.promo_code AND pd.user_id = $2) >= p.usage_limit_per_user
+	)`
 	if err := s.db.Get(&used, q, code, userID); err != nil {
 		return model.NewAppErr("PgPromotionStore.IsUsed", model.ErrInternal, locale.GetUserLocalizer("en"), msgPromoStatus, http.StatusInternalServerError, nil)
 	}
@@ -160,3 +299,43 @@ func (s PgPromotionStore) IsUsed(code string, userID int64) *model.AppErr {
 	}
 	return nil
 }
+
+// GetUsage gets the number of the redemptions of the promo code, in total and by the user
+func (s PgPromotionStore) GetUsage(code string, userID int64) (*model.PromotionUsage, *model.AppErr) {
+	var usage model.PromotionUsage
+	q := `SELECT p.usage_count AS total, (SELECT COUNT(*) FROM public.promotion_detail pd WHERE pd.promo_code = p.promo_code AND pd.user_id = $2) AS by_user
+	FROM public.promotion p WHERE p.promo_code = $1`
+	if err := s.db.Get(&usage, q, code, userID); err != nil {
+		return nil, model.NewAppErr("PgPromotionStore.GetUsage", model.ErrInternal, locale.GetUserLocalizer("en"), msgPromoStatus, http.StatusInternalServerError, nil)
+	}
+	return &usage, nil
+}
+
+// GetTargetedProductIDS gets the products among the given ones that are targeted by the promotion
+// the product is targeted directly, by its brand, by its tag or by its category or any parent category
+func (s PgPromotionStore) GetTargetedProductIDS(code string, pids []int64) ([]int64, *model.AppErr) {
+	ids := make([]int64, 0)
+	if len(pids) == 0 {
+		return ids, nil
+	}
+
+	q, args, err := sqlx.In(`SELECT p.id FROM public.product p
+	WHERE p.id IN (?) AND EXISTS (
+		SELECT 1 FROM public.promotion_target t WHERE t.promo_code = ? AND (
+			(t.target_type = 'product' AND t.target_id = p.id)
+			OR (t.target_type = 'brand' AND t.target_id = p.brand_id)
+			OR (t.target_type = 'tag' AND EXISTS (SELECT 1 FROM public.product_tag pt WHERE pt.product_id = p.id AND pt.tag_id = t.target_id))
+			OR (t.target_type = 'category' AND EXISTS (
+				SELECT 1 FROM public.category c INNER JOIN public.category tc ON tc.id = t.target_id
+				WHERE c.id = p.category_id AND c.path LIKE tc.path || '%'
+			))
+		)
+	)`, pids, code)
+	if err != nil {
+		return nil, model.NewAppErr("PgPromotionStore.GetTargetedProductIDS", model.ErrInternal, locale.GetUserLocalizer("en"), msgGetPromotionTargets, http.StatusInternalServerError, nil)
+	}
+	if err := s.db.Select(&ids, s.db.Rebind(q), args...); err != nil {
+		return nil, model.NewAppErr("PgPromotionStore.GetTargetedProductIDS", model.ErrInternal, locale.GetUserLocalizer("en"), msgGetPromotionTargets, http.StatusInternalServerError, nil)
+	}
+	return ids, nil
+}
@@ -0,0 +1,120 @@
+package postgres
+
+import (
+	"net/http"
+
+	"github.com/dankobgd/ecommerce-shop/model"
+	"github.com/dankobgd/ecommerce-shop/store"
+	"github.com/dankobgd/ecommerce-shop/utils/locale"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+// PgRefundStore is the postgres implementation
+type PgRefundStore struct {
+	PgStore
+}
+
+// NewPgRefundStore creates the new refund store
+func NewPgRefundStore(pgst *PgStore) store.RefundStore {
+	return &PgRefundStore{*pgst}
+}
+
+var (
+	msgSaveRefund  = &i18n.Message{ID: "store.postgres.refund.save.app_error", Other: "could not save refund"}
+	msgGetRefunds  = &i18n.Message{ID: "store.postgres.refund.get_all.app_error", Other: "could not get refunds"}
+	msgGetRefunded = &i18n.Message{ID: "store.postgres.refund.get_refunded.app_error", Other: "could not get refunded amount"}
+)
+
+// Save inserts the refund with its items
+func (s PgRefundStore) Save(r *model.OrderRefund) (*model.OrderRefund, *model.AppErr) {
+	tx, err := s.beginx()
+	if err != nil {
+		return nil, model.NewAppErr("PgRefundStore.Save", model.ErrInternal, locale.GetUserLocalizer("en"), msgSaveRefund, http.StatusInternalServerError, nil)
+	}
+	defer tx.Rollback()
+
+	q := `INSERT INTO public.order_refund (order_id, provider_refund_id, amount, reason, created_by, created_at) VALUES (:order_id, :provider_refund_id, :amount, :reason, :created_by, :created_at) RETURNING id`
+
+	var id int64
+	rows, err := tx.NamedQuery(q, r)
+	if err != nil {
+		return nil, model.NewAppErr("PgRefundStore.Save", model.ErrInternal, locale.GetUserLocalizer("en"), msgSaveRefund, http.StatusInternalServerError, nil)
+	}
+	for rows.Next() {
+		rows.Scan(&id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, model.NewAppErr("PgRefundStore.Save", model.ErrInternal, locale.GetUserLocalizer("en"), msgSaveRefund, http.StatusInternalServerError, nil)
+	}
+
+	r.ID = id
+	for _, item := range r.Items {
+		item.RefundID = id
+		item.OrderID = r.OrderID
+	}
+
+	if len(r.Items) > 0 {
+		if _, err := tx.NamedExec(`INSERT INTO public.order_refund_item (refund_id, order_id, line, product_id, variant_id, quantity, amount) VALUES (:refund_id, :order_id, :line, :product_id, :variant_id, :quantity, :amount)`, r.Items); err != nil {
+			return nil, model.NewAppErr("PgRefundStore.Save", model.ErrInternal, locale.GetUserLocalizer("en"), msgSaveRefund, http.StatusInternalServerError, nil)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, model.NewAppErr("PgRefundStore.Save", model.ErrInternal, locale.GetUserLocalizer("en"), msgSaveRefund, http.StatusInternalServerError, nil)
+	}
+
+	return r, nil
+}
+
+// GetAll gets all refunds of the order with their items
+func (s PgRefundStore) GetAll(orderID int64) ([]*model.OrderRefund, *model.AppErr) {
+	var refunds = make([]*model.OrderRefund, 0)
+	if err := s.db.Select(&refunds, `SELECT * FROM public.order_refund WHERE order_id = $1 ORDER BY created_at ASC, id ASC`, orderID); err != nil {
+		return nil, model.NewAppErr("PgRefundStore.GetAll", model.ErrInternal, locale.GetUserLocalizer("en"), msgGetRefunds, http.StatusInternalServerError, nil)
+	}
+
+	var items = make([]*model.OrderRefundItem, 0)
+	if err := s.db.Select(&items, `SELECT * FROM public.order_refund_item WHERE order_id = $1`, orderID); err != nil {
+		return nil, model.NewAppErr("PgRefundStore.GetAll", model.ErrInternal, locale.GetUserLocalizer("en"), msgGetRefunds, http.StatusInternalServerError, nil)
+	}
+
+	byID := make(map[int64]*model.OrderRefund, len(refunds))
+	for _, r := range refunds {
+		r.Items = make([]*model.OrderRefundItem, 0)
+		byID[r.ID] = r
+	}
+	for _, item := range items {
+		if r, ok := byID[item.RefundID]; ok {
+			r.Items = append(r.Items, item)
+		}
+	}
+
+	return refunds, nil
+}
+
+// GetRefundedQuantities gets the already refunded quantity for each line of the order
+func (s PgRefundStore) GetRefundedQuantities(orderID int64) (map[int]int, *model.AppErr) {
+	var rows []struct {
+		Line     int `db:"line"`
+		Quantity int `db:"quantity"`
+	}
+	if err := s.db.Select(&rows, `SELECT line, SUM(quantity) AS quantity FROM public.order_refund_item WHERE order_id = $1 GROUP BY line`, orderID); err != nil {
+		return nil, model.NewAppErr("PgRefundStore.GetRefundedQuantities", model.ErrInternal, locale.GetUserLocalizer("en"), msgGetRefunded, http.StatusInternalServerError, nil)
+	}
+
+	quantities := make(map[int]int, len(rows))
+	for _, r := range rows {
+		quantities[r.Line] = r.Quantity
+	}
+	return quantities, nil
+}
+
+// GetRefundedAmount gets the sum of all refunds of the order
+func (s PgRefundStore) GetRefundedAmount(orderID int64) (int, *model.AppErr) {
+	var amount int
+	if err := s.db.Get(&amount, `SELECT COALESCE(SUM(amount), 0) FROM public.order_refund WHERE order_id = $1`, orderID); err != nil {
+		return 0, model.NewAppErr("PgRefundStore.GetRefundedAmount", model.ErrInternal, locale.GetUserLocalizer("en"), msgGetRefunded, http.StatusInternalServerError, nil)
+	}
+	return amount, nil
+}
@@ -0,0 +1,126 @@
+package postgres
+
+import (
+	"net/http"
+
+	"github.com/dankobgd/ecommerce-shop/model"
+	"github.com/dankobgd/ecommerce-shop/store"
+	"github.com/dankobgd/ecommerce-shop/utils/locale"
+	"github.com/jmoiron/sqlx"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+// PgSearchIndexStore is the postgres implementation
+type PgSearchIndexStore struct {
+	PgStore
+}
+
+// NewPgSearchIndexStore creates the new search index store
+func NewPgSearchIndexStore(pgst *PgStore) store.SearchIndexStore {
+	return &PgSearchIndexStore{*pgst}
+}
+
+var (
+	msgRefreshSearchIndex = &i18n.Message{ID: "store.postgres.search_index.refresh.app_error", Other: "could not refresh the product search index"}
+	msgRebuildSearchIndex = &i18n.Message{ID: "store.postgres.search_index.rebuild.app_error", Other: "could not rebuild the product search index"}
+)
+
+// searchIndexRows selects the index rows from the search view, the latest started price wins the overlapping sale windows
+const searchIndexRows = `SELECT DISTINCT ON (v.id) v.* FROM product_search_view v`
+
+// enqueueStaleSearchPrices queues the products whose indexed price has ended or whose newer price has started
+// the triggers can't see these, since the sale windows change with the time and not with the writes
+const enqueueStaleSearchPrices = `INSERT INTO public.product_search_queue (product_id)
+	SELECT s.id FROM public.product_search s WHERE s.pricing_sale_ends < CURRENT_TIMESTAMP
+	UNION
+	SELECT pp.product_id FROM public.product_pricing pp
+	WHERE pp.variant_id IS NULL AND CURRENT_TIMESTAMP BETWEEN pp.sale_starts AND pp.sale_ends
+	AND NOT EXISTS (SELECT 1 FROM public.product_search s WHERE s.id = pp.product_id AND s.pricing_sale_starts >= pp.sale_starts)
+	ON CONFLICT DO NOTHING`
+
+// Refresh reindexes at most limit queued products and returns how many were taken off the queue
+// the queue rows are locked with skip locked, so the concurrent refreshes take the different products
+func (s PgSearchIndexStore) Refresh(limit int) (int, *model.AppErr) {
+	appErr := func() *model.AppErr {
+		return model.NewAppErr("PgSearchIndexStore.Refresh", model.ErrInternal, locale.GetUserLocalizer("en"), msgRefreshSearchIndex, http.StatusInternalServerError, nil)
+	}
+
+	tx, err := s.beginx()
+	if err != nil {
+		return 0, appErr()
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(enqueueStaleSearchPrices); err != nil {
+		return 0, appErr()
+	}
+
+	var ids []int64
+	q := `DELETE FROM public.product_search_queue WHERE product_id IN (
+		SELECT product_id FROM public.product_search_queue ORDER BY queued_at LIMIT $1 FOR UPDATE SKIP LOCKED
+	) RETURNING product_id`
+	if err := tx.Select(&ids, q, limit); err != nil {
+		return 0, appErr()
+	}
+	if len(ids) == 0 {
+		if err := tx.Commit(); err != nil {
+			return 0, appErr()
+		}
+		return 0, nil
+	}
+
+	del, args, err := sqlx.In(`DELETE FROM public.product_search WHERE id IN (?)`, ids)
+	if err != nil {
+		return 0, appErr()
+	}
+	if _, err := tx.Exec(tx.Rebind(del), args...); err != nil {
+		return 0, appErr()
+	}
+
+	ins, args, err := sqlx.In(`INSERT INTO public.product_search `+searchIndexRows+` WHERE v.id IN (?) ORDER BY v.id, v.pricing_sale_starts DESC`, ids)
+	if err != nil {
+		return 0, appErr()
+	}
+	if _, err := tx.Exec(tx.Rebind(ins), args...); err != nil {
+		return 0, appErr()
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, appErr()
+	}
+	return len(ids), nil
+}
+
+// Rebuild replaces the whole index and clears the queue, returns the number of the indexed products
+// the rows are deleted instead of truncated so the searches keep reading the old index until the commit
+func (s PgSearchIndexStore) Rebuild() (int, *model.AppErr) {
+	appErr := func() *model.AppErr {
+		return model.NewAppErr("PgSearchIndexStore.Rebuild", model.ErrInternal, locale.GetUserLocalizer("en"), msgRebuildSearchIndex, http.StatusInternalServerError, nil)
+	}
+
+	tx, err := s.beginx()
+	if err != nil {
+		return 0, appErr()
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM public.product_search_queue`); err != nil {
+		return 0, appErr()
+	}
+	if _, err := tx.Exec(`DELETE FROM public.product_search`); err != nil {
+		return 0, appErr()
+	}
+	res, err := tx.Exec(`INSERT INTO public.product_search ` + searchIndexRows + ` ORDER BY v.id, v.pricing_sale_starts DESC`)
+	if err != nil {
+		return 0, appErr()
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, appErr()
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, appErr()
+	}
+	return int(n), nil
+}
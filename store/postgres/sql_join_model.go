@@ -10,8 +10,10 @@ import (
 // productJoin is temp join type
 type productJoin struct {
 	model.Product
-	Tsv  string `json:"-" db:"tsv"`
-	Rank string `json:"-" db:"rank"`
+	Tsv                  string  `json:"-" db:"tsv"`
+	Rank                 string  `json:"-" db:"rank"`
+	NameHighlight        *string `json:"-" db:"name_highlight"`
+	DescriptionHighlight *string `json:"-" db:"description_highlight"`
 	*PricingJoin
 	*BrandJoin
 	*CategoryJoin
@@ -57,6 +59,11 @@ type CategoryJoin struct {
 }
 
 func (pj *productJoin) ToProduct() *model.Product {
+	var highlight *model.ProductHighlight
+	if pj.NameHighlight != nil && pj.DescriptionHighlight != nil {
+		highlight = &model.ProductHighlight{Name: *pj.NameHighlight, Description: *pj.DescriptionHighlight}
+	}
+
 	return &model.Product{
 		BrandID:           pj.BID,
 		CategoryID:        pj.CID,
@@ -68,11 +75,13 @@ func (pj *productJoin) ToProduct() *model.Product {
 		ImagePublicID:     pj.ImagePublicID,
 		Description:       pj.Description,
 		InStock:           pj.InStock,
+		StockQuantity:     pj.StockQuantity,
 		SKU:               pj.SKU,
 		IsFeatured:        pj.IsFeatured,
 		CreatedAt:         pj.CreatedAt,
 		UpdatedAt:         pj.UpdatedAt,
 		Properties:        pj.Properties,
+		Highlight:         highlight,
 		ProductPricing: &model.ProductPricing{
 			PriceID:       pj.PID,
 			ProductID:     pj.PProductID,
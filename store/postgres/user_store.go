@@ -221,7 +221,7 @@ func (s PgUserStore) GetWishlist(userID int64) ([]*model.Product, *model.AppErr)
   pp.sale_starts AS pricing_sale_starts,
   pp.sale_ends AS pricing_sale_ends
 	FROM public.product p
-	LEFT JOIN product_pricing pp ON p.id = pp.product_id
+	LEFT JOIN product_pricing pp ON p.id = pp.product_id AND pp.variant_id IS NULL
 	LEFT JOIN brand b ON p.brand_id = b.id
 	LEFT JOIN category c ON p.category_id = c.id	
 	LEFT JOIN product_wishlist w ON p.id = w.product_id
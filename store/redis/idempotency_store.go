@@ -0,0 +1,74 @@
+package redis
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/dankobgd/ecommerce-shop/model"
+	"github.com/dankobgd/ecommerce-shop/store"
+	"github.com/dankobgd/ecommerce-shop/utils/locale"
+	"github.com/go-redis/redis/v8"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+var (
+	msgLockIdempotencyKey   = &i18n.Message{ID: "store.redis.idempotency.lock.app_error", Other: "could not lock the idempotency key"}
+	msgGetIdempotencyKey    = &i18n.Message{ID: "store.redis.idempotency.get.app_error", Other: "could not get the idempotency key"}
+	msgSaveIdempotencyKey   = &i18n.Message{ID: "store.redis.idempotency.save.app_error", Other: "could not save the idempotency key"}
+	msgDeleteIdempotencyKey = &i18n.Message{ID: "store.redis.idempotency.delete.app_error", Other: "could not delete the idempotency key"}
+)
+
+const idempotencyKeyPrefix = "idempotency:"
+
+// RdIdempotencyStore is the redis implementation
+type RdIdempotencyStore struct {
+	RdStore
+}
+
+// NewRedisIdempotencyStore creates the new idempotency store
+func NewRedisIdempotencyStore(rdst *RdStore) store.IdempotencyStore {
+	return &RdIdempotencyStore{*rdst}
+}
+
+// Lock stores the record only if the key doesn't exist yet, returns false if it does
+func (s RdIdempotencyStore) Lock(key string, rec *model.IdempotencyRecord, ttl time.Duration) (bool, *model.AppErr) {
+	ok, err := s.client.SetNX(context.TODO(), idempotencyKeyPrefix+key, rec.ToJSON(), ttl).Result()
+	if err != nil {
+		return false, model.NewAppErr("RdIdempotencyStore.Lock", model.ErrInternal, locale.GetUserLocalizer("en"), msgLockIdempotencyKey, http.StatusInternalServerError, nil)
+	}
+	return ok, nil
+}
+
+// Get gets the record, nil record means the key doesn't exist
+func (s RdIdempotencyStore) Get(key string) (*model.IdempotencyRecord, *model.AppErr) {
+	data, err := s.client.Get(context.TODO(), idempotencyKeyPrefix+key).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, model.NewAppErr("RdIdempotencyStore.Get", model.ErrInternal, locale.GetUserLocalizer("en"), msgGetIdempotencyKey, http.StatusInternalServerError, nil)
+	}
+
+	rec, jErr := model.IdempotencyRecordFromJSON(data)
+	if jErr != nil {
+		return nil, model.NewAppErr("RdIdempotencyStore.Get", model.ErrInternal, locale.GetUserLocalizer("en"), msgGetIdempotencyKey, http.StatusInternalServerError, nil)
+	}
+	return rec, nil
+}
+
+// Save overwrites the record
+func (s RdIdempotencyStore) Save(key string, rec *model.IdempotencyRecord, ttl time.Duration) *model.AppErr {
+	if err := s.client.Set(context.TODO(), idempotencyKeyPrefix+key, rec.ToJSON(), ttl).Err(); err != nil {
+		return model.NewAppErr("RdIdempotencyStore.Save", model.ErrInternal, locale.GetUserLocalizer("en"), msgSaveIdempotencyKey, http.StatusInternalServerError, nil)
+	}
+	return nil
+}
+
+// Delete deletes the record so the request can be retried
+func (s RdIdempotencyStore) Delete(key string) *model.AppErr {
+	if err := s.client.Del(context.TODO(), idempotencyKeyPrefix+key).Err(); err != nil {
+		return model.NewAppErr("RdIdempotencyStore.Delete", model.ErrInternal, locale.GetUserLocalizer("en"), msgDeleteIdempotencyKey, http.StatusInternalServerError, nil)
+	}
+	return nil
+}
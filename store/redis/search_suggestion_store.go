@@ -0,0 +1,55 @@
+package redis
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/dankobgd/ecommerce-shop/model"
+	"github.com/dankobgd/ecommerce-shop/store"
+	"github.com/dankobgd/ecommerce-shop/utils/locale"
+	"github.com/go-redis/redis/v8"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+var (
+	msgGetSearchSuggestions  = &i18n.Message{ID: "store.redis.search_suggestion.get.app_error", Other: "could not get the cached search suggestions"}
+	msgSaveSearchSuggestions = &i18n.Message{ID: "store.redis.search_suggestion.save.app_error", Other: "could not cache the search suggestions"}
+)
+
+const searchSuggestionKeyPrefix = "suggest:"
+
+// RdSearchSuggestionStore is the redis implementation
+type RdSearchSuggestionStore struct {
+	RdStore
+}
+
+// NewRedisSearchSuggestionStore creates the new search suggestion store
+func NewRedisSearchSuggestionStore(rdst *RdStore) store.SearchSuggestionStore {
+	return &RdSearchSuggestionStore{*rdst}
+}
+
+// Get gets the cached suggestions of the input, nil suggestions mean the input is not cached
+func (s RdSearchSuggestionStore) Get(input string) ([]*model.SearchSuggestion, *model.AppErr) {
+	data, err := s.client.Get(context.TODO(), searchSuggestionKeyPrefix+input).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, model.NewAppErr("RdSearchSuggestionStore.Get", model.ErrInternal, locale.GetUserLocalizer("en"), msgGetSearchSuggestions, http.StatusInternalServerError, nil)
+	}
+
+	suggestions, jErr := model.SearchSuggestionsFromJSON(data)
+	if jErr != nil {
+		return nil, model.NewAppErr("RdSearchSuggestionStore.Get", model.ErrInternal, locale.GetUserLocalizer("en"), msgGetSearchSuggestions, http.StatusInternalServerError, nil)
+	}
+	return suggestions, nil
+}
+
+// Save caches the suggestions of the input
+func (s RdSearchSuggestionStore) Save(input string, suggestions []*model.SearchSuggestion, ttl time.Duration) *model.AppErr {
+	if err := s.client.Set(context.TODO(), searchSuggestionKeyPrefix+input, model.SearchSuggestionsToJSON(suggestions), ttl).Err(); err != nil {
+		return model.NewAppErr("RdSearchSuggestionStore.Save", model.ErrInternal, locale.GetUserLocalizer("en"), msgSaveSearchSuggestions, http.StatusInternalServerError, nil)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package redis
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dankobgd/ecommerce-shop/model"
+	"github.com/dankobgd/ecommerce-shop/store"
+	"github.com/dankobgd/ecommerce-shop/utils/locale"
+	"github.com/go-redis/redis/v8"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+var (
+	msgReserveStock      = &i18n.Message{ID: "store.redis.stock_reservation.reserve.app_error", Other: "could not reserve the stock"}
+	msgReleaseStock      = &i18n.Message{ID: "store.redis.stock_reservation.release.app_error", Other: "could not release the stock reservation"}
+	msgExpiredStockLocks = &i18n.Message{ID: "store.redis.stock_reservation.get_expired.app_error", Other: "could not get the expired stock reservations"}
+)
+
+// stockReservationsKey is the sorted set of the order ids, scored by the reservation expiry time
+const stockReservationsKey = "stock_reservations"
+
+// RdStockReservationStore is the redis implementation
+type RdStockReservationStore struct {
+	RdStore
+}
+
+// NewRedisStockReservationStore creates the new stock reservation store
+func NewRedisStockReservationStore(rdst *RdStore) store.StockReservationStore {
+	return &RdStockReservationStore{*rdst}
+}
+
+// Reserve holds the order stock until expiresAt
+func (s RdStockReservationStore) Reserve(orderID int64, expiresAt time.Time) *model.AppErr {
+	z := &redis.Z{Score: float64(expiresAt.Unix()), Member: strconv.FormatInt(orderID, 10)}
+	if err := s.client.ZAdd(context.TODO(), stockReservationsKey, z).Err(); err != nil {
+		return model.NewAppErr("RdStockReservationStore.Reserve", model.ErrInternal, locale.GetUserLocalizer("en"), msgReserveStock, http.StatusInternalServerError, nil)
+	}
+	return nil
+}
+
+// Release removes the order reservation, it returns false if it was already released
+// only the caller that removed the reservation should act on it
+func (s RdStockReservationStore) Release(orderID int64) (bool, *model.AppErr) {
+	n, err := s.client.ZRem(context.TODO(), stockReservationsKey, strconv.FormatInt(orderID, 10)).Result()
+	if err != nil {
+		return false, model.NewAppErr("RdStockReservationStore.Release", model.ErrInternal, locale.GetUserLocalizer("en"), msgReleaseStock, http.StatusInternalServerError, nil)
+	}
+	return n > 0, nil
+}
+
+// GetExpired gets the ids of the orders whose reservation expired before now
+func (s RdStockReservationStore) GetExpired(now time.Time, limit int) ([]int64, *model.AppErr) {
+	members, err := s.client.ZRangeByScore(context.TODO(), stockReservationsKey, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(now.Unix(), 10),
+		Count: int64(limit),
+	}).Result()
+	if err != nil {
+		return nil, model.NewAppErr("RdStockReservationStore.GetExpired", model.ErrInternal, locale.GetUserLocalizer("en"), msgExpiredStockLocks, http.StatusInternalServerError, nil)
+	}
+
+	ids := make([]int64, 0, len(members))
+	for _, m := range members {
+		if id, err := strconv.ParseInt(m, 10, 64); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
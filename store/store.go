@@ -1,25 +1,48 @@
 package store
 
 import (
+	"time"
+
 	"github.com/dankobgd/ecommerce-shop/model"
 )
 
 // Store represents all stores
 type Store interface {
+	Begin() (Tx, *model.AppErr)
 	AccessToken() AccessTokenStore
+	Idempotency() IdempotencyStore
+	StockReservation() StockReservationStore
+	SearchSuggestion() SearchSuggestionStore
 	User() UserStore
 	Token() TokenStore
 	Product() ProductStore
+	SearchIndex() SearchIndexStore
 	ProductTag() ProductTagStore
+	ProductRelation() ProductRelationStore
+	ProductRecommendation() ProductRecommendationStore
+	ProductBundle() ProductBundleStore
+	ProductVariant() ProductVariantStore
 	ProductImage() ProductImageStore
 	ProductReview() ProductReviewStore
+	Inventory() InventoryStore
 	Order() OrderStore
 	OrderDetail() OrderDetailStore
+	OrderDiscount() OrderDiscountStore
+	Refund() RefundStore
+	PaymentEvent() PaymentEventStore
 	Address() AddressStore
 	Category() CategoryStore
 	Brand() BrandStore
 	Tag() TagStore
 	Promotion() PromotionStore
+	PromotionCode() PromotionCodeStore
+}
+
+// Tx is the unit of work, all stores it returns share the same db transaction
+type Tx interface {
+	Store
+	Commit() *model.AppErr
+	Rollback() *model.AppErr
 }
 
 // UserStore ris the user store
@@ -50,6 +73,27 @@ type AccessTokenStore interface {
 	DeleteAuth(uuid string) (int64, *model.AppErr)
 }
 
+// IdempotencyStore is the idempotency key store
+type IdempotencyStore interface {
+	Lock(key string, rec *model.IdempotencyRecord, ttl time.Duration) (bool, *model.AppErr)
+	Get(key string) (*model.IdempotencyRecord, *model.AppErr)
+	Save(key string, rec *model.IdempotencyRecord, ttl time.Duration) *model.AppErr
+	Delete(key string) *model.AppErr
+}
+
+// SearchSuggestionStore is the cache of the search suggestions
+type SearchSuggestionStore interface {
+	Get(input string) ([]*model.SearchSuggestion, *model.AppErr)
+	Save(input string, suggestions []*model.SearchSuggestion, ttl time.Duration) *model.AppErr
+}
+
+// StockReservationStore is the store of the stock held by the orders awaiting payment
+type StockReservationStore interface {
+	Reserve(orderID int64, expiresAt time.Time) *model.AppErr
+	Release(orderID int64) (bool, *model.AppErr)
+	GetExpired(now time.Time, limit int) ([]int64, *model.AppErr)
+}
+
 // TokenStore is the access token store
 type TokenStore interface {
 	Save(token *model.Token) *model.AppErr
@@ -65,21 +109,53 @@ type ProductStore interface {
 	Save(p *model.Product) (*model.Product, *model.AppErr)
 	Get(id int64) (*model.Product, *model.AppErr)
 	ListByIDS(ids []int64) ([]*model.Product, *model.AppErr)
-	GetAll(filters map[string][]string, limit, offset int) ([]*model.Product, *model.AppErr)
-	GetFeatured(limit, offset int) ([]*model.Product, *model.AppErr)
+	GetAll(filter model.FilterNode, sort model.ProductSort, limit, offset int) ([]*model.Product, *model.AppErr)
+	GetFeatured(sort model.ProductSort, limit, offset int) ([]*model.Product, *model.AppErr)
 	GetMostSold(limit, offset int) ([]*model.Product, *model.AppErr)
 	GetBestDeals(limit, offset int) ([]*model.Product, *model.AppErr)
 	Update(id int64, u *model.Product) (*model.Product, *model.AppErr)
 	Delete(id int64) *model.AppErr
 	BulkDelete(ids []int) *model.AppErr
 	GetReviews(id int64) ([]*model.ProductReview, *model.AppErr)
-	Search(query string) ([]*model.Product, *model.AppErr)
-	GetLatestPricing(pid int64) (*model.ProductPricing, *model.AppErr)
+	Search(search *model.SearchQuery, filter model.FilterNode, sort model.ProductSort, limit, offset int) ([]*model.Product, *model.AppErr)
+	Suggest(input string, limit int) ([]*model.SearchSuggestion, *model.AppErr)
+	GetFacets(filter model.FilterNode, categories []*model.Category) (*model.ProductFacets, *model.AppErr)
+	GetLatestPricing(pid int64, variantID *int64) (*model.ProductPricing, *model.AppErr)
 	InsertPricingBulk(pricing []*model.ProductPricing) *model.AppErr
 	InsertPricing(pricing *model.ProductPricing) (*model.ProductPricing, *model.AppErr)
 	UpdatePricing(pricing *model.ProductPricing) (*model.ProductPricing, *model.AppErr)
 }
 
+// SearchIndexStore is the store of the materialized product search index
+type SearchIndexStore interface {
+	Refresh(limit int) (int, *model.AppErr)
+	Rebuild() (int, *model.AppErr)
+}
+
+// ProductVariantStore is the product variant and option store
+type ProductVariantStore interface {
+	Save(v *model.ProductVariant) (*model.ProductVariant, *model.AppErr)
+	Get(pid, vid int64) (*model.ProductVariant, *model.AppErr)
+	GetDefault(pid int64) (*model.ProductVariant, *model.AppErr)
+	GetAll(pid int64) ([]*model.ProductVariant, *model.AppErr)
+	ListByIDS(ids []int64) ([]*model.ProductVariant, *model.AppErr)
+	ListDefaultsByProductIDS(pids []int64) ([]*model.ProductVariant, *model.AppErr)
+	CreateDefaults(pids []int64) *model.AppErr
+	Update(v *model.ProductVariant) (*model.ProductVariant, *model.AppErr)
+	Delete(pid, vid int64) *model.AppErr
+	GetOptions(pid int64) ([]*model.ProductOption, *model.AppErr)
+	ReplaceOptions(pid int64, opts []*model.ProductOption) ([]*model.ProductOption, *model.AppErr)
+}
+
+// InventoryStore is the product variant stock store
+type InventoryStore interface {
+	Adjust(variantID int64, quantity int) (*model.ProductStock, *model.AppErr)
+	GetStock(variantID int64) (*model.ProductStock, *model.AppErr)
+	GetProductStock(productID int64) ([]*model.ProductStock, *model.AppErr)
+	SaveMovement(m *model.StockMovement) (*model.StockMovement, *model.AppErr)
+	GetMovements(productID int64, limit, offset int) ([]*model.StockMovement, *model.AppErr)
+}
+
 // ProductTagStore is the product tag store
 type ProductTagStore interface {
 	BulkInsert(tags []*model.ProductTag) *model.AppErr
@@ -92,6 +168,29 @@ type ProductTagStore interface {
 	BulkDelete(pid int64, ids []int) *model.AppErr
 }
 
+// ProductRelationStore is the store of the links between the products
+type ProductRelationStore interface {
+	GetAll(pid int64, typ model.ProductRelationType) ([]*model.ProductRelation, *model.AppErr)
+	Replace(pid int64, typ model.ProductRelationType, relatedIDs []int) ([]*model.ProductRelation, *model.AppErr)
+	GetCartSuggestions(pids []int64, types []model.ProductRelationType, limit int) ([]int64, *model.AppErr)
+}
+
+// ProductBundleStore is the store of the bundle products and their components
+type ProductBundleStore interface {
+	Get(pid int64) (*model.ProductBundle, *model.AppErr)
+	ListByProductIDS(pids []int64) ([]*model.ProductBundle, *model.AppErr)
+	IsComponent(pid int64) (bool, *model.AppErr)
+	Save(b *model.ProductBundle) (*model.ProductBundle, *model.AppErr)
+	Delete(pid int64) *model.AppErr
+}
+
+// ProductRecommendationStore is the store of the co-purchase recommendations
+type ProductRecommendationStore interface {
+	Recompute(params model.RecommendationParams) (int, *model.AppErr)
+	GetRecommended(pid int64, limit int) ([]int64, *model.AppErr)
+	GetCategoryBestSellers(categoryID int64, exclude []int64, limit int) ([]int64, *model.AppErr)
+}
+
 // ProductImageStore is the product image store
 type ProductImageStore interface {
 	BulkInsert(imgs []*model.ProductImage) *model.AppErr
@@ -118,9 +217,15 @@ type ProductReviewStore interface {
 type OrderStore interface {
 	Save(order *model.Order) (*model.Order, *model.AppErr)
 	Get(id int64) (*model.Order, *model.AppErr)
+	GetForUpdate(id int64) (*model.Order, *model.AppErr)
+	GetByPaymentIntentID(paymentIntentID string) (*model.Order, *model.AppErr)
 	GetAll(limit, offset int) ([]*model.Order, *model.AppErr)
 	Update(id int64, order *model.Order) (*model.Order, *model.AppErr)
 	Delete(id int64) *model.AppErr
+	UpdateStatus(h *model.OrderStatusHistory, shippedAt *time.Time) *model.AppErr
+	UpdatePayment(id int64, paymentIntentID, receiptURL string) *model.AppErr
+	InsertStatusHistory(h *model.OrderStatusHistory) (*model.OrderStatusHistory, *model.AppErr)
+	GetStatusHistory(orderID int64) ([]*model.OrderStatusHistory, *model.AppErr)
 }
 
 // OrderDetailStore is the order detail store
@@ -130,6 +235,22 @@ type OrderDetailStore interface {
 	GetAll(orderID int64) ([]*model.OrderInfo, *model.AppErr)
 }
 
+// RefundStore is the order refund store
+type RefundStore interface {
+	Save(r *model.OrderRefund) (*model.OrderRefund, *model.AppErr)
+	GetAll(orderID int64) ([]*model.OrderRefund, *model.AppErr)
+	GetRefundedQuantities(orderID int64) (map[int]int, *model.AppErr)
+	GetRefundedAmount(orderID int64) (int, *model.AppErr)
+}
+
+// PaymentEventStore is the payment webhook event store
+type PaymentEventStore interface {
+	Save(e *model.PaymentEvent) (*model.PaymentEvent, *model.AppErr)
+	Claim(id int64, staleAfter time.Duration) (bool, *model.AppErr)
+	MarkProcessed(id int64) *model.AppErr
+	MarkFailed(id int64, reason string) *model.AppErr
+}
+
 // AddressStore is the contact address store
 type AddressStore interface {
 	Save(addr *model.Address, userID int64) (*model.Address, *model.AppErr)
@@ -144,8 +265,13 @@ type CategoryStore interface {
 	BulkInsert(categories []*model.Category) *model.AppErr
 	Save(c *model.Category) (*model.Category, *model.AppErr)
 	Get(id int64) (*model.Category, *model.AppErr)
+	ListBySlugs(slugs []string) ([]*model.Category, *model.AppErr)
 	GetAll(limit, offset int) ([]*model.Category, *model.AppErr)
 	GetFeatured(limit, offset int) ([]*model.Category, *model.AppErr)
+	GetTree() ([]*model.Category, *model.AppErr)
+	GetSubtree(id int64) ([]*model.Category, *model.AppErr)
+	GetAncestors(id int64) ([]*model.Category, *model.AppErr)
+	Move(id int64, parentID *int64) (*model.Category, *model.AppErr)
 	Update(id int64, addr *model.Category) (*model.Category, *model.AppErr)
 	Delete(id int64) *model.AppErr
 	BulkDelete(ids []int) *model.AppErr
@@ -173,16 +299,38 @@ type TagStore interface {
 	BulkDelete(ids []int) *model.AppErr
 }
 
+// OrderDiscountStore is the store of the discounts applied to the orders
+type OrderDiscountStore interface {
+	BulkInsert(discounts []*model.OrderDiscount) *model.AppErr
+	GetAll(orderID int64) ([]*model.OrderDiscount, *model.AppErr)
+}
+
+// PromotionCodeStore is the store of the generated codes of the campaigns
+type PromotionCodeStore interface {
+	BulkInsert(promoCode string, codes []*model.PromotionCode) *model.AppErr
+	ListTaken(codes []string) ([]string, *model.AppErr)
+	Get(code string) (*model.PromotionCode, *model.AppErr)
+	GetAll(promoCode string, limit, offset int) ([]*model.PromotionCode, *model.AppErr)
+	ListByPromotion(promoCode string) ([]*model.PromotionCode, *model.AppErr)
+	Redeem(code string, userID, orderID int64) *model.AppErr
+	ReleaseOrder(orderID int64) *model.AppErr
+}
+
 // PromotionStore is the promotion store
 type PromotionStore interface {
 	BulkInsert(promotions []*model.Promotion) *model.AppErr
 	Save(p *model.Promotion) (*model.Promotion, *model.AppErr)
 	Get(code string) (*model.Promotion, *model.AppErr)
 	GetAll(limit, offset int) ([]*model.Promotion, *model.AppErr)
+	GetActiveAutomatic() ([]*model.Promotion, *model.AppErr)
 	Update(code string, p *model.Promotion) (*model.Promotion, *model.AppErr)
 	Delete(code string) *model.AppErr
 	BulkDelete(codes []string) *model.AppErr
-	InsertDetail(pd *model.PromotionDetail) (*model.PromotionDetail, *model.AppErr)
+	ClaimDetail(pd *model.PromotionDetail) (*model.PromotionDetail, *model.AppErr)
+	DeleteDetail(pd *model.PromotionDetail) *model.AppErr
+	DeleteOrderDetails(orderID int64) *model.AppErr
 	IsValid(code string) *model.AppErr
 	IsUsed(code string, userID int64) *model.AppErr
+	GetUsage(code string, userID int64) (*model.PromotionUsage, *model.AppErr)
+	GetTargetedProductIDS(code string, pids []int64) ([]int64, *model.AppErr)
 }
@@ -1,6 +1,7 @@
 package supplier
 
 import (
+	"github.com/dankobgd/ecommerce-shop/model"
 	"github.com/dankobgd/ecommerce-shop/store"
 	"github.com/dankobgd/ecommerce-shop/store/postgres"
 	"github.com/dankobgd/ecommerce-shop/store/redis"
@@ -12,11 +13,50 @@ type Supplier struct {
 	Rdst *redis.RdStore
 }
 
+// TxSupplier contains the stores bound to the single db transaction
+type TxSupplier struct {
+	Supplier
+}
+
+// Begin starts the unit of work
+func (s *Supplier) Begin() (store.Tx, *model.AppErr) {
+	pgst, err := s.Pgst.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &TxSupplier{Supplier{Pgst: pgst, Rdst: s.Rdst}}, nil
+}
+
+// Commit commits the unit of work
+func (s *TxSupplier) Commit() *model.AppErr {
+	return s.Pgst.Commit()
+}
+
+// Rollback aborts the unit of work, it is safe to call after commit
+func (s *TxSupplier) Rollback() *model.AppErr {
+	return s.Pgst.Rollback()
+}
+
 // AccessToken returns the AccessToken store implementation
 func (s *Supplier) AccessToken() store.AccessTokenStore {
 	return redis.NewRedisAccessTokenStore(s.Rdst)
 }
 
+// Idempotency returns the Idempotency store implementation
+func (s *Supplier) Idempotency() store.IdempotencyStore {
+	return redis.NewRedisIdempotencyStore(s.Rdst)
+}
+
+// SearchSuggestion returns the SearchSuggestion store implementation
+func (s *Supplier) SearchSuggestion() store.SearchSuggestionStore {
+	return redis.NewRedisSearchSuggestionStore(s.Rdst)
+}
+
+// StockReservation returns the StockReservation store implementation
+func (s *Supplier) StockReservation() store.StockReservationStore {
+	return redis.NewRedisStockReservationStore(s.Rdst)
+}
+
 // User returns the User store implementation
 func (s *Supplier) User() store.UserStore {
 	return postgres.NewPgUserStore(s.Pgst)
@@ -32,11 +72,41 @@ func (s *Supplier) Product() store.ProductStore {
 	return postgres.NewPgProductStore(s.Pgst)
 }
 
+// SearchIndex returns the SearchIndex store implementation
+func (s *Supplier) SearchIndex() store.SearchIndexStore {
+	return postgres.NewPgSearchIndexStore(s.Pgst)
+}
+
+// Inventory returns the Inventory store implementation
+func (s *Supplier) Inventory() store.InventoryStore {
+	return postgres.NewPgInventoryStore(s.Pgst)
+}
+
 // ProductTag returns the Product store implementation
 func (s *Supplier) ProductTag() store.ProductTagStore {
 	return postgres.NewPgProductTagStore(s.Pgst)
 }
 
+// ProductRelation returns the ProductRelation store implementation
+func (s *Supplier) ProductRelation() store.ProductRelationStore {
+	return postgres.NewPgProductRelationStore(s.Pgst)
+}
+
+// ProductRecommendation returns the ProductRecommendation store implementation
+func (s *Supplier) ProductRecommendation() store.ProductRecommendationStore {
+	return postgres.NewPgProductRecommendationStore(s.Pgst)
+}
+
+// ProductBundle returns the ProductBundle store implementation
+func (s *Supplier) ProductBundle() store.ProductBundleStore {
+	return postgres.NewPgProductBundleStore(s.Pgst)
+}
+
+// ProductVariant returns the Product variant store implementation
+func (s *Supplier) ProductVariant() store.ProductVariantStore {
+	return postgres.NewPgProductVariantStore(s.Pgst)
+}
+
 // ProductImage returns the Product image store implementation
 func (s *Supplier) ProductImage() store.ProductImageStore {
 	return postgres.NewPgProductImageStore(s.Pgst)
@@ -57,6 +127,21 @@ func (s *Supplier) OrderDetail() store.OrderDetailStore {
 	return postgres.NewPgOrderDetailStore(s.Pgst)
 }
 
+// OrderDiscount returns the OrderDiscount store implementation
+func (s *Supplier) OrderDiscount() store.OrderDiscountStore {
+	return postgres.NewPgOrderDiscountStore(s.Pgst)
+}
+
+// Refund returns the Refund store implementation
+func (s *Supplier) Refund() store.RefundStore {
+	return postgres.NewPgRefundStore(s.Pgst)
+}
+
+// PaymentEvent returns the PaymentEvent store implementation
+func (s *Supplier) PaymentEvent() store.PaymentEventStore {
+	return postgres.NewPgPaymentEventStore(s.Pgst)
+}
+
 // Address returns the Address store implementation
 func (s *Supplier) Address() store.AddressStore {
 	return postgres.NewPgAddressStore(s.Pgst)
@@ -81,3 +166,8 @@ func (s *Supplier) Tag() store.TagStore {
 func (s *Supplier) Promotion() store.PromotionStore {
 	return postgres.NewPgPromotionStore(s.Pgst)
 }
+
+// PromotionCode returns the PromotionCode store implementation
+func (s *Supplier) PromotionCode() store.PromotionCodeStore {
+	return postgres.NewPgPromotionCodeStore(s.Pgst)
+}
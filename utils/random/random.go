@@ -13,6 +13,7 @@ var (
 	alpha                = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
 	alphanumeric         = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789")
 	alphanumericExtended = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789$_")
+	unambiguous          = []rune("ABCDEFGHJKLMNPQRSTUVWXYZ23456789")
 )
 
 // IntFromRange returns random int within range from min to max
@@ -67,6 +68,22 @@ func SecureToken(length int) string {
 	return removePadding(base64.URLEncoding.EncodeToString(b))
 }
 
+// SecureCode creates the uppercase code that is easy to read and type, without the look-alike characters
+// every placeholder rune in the pattern is replaced with the random character, the other runes are kept
+func SecureCode(pattern string, placeholder rune) string {
+	b := []rune(pattern)
+	rnd := make([]byte, len(b))
+	if _, err := io.ReadFull(cryptoRand.Reader, rnd); err != nil {
+		panic(err.Error())
+	}
+	for i, r := range b {
+		if r == placeholder {
+			b[i] = unambiguous[int(rnd[i])%len(unambiguous)]
+		}
+	}
+	return string(b)
+}
+
 func removePadding(token string) string {
 	return strings.TrimRight(token, "=")
 }